@@ -6,9 +6,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"reddit-clone/models"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -102,6 +104,251 @@ func (c *Client) LeaveSubreddit(ctx context.Context, name string) error {
 	return c.post(fmt.Sprintf("/api/subreddits/%s/leave", name), nil, nil)
 }
 
+func (c *Client) GetSubreddit(ctx context.Context, name string) (*models.Subreddit, error) {
+	var subreddit models.Subreddit
+	err := c.get(fmt.Sprintf("/api/subreddits/%s", url.PathEscape(name)), &subreddit)
+	return &subreddit, err
+}
+
+// UpdateSubreddit changes a subreddit's description. It needs the config
+// moderator permission.
+func (c *Client) UpdateSubreddit(ctx context.Context, name, description string) (*models.Subreddit, error) {
+	payload := map[string]string{
+		"description": description,
+	}
+	var subreddit models.Subreddit
+	err := c.do(http.MethodPatch, fmt.Sprintf("/api/subreddits/%s", url.PathEscape(name)), payload, &subreddit)
+	return &subreddit, err
+}
+
+// SetCrosspostsAllowed decides whether a subreddit the user moderates takes
+// crossposts of posts made in other subreddits. It needs the config
+// permission.
+func (c *Client) SetCrosspostsAllowed(ctx context.Context, name string, allowed bool) (*models.Subreddit, error) {
+	payload := map[string]bool{
+		"crossposts_disabled": !allowed,
+	}
+	var subreddit models.Subreddit
+	err := c.do(http.MethodPatch, fmt.Sprintf("/api/subreddits/%s", url.PathEscape(name)), payload, &subreddit)
+	return &subreddit, err
+}
+
+// Moderator methods
+
+// GetModerators fetches a subreddit's mod team, most senior first. Pending
+// invites are included when the user is one of its moderators.
+func (c *Client) GetModerators(ctx context.Context, subreddit string) (*models.ModeratorListResponse, error) {
+	var mods models.ModeratorListResponse
+	err := c.get(moderatorsPath(subreddit, ""), &mods)
+	return &mods, err
+}
+
+// InviteModerator invites a user to a subreddit's mod team with permissions
+// among posts, users, config, wiki and mail, or every permission if none are
+// given. It needs full permissions.
+func (c *Client) InviteModerator(ctx context.Context, subreddit, username string, permissions []string) error {
+	payload := map[string]interface{}{
+		"username":    username,
+		"permissions": permissions,
+	}
+	return c.post(moderatorsPath(subreddit, "invite"), payload, nil)
+}
+
+func (c *Client) RevokeModeratorInvite(ctx context.Context, subreddit, username string) error {
+	return c.do(http.MethodDelete, moderatorsPath(subreddit, "invite/"+url.PathEscape(username)), nil, nil)
+}
+
+func (c *Client) AcceptModeratorInvite(ctx context.Context, subreddit string) (*models.ModeratorListResponse, error) {
+	var mods models.ModeratorListResponse
+	err := c.post(moderatorsPath(subreddit, "accept"), nil, &mods)
+	return &mods, err
+}
+
+func (c *Client) ResignModerator(ctx context.Context, subreddit string) (*models.ModeratorListResponse, error) {
+	var mods models.ModeratorListResponse
+	err := c.post(moderatorsPath(subreddit, "resign"), nil, &mods)
+	return &mods, err
+}
+
+// TransferOwnership makes another moderator the owner of a subreddit. Only
+// its current owner may transfer it.
+func (c *Client) TransferOwnership(ctx context.Context, subreddit, username string) (*models.ModeratorListResponse, error) {
+	payload := map[string]string{
+		"username": username,
+	}
+	var mods models.ModeratorListResponse
+	err := c.post(moderatorsPath(subreddit, "transfer"), payload, &mods)
+	return &mods, err
+}
+
+// SetModeratorPermissions replaces the permissions of a more junior moderator
+func (c *Client) SetModeratorPermissions(ctx context.Context, subreddit, username string, permissions []string) (*models.ModeratorListResponse, error) {
+	payload := map[string]interface{}{
+		"permissions": permissions,
+	}
+	var mods models.ModeratorListResponse
+	err := c.do(http.MethodPut, moderatorsPath(subreddit, url.PathEscape(username)), payload, &mods)
+	return &mods, err
+}
+
+// RemoveModerator takes a more junior moderator off the mod team
+func (c *Client) RemoveModerator(ctx context.Context, subreddit, username string) (*models.ModeratorListResponse, error) {
+	var mods models.ModeratorListResponse
+	err := c.do(http.MethodDelete, moderatorsPath(subreddit, url.PathEscape(username)), nil, &mods)
+	return &mods, err
+}
+
+// Moderation methods
+
+// ReportPost flags a post for the moderators of its subreddit
+func (c *Client) ReportPost(ctx context.Context, postID uuid.UUID, reason string) error {
+	payload := map[string]string{
+		"reason": reason,
+	}
+	return c.post(fmt.Sprintf("/api/posts/%s/report", postID), payload, nil)
+}
+
+func (c *Client) ReportComment(ctx context.Context, commentID uuid.UUID, reason string) error {
+	payload := map[string]string{
+		"reason": reason,
+	}
+	return c.post(fmt.Sprintf("/api/comments/%s/report", commentID), payload, nil)
+}
+
+// ModeratePost applies a moderator action to a post: remove, spam, approve,
+// lock or unlock. It needs the posts permission.
+func (c *Client) ModeratePost(ctx context.Context, postID uuid.UUID, action string) (*models.Post, error) {
+	payload := map[string]string{
+		"action": action,
+	}
+	var post models.Post
+	err := c.post(fmt.Sprintf("/api/posts/%s/moderate", postID), payload, &post)
+	return &post, err
+}
+
+// ModerateComment applies a moderator action to a comment: remove, spam or
+// approve. It needs the posts permission.
+func (c *Client) ModerateComment(ctx context.Context, commentID uuid.UUID, action string) (*models.Comment, error) {
+	payload := map[string]string{
+		"action": action,
+	}
+	var comment models.Comment
+	err := c.post(fmt.Sprintf("/api/comments/%s/moderate", commentID), payload, &comment)
+	return &comment, err
+}
+
+// GetModQueue fetches a page of the reported and filtered content of a
+// subreddit awaiting a moderator's decision
+func (c *Client) GetModQueue(ctx context.Context, subreddit, cursor string, limit int) (*models.ModQueueResponse, error) {
+	var queue models.ModQueueResponse
+	query := pageQuery(cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/subreddits/%s/modqueue?%s", url.PathEscape(subreddit), query), &queue)
+	return &queue, err
+}
+
+// Ban and mute methods
+
+// BanUser bans a user from a subreddit for duration, or permanently if
+// duration is 0. It needs the users permission.
+func (c *Client) BanUser(ctx context.Context, subreddit, username string, duration time.Duration, reason, note string) (*models.Ban, error) {
+	var ban models.Ban
+	err := c.post(fmt.Sprintf("/api/subreddits/%s/bans", url.PathEscape(subreddit)), restriction(username, duration, reason, note), &ban)
+	return &ban, err
+}
+
+func (c *Client) UnbanUser(ctx context.Context, subreddit, username string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/subreddits/%s/bans/%s", url.PathEscape(subreddit), url.PathEscape(username)), nil, nil)
+}
+
+func (c *Client) GetBans(ctx context.Context, subreddit string) (*models.BanListResponse, error) {
+	var bans models.BanListResponse
+	err := c.get(fmt.Sprintf("/api/subreddits/%s/bans", url.PathEscape(subreddit)), &bans)
+	return &bans, err
+}
+
+// MuteUser keeps a user from messaging a subreddit's moderators for
+// duration, or permanently if duration is 0
+func (c *Client) MuteUser(ctx context.Context, subreddit, username string, duration time.Duration, reason string) (*models.Ban, error) {
+	var mute models.Ban
+	err := c.post(fmt.Sprintf("/api/subreddits/%s/mutes", url.PathEscape(subreddit)), restriction(username, duration, reason, ""), &mute)
+	return &mute, err
+}
+
+func (c *Client) UnmuteUser(ctx context.Context, subreddit, username string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/subreddits/%s/mutes/%s", url.PathEscape(subreddit), url.PathEscape(username)), nil, nil)
+}
+
+func (c *Client) GetMutes(ctx context.Context, subreddit string) (*models.MuteListResponse, error) {
+	var mutes models.MuteListResponse
+	err := c.get(fmt.Sprintf("/api/subreddits/%s/mutes", url.PathEscape(subreddit)), &mutes)
+	return &mutes, err
+}
+
+// MessageModerators sends a message to the moderators of a subreddit
+func (c *Client) MessageModerators(ctx context.Context, subreddit, content string) (*models.MessageListResponse, error) {
+	payload := map[string]string{
+		"content": content,
+	}
+	var messages models.MessageListResponse
+	err := c.post(fmt.Sprintf("/api/subreddits/%s/modmail", url.PathEscape(subreddit)), payload, &messages)
+	return &messages, err
+}
+
+// restriction builds the body of a ban or mute
+func restriction(username string, duration time.Duration, reason, note string) map[string]string {
+	payload := map[string]string{
+		"username": username,
+		"reason":   reason,
+		"note":     note,
+	}
+	if duration > 0 {
+		payload["duration"] = duration.String()
+	}
+	return payload
+}
+
+// AutoModerator methods
+
+func (c *Client) GetAutoModRules(ctx context.Context, subreddit string) (*models.AutoModRulesResponse, error) {
+	var rules models.AutoModRulesResponse
+	err := c.get(automodPath(subreddit, ""), &rules)
+	return &rules, err
+}
+
+// SetAutoModRules replaces the AutoModerator rules of a subreddit. It needs
+// the config permission.
+func (c *Client) SetAutoModRules(ctx context.Context, subreddit string, rules []models.AutoModRule) (*models.AutoModRulesResponse, error) {
+	var saved models.AutoModRulesResponse
+	err := c.do(http.MethodPut, automodPath(subreddit, ""), rules, &saved)
+	return &saved, err
+}
+
+func (c *Client) ValidateAutoModRules(ctx context.Context, subreddit string, rules []models.AutoModRule) (*models.AutoModValidationResponse, error) {
+	var validation models.AutoModValidationResponse
+	err := c.post(automodPath(subreddit, "validate"), rules, &validation)
+	return &validation, err
+}
+
+// DryRunAutoMod reports what rules would match among the existing content of
+// a subreddit. Nil rules try the subreddit's own rules.
+func (c *Client) DryRunAutoMod(ctx context.Context, subreddit string, rules []models.AutoModRule) (*models.AutoModDryRunResponse, error) {
+	var payload interface{}
+	if rules != nil {
+		payload = rules
+	}
+	var dryRun models.AutoModDryRunResponse
+	err := c.post(automodPath(subreddit, "dryrun"), payload, &dryRun)
+	return &dryRun, err
+}
+
+func automodPath(subreddit, action string) string {
+	path := fmt.Sprintf("/api/subreddits/%s/automod", url.PathEscape(subreddit))
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
 // Post methods
 func (c *Client) CreatePost(ctx context.Context, title, content, subreddit string) (*models.Post, error) {
 	payload := map[string]string{
@@ -120,6 +367,51 @@ func (c *Client) GetPost(ctx context.Context, postID uuid.UUID) (*models.Post, e
 	return &post, err
 }
 
+// Crosspost shares a post into another subreddit. Crossposting a crosspost
+// shares the original it points to.
+func (c *Client) Crosspost(ctx context.Context, postID uuid.UUID, subreddit string) (*models.Post, error) {
+	payload := map[string]string{
+		"subreddit": subreddit,
+	}
+	var post models.Post
+	err := c.post(fmt.Sprintf("/api/posts/%s/crosspost", postID), payload, &post)
+	return &post, err
+}
+
+// GetCrossposts returns a page of the crossposts of a post's original, newest
+// first
+func (c *Client) GetCrossposts(ctx context.Context, postID uuid.UUID, cursor string, limit int) (*models.FeedResponse, error) {
+	var crossposts models.FeedResponse
+	err := c.get(fmt.Sprintf("/api/posts/%s/crossposts?%s", postID, pageQuery(cursor, limit).Encode()), &crossposts)
+	return &crossposts, err
+}
+
+// EditPost replaces the title and content of one of the user's posts. Pass
+// nil to leave either unchanged.
+func (c *Client) EditPost(ctx context.Context, postID uuid.UUID, title, content *string) (*models.Post, error) {
+	payload := map[string]*string{
+		"title":   title,
+		"content": content,
+	}
+	var post models.Post
+	err := c.do(http.MethodPatch, fmt.Sprintf("/api/posts/%s", postID), payload, &post)
+	return &post, err
+}
+
+// DeletePost replaces one of the user's posts with a tombstone
+func (c *Client) DeletePost(ctx context.Context, postID uuid.UUID) (*models.Post, error) {
+	var post models.Post
+	err := c.do(http.MethodDelete, fmt.Sprintf("/api/posts/%s", postID), nil, &post)
+	return &post, err
+}
+
+// GetPostRevisions fetches the earlier versions of a post, oldest first
+func (c *Client) GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := c.get(fmt.Sprintf("/api/posts/%s/revisions", postID), &revisions)
+	return revisions, err
+}
+
 // Comment methods
 func (c *Client) CreateComment(ctx context.Context, postID uuid.UUID, content string, parentID *uuid.UUID) (*models.Comment, error) {
 	payload := map[string]interface{}{
@@ -132,31 +424,181 @@ func (c *Client) CreateComment(ctx context.Context, postID uuid.UUID, content st
 	return &comment, err
 }
 
+func (c *Client) EditComment(ctx context.Context, commentID uuid.UUID, content string) (*models.Comment, error) {
+	payload := map[string]string{
+		"content": content,
+	}
+	var comment models.Comment
+	err := c.do(http.MethodPatch, fmt.Sprintf("/api/comments/%s", commentID), payload, &comment)
+	return &comment, err
+}
+
+// DeleteComment replaces one of the user's comments with a tombstone. Replies
+// under it are kept.
+func (c *Client) DeleteComment(ctx context.Context, commentID uuid.UUID) (*models.Comment, error) {
+	var comment models.Comment
+	err := c.do(http.MethodDelete, fmt.Sprintf("/api/comments/%s", commentID), nil, &comment)
+	return &comment, err
+}
+
+func (c *Client) GetCommentRevisions(ctx context.Context, commentID uuid.UUID) ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := c.get(fmt.Sprintf("/api/comments/%s/revisions", commentID), &revisions)
+	return revisions, err
+}
+
+// GetComments fetches a post's comment thread sorted by best, top, new or
+// controversial. depth limits the levels and limit the replies under each
+// comment, 0 for no limit. Pass the token of a MoreComments as more to load
+// the comments it stands for.
+func (c *Client) GetComments(ctx context.Context, postID uuid.UUID, sort string, depth, limit int, more string) (*models.CommentTreeResponse, error) {
+	params := url.Values{}
+	if sort != "" {
+		params.Set("sort", sort)
+	}
+	if depth > 0 {
+		params.Set("depth", fmt.Sprint(depth))
+	}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprint(limit))
+	}
+	if more != "" {
+		params.Set("more", more)
+	}
+	var tree models.CommentTreeResponse
+	err := c.get(fmt.Sprintf("/api/posts/%s/comments?%s", postID, params.Encode()), &tree)
+	return &tree, err
+}
+
 // Vote methods
+
+// Vote casts the user's vote on a post or comment, replacing any earlier one
 func (c *Client) Vote(ctx context.Context, targetID uuid.UUID, isUpvote bool, targetType string) error {
 	payload := map[string]interface{}{
 		"is_upvote": isUpvote,
 	}
 
-	var endpoint string
-	if targetType == "post" {
-		endpoint = fmt.Sprintf("/api/posts/%s/vote", targetID)
-	} else if targetType == "comment" {
-		endpoint = fmt.Sprintf("/api/comments/%s/vote", targetID)
-	} else {
-		return fmt.Errorf("invalid target type: %s", targetType)
+	endpoint, err := voteEndpoint(targetID, targetType)
+	if err != nil {
+		return err
 	}
-
 	return c.post(endpoint, payload, nil)
 }
 
+// RetractVote removes the user's vote on a post or comment
+func (c *Client) RetractVote(ctx context.Context, targetID uuid.UUID, targetType string) error {
+	endpoint, err := voteEndpoint(targetID, targetType)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodDelete, endpoint, nil, nil)
+}
+
+// RecomputeKarma rebuilds every user's karma from the recorded votes and
+// returns how many users had theirs corrected
+func (c *Client) RecomputeKarma(ctx context.Context) (int, error) {
+	var response struct {
+		Corrected int `json:"corrected"`
+	}
+	err := c.post("/api/karma/recompute", nil, &response)
+	return response.Corrected, err
+}
+
+// voteEndpoint returns the path votes on a post or comment are sent to
+func voteEndpoint(targetID uuid.UUID, targetType string) (string, error) {
+	switch targetType {
+	case "post":
+		return fmt.Sprintf("/api/posts/%s/vote", targetID), nil
+	case "comment":
+		return fmt.Sprintf("/api/comments/%s/vote", targetID), nil
+	}
+	return "", fmt.Errorf("invalid target type: %s", targetType)
+}
+
 // Feed methods
-func (c *Client) GetFeed(ctx context.Context, offset, limit int) (*models.FeedResponse, error) {
+
+// GetFeed fetches a page of the feed. Pass an empty cursor for the first page
+// and the NextCursor of the previous response for the following ones.
+func (c *Client) GetFeed(ctx context.Context, cursor string, limit int) (*models.FeedResponse, error) {
+	var feed models.FeedResponse
+	err := c.get("/api/feed?"+pageQuery(cursor, limit).Encode(), &feed)
+	return &feed, err
+}
+
+// GetSortedFeed fetches the feed ordered by sort (hot, top, new, controversial
+// or rising). window limits top and controversial to the past hour, day, week
+// or all time.
+func (c *Client) GetSortedFeed(ctx context.Context, sort, window, cursor string, limit int) (*models.FeedResponse, error) {
+	var feed models.FeedResponse
+	err := c.get("/api/feed?"+sortedPageQuery(sort, window, cursor, limit).Encode(), &feed)
+	return &feed, err
+}
+
+// GetSubredditPosts fetches a page of a subreddit's posts, sorted like GetSortedFeed
+func (c *Client) GetSubredditPosts(ctx context.Context, subreddit, sort, window, cursor string, limit int) (*models.FeedResponse, error) {
+	var feed models.FeedResponse
+	query := sortedPageQuery(sort, window, cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/subreddits/%s/posts?%s", url.PathEscape(subreddit), query), &feed)
+	return &feed, err
+}
+
+// User history methods
+func (c *Client) GetUserPosts(ctx context.Context, username, cursor string, limit int) (*models.FeedResponse, error) {
 	var feed models.FeedResponse
-	err := c.get(fmt.Sprintf("/api/feed?offset=%d&limit=%d", offset, limit), &feed)
+	query := pageQuery(cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/users/%s/posts?%s", url.PathEscape(username), query), &feed)
 	return &feed, err
 }
 
+func (c *Client) GetUserComments(ctx context.Context, username, cursor string, limit int) (*models.CommentListResponse, error) {
+	var comments models.CommentListResponse
+	query := pageQuery(cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/users/%s/comments?%s", url.PathEscape(username), query), &comments)
+	return &comments, err
+}
+
+// GetProfile returns a user's karma, post and comment counts, and follower
+// and following counts
+func (c *Client) GetProfile(ctx context.Context, username string) (*models.Profile, error) {
+	var profile models.Profile
+	err := c.get("/api/users/"+url.PathEscape(username), &profile)
+	return &profile, err
+}
+
+// GetPresence returns whether a user is connected and when they were last seen
+func (c *Client) GetPresence(ctx context.Context, username string) (*models.Presence, error) {
+	var presence models.Presence
+	err := c.get(fmt.Sprintf("/api/users/%s/presence", url.PathEscape(username)), &presence)
+	return &presence, err
+}
+
+// FollowUser adds a user's posts to the home feed
+func (c *Client) FollowUser(ctx context.Context, username string) (*models.Follow, error) {
+	var follow models.Follow
+	err := c.post(fmt.Sprintf("/api/users/%s/follow", url.PathEscape(username)), nil, &follow)
+	return &follow, err
+}
+
+func (c *Client) UnfollowUser(ctx context.Context, username string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/api/users/%s/follow", url.PathEscape(username)), nil, nil)
+}
+
+// GetFollowers returns a page of the users following a user, newest first
+func (c *Client) GetFollowers(ctx context.Context, username, cursor string, limit int) (*models.FollowListResponse, error) {
+	var follows models.FollowListResponse
+	query := pageQuery(cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/users/%s/followers?%s", url.PathEscape(username), query), &follows)
+	return &follows, err
+}
+
+// GetFollowing returns a page of the users a user follows, newest first
+func (c *Client) GetFollowing(ctx context.Context, username, cursor string, limit int) (*models.FollowListResponse, error) {
+	var follows models.FollowListResponse
+	query := pageQuery(cursor, limit).Encode()
+	err := c.get(fmt.Sprintf("/api/users/%s/following?%s", url.PathEscape(username), query), &follows)
+	return &follows, err
+}
+
 // Message methods
 func (c *Client) SendMessage(ctx context.Context, toUser, content string) error {
 	payload := map[string]string{
@@ -166,17 +608,162 @@ func (c *Client) SendMessage(ctx context.Context, toUser, content string) error
 	return c.post("/api/messages", payload, nil)
 }
 
-func (c *Client) GetMessages(ctx context.Context) ([]*models.DirectMessage, error) {
-	var messages []*models.DirectMessage
-	err := c.get("/api/messages", &messages)
-	return messages, err
+func (c *Client) GetMessages(ctx context.Context, cursor string, limit int) (*models.MessageListResponse, error) {
+	var messages models.MessageListResponse
+	err := c.get("/api/messages?"+pageQuery(cursor, limit).Encode(), &messages)
+	return &messages, err
+}
+
+// ReplyToMessage replies to a message the user sent or received. The reply
+// goes to the other user of the message.
+func (c *Client) ReplyToMessage(ctx context.Context, messageID uuid.UUID, content string) (*models.DirectMessage, error) {
+	payload := map[string]interface{}{
+		"reply_to_id": messageID,
+		"content":     content,
+	}
+	var message models.DirectMessage
+	err := c.post("/api/messages", payload, &message)
+	return &message, err
+}
+
+// GetConversations returns a summary of each of the user's conversations
+func (c *Client) GetConversations(ctx context.Context) (*models.ConversationListResponse, error) {
+	var conversations models.ConversationListResponse
+	err := c.get("/api/messages/conversations", &conversations)
+	return &conversations, err
+}
+
+// GetConversation returns a page of the messages between the user and
+// another user, newest first
+func (c *Client) GetConversation(ctx context.Context, with, cursor string, limit int) (*models.ConversationResponse, error) {
+	var conversation models.ConversationResponse
+	err := c.get(fmt.Sprintf("/api/messages/conversations/%s?%s", url.PathEscape(with), pageQuery(cursor, limit).Encode()), &conversation)
+	return &conversation, err
+}
+
+// MarkConversationRead marks every message from another user read and
+// returns how many were unread
+func (c *Client) MarkConversationRead(ctx context.Context, with string) (int, error) {
+	var response struct {
+		Marked int `json:"marked"`
+	}
+	err := c.post(fmt.Sprintf("/api/messages/conversations/%s/read", url.PathEscape(with)), nil, &response)
+	return response.Marked, err
+}
+
+// MarkMessageRead marks a message to the user read
+func (c *Client) MarkMessageRead(ctx context.Context, messageID uuid.UUID) (*models.DirectMessage, error) {
+	var message models.DirectMessage
+	err := c.post(fmt.Sprintf("/api/messages/%s/read", messageID), nil, &message)
+	return &message, err
+}
+
+// GetMessageThread returns the reply chain ending with a message, oldest first
+func (c *Client) GetMessageThread(ctx context.Context, messageID uuid.UUID) ([]models.DirectMessage, error) {
+	var thread models.MessageListResponse
+	err := c.get(fmt.Sprintf("/api/messages/%s/thread", messageID), &thread)
+	return thread.Messages, err
+}
+
+// SavePost adds a post to the user's saved list, or removes it with save false
+func (c *Client) SavePost(ctx context.Context, postID uuid.UUID, save bool) error {
+	return c.do(saveMethod(save), fmt.Sprintf("/api/posts/%s/save", postID), nil, nil)
+}
+
+// SaveComment adds a comment to the user's saved list, or removes it with
+// save false
+func (c *Client) SaveComment(ctx context.Context, commentID uuid.UUID, save bool) error {
+	return c.do(saveMethod(save), fmt.Sprintf("/api/comments/%s/save", commentID), nil, nil)
+}
+
+// GetSaved returns a page of the user's saved posts and comments, most
+// recently saved first
+func (c *Client) GetSaved(ctx context.Context, cursor string, limit int) (*models.SavedListResponse, error) {
+	var saved models.SavedListResponse
+	err := c.get("/api/saved?"+pageQuery(cursor, limit).Encode(), &saved)
+	return &saved, err
+}
+
+// HidePost keeps a post out of the user's feed and subreddit listings, or
+// lets it back with hide false
+func (c *Client) HidePost(ctx context.Context, postID uuid.UUID, hide bool) error {
+	return c.do(saveMethod(hide), fmt.Sprintf("/api/posts/%s/hide", postID), nil, nil)
+}
+
+// GetHidden returns a page of the posts the user has hidden, most recently
+// hidden first
+func (c *Client) GetHidden(ctx context.Context, cursor string, limit int) (*models.FeedResponse, error) {
+	var hidden models.FeedResponse
+	err := c.get("/api/hidden?"+pageQuery(cursor, limit).Encode(), &hidden)
+	return &hidden, err
+}
+
+// GetNotifications returns a page of the user's notifications, newest first,
+// with their unread count. With unreadOnly set, read ones are left out.
+func (c *Client) GetNotifications(ctx context.Context, unreadOnly bool, cursor string, limit int) (*models.NotificationListResponse, error) {
+	var notifications models.NotificationListResponse
+	params := pageQuery(cursor, limit)
+	if unreadOnly {
+		params.Set("unread", "true")
+	}
+	err := c.get("/api/notifications?"+params.Encode(), &notifications)
+	return &notifications, err
+}
+
+// MarkNotificationRead marks one of the user's notifications read, or unread
+// again if read is false
+func (c *Client) MarkNotificationRead(ctx context.Context, notificationID uuid.UUID, read bool) (*models.Notification, error) {
+	action := "unread"
+	if read {
+		action = "read"
+	}
+	var notification models.Notification
+	err := c.post(fmt.Sprintf("/api/notifications/%s/%s", notificationID, action), nil, &notification)
+	return &notification, err
+}
+
+// MarkAllNotificationsRead marks every notification of the user read and
+// returns how many were unread
+func (c *Client) MarkAllNotificationsRead(ctx context.Context) (int, error) {
+	var response struct {
+		Marked int `json:"marked"`
+	}
+	err := c.post("/api/notifications/read", nil, &response)
+	return response.Marked, err
+}
+
+// BlockUser blocks another user: they can no longer message the user, and
+// their content stops notifying them and is hidden from their listings
+func (c *Client) BlockUser(ctx context.Context, username string) (*models.BlockedUser, error) {
+	var blocked models.BlockedUser
+	err := c.post("/api/blocks", map[string]string{"username": username}, &blocked)
+	return &blocked, err
+}
+
+// UnblockUser lifts a block
+func (c *Client) UnblockUser(ctx context.Context, username string) error {
+	return c.do(http.MethodDelete, "/api/blocks/"+url.PathEscape(username), nil, nil)
+}
+
+// GetBlockedUsers returns the users the user has blocked, sorted by name
+func (c *Client) GetBlockedUsers(ctx context.Context) ([]models.BlockedUser, error) {
+	var blocks models.BlockListResponse
+	err := c.get("/api/blocks", &blocks)
+	return blocks.Blocked, err
 }
 
-// Search method
-func (c *Client) Search(ctx context.Context, query string) ([]*models.Post, error) {
-	var posts []*models.Post
-	err := c.get(fmt.Sprintf("/api/search?q=%s", url.QueryEscape(query)), &posts)
-	return posts, err
+// Search returns a page of the posts and comments matching a query, which
+// may use the subreddit:, author:, type:, after: and before: filters. Sort is
+// relevance, new or top; empty means relevance.
+func (c *Client) Search(ctx context.Context, query, sort, cursor string, limit int) (*models.SearchResponse, error) {
+	var results models.SearchResponse
+	params := pageQuery(cursor, limit)
+	params.Set("q", query)
+	if sort != "" {
+		params.Set("sort", sort)
+	}
+	err := c.get("/api/search?"+params.Encode(), &results)
+	return &results, err
 }
 
 // WebSocket methods
@@ -191,48 +778,80 @@ func (c *Client) ConnectWebSocket(username string) error {
 }
 
 // Helper methods
-func (c *Client) post(endpoint string, payload interface{}, response interface{}) error {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
 
-	req, err := http.NewRequest("POST", c.baseURL+endpoint, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+// pageQuery builds the query parameters of a paginated listing. A limit of 0
+// leaves the page size to the server.
+func pageQuery(cursor string, limit int) url.Values {
+	params := url.Values{}
+	if cursor != "" {
+		params.Set("cursor", cursor)
 	}
-
-	if c.authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.authToken)
-		req.Header.Set("X-User", c.username) // Add this line
+	if limit > 0 {
+		params.Set("limit", fmt.Sprint(limit))
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return params
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
+// saveMethod returns the method that sets (POST) or clears (DELETE) a
+// saved or hidden mark
+func saveMethod(set bool) string {
+	if set {
+		return http.MethodPost
 	}
-	defer resp.Body.Close()
+	return http.MethodDelete
+}
 
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("server returned error: %d", resp.StatusCode)
+// moderatorsPath returns the path of a subreddit's moderator endpoint
+func moderatorsPath(subreddit, action string) string {
+	path := fmt.Sprintf("/api/subreddits/%s/moderators", url.PathEscape(subreddit))
+	if action != "" {
+		path += "/" + action
 	}
+	return path
+}
 
-	if response != nil {
-		return json.NewDecoder(resp.Body).Decode(response)
+func sortedPageQuery(sort, window, cursor string, limit int) url.Values {
+	params := pageQuery(cursor, limit)
+	if sort != "" {
+		params.Set("sort", sort)
 	}
-	return nil
+	if window != "" {
+		params.Set("t", window)
+	}
+	return params
+}
+
+func (c *Client) post(endpoint string, payload interface{}, response interface{}) error {
+	return c.do(http.MethodPost, endpoint, payload, response)
 }
 
 func (c *Client) get(endpoint string, response interface{}) error {
-	req, err := http.NewRequest("GET", c.baseURL+endpoint, nil)
+	return c.do(http.MethodGet, endpoint, nil, response)
+}
+
+// do sends an authenticated request with payload as its JSON body, if not
+// nil, and decodes the JSON response into response, if not nil
+func (c *Client) do(method, endpoint string, payload interface{}, response interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+endpoint, body)
 	if err != nil {
 		return err
 	}
 
 	if c.authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.authToken)
-		req.Header.Set("X-User", c.username) // Add this line
+		req.Header.Set("X-User", c.username)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
 	resp, err := c.httpClient.Do(req)
@@ -245,5 +864,8 @@ func (c *Client) get(endpoint string, response interface{}) error {
 		return fmt.Errorf("server returned error: %d", resp.StatusCode)
 	}
 
-	return json.NewDecoder(resp.Body).Decode(response)
+	if response != nil {
+		return json.NewDecoder(resp.Body).Decode(response)
+	}
+	return nil
 }
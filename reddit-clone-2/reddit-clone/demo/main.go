@@ -184,23 +184,30 @@ func (d *Demo) handleSearch() {
 	query := d.readLine()
 
 	ctx := context.Background()
-	posts, err := d.client.Search(ctx, query)
+	results, err := d.client.Search(ctx, query, "", "", 10)
 	if err != nil {
 		fmt.Printf("Search failed: %v\n", err)
 		return
 	}
 
-	fmt.Printf("\nFound %d posts:\n", len(posts))
-	for i, post := range posts {
-		fmt.Printf("\n%d. %s\n", i+1, post.Title)
-		fmt.Printf("   Posted by u/%s in r/%s\n", post.AuthorName, post.SubredditName)
-		fmt.Printf("   Score: %d\n", post.Score)
+	fmt.Printf("\nFound %d results:\n", len(results.Results))
+	for i, result := range results.Results {
+		if post := result.Post; post != nil {
+			fmt.Printf("\n%d. %s\n", i+1, post.Title)
+			fmt.Printf("   Posted by u/%s in r/%s\n", post.AuthorName, post.SubredditName)
+			fmt.Printf("   Score: %d\n", post.Score)
+			continue
+		}
+		comment := result.Comment
+		fmt.Printf("\n%d. %s\n", i+1, comment.Content)
+		fmt.Printf("   Comment by u/%s\n", comment.AuthorName)
+		fmt.Printf("   Score: %d\n", comment.Score)
 	}
 }
 
 func (d *Demo) handleViewFeed() {
 	ctx := context.Background()
-	feed, err := d.client.GetFeed(ctx, 0, 10)
+	feed, err := d.client.GetFeed(ctx, "", 10)
 	if err != nil {
 		fmt.Printf("Failed to get feed: %v\n", err)
 		return
@@ -274,11 +281,12 @@ func (d *Demo) handleSendMessage() {
 
 func (d *Demo) handleViewMessages() {
 	ctx := context.Background()
-	messages, err := d.client.GetMessages(ctx)
+	inbox, err := d.client.GetMessages(ctx, "", 0)
 	if err != nil {
 		fmt.Printf("Failed to get messages: %v\n", err)
 		return
 	}
+	messages := inbox.Messages
 
 	fmt.Println("\n=== Your Messages ===")
 	if len(messages) == 0 {
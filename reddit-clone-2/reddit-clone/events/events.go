@@ -0,0 +1,241 @@
+// events/events.go
+package events
+
+import (
+	"reddit-clone/models"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type names a kind of event
+type Type string
+
+const (
+	TypePostCreated    Type = "post_created"
+	TypeCommentCreated Type = "comment_created"
+	TypeVoteCast       Type = "vote_cast"
+	TypeUserJoined     Type = "user_joined"
+	TypeMessageSent    Type = "message_sent"
+	TypeUserOnline     Type = "user_online"
+	TypeUserOffline    Type = "user_offline"
+	TypeDropped        Type = "dropped"
+)
+
+// Event is something that happened on the server. Events hold copies, so
+// subscribers can keep them without racing the server.
+type Event interface {
+	Type() Type
+}
+
+// PostCreated is published for every new post, once AutoModerator has acted
+// on it
+type PostCreated struct {
+	Post models.Post
+}
+
+// CommentCreated is published for every new comment, once AutoModerator has
+// acted on it. AutoModerator's own comments are included, and are published
+// ahead of the post or comment they answer.
+type CommentCreated struct {
+	Comment   models.Comment
+	Subreddit string
+}
+
+// VoteCast is published for every vote on a post or comment
+type VoteCast struct {
+	Username  string
+	PostID    *uuid.UUID // Set for votes on posts
+	CommentID *uuid.UUID // Set for votes on comments
+	Upvote    bool
+	Time      time.Time
+}
+
+// UserJoined is published when a user joins a subreddit
+type UserJoined struct {
+	Username  string
+	Subreddit string
+	Time      time.Time
+}
+
+// MessageSent is published for every direct message, including modmail
+type MessageSent struct {
+	Message models.DirectMessage
+}
+
+// UserOnline is published when a user opens their first websocket connection
+type UserOnline struct {
+	Username string
+	Time     time.Time
+}
+
+// UserOffline is published when a user closes their last websocket connection
+type UserOffline struct {
+	Username string
+	Time     time.Time
+}
+
+// Dropped tells an asynchronous subscriber that Count events were dropped
+// because its queue was full. It is delivered in place of the missing
+// events, before the next one the subscriber receives.
+type Dropped struct {
+	Count uint64
+}
+
+func (PostCreated) Type() Type    { return TypePostCreated }
+func (CommentCreated) Type() Type { return TypeCommentCreated }
+func (VoteCast) Type() Type       { return TypeVoteCast }
+func (UserJoined) Type() Type     { return TypeUserJoined }
+func (MessageSent) Type() Type    { return TypeMessageSent }
+func (UserOnline) Type() Type     { return TypeUserOnline }
+func (UserOffline) Type() Type    { return TypeUserOffline }
+func (Dropped) Type() Type        { return TypeDropped }
+
+// Bus delivers published events to subscribers. Each subscriber sees the
+// events of one publisher in the order they were published.
+//
+// Synchronous subscribers run in the publisher's goroutine, which may hold
+// the server's lock: they must be quick and must not call back into the
+// server or the bus. Asynchronous subscribers run in a goroutine of their
+// own, fed by a bounded queue. Publishing never blocks on them: when a queue
+// is full the event is dropped, counted, and reported to the subscriber with
+// a Dropped event.
+type Bus struct {
+	mu          sync.RWMutex // Held for reading while publishing
+	subscribers map[*Subscription]bool
+}
+
+// Subscription is a subscriber's registration on a Bus
+type Subscription struct {
+	bus     *Bus
+	types   map[Type]bool // Nil for every type
+	handler func(Event)
+	queue   chan Event    // Nil for synchronous subscribers
+	done    chan struct{} // Closed once the queue has been drained
+	missed  atomic.Uint64 // Dropped events not yet reported
+	dropped atomic.Uint64 // Dropped events in total
+	once    sync.Once
+}
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]bool)}
+}
+
+// Subscribe calls handler in the publisher's goroutine for every event of
+// the given types, or of every type if none are given
+func (b *Bus) Subscribe(handler func(Event), types ...Type) *Subscription {
+	return b.subscribe(&Subscription{handler: handler}, types)
+}
+
+// SubscribeAsync calls handler in a goroutine of its own for every event of
+// the given types, or of every type if none are given. Up to buffer events
+// wait for the handler; any more are dropped and reported.
+func (b *Bus) SubscribeAsync(buffer int, handler func(Event), types ...Type) *Subscription {
+	s := &Subscription{handler: handler, queue: make(chan Event, max(buffer, 1)), done: make(chan struct{})}
+	go s.run()
+	return b.subscribe(s, types)
+}
+
+func (b *Bus) subscribe(s *Subscription, types []Type) *Subscription {
+	s.bus = b
+	if len(types) > 0 {
+		s.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	b.subscribers[s] = true
+	b.mu.Unlock()
+	return s
+}
+
+// Publish delivers an event to every subscriber interested in its type
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subscribers {
+		if s.types != nil && !s.types[event.Type()] {
+			continue
+		}
+		if s.queue == nil {
+			s.handler(event)
+		} else {
+			s.offer(event)
+		}
+	}
+}
+
+// Close unsubscribes every subscriber, waiting for asynchronous ones to
+// handle the events already queued
+func (b *Bus) Close() {
+	b.mu.RLock()
+	subscribers := make([]*Subscription, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.RUnlock()
+	for _, s := range subscribers {
+		s.Unsubscribe()
+	}
+}
+
+// offer queues an event for an asynchronous subscriber, reporting earlier
+// drops first. Must be called with the bus locked for reading.
+func (s *Subscription) offer(event Event) {
+	if missed := s.missed.Swap(0); missed > 0 {
+		select {
+		case s.queue <- Dropped{Count: missed}:
+		default:
+			s.missed.Add(missed)
+			s.drop()
+			return
+		}
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.drop()
+	}
+}
+
+func (s *Subscription) drop() {
+	s.missed.Add(1)
+	s.dropped.Add(1)
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		s.handler(event)
+	}
+	if missed := s.missed.Swap(0); missed > 0 {
+		s.handler(Dropped{Count: missed})
+	}
+}
+
+// Dropped returns how many events were dropped because the subscriber's
+// queue was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Unsubscribe stops deliveries to the subscriber. For an asynchronous
+// subscriber it waits until the events already queued have been handled, so
+// it must not be called from the subscriber's own handler.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		if s.queue != nil {
+			close(s.queue)
+		}
+	})
+	if s.done != nil {
+		<-s.done
+	}
+}
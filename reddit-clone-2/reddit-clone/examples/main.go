@@ -94,7 +94,7 @@ func testAllFunctionality(client *reddit.Client) error {
 	fmt.Println("✓ Sent direct message")
 
 	// 7. Get feed
-	feed, err := client.GetFeed(ctx, 0, 10)
+	feed, err := client.GetFeed(ctx, "", 10)
 	if err != nil {
 		return fmt.Errorf("failed to get feed: %v", err)
 	}
@@ -10,12 +10,43 @@ import (
 // User represents a Reddit user account
 type User struct {
 	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // Never sent to client
-	Karma        int       `json:"karma"`
+	PasswordHash string    `json:"-"`             // Never sent to client
+	Karma        int       `json:"karma"`         // PostKarma plus CommentKarma
+	PostKarma    int       `json:"post_karma"`    // Net votes from other users on their posts
+	CommentKarma int       `json:"comment_karma"` // Net votes from other users on their comments
 	CreatedAt    time.Time `json:"created_at"`
 	Subreddits   []string  `json:"subreddits"` // List of subscribed subreddit names
 }
 
+// Profile summarises a user's public activity. Their posts and comments are
+// listed page by page by the user history endpoints.
+type Profile struct {
+	Username       string    `json:"username"`
+	Karma          int       `json:"karma"`
+	PostKarma      int       `json:"post_karma"`
+	CommentKarma   int       `json:"comment_karma"`
+	CreatedAt      time.Time `json:"created_at"`
+	PostCount      int       `json:"post_count"`
+	CommentCount   int       `json:"comment_count"`
+	FollowerCount  int       `json:"follower_count"`
+	FollowingCount int       `json:"following_count"`
+	Followed       bool      `json:"followed"` // Whether the signed-in user follows them
+}
+
+// Follow is one user following another
+type Follow struct {
+	Follower   string    `json:"follower"`
+	Followee   string    `json:"followee"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// FollowListResponse represents a paginated list of follows, newest first
+type FollowListResponse struct {
+	Follows    []Follow `json:"follows"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	HasMore    bool     `json:"has_more"`
+}
+
 // Subreddit represents a community
 type Subreddit struct {
 	Name        string    `json:"name"`
@@ -23,20 +54,56 @@ type Subreddit struct {
 	CreatedAt   time.Time `json:"created_at"`
 	Moderators  []string  `json:"moderators"` // List of moderator usernames
 	Subscribers int       `json:"subscribers"`
+
+	CrosspostsDisabled bool `json:"crossposts_disabled,omitempty"` // Refuses crossposts from other subreddits
+}
+
+// Moderator is a member of a subreddit's mod team. Lists of moderators are
+// ordered by seniority, the first one owning the subreddit.
+type Moderator struct {
+	Username    string    `json:"username"`
+	Permissions []string  `json:"permissions"` // "all", or any of posts, users, config, wiki and mail
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// ModeratorInvite is an invitation to join a mod team, pending until accepted
+type ModeratorInvite struct {
+	Username    string    `json:"username"`
+	Permissions []string  `json:"permissions"`
+	InvitedBy   string    `json:"invited_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ModeratorListResponse represents a subreddit's mod team. Invites are only
+// shown to its moderators.
+type ModeratorListResponse struct {
+	Subreddit  string            `json:"subreddit"`
+	Moderators []Moderator       `json:"moderators"`
+	Invites    []ModeratorInvite `json:"invites,omitempty"`
 }
 
 // Post represents content submitted to a subreddit
 type Post struct {
-	ID            uuid.UUID `json:"id"`
-	Title         string    `json:"title"`
-	Content       string    `json:"content"`
-	AuthorName    string    `json:"author_name"`
-	SubredditName string    `json:"subreddit_name"`
-	CreatedAt     time.Time `json:"created_at"`
-	Score         int       `json:"score"`
-	CommentsCount int       `json:"comments_count"`
-	Upvotes       int       `json:"upvotes"`
-	Downvotes     int       `json:"downvotes"`
+	ID            uuid.UUID  `json:"id"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	AuthorName    string     `json:"author_name"`
+	SubredditName string     `json:"subreddit_name"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Score         int        `json:"score"`
+	CommentsCount int        `json:"comments_count"`
+	Upvotes       int        `json:"upvotes"`
+	Downvotes     int        `json:"downvotes"`
+	EditedAt      *time.Time `json:"edited_at,omitempty"` // Last edit or deletion
+	Deleted       bool       `json:"deleted,omitempty"`
+	Removed       bool       `json:"removed,omitempty"`  // Hidden by a moderator
+	Spam          bool       `json:"spam,omitempty"`     // Removed as spam
+	Filtered      bool       `json:"filtered,omitempty"` // Held for moderator review
+	Locked        bool       `json:"locked,omitempty"`   // Takes no new comments or votes
+	Flair         string     `json:"flair,omitempty"`
+
+	CrosspostOf    *uuid.UUID `json:"crosspost_of,omitempty"`    // Original a crosspost shares, never another crosspost
+	CrosspostCount int        `json:"crosspost_count,omitempty"` // Crossposts of an original
 }
 
 // Comment represents a response to a post or another comment
@@ -51,6 +118,152 @@ type Comment struct {
 	Upvotes      int        `json:"upvotes"`
 	Downvotes    int        `json:"downvotes"`
 	RepliesCount int        `json:"replies_count"`
+	EditedAt     *time.Time `json:"edited_at,omitempty"` // Last edit or deletion
+	Deleted      bool       `json:"deleted,omitempty"`
+	Removed      bool       `json:"removed,omitempty"`  // Hidden by a moderator
+	Spam         bool       `json:"spam,omitempty"`     // Removed as spam
+	Filtered     bool       `json:"filtered,omitempty"` // Held for moderator review
+}
+
+// DeletedContent replaces the title and content of deleted posts and comments
+const DeletedContent = "[deleted]"
+
+// RemovedContent replaces the title and content of removed posts and
+// comments for users who don't moderate their subreddit, and the author is
+// left out
+const RemovedContent = "[removed]"
+
+// BlockedContent replaces the content of comments by a user the viewer has
+// blocked when replies by others are shown under them, and the author is left
+// out
+const BlockedContent = "[blocked]"
+
+// Report is a user's complaint about a post or comment. Reporters are only
+// known to the server.
+type Report struct {
+	Reporter  string    `json:"-"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModQueueItem is a reported or filtered post or comment awaiting a
+// moderator's decision. Exactly one of Post and Comment is set.
+type ModQueueItem struct {
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+	Reports []Report `json:"reports"`
+}
+
+// ModQueueResponse represents a page of a subreddit's mod queue
+type ModQueueResponse struct {
+	Items      []ModQueueItem `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// Ban keeps a user from posting, commenting and voting in a subreddit. Mutes
+// share its shape and keep a user from messaging the subreddit's moderators.
+type Ban struct {
+	Username  string     `json:"username"`
+	Moderator string     `json:"moderator"` // Who issued it
+	Reason    string     `json:"reason"`
+	Note      string     `json:"note,omitempty"` // Only shown to moderators
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Null for a permanent ban
+}
+
+// BanListResponse represents the users banned from a subreddit
+type BanListResponse struct {
+	Subreddit string `json:"subreddit"`
+	Bans      []Ban  `json:"bans"`
+}
+
+// MuteListResponse represents the users muted in a subreddit
+type MuteListResponse struct {
+	Subreddit string `json:"subreddit"`
+	Mutes     []Ban  `json:"mutes"`
+}
+
+// AutoModRule is one AutoModerator rule of a subreddit. It matches new posts
+// and comments that pass every check it sets, then takes every action it
+// sets.
+type AutoModRule struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"` // "post", "comment" or "any", the default
+
+	// Checks
+	Author *AutoModAuthor `json:"author,omitempty"`
+	Title  string         `json:"title,omitempty"`  // Regular expression, posts only
+	Body   string         `json:"body,omitempty"`   // Regular expression
+	Domain []string       `json:"domain,omitempty"` // Domains of links in the title or body, subdomains included
+
+	// Actions
+	Action   string `json:"action,omitempty"`    // remove, spam or filter
+	Comment  string `json:"comment,omitempty"`   // Reply left by AutoModerator
+	SetFlair string `json:"set_flair,omitempty"` // Posts only
+	Report   string `json:"report,omitempty"`    // Reason of a report filed by AutoModerator
+}
+
+// AutoModAuthor checks the author of new content. Every check set must hold.
+type AutoModAuthor struct {
+	KarmaBelow      *int   `json:"karma_below,omitempty"`
+	AccountAgeBelow string `json:"account_age_below,omitempty"` // Such as "72h"
+}
+
+// AutoModRulesResponse represents the AutoModerator rules of a subreddit
+type AutoModRulesResponse struct {
+	Subreddit string        `json:"subreddit"`
+	Rules     []AutoModRule `json:"rules"`
+}
+
+// AutoModValidationResponse lists the problems found in a set of rules
+type AutoModValidationResponse struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// AutoModMatch is a post or comment a rule matched during a dry run. Exactly
+// one of PostID and CommentID is set.
+type AutoModMatch struct {
+	PostID    *uuid.UUID `json:"post_id,omitempty"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	Rule      string     `json:"rule"` // Name of the rule, or its position if it has none
+}
+
+// AutoModDryRunResponse lists what a set of rules would match among the
+// existing content of a subreddit
+type AutoModDryRunResponse struct {
+	Subreddit string         `json:"subreddit"`
+	Matches   []AutoModMatch `json:"matches"`
+}
+
+// Revision is an earlier version of an edited post or comment
+type Revision struct {
+	Title     string    `json:"title,omitempty"` // Posts only
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"` // When this version was written
+}
+
+// CommentNode is a comment with the replies returned under it in a thread
+type CommentNode struct {
+	Comment
+	Replies []CommentNode `json:"replies"`
+	More    *MoreComments `json:"more,omitempty"` // Replies left out by the depth or breadth limit
+}
+
+// MoreComments stands in for comments left out of a thread
+type MoreComments struct {
+	Count int    `json:"count"` // Comments left out, replies included
+	Token string `json:"token"` // Pass as the "more" parameter to load them
+}
+
+// CommentTreeResponse represents a post's comment thread, or the part of it a
+// continuation token stands for
+type CommentTreeResponse struct {
+	PostID   uuid.UUID     `json:"post_id"`
+	Sort     string        `json:"sort"`
+	Comments []CommentNode `json:"comments"`
+	More     *MoreComments `json:"more,omitempty"` // Top-level comments left out
 }
 
 // Vote represents a user's vote on a post or comment
@@ -69,6 +282,128 @@ type DirectMessage struct {
 	Content   string     `json:"content"`
 	CreatedAt time.Time  `json:"created_at"`
 	ReadAt    *time.Time `json:"read_at,omitempty"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"` // Message this one replies to
+}
+
+// Conversation summarises the direct messages between the user and one
+// other user
+type Conversation struct {
+	With         string        `json:"with"`
+	LastMessage  DirectMessage `json:"last_message"` // Newest message in either direction
+	MessageCount int           `json:"message_count"`
+	UnreadCount  int           `json:"unread_count"` // Messages to the user they have not read
+}
+
+// ConversationListResponse lists the user's conversations, the one with the
+// newest message first
+type ConversationListResponse struct {
+	Conversations []Conversation `json:"conversations"`
+	UnreadCount   int            `json:"unread_count"` // Across all conversations
+}
+
+// ConversationResponse represents a paginated conversation, newest message
+// first
+type ConversationResponse struct {
+	With        string          `json:"with"`
+	Messages    []DirectMessage `json:"messages"`
+	UnreadCount int             `json:"unread_count"` // Across all pages
+	NextCursor  string          `json:"next_cursor,omitempty"`
+	HasMore     bool            `json:"has_more"`
+}
+
+// Notification types
+const (
+	NotificationPostReply    = "post_reply"    // A comment on the user's post
+	NotificationCommentReply = "comment_reply" // A reply to the user's comment
+	NotificationMention      = "mention"       // A u/name mention of the user
+)
+
+// Notification tells a user about a reply to their post or comment, or a
+// u/name mention of them in a post or comment
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	Type      string     `json:"type"`
+	Username  string     `json:"username"`  // Recipient
+	FromUser  string     `json:"from_user"` // Author of the reply or mention
+	Subreddit string     `json:"subreddit"`
+	PostID    uuid.UUID  `json:"post_id"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"` // Null for a mention in a post
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"` // Null while unread
+}
+
+// NotificationListResponse represents a paginated list of notifications
+type NotificationListResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"` // Across all pages
+	NextCursor    string         `json:"next_cursor,omitempty"`
+	HasMore       bool           `json:"has_more"`
+}
+
+// NotificationPush is sent over the websocket connection of a user who is
+// online when they are notified
+type NotificationPush struct {
+	Type         string       `json:"type"` // Always "notification"
+	Notification Notification `json:"notification"`
+	UnreadCount  int          `json:"unread_count"`
+}
+
+// MessagePush is sent over the websocket connections of a user who is online
+// when they receive a direct message
+type MessagePush struct {
+	Type    string        `json:"type"` // Always "message"
+	Message DirectMessage `json:"message"`
+}
+
+// Delivery is a direct message or a notification queued for a user who was
+// offline when it arrived
+type Delivery struct {
+	Message      *DirectMessage `json:"message,omitempty"`
+	Notification *Notification  `json:"notification,omitempty"`
+}
+
+// PendingPush is sent over the first websocket connection of a user coming
+// back online, with everything they received while offline
+type PendingPush struct {
+	Type       string     `json:"type"`       // Always "pending"
+	Deliveries []Delivery `json:"deliveries"` // Oldest first
+}
+
+// Presence tells whether a user is connected and when they were last seen
+type Presence struct {
+	Username string     `json:"username"`
+	Online   bool       `json:"online"`
+	Sessions int        `json:"sessions"`            // Open websocket connections
+	LastSeen *time.Time `json:"last_seen,omitempty"` // When the last connection closed, null if never
+}
+
+// BlockedUser is a user on the block list of the signed-in user
+type BlockedUser struct {
+	Username  string    `json:"username"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockListResponse lists the users the signed-in user has blocked, sorted
+// by name
+type BlockListResponse struct {
+	Blocked []BlockedUser `json:"blocked"`
+}
+
+// SavedItem is a post or comment the user saved, as it reads now: later
+// edits show, and deleted content reads DeletedContent. Exactly one of Post
+// and Comment is set.
+type SavedItem struct {
+	Post    *Post     `json:"post,omitempty"`
+	Comment *Comment  `json:"comment,omitempty"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// SavedListResponse represents a paginated list of saved items, most
+// recently saved first
+type SavedListResponse struct {
+	Items      []SavedItem `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	HasMore    bool        `json:"has_more"`
 }
 
 // FeedResponse represents a paginated feed of posts
@@ -78,6 +413,35 @@ type FeedResponse struct {
 	HasMore    bool   `json:"has_more"`
 }
 
+// SearchResult is a post or comment matching a search
+type SearchResult struct {
+	Type    string   `json:"type"`  // "post" or "comment"
+	Score   float64  `json:"score"` // BM25 relevance, 0 for a search of filters alone
+	Post    *Post    `json:"post,omitempty"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+// SearchResponse represents a paginated list of search results
+type SearchResponse struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// CommentListResponse represents a paginated list of comments
+type CommentListResponse struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	HasMore    bool      `json:"has_more"`
+}
+
+// MessageListResponse represents a paginated list of direct messages
+type MessageListResponse struct {
+	Messages   []DirectMessage `json:"messages"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	HasMore    bool            `json:"has_more"`
+}
+
 // ErrorResponse represents an API error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
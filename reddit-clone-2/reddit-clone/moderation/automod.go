@@ -0,0 +1,229 @@
+// moderation/automod.go
+package moderation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reddit-clone/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AutoModerator is the user AutoModerator comments and reports as
+const AutoModerator = "AutoModerator"
+
+// linkPattern finds the host of every link in some text
+var linkPattern = regexp.MustCompile(`(?i)https?://([^/\s?#:]+)`)
+
+// RuleSet is the validated AutoModerator rules of a subreddit. It is safe for
+// concurrent use.
+type RuleSet struct {
+	rules []rule
+}
+
+// rule is an AutoModRule ready to be checked
+type rule struct {
+	models.AutoModRule
+	title, body     *regexp.Regexp
+	maxAge          time.Duration
+	posts, comments bool
+}
+
+// Outcome is what AutoModerator does to a new post or comment
+type Outcome struct {
+	Rules   []string // Labels of the rules that matched
+	Action  Action   // Strongest of the matching actions: spam, then remove, then filter
+	Flair   string   // Set by the last matching rule that sets one
+	Reports []string
+	Replies []string
+}
+
+// ParseRules parses a JSON array of rules and validates every one of them.
+// The error lists all the problems found.
+func ParseRules(data []byte) (*RuleSet, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var specs []models.AutoModRule
+	if err := decoder.Decode(&specs); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+
+	set := &RuleSet{rules: make([]rule, len(specs))}
+	var errs []error
+	for i, spec := range specs {
+		set.rules[i].AutoModRule = spec
+		if err := set.rules[i].compile(); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidRule, label(spec, i), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return set, nil
+}
+
+// compile validates the rule and prepares its checks
+func (r *rule) compile() error {
+	switch r.Type {
+	case "", "any":
+		r.posts, r.comments = true, true
+	case "post":
+		r.posts = true
+	case "comment":
+		r.comments = true
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+
+	var err error
+	if r.Title != "" {
+		// Rules that check titles only apply to posts
+		if !r.posts {
+			return errors.New("only posts have a title")
+		}
+		r.comments = false
+		if r.title, err = regexp.Compile(r.Title); err != nil {
+			return fmt.Errorf("title: %v", err)
+		}
+	}
+	if r.Body != "" {
+		if r.body, err = regexp.Compile(r.Body); err != nil {
+			return fmt.Errorf("body: %v", err)
+		}
+	}
+	if r.Author != nil && r.Author.AccountAgeBelow != "" {
+		if r.maxAge, err = time.ParseDuration(r.Author.AccountAgeBelow); err != nil || r.maxAge <= 0 {
+			return fmt.Errorf("invalid account age %q", r.Author.AccountAgeBelow)
+		}
+	}
+	domains := make([]string, len(r.Domain))
+	for i, domain := range r.Domain {
+		domains[i] = strings.ToLower(strings.TrimPrefix(domain, "www."))
+	}
+	r.Domain = domains
+
+	switch Action(r.Action) {
+	case "", ActionRemove, ActionSpam, ActionFilter:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if r.SetFlair != "" && !r.posts {
+		return errors.New("only posts take a flair")
+	}
+	if r.Action == "" && r.Comment == "" && r.SetFlair == "" && r.Report == "" {
+		return errors.New("no action")
+	}
+	return nil
+}
+
+// label names the rule at position i
+func label(spec models.AutoModRule, i int) string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// Rules returns the rules as they were written
+func (s *RuleSet) Rules() []models.AutoModRule {
+	specs := make([]models.AutoModRule, len(s.rules))
+	for i, r := range s.rules {
+		specs[i] = r.AutoModRule
+	}
+	return specs
+}
+
+// CheckPost runs the rules on a post by author, who may be unknown, as of
+// the time it was made
+func (s *RuleSet) CheckPost(post *models.Post, author *models.User) Outcome {
+	return s.check(post.Title, post.Content, false, author, post.CreatedAt)
+}
+
+// CheckComment runs the rules on a comment by author, who may be unknown, as
+// of the time it was made
+func (s *RuleSet) CheckComment(comment *models.Comment, author *models.User) Outcome {
+	return s.check("", comment.Content, true, author, comment.CreatedAt)
+}
+
+func (s *RuleSet) check(title, body string, isComment bool, author *models.User, at time.Time) Outcome {
+	var outcome Outcome
+	for i := range s.rules {
+		r := &s.rules[i]
+		if !r.matches(title, body, isComment, author, at) {
+			continue
+		}
+		outcome.Rules = append(outcome.Rules, label(r.AutoModRule, i))
+		if action := Action(r.Action); severity(action) > severity(outcome.Action) {
+			outcome.Action = action
+		}
+		if r.SetFlair != "" && !isComment {
+			outcome.Flair = r.SetFlair
+		}
+		if r.Report != "" {
+			outcome.Reports = append(outcome.Reports, r.Report)
+		}
+		if r.Comment != "" {
+			outcome.Replies = append(outcome.Replies, r.Comment)
+		}
+	}
+	return outcome
+}
+
+// matches reports whether content passes every check of the rule. Unknown
+// authors count as brand new accounts without karma.
+func (r *rule) matches(title, body string, isComment bool, author *models.User, at time.Time) bool {
+	if isComment && !r.comments || !isComment && !r.posts {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(title) {
+		return false
+	}
+	if r.body != nil && !r.body.MatchString(body) {
+		return false
+	}
+	if len(r.Domain) > 0 && !linksTo(title+"\n"+body, r.Domain) {
+		return false
+	}
+	if r.Author != nil {
+		karma, created := 0, at
+		if author != nil {
+			karma, created = author.Karma, author.CreatedAt
+		}
+		if r.Author.KarmaBelow != nil && karma >= *r.Author.KarmaBelow {
+			return false
+		}
+		if r.maxAge > 0 && at.Sub(created) >= r.maxAge {
+			return false
+		}
+	}
+	return true
+}
+
+// linksTo reports whether text links to any of domains or their subdomains
+func linksTo(text string, domains []string) bool {
+	for _, link := range linkPattern.FindAllStringSubmatch(text, -1) {
+		host := strings.ToLower(link[1])
+		for _, domain := range domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// severity orders the actions a rule can take
+func severity(action Action) int {
+	switch action {
+	case ActionFilter:
+		return 1
+	case ActionRemove:
+		return 2
+	case ActionSpam:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+// moderation/bans.go
+package moderation
+
+import (
+	"reddit-clone/models"
+	"sort"
+	"time"
+)
+
+// BanList holds the bans, or the mutes, of a subreddit by username. Bans
+// lapse on their own once they expire. A BanList is not safe for concurrent
+// use.
+type BanList struct {
+	bans map[string]models.Ban
+}
+
+// NewBanList returns an empty ban list
+func NewBanList() *BanList {
+	return &BanList{bans: make(map[string]models.Ban)}
+}
+
+// Add bans ban.Username, replacing any earlier ban. Expired bans are
+// dropped on the way.
+func (l *BanList) Add(ban models.Ban) {
+	for username, old := range l.bans {
+		if !active(old, ban.CreatedAt) {
+			delete(l.bans, username)
+		}
+	}
+	l.bans[ban.Username] = ban
+}
+
+// Remove lifts the ban on username
+func (l *BanList) Remove(username string, now time.Time) error {
+	if _, banned := l.Get(username, now); !banned {
+		return ErrNotBanned
+	}
+	delete(l.bans, username)
+	return nil
+}
+
+// Get returns the ban on username if it is in force at now
+func (l *BanList) Get(username string, now time.Time) (models.Ban, bool) {
+	ban, exists := l.bans[username]
+	if !exists || !active(ban, now) {
+		return models.Ban{}, false
+	}
+	return ban, true
+}
+
+// Bans returns the bans in force at now, oldest first
+func (l *BanList) Bans(now time.Time) []models.Ban {
+	bans := make([]models.Ban, 0, len(l.bans))
+	for _, ban := range l.bans {
+		if active(ban, now) {
+			bans = append(bans, ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].CreatedAt.Before(bans[j].CreatedAt) })
+	return bans
+}
+
+func active(ban models.Ban, now time.Time) bool {
+	return ban.ExpiresAt == nil || now.Before(*ban.ExpiresAt)
+}
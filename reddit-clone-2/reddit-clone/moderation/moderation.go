@@ -0,0 +1,310 @@
+// moderation/moderation.go
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"reddit-clone/models"
+	"sort"
+	"time"
+)
+
+// Permission grants a moderator a set of moderation actions
+type Permission string
+
+const (
+	PermAll    Permission = "all"    // Every permission, including managing the mod team
+	PermPosts  Permission = "posts"  // Remove, approve and lock posts and comments
+	PermUsers  Permission = "users"  // Ban and mute users
+	PermConfig Permission = "config" // Change the subreddit's settings and rules
+	PermWiki   Permission = "wiki"   // Edit the wiki
+	PermMail   Permission = "mail"   // Read and answer modmail
+)
+
+// Action is a moderator's decision on a post or comment
+type Action string
+
+const (
+	ActionRemove  Action = "remove"  // Hide from everyone but the subreddit's moderators
+	ActionSpam    Action = "spam"    // Remove as spam
+	ActionApprove Action = "approve" // Restore removed or filtered content and dismiss its reports
+	ActionLock    Action = "lock"    // Posts only: take no new comments or votes
+	ActionUnlock  Action = "unlock"  // Posts only
+	ActionFilter  Action = "filter"  // AutoModerator only: hide until a moderator approves or removes it
+)
+
+var (
+	ErrNotModerator      = errors.New("not a moderator")
+	ErrPermissionDenied  = errors.New("moderator lacks permission")
+	ErrOutranked         = errors.New("moderator is not senior enough")
+	ErrAlreadyModerator  = errors.New("already a moderator")
+	ErrLastModerator     = errors.New("the last moderator cannot resign")
+	ErrNoInvite          = errors.New("no pending moderator invite")
+	ErrInvalidPermission = errors.New("unknown moderator permission")
+	ErrInvalidAction     = errors.New("invalid moderator action")
+	ErrBanModerator      = errors.New("moderators cannot be banned or muted")
+	ErrNotBanned         = errors.New("user is not banned or muted")
+	ErrInvalidRule       = errors.New("invalid AutoModerator rule")
+)
+
+// ParsePermissions validates permission names. No names stand for every
+// permission, as for a full moderator.
+func ParsePermissions(names []string) ([]Permission, error) {
+	if len(names) == 0 {
+		return []Permission{PermAll}, nil
+	}
+	perms := make([]Permission, 0, len(names))
+	for _, name := range names {
+		switch perm := Permission(name); perm {
+		case PermAll, PermPosts, PermUsers, PermConfig, PermWiki, PermMail:
+			perms = append(perms, perm)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPermission, name)
+		}
+	}
+	return perms, nil
+}
+
+// ApplyToPost applies a moderator's action to a post
+func ApplyToPost(post *models.Post, action Action) error {
+	switch action {
+	case ActionLock:
+		post.Locked = true
+		return nil
+	case ActionUnlock:
+		post.Locked = false
+		return nil
+	}
+	return applyTo(&post.Removed, &post.Spam, &post.Filtered, action)
+}
+
+// ApplyToComment applies a moderator's action to a comment. Comments are
+// locked with their post.
+func ApplyToComment(comment *models.Comment, action Action) error {
+	return applyTo(&comment.Removed, &comment.Spam, &comment.Filtered, action)
+}
+
+func applyTo(removed, spam, filtered *bool, action Action) error {
+	switch action {
+	case ActionRemove:
+		*removed, *filtered = true, false
+	case ActionSpam:
+		*removed, *spam, *filtered = true, true, false
+	case ActionApprove:
+		*removed, *spam, *filtered = false, false, false
+	case ActionFilter:
+		*filtered = !*removed
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidAction, action)
+	}
+	return nil
+}
+
+// Roster is the mod team of a subreddit together with its pending invites.
+// Moderators are ordered by seniority: the first one owns the subreddit and
+// always holds every permission, and moderators can only manage those added
+// after them. A Roster is not safe for concurrent use.
+type Roster struct {
+	moderators []models.Moderator
+	invites    map[string]models.ModeratorInvite
+}
+
+// NewRoster returns the mod team of a new subreddit, owned by its creator
+func NewRoster(owner string, now time.Time) *Roster {
+	return &Roster{
+		moderators: []models.Moderator{{Username: owner, Permissions: []string{string(PermAll)}, AddedAt: now}},
+		invites:    make(map[string]models.ModeratorInvite),
+	}
+}
+
+// Moderators returns the mod team in seniority order
+func (r *Roster) Moderators() []models.Moderator {
+	return append([]models.Moderator(nil), r.moderators...)
+}
+
+// Usernames returns the moderators' usernames in seniority order
+func (r *Roster) Usernames() []string {
+	usernames := make([]string, len(r.moderators))
+	for i, mod := range r.moderators {
+		usernames[i] = mod.Username
+	}
+	return usernames
+}
+
+// Invites returns the pending invites, oldest first
+func (r *Roster) Invites() []models.ModeratorInvite {
+	invites := make([]models.ModeratorInvite, 0, len(r.invites))
+	for _, invite := range r.invites {
+		invites = append(invites, invite)
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.Before(invites[j].CreatedAt) })
+	return invites
+}
+
+// IsModerator reports whether username is on the mod team
+func (r *Roster) IsModerator(username string) bool {
+	return r.rank(username) >= 0
+}
+
+// Check verifies that username is a moderator holding perm
+func (r *Roster) Check(username string, perm Permission) error {
+	rank := r.rank(username)
+	if rank < 0 {
+		return fmt.Errorf("%w: %s", ErrNotModerator, username)
+	}
+	if rank == 0 {
+		return nil
+	}
+	for _, held := range r.moderators[rank].Permissions {
+		if Permission(held) == PermAll || Permission(held) == perm {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s needs %s", ErrPermissionDenied, username, perm)
+}
+
+// Invite invites username to the mod team with perms. Only full moderators
+// may invite, and a new invite for the same user replaces the pending one.
+func (r *Roster) Invite(by, username string, perms []Permission, now time.Time) error {
+	if err := r.Check(by, PermAll); err != nil {
+		return err
+	}
+	if r.IsModerator(username) {
+		return fmt.Errorf("%w: %s", ErrAlreadyModerator, username)
+	}
+	r.invites[username] = models.ModeratorInvite{
+		Username:    username,
+		Permissions: permissionNames(perms),
+		InvitedBy:   by,
+		CreatedAt:   now,
+	}
+	return nil
+}
+
+// RevokeInvite withdraws the pending invite of username
+func (r *Roster) RevokeInvite(by, username string) error {
+	if err := r.Check(by, PermAll); err != nil {
+		return err
+	}
+	if _, exists := r.invites[username]; !exists {
+		return fmt.Errorf("%w: %s", ErrNoInvite, username)
+	}
+	delete(r.invites, username)
+	return nil
+}
+
+// Accept adds username to the mod team as its most junior moderator, with
+// the permissions they were invited with
+func (r *Roster) Accept(username string, now time.Time) error {
+	invite, exists := r.invites[username]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrNoInvite, username)
+	}
+	delete(r.invites, username)
+	r.moderators = append(r.moderators, models.Moderator{
+		Username:    username,
+		Permissions: invite.Permissions,
+		AddedAt:     now,
+	})
+	return nil
+}
+
+// Resign removes username from the mod team. If the owner resigns, the next
+// most senior moderator takes over the subreddit; an owner moderating alone
+// cannot resign, so that a subreddit always has an owner.
+func (r *Roster) Resign(username string) error {
+	rank := r.rank(username)
+	if rank < 0 {
+		return fmt.Errorf("%w: %s", ErrNotModerator, username)
+	}
+	if len(r.moderators) == 1 {
+		return fmt.Errorf("%w: %s", ErrLastModerator, username)
+	}
+	r.moderators = append(r.moderators[:rank], r.moderators[rank+1:]...)
+	if rank == 0 {
+		r.crownOwner()
+	}
+	return nil
+}
+
+// Remove takes username off the mod team. by must be a full moderator
+// senior to them.
+func (r *Roster) Remove(by, username string) error {
+	if err := r.checkOutranks(by, username); err != nil {
+		return err
+	}
+	return r.Resign(username)
+}
+
+// SetPermissions replaces the permissions of username. by must be a full
+// moderator senior to them.
+func (r *Roster) SetPermissions(by, username string, perms []Permission) error {
+	if err := r.checkOutranks(by, username); err != nil {
+		return err
+	}
+	r.moderators[r.rank(username)].Permissions = permissionNames(perms)
+	return nil
+}
+
+// Transfer hands ownership of the subreddit to another moderator, who becomes
+// the most senior one. Only the owner may transfer and stays on the team
+// right below the new owner.
+func (r *Roster) Transfer(by, username string) error {
+	if r.rank(by) != 0 {
+		return fmt.Errorf("%w: only the owner can transfer ownership", ErrOutranked)
+	}
+	rank := r.rank(username)
+	if rank < 0 {
+		return fmt.Errorf("%w: %s", ErrNotModerator, username)
+	}
+	if rank == 0 {
+		return nil
+	}
+
+	heir := r.moderators[rank]
+	copy(r.moderators[1:rank+1], r.moderators[:rank])
+	r.moderators[0] = heir
+	r.crownOwner()
+	return nil
+}
+
+// crownOwner gives the most senior moderator every permission, which Check
+// grants the owner anyway, so that the team lists what they can do. It is
+// called whenever the owner changes.
+func (r *Roster) crownOwner() {
+	r.moderators[0].Permissions = []string{string(PermAll)}
+}
+
+// checkOutranks verifies that by is a full moderator senior to username
+func (r *Roster) checkOutranks(by, username string) error {
+	if err := r.Check(by, PermAll); err != nil {
+		return err
+	}
+	rank := r.rank(username)
+	if rank < 0 {
+		return fmt.Errorf("%w: %s", ErrNotModerator, username)
+	}
+	if r.rank(by) >= rank {
+		return fmt.Errorf("%w: %s cannot manage %s", ErrOutranked, by, username)
+	}
+	return nil
+}
+
+// rank returns the seniority of username, 0 for the owner, or -1 if they are
+// not a moderator
+func (r *Roster) rank(username string) int {
+	for i, mod := range r.moderators {
+		if mod.Username == username {
+			return i
+		}
+	}
+	return -1
+}
+
+func permissionNames(perms []Permission) []string {
+	names := make([]string, len(perms))
+	for i, perm := range perms {
+		names[i] = string(perm)
+	}
+	return names
+}
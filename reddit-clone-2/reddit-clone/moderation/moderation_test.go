@@ -0,0 +1,208 @@
+// moderation/moderation_test.go
+package moderation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seedRoster returns a team owned by owner, with senior and junior added
+// after them as full moderators
+func seedRoster(t *testing.T) *Roster {
+	t.Helper()
+	now := time.Now()
+	roster := NewRoster("owner", now)
+	for _, username := range []string{"senior", "junior"} {
+		if err := roster.Invite("owner", username, []Permission{PermAll}, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := roster.Accept(username, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return roster
+}
+
+func TestRosterSeniority(t *testing.T) {
+	roster := seedRoster(t)
+	if err := roster.Remove("junior", "senior"); !errors.Is(err, ErrOutranked) {
+		t.Errorf("junior removing senior: got %v, want ErrOutranked", err)
+	}
+	if err := roster.SetPermissions("junior", "senior", []Permission{PermWiki}); !errors.Is(err, ErrOutranked) {
+		t.Errorf("junior changing the permissions of senior: got %v, want ErrOutranked", err)
+	}
+	if err := roster.Remove("senior", "owner"); !errors.Is(err, ErrOutranked) {
+		t.Errorf("senior removing the owner: got %v, want ErrOutranked", err)
+	}
+	if err := roster.Remove("junior", "junior"); !errors.Is(err, ErrOutranked) {
+		t.Errorf("junior removing themselves: got %v, want ErrOutranked", err)
+	}
+
+	if err := roster.Remove("senior", "junior"); err != nil {
+		t.Fatalf("senior removing junior: %v", err)
+	}
+	if got := roster.Usernames(); !reflect.DeepEqual(got, []string{"owner", "senior"}) {
+		t.Errorf("team is %v, want owner and senior", got)
+	}
+	if err := roster.Remove("owner", "junior"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("removing a former moderator: got %v, want ErrNotModerator", err)
+	}
+}
+
+func TestRosterPermissions(t *testing.T) {
+	perms := []Permission{PermPosts, PermUsers, PermConfig, PermWiki, PermMail}
+	now := time.Now()
+	roster := NewRoster("owner", now)
+	for _, perm := range perms {
+		username := string(perm) + "-mod"
+		roster.Invite("owner", username, []Permission{perm}, now)
+		if err := roster.Accept(username, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, held := range perms {
+		for _, needed := range perms {
+			err := roster.Check(string(held)+"-mod", needed)
+			if held == needed && err != nil {
+				t.Errorf("%s moderator denied %s: %v", held, needed, err)
+			}
+			if held != needed && !errors.Is(err, ErrPermissionDenied) {
+				t.Errorf("%s moderator checked for %s: got %v, want ErrPermissionDenied", held, needed, err)
+			}
+		}
+		// Managing the team takes every permission
+		if err := roster.Invite(string(held)+"-mod", "newcomer", nil, now); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s moderator inviting: got %v, want ErrPermissionDenied", held, err)
+		}
+	}
+	for _, needed := range append(perms, PermAll) {
+		if err := roster.Check("owner", needed); err != nil {
+			t.Errorf("owner denied %s: %v", needed, err)
+		}
+		if err := roster.Check("outsider", needed); !errors.Is(err, ErrNotModerator) {
+			t.Errorf("outsider checked for %s: got %v, want ErrNotModerator", needed, err)
+		}
+	}
+
+	if err := roster.SetPermissions("owner", "wiki-mod", []Permission{PermWiki, PermMail}); err != nil {
+		t.Fatal(err)
+	}
+	if roster.Check("wiki-mod", PermMail) != nil || !errors.Is(roster.Check("wiki-mod", PermPosts), ErrPermissionDenied) {
+		t.Error("new permissions not in force")
+	}
+	if _, err := ParsePermissions([]string{"posts", "bogus"}); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("ParsePermissions with an unknown name: got %v, want ErrInvalidPermission", err)
+	}
+}
+
+func TestRosterInvites(t *testing.T) {
+	now := time.Now()
+	roster := NewRoster("owner", now)
+	if err := roster.Invite("owner", "alice", []Permission{PermPosts}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// A pending invite grants nothing
+	if roster.IsModerator("alice") {
+		t.Error("invited user is a moderator before accepting")
+	}
+	if err := roster.Check("alice", PermPosts); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("invited user checked: got %v, want ErrNotModerator", err)
+	}
+	if err := roster.Invite("alice", "bob", nil, now); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("invited user inviting: got %v, want ErrNotModerator", err)
+	}
+	if err := roster.Accept("bob", now); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accepting without an invite: got %v, want ErrNoInvite", err)
+	}
+
+	if err := roster.Accept("alice", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := roster.Check("alice", PermPosts); err != nil {
+		t.Errorf("moderator denied the permission they were invited with: %v", err)
+	}
+	if len(roster.Invites()) != 0 {
+		t.Errorf("accepted invite still pending: %+v", roster.Invites())
+	}
+	if err := roster.Accept("alice", now); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accepting twice: got %v, want ErrNoInvite", err)
+	}
+	if err := roster.Invite("owner", "alice", nil, now); !errors.Is(err, ErrAlreadyModerator) {
+		t.Errorf("inviting a moderator: got %v, want ErrAlreadyModerator", err)
+	}
+
+	roster.Invite("owner", "bob", nil, now)
+	if err := roster.RevokeInvite("owner", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := roster.Accept("bob", now); !errors.Is(err, ErrNoInvite) {
+		t.Errorf("accepting a revoked invite: got %v, want ErrNoInvite", err)
+	}
+}
+
+func TestRosterTransfer(t *testing.T) {
+	roster := seedRoster(t)
+	roster.SetPermissions("owner", "junior", []Permission{PermWiki})
+	if err := roster.Transfer("senior", "junior"); !errors.Is(err, ErrOutranked) {
+		t.Errorf("transfer by a non-owner: got %v, want ErrOutranked", err)
+	}
+	if err := roster.Transfer("owner", "outsider"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("transfer to a non-moderator: got %v, want ErrNotModerator", err)
+	}
+
+	if err := roster.Transfer("owner", "junior"); err != nil {
+		t.Fatal(err)
+	}
+	if got := roster.Usernames(); !reflect.DeepEqual(got, []string{"junior", "owner", "senior"}) {
+		t.Errorf("team is %v after the transfer, want junior, owner and senior", got)
+	}
+	if err := roster.Check("junior", PermConfig); err != nil {
+		t.Errorf("new owner denied a permission: %v", err)
+	}
+	if got := roster.Moderators()[0].Permissions; !reflect.DeepEqual(got, []string{string(PermAll)}) {
+		t.Errorf("new owner is listed with permissions %v, want all", got)
+	}
+	if err := roster.Remove("junior", "owner"); err != nil {
+		t.Errorf("new owner removing the former one: %v", err)
+	}
+	if err := roster.Remove("senior", "junior"); !errors.Is(err, ErrOutranked) {
+		t.Errorf("senior removing the new owner: got %v, want ErrOutranked", err)
+	}
+}
+
+func TestRosterResign(t *testing.T) {
+	roster := seedRoster(t)
+	if err := roster.Resign("outsider"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("outsider resigning: got %v, want ErrNotModerator", err)
+	}
+	roster.SetPermissions("owner", "senior", []Permission{PermMail})
+	roster.SetPermissions("owner", "junior", []Permission{PermWiki})
+	if err := roster.Resign("owner"); err != nil {
+		t.Fatalf("owner resigning: %v", err)
+	}
+	moderators := roster.Moderators()
+	if got := moderators[0].Permissions; moderators[0].Username != "senior" || !reflect.DeepEqual(got, []string{string(PermAll)}) {
+		t.Errorf("%s took over with permissions %v, want senior with all", moderators[0].Username, got)
+	}
+	if got := moderators[1].Permissions; !reflect.DeepEqual(got, []string{string(PermWiki)}) {
+		t.Errorf("junior has permissions %v after the owner resigned, want wiki", got)
+	}
+	if err := roster.Resign("senior"); err != nil {
+		t.Fatalf("senior resigning: %v", err)
+	}
+
+	// The next most senior moderator takes over, and can't leave it ownerless
+	if got := roster.Usernames(); !reflect.DeepEqual(got, []string{"junior"}) {
+		t.Errorf("team is %v, want junior alone", got)
+	}
+	if err := roster.Resign("junior"); !errors.Is(err, ErrLastModerator) {
+		t.Errorf("last owner resigning: got %v, want ErrLastModerator", err)
+	}
+	if !roster.IsModerator("junior") {
+		t.Error("last owner left the team")
+	}
+}
@@ -0,0 +1,281 @@
+// pagination/pagination.go
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Orders are kept for orderTTL. Each viewer keeps at most maxOrders of a
+// listing, dropping their oldest first, and at most maxListings listings and
+// viewers are kept in all, dropping the least recently frozen first. Only the
+// first maxPositions items of a listing are frozen.
+const (
+	orderTTL     = 30 * time.Minute
+	maxOrders    = 4
+	maxListings  = 1024
+	maxPositions = 250
+)
+
+// ErrInvalidCursor is returned for cursors that are malformed or were issued
+// for another listing
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Position places an item in a listing. Listings run from the highest key
+// down, with ties going to the newer item and then the higher ID, so every
+// item has exactly one place.
+type Position struct {
+	Key  float64   `json:"k,omitempty"`
+	Time time.Time `json:"t"`
+	ID   string    `json:"i"`
+}
+
+// Before reports whether p is listed ahead of q
+func (p Position) Before(q Position) bool {
+	if p.Key != q.Key {
+		return p.Key > q.Key
+	}
+	if !p.Time.Equal(q.Time) {
+		return p.Time.After(q.Time)
+	}
+	return p.ID > q.ID
+}
+
+// Cursor is decoded from the opaque token handed out with a page. It
+// remembers the last item served rather than a count, so items added or
+// removed ahead of it do not shift the next page, and the time of the first
+// page, so items created since then are left out instead of pushing others
+// across the page boundary.
+type Cursor struct {
+	Scope string    `json:"s"` // Listing the cursor was issued for
+	Last  Position  `json:"l"`
+	AsOf  time.Time `json:"a"`
+	Order string    `json:"o,omitempty"` // Frozen order of a ranked listing
+}
+
+// Encode returns the opaque form of the cursor
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor issued for scope. An empty token starts the listing
+// from the beginning and returns nil.
+func Decode(token, scope string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Scope != scope {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Now returns the time a listing is computed at: the time its first page was
+// served, or the current time for a first page. Scores that depend on the
+// current time should use it so the order stays the same across pages.
+func Now(after *Cursor) time.Time {
+	if after != nil {
+		return after.AsOf
+	}
+	return time.Now()
+}
+
+// Page orders items by their position and returns up to limit of them
+// following the cursor, leaving out items newer than asOf and those for which
+// position reports false. The returned cursor is nil on the last page.
+func Page[T any](items []T, after *Cursor, scope string, limit int, asOf time.Time, position func(T) (Position, bool)) ([]T, *Cursor) {
+	listed := order(items, asOf, position)
+	if after != nil {
+		start := sort.Search(len(listed), func(i int) bool {
+			return after.Last.Before(listed[i].pos)
+		})
+		listed = listed[start:]
+	}
+
+	var next *Cursor
+	if limit > 0 && len(listed) > limit {
+		listed = listed[:limit]
+		next = &Cursor{Scope: scope, Last: listed[limit-1].pos, AsOf: asOf}
+	}
+	return itemsOf(listed), next
+}
+
+// placed is an item with its position in a listing
+type placed[T any] struct {
+	item T
+	pos  Position
+}
+
+// order returns the items not newer than asOf for which position reports
+// true, in listing order
+func order[T any](items []T, asOf time.Time, position func(T) (Position, bool)) []placed[T] {
+	var listed []placed[T]
+	for _, item := range items {
+		if pos, ok := position(item); ok && !pos.Time.After(asOf) {
+			listed = append(listed, placed[T]{item: item, pos: pos})
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].pos.Before(listed[j].pos)
+	})
+	return listed
+}
+
+// PageFrozen is Page for listings ranked by keys that change over time, such
+// as vote scores. A first page with more to come freezes the order of the top
+// of the listing for viewer in orders, and later pages follow it, so votes
+// cast between pages neither repeat nor skip items. Past the frozen items,
+// pages follow the current order. Items gone since the first page, or for
+// which position now reports false, are left out. Cursors stop working once
+// their order has been dropped.
+func PageFrozen[T any](orders *Orders, viewer string, items []T, after *Cursor, scope string, limit int, asOf time.Time, position func(T) (Position, bool)) ([]T, *Cursor, error) {
+	if after == nil {
+		listed := order(items, asOf, position)
+		if limit <= 0 || len(listed) <= limit {
+			return itemsOf(listed), nil, nil
+		}
+		frozen := make([]Position, 0, maxPositions)
+		for _, p := range listed {
+			if len(frozen) == maxPositions {
+				break
+			}
+			frozen = append(frozen, p.pos)
+		}
+		id := orders.freeze(scope, viewer, frozen)
+		return itemsOf(listed[:limit]), &Cursor{Scope: scope, Last: listed[limit-1].pos, AsOf: asOf, Order: id}, nil
+	}
+
+	frozen, exists := orders.get(after.Order, scope, viewer)
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: listing expired, start again from the first page", ErrInvalidCursor)
+	}
+	isFrozen := make(map[string]bool, len(frozen))
+	for _, pos := range frozen {
+		isFrozen[pos.ID] = true
+	}
+	listed := order(items, after.AsOf, position)
+	current := make(map[string]T, len(listed))
+	for _, p := range listed {
+		current[p.pos.ID] = p.item
+	}
+
+	// The rest of the listing is what is left of the frozen items, then the
+	// items past them in their current order
+	var rest []placed[T]
+	inFrozen := isFrozen[after.Last.ID]
+	if inFrozen {
+		start := sort.Search(len(frozen), func(i int) bool {
+			return after.Last.Before(frozen[i])
+		})
+		for _, pos := range frozen[start:] {
+			if item, exists := current[pos.ID]; exists {
+				rest = append(rest, placed[T]{item: item, pos: pos})
+			}
+		}
+	}
+	for _, p := range listed {
+		if !isFrozen[p.pos.ID] && (inFrozen || after.Last.Before(p.pos)) {
+			rest = append(rest, p)
+		}
+	}
+	if limit <= 0 || len(rest) <= limit {
+		return itemsOf(rest), nil, nil
+	}
+	return itemsOf(rest[:limit]), &Cursor{Scope: scope, Last: rest[limit-1].pos, AsOf: after.AsOf, Order: after.Order}, nil
+}
+
+func itemsOf[T any](listed []placed[T]) []T {
+	items := make([]T, len(listed))
+	for i, p := range listed {
+		items[i] = p.item
+	}
+	return items
+}
+
+// frozenOrder is the order of the top of a ranked listing as of its first
+// page
+type frozenOrder struct {
+	id        string
+	positions []Position
+	frozen    time.Time
+}
+
+// orderKey names the listing and viewer a frozen order was made for, so that
+// viewers paging through listings never drop each other's orders
+type orderKey struct {
+	scope, viewer string
+}
+
+// Orders keeps the frozen orders of the ranked listings being paged through.
+// It is safe for concurrent use, and the zero value is ready to use.
+type Orders struct {
+	mu    sync.Mutex
+	byKey map[orderKey][]*frozenOrder // Oldest first
+}
+
+// freeze keeps the order of a listing for viewer and returns its ID
+func (o *Orders) freeze(scope, viewer string, positions []Position) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	key := orderKey{scope: scope, viewer: viewer}
+	kept, exists := o.byKey[key]
+	for len(kept) > 0 && (len(kept) >= maxOrders || now.Sub(kept[0].frozen) > orderTTL) {
+		kept = kept[1:]
+	}
+	if o.byKey == nil {
+		o.byKey = make(map[orderKey][]*frozenOrder)
+	}
+	if !exists && len(o.byKey) >= maxListings {
+		o.dropStalest(now)
+	}
+	id := uuid.New().String()
+	o.byKey[key] = append(kept, &frozenOrder{id: id, positions: positions, frozen: now})
+	return id
+}
+
+// dropStalest makes room for another listing by dropping those whose orders
+// have all expired, or else the one frozen least recently. Must be called with
+// o.mu held.
+func (o *Orders) dropStalest(now time.Time) {
+	var stalest orderKey
+	var stalestFrozen time.Time
+	for key, kept := range o.byKey {
+		newest := kept[len(kept)-1].frozen
+		if now.Sub(newest) > orderTTL {
+			delete(o.byKey, key)
+		} else if stalestFrozen.IsZero() || newest.Before(stalestFrozen) {
+			stalest, stalestFrozen = key, newest
+		}
+	}
+	if len(o.byKey) >= maxListings {
+		delete(o.byKey, stalest)
+	}
+}
+
+// get returns the frozen order of a listing for viewer, if it is still kept
+func (o *Orders) get(id, scope, viewer string) ([]Position, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, order := range o.byKey[orderKey{scope: scope, viewer: viewer}] {
+		if order.id == id {
+			return order.positions, time.Since(order.frozen) <= orderTTL
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,193 @@
+// pagination/pagination_test.go
+package pagination
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type scored struct {
+	id      string
+	score   int
+	created time.Time
+}
+
+func byScore(item *scored) (Position, bool) {
+	return Position{Key: float64(item.score), Time: item.created, ID: item.id}, true
+}
+
+func seedScored(n int) []*scored {
+	start := time.Now().Add(-time.Hour)
+	items := make([]*scored, n)
+	for i := range items {
+		items[i] = &scored{id: fmt.Sprintf("item-%02d", i), score: i % 5, created: start.Add(time.Duration(i) * time.Second)}
+	}
+	return items
+}
+
+func TestPageNewestFirst(t *testing.T) {
+	items := seedScored(10)
+	byTime := func(item *scored) (Position, bool) {
+		return Position{Time: item.created, ID: item.id}, true
+	}
+	var served []string
+	var after *Cursor
+	for {
+		page, next := Page(items, after, "new", 4, Now(after), byTime)
+		for _, item := range page {
+			served = append(served, item.id)
+		}
+		if next == nil {
+			break
+		}
+		if after, _ = Decode(next.Encode(), "new"); after == nil {
+			t.Fatal("cursor did not round-trip")
+		}
+	}
+	if len(served) != 10 || served[0] != "item-09" || served[9] != "item-00" {
+		t.Errorf("served %v, want item-09 down to item-00", served)
+	}
+}
+
+func TestPageFrozenVotesBetweenPages(t *testing.T) {
+	items := seedScored(30)
+	orders := new(Orders)
+	served := make(map[string]bool)
+	gone := items[0] // Last in the order, removed before the second page
+	var after *Cursor
+	for pages := 0; ; pages++ {
+		var listed []*scored
+		for _, item := range items {
+			if pages == 0 || item != gone {
+				listed = append(listed, item)
+			}
+		}
+		page, next, err := PageFrozen(orders, "reader", listed, after, "top", 7, Now(after), byScore)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		if len(page) > 7 {
+			t.Fatalf("page %d has %d items, limit is 7", pages, len(page))
+		}
+		for _, item := range page {
+			if served[item.id] {
+				t.Errorf("%s served twice", item.id)
+			}
+			served[item.id] = true
+		}
+		if next == nil {
+			break
+		}
+		if after, err = Decode(next.Encode(), "top"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Votes push the items still to come ahead of those served
+		for _, item := range items {
+			if served[item.id] {
+				item.score -= 10
+			} else {
+				item.score += 10
+			}
+		}
+	}
+
+	if served[gone.id] {
+		t.Errorf("%s served after it was removed", gone.id)
+	}
+	for _, item := range items {
+		if item != gone && !served[item.id] {
+			t.Errorf("%s skipped", item.id)
+		}
+	}
+}
+
+func TestPageFrozenOrderDropped(t *testing.T) {
+	items := seedScored(3)
+	orders := new(Orders)
+	_, next, err := PageFrozen(orders, "reader", items, nil, "top", 1, time.Now(), byScore)
+	if err != nil || next == nil {
+		t.Fatalf("first page: %v, cursor %v", err, next)
+	}
+	if _, _, err := PageFrozen(orders, "reader", items, next, "hot", 1, next.AsOf, byScore); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("order of another listing: got %v, want ErrInvalidCursor", err)
+	}
+	for i := 0; i < maxOrders; i++ {
+		PageFrozen(orders, "reader", items, nil, "top", 1, time.Now(), byScore)
+	}
+	if _, _, err := PageFrozen(orders, "reader", items, next, "top", 1, next.AsOf, byScore); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("dropped order: got %v, want ErrInvalidCursor", err)
+	}
+
+	// A single page freezes nothing
+	if _, next, _ := PageFrozen(orders, "reader", items, nil, "top", 3, time.Now(), byScore); next != nil {
+		t.Errorf("cursor %+v for a single page", next)
+	}
+}
+
+func TestPageFrozenOrdersPerViewer(t *testing.T) {
+	items := seedScored(3)
+	orders := new(Orders)
+	_, next, err := PageFrozen(orders, "reader", items, nil, "top", 1, time.Now(), byScore)
+	if err != nil || next == nil {
+		t.Fatalf("first page: %v, cursor %v", err, next)
+	}
+
+	// Another client reloading the first page only drops its own orders
+	for i := 0; i < maxListings+maxOrders; i++ {
+		PageFrozen(orders, "crawler", items, nil, "top", 1, time.Now(), byScore)
+		PageFrozen(orders, "", items, nil, "top", 1, time.Now(), byScore)
+	}
+	if _, _, err := PageFrozen(orders, "reader", items, next, "top", 1, next.AsOf, byScore); err != nil {
+		t.Errorf("reader's cursor after other clients paged: %v", err)
+	}
+	if _, _, err := PageFrozen(orders, "crawler", items, next, "top", 1, next.AsOf, byScore); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("reader's cursor followed by another viewer: got %v, want ErrInvalidCursor", err)
+	}
+
+	// Listings past the limit drop the one frozen least recently
+	for i := 0; i < maxListings; i++ {
+		PageFrozen(orders, fmt.Sprintf("viewer-%d", i), items, nil, "top", 1, time.Now(), byScore)
+	}
+	if len(orders.byKey) > maxListings {
+		t.Errorf("%d listings kept, at most %d allowed", len(orders.byKey), maxListings)
+	}
+	if _, _, err := PageFrozen(orders, "reader", items, next, "top", 1, next.AsOf, byScore); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of a dropped listing: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestPageFrozenWindow(t *testing.T) {
+	items := seedScored(maxPositions + 40)
+	orders := new(Orders)
+	served := make(map[string]bool)
+	var after *Cursor
+	for pages := 0; ; pages++ {
+		page, next, err := PageFrozen(orders, "reader", items, after, "top", 60, Now(after), byScore)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, item := range page {
+			if served[item.id] {
+				t.Errorf("%s served twice", item.id)
+			}
+			served[item.id] = true
+		}
+		if pages == 0 {
+			for _, kept := range orders.byKey {
+				if n := len(kept[0].positions); n != maxPositions {
+					t.Errorf("froze %d positions, want %d", n, maxPositions)
+				}
+			}
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+	if len(served) != len(items) {
+		t.Errorf("served %d of %d items", len(served), len(items))
+	}
+}
@@ -0,0 +1,203 @@
+// ranking/ranking.go
+package ranking
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"time"
+)
+
+// SortMode selects how a listing is ordered
+type SortMode string
+
+const (
+	SortHot           SortMode = "hot"           // Score decayed by age
+	SortTop           SortMode = "top"           // Highest score within a time window
+	SortNew           SortMode = "new"           // Newest first
+	SortControversial SortMode = "controversial" // Many votes, evenly split
+	SortRising        SortMode = "rising"        // Fastest scoring among recent posts
+)
+
+// TimeWindow limits a top or controversial listing to recent posts
+type TimeWindow string
+
+const (
+	WindowHour TimeWindow = "hour"
+	WindowDay  TimeWindow = "day"
+	WindowWeek TimeWindow = "week"
+	WindowAll  TimeWindow = "all"
+)
+
+// ErrInvalidSort is returned for unknown sort modes and time windows
+var ErrInvalidSort = errors.New("unknown sort mode or time window")
+
+// risingWindow is how old a post may be and still be rising
+const risingWindow = 24 * time.Hour
+
+// hotEpoch is the reference time of the hot ranking. A post needs ten times
+// the score of one made 12.5 hours later to rank as high.
+var hotEpoch = time.Unix(1134028003, 0)
+
+// ParseSortMode validates a sort mode name. An empty name means SortHot.
+func ParseSortMode(name string) (SortMode, error) {
+	switch mode := SortMode(name); mode {
+	case "":
+		return SortHot, nil
+	case SortHot, SortTop, SortNew, SortControversial, SortRising:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// ParseTimeWindow validates a time window name. An empty name means WindowAll.
+func ParseTimeWindow(name string) (TimeWindow, error) {
+	switch window := TimeWindow(name); window {
+	case "":
+		return WindowAll, nil
+	case WindowHour, WindowDay, WindowWeek, WindowAll:
+		return window, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// Duration returns the length of the window, or 0 for WindowAll
+func (w TimeWindow) Duration() time.Duration {
+	switch w {
+	case WindowHour:
+		return time.Hour
+	case WindowDay:
+		return 24 * time.Hour
+	case WindowWeek:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// HotScore ranks by the logarithm of the score plus a bonus that grows with
+// the post's age, so newer posts need fewer votes to rank as high.
+func HotScore(upvotes, downvotes int, posted time.Time) float64 {
+	score := upvotes - downvotes
+	order := math.Log10(math.Max(math.Abs(float64(score)), 1))
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+	seconds := posted.Sub(hotEpoch).Seconds()
+	return sign*order + seconds/45000
+}
+
+// ControversialScore favors targets with many votes split evenly between up
+// and down. Targets voted only one way score 0.
+func ControversialScore(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	magnitude := float64(upvotes + downvotes)
+	balance := float64(downvotes) / float64(upvotes)
+	if upvotes < downvotes {
+		balance = float64(upvotes) / float64(downvotes)
+	}
+	return math.Pow(magnitude, balance)
+}
+
+// RisingScore is the score a post has gained per hour since it was made
+func RisingScore(upvotes, downvotes int, posted, now time.Time) float64 {
+	hours := math.Max(now.Sub(posted).Hours(), 1.0/60)
+	return float64(upvotes-downvotes) / hours
+}
+
+// Key returns the value a post is ordered by under mode, highest first, and
+// false if the post falls outside the time window. The window only applies to
+// SortTop and SortControversial; rising posts are always limited to the last day.
+func Key(post *models.Post, mode SortMode, window TimeWindow, now time.Time) (float64, bool) {
+	var cutoff time.Duration
+	switch mode {
+	case SortTop, SortControversial:
+		cutoff = window.Duration()
+	case SortRising:
+		cutoff = risingWindow
+	}
+	if cutoff > 0 && now.Sub(post.CreatedAt) > cutoff {
+		return 0, false
+	}
+
+	switch mode {
+	case SortTop:
+		return float64(post.Upvotes - post.Downvotes), true
+	case SortControversial:
+		return ControversialScore(post.Upvotes, post.Downvotes), true
+	case SortRising:
+		return RisingScore(post.Upvotes, post.Downvotes, post.CreatedAt, now), true
+	case SortNew:
+		return 0, true // Ordered by time alone
+	}
+	return HotScore(post.Upvotes, post.Downvotes, post.CreatedAt), true
+}
+
+// PostPosition places posts in a listing sorted by mode, for use with pagination.Page
+func PostPosition(mode SortMode, window TimeWindow, now time.Time) func(*models.Post) (pagination.Position, bool) {
+	return func(post *models.Post) (pagination.Position, bool) {
+		key, ok := Key(post, mode, window, now)
+		return pagination.Position{Key: key, Time: post.CreatedAt, ID: post.ID.String()}, ok
+	}
+}
+
+// CommentSort selects how sibling comments are ordered in a thread
+type CommentSort string
+
+const (
+	CommentBest          CommentSort = "best"          // Most confidently well received
+	CommentTop           CommentSort = "top"           // Highest score
+	CommentNew           CommentSort = "new"           // Newest first
+	CommentControversial CommentSort = "controversial" // Many votes, evenly split
+)
+
+// bestConfidence is the z-score of the confidence level used by BestScore (80%)
+const bestConfidence = 1.281551565545
+
+// ParseCommentSort validates a comment sort name. An empty name means CommentBest.
+func ParseCommentSort(name string) (CommentSort, error) {
+	switch mode := CommentSort(name); mode {
+	case "":
+		return CommentBest, nil
+	case CommentBest, CommentTop, CommentNew, CommentControversial:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// BestScore is the lower bound of the Wilson score interval for the share of
+// upvotes. Unlike the raw score it does not let a comment with a single
+// upvote outrank one with 90 up and 10 down.
+func BestScore(upvotes, downvotes int) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	z := bestConfidence
+	phat := float64(upvotes) / n
+	return (phat + z*z/(2*n) - z*math.Sqrt((phat*(1-phat)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// CommentPosition places a comment among its siblings for use with pagination.Page
+func CommentPosition(mode CommentSort) func(*models.Comment) (pagination.Position, bool) {
+	return func(comment *models.Comment) (pagination.Position, bool) {
+		var key float64
+		switch mode {
+		case CommentTop:
+			key = float64(comment.Upvotes - comment.Downvotes)
+		case CommentControversial:
+			key = ControversialScore(comment.Upvotes, comment.Downvotes)
+		case CommentNew:
+			// Ordered by time alone
+		default:
+			key = BestScore(comment.Upvotes, comment.Downvotes)
+		}
+		return pagination.Position{Key: key, Time: comment.CreatedAt, ID: comment.ID.String()}, true
+	}
+}
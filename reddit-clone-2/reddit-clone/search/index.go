@@ -0,0 +1,157 @@
+// search/index.go
+package search
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BM25 parameters: k1 limits how much repeating a term adds, b how much
+// longer documents are penalised
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Kind tells posts and comments apart
+type Kind string
+
+const (
+	KindPost    Kind = "post"
+	KindComment Kind = "comment"
+)
+
+// Document is a post or comment as the index sees it
+type Document struct {
+	ID        uuid.UUID
+	Kind      Kind
+	Subreddit string // For a comment, the subreddit of its post
+	Author    string
+	CreatedAt time.Time
+	Text      string // Title and content of a post, content of a comment
+}
+
+// Result is a document matching a query
+type Result struct {
+	ID        uuid.UUID
+	Kind      Kind
+	CreatedAt time.Time
+	Score     float64 // BM25 relevance, 0 for a query without terms
+}
+
+// Index is an inverted index of posts and comments, ranking matches with
+// BM25. It is not safe for concurrent use, except for concurrent searches.
+type Index struct {
+	postings map[string]map[uuid.UUID]int // Documents holding each term, and how often
+	docs     map[uuid.UUID]*indexed
+	length   int // Terms in all documents
+}
+
+// indexed is a document with its terms counted
+type indexed struct {
+	Document
+	terms  map[string]int
+	length int
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[uuid.UUID]int),
+		docs:     make(map[uuid.UUID]*indexed),
+	}
+}
+
+// Len returns the number of documents in the index
+func (ix *Index) Len() int {
+	return len(ix.docs)
+}
+
+// Add indexes a document, replacing any earlier version of it, so edits are
+// indexed by adding the document again
+func (ix *Index) Add(doc Document) {
+	ix.Remove(doc.ID)
+
+	d := &indexed{Document: doc, terms: make(map[string]int)}
+	for _, term := range Tokenize(doc.Text) {
+		d.terms[term]++
+		d.length++
+	}
+	for term, count := range d.terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[uuid.UUID]int)
+		}
+		ix.postings[term][doc.ID] = count
+	}
+	d.Text = "" // Only the terms are needed from now on
+	ix.docs[doc.ID] = d
+	ix.length += d.length
+}
+
+// Remove drops a document from the index, if it is there
+func (ix *Index) Remove(id uuid.UUID) {
+	d, exists := ix.docs[id]
+	if !exists {
+		return
+	}
+	for term := range d.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.docs, id)
+	ix.length -= d.length
+}
+
+// Search returns the documents that hold every term of the query and pass
+// its filters, in no particular order
+func (ix *Index) Search(q Query) []Result {
+	var results []Result
+	if len(q.Terms) == 0 {
+		for _, d := range ix.docs {
+			if q.matches(&d.Document) {
+				results = append(results, Result{ID: d.ID, Kind: d.Kind, CreatedAt: d.CreatedAt})
+			}
+		}
+		return results
+	}
+
+	// Walk the rarest term's documents, since every match holds it
+	rarest := q.Terms[0]
+	for _, term := range q.Terms[1:] {
+		if len(ix.postings[term]) < len(ix.postings[rarest]) {
+			rarest = term
+		}
+	}
+	averageLength := float64(ix.length) / float64(len(ix.docs))
+docs:
+	for id := range ix.postings[rarest] {
+		d := ix.docs[id]
+		if !q.matches(&d.Document) {
+			continue
+		}
+		score := 0.0
+		norm := 1 - b + b*float64(d.length)/averageLength
+		for _, term := range q.Terms {
+			count, holds := d.terms[term]
+			if !holds {
+				continue docs
+			}
+			tf := float64(count)
+			score += ix.idf(term) * tf * (k1 + 1) / (tf + k1*norm)
+		}
+		results = append(results, Result{ID: id, Kind: d.Kind, CreatedAt: d.CreatedAt, Score: score})
+	}
+	return results
+}
+
+// idf weighs a term by how few documents hold it. It is never negative, so
+// very common terms add little rather than counting against a match.
+func (ix *Index) idf(term string) float64 {
+	n := float64(len(ix.docs))
+	df := float64(len(ix.postings[term]))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
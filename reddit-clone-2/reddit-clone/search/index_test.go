@@ -0,0 +1,134 @@
+// search/index_test.go
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// seedIndex indexes one post per text in r/golang by alice, a minute apart,
+// and returns their IDs in order
+func seedIndex(ix *Index, texts ...string) []uuid.UUID {
+	start := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	ids := make([]uuid.UUID, len(texts))
+	for i, text := range texts {
+		ids[i] = uuid.New()
+		ix.Add(Document{ID: ids[i], Kind: KindPost, Subreddit: "golang", Author: "alice", CreatedAt: start.Add(time.Duration(i) * time.Minute), Text: text})
+	}
+	return ids
+}
+
+// scores searches for text and returns the score of each match by ID
+func scores(t *testing.T, ix *Index, text string) map[uuid.UUID]float64 {
+	t.Helper()
+	q, err := ParseQuery(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[uuid.UUID]float64)
+	for _, result := range ix.Search(q) {
+		found[result.ID] = result.Score
+	}
+	return found
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := NewIndex()
+	ids := seedIndex(ix,
+		"golang generics",
+		"golang channels",
+		"golang modules explained at some length for newcomers",
+		"rust lifetimes",
+	)
+
+	// A term few documents hold counts for more than a common one
+	common, rare := scores(t, ix, "golang"), scores(t, ix, "generics")
+	if len(common) != 3 || len(rare) != 1 {
+		t.Fatalf("golang matched %d documents and generics %d, want 3 and 1", len(common), len(rare))
+	}
+	if rare[ids[0]] <= common[ids[0]] {
+		t.Errorf("generics scored %f, golang %f, want the rarer term higher", rare[ids[0]], common[ids[0]])
+	}
+	// Among documents holding a term as often, the shorter one ranks higher
+	if common[ids[1]] <= common[ids[2]] {
+		t.Errorf("short document scored %f, long one %f, want the short one higher", common[ids[1]], common[ids[2]])
+	}
+	// Every term must match
+	if both := scores(t, ix, "golang generics"); len(both) != 1 || both[ids[0]] <= rare[ids[0]] {
+		t.Errorf("golang generics matched %v, want the first document alone, scoring above either term", both)
+	}
+	if none := scores(t, ix, "golang lifetimes"); len(none) != 0 {
+		t.Errorf("golang lifetimes matched %v, want nothing", none)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	ix := NewIndex()
+	ids := seedIndex(ix, "golang generics", "golang channels")
+	comment := uuid.New()
+	ix.Add(Document{ID: comment, Kind: KindComment, Subreddit: "GoLang", Author: "bob", CreatedAt: time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC), Text: "golang"})
+
+	for _, tt := range []struct {
+		text string
+		want []uuid.UUID
+	}{
+		{"golang", []uuid.UUID{ids[0], ids[1], comment}},
+		{"golang r/golang", []uuid.UUID{ids[0], ids[1], comment}},
+		{"golang u/BOB", []uuid.UUID{comment}},
+		{"golang type:post", ids},
+		{"after:2024-03-02", []uuid.UUID{comment}},
+		{"before:2024-03-02", ids},
+		{"r/rust", nil},
+	} {
+		got := scores(t, ix, tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q matched %d documents, want %d", tt.text, len(got), len(tt.want))
+			continue
+		}
+		for _, id := range tt.want {
+			if score, found := got[id]; !found {
+				t.Errorf("%q missed %s", tt.text, id)
+			} else if score < 0 {
+				t.Errorf("%q scored %s at %f", tt.text, id, score)
+			}
+		}
+	}
+	if got := scores(t, ix, "u/bob"); got[comment] != 0 {
+		t.Errorf("a search of filters alone scored %f, want 0", got[comment])
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := NewIndex()
+	ids := seedIndex(ix, "golang generics", "golang channels")
+
+	// Adding a document again replaces it
+	ix.Add(Document{ID: ids[0], Kind: KindPost, Subreddit: "golang", Author: "alice", Text: "rust lifetimes"})
+	if ix.Len() != 2 {
+		t.Errorf("index holds %d documents after an edit, want 2", ix.Len())
+	}
+	if got := scores(t, ix, "generics"); len(got) != 0 {
+		t.Errorf("generics still matches %v after the edit", got)
+	}
+	if got := scores(t, ix, "lifetimes"); len(got) != 1 {
+		t.Errorf("lifetimes matches %v after the edit, want the edited document", got)
+	}
+	if got := scores(t, ix, "golang"); len(got) != 1 {
+		t.Errorf("golang matches %v after the edit, want the unedited document alone", got)
+	}
+
+	ix.Remove(ids[1])
+	ix.Remove(ids[1])
+	if ix.Len() != 1 {
+		t.Errorf("index holds %d documents after a delete, want 1", ix.Len())
+	}
+	if got := scores(t, ix, "golang"); len(got) != 0 {
+		t.Errorf("golang still matches %v after the delete", got)
+	}
+	ix.Remove(ids[0])
+	if ix.Len() != 0 || len(ix.postings) != 0 || ix.length != 0 {
+		t.Errorf("emptied index holds %d documents, %d terms and length %d", ix.Len(), len(ix.postings), ix.length)
+	}
+}
@@ -0,0 +1,137 @@
+// search/query.go
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayout is the format of the after: and before: filters
+const dateLayout = "2006-01-02"
+
+var (
+	ErrInvalidQuery = errors.New("invalid search query")
+	ErrInvalidSort  = errors.New("unknown search sort")
+)
+
+// Sort selects how search results are ordered
+type Sort string
+
+const (
+	SortRelevance Sort = "relevance" // Highest BM25 score first
+	SortNew       Sort = "new"       // Newest first
+	SortTop       Sort = "top"       // Highest vote score first
+)
+
+// ParseSort validates a sort name. An empty name means SortRelevance.
+func ParseSort(name string) (Sort, error) {
+	switch sort := Sort(name); sort {
+	case "":
+		return SortRelevance, nil
+	case SortRelevance, SortNew, SortTop:
+		return sort, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// Query is a parsed search. Every term must appear in a document for it to
+// match, and so must every filter that is set.
+type Query struct {
+	Terms     []string  // Stemmed, each once
+	Subreddit string    // Case-insensitive, empty for every subreddit
+	Author    string    // Case-insensitive, empty for every author
+	Kind      Kind      // Empty for posts and comments
+	After     time.Time // Created at or after, zero if unbounded
+	Before    time.Time // Created before, zero if unbounded
+}
+
+// ParseQuery reads the words of a search and its filters:
+//
+//	subreddit:name  only in the subreddit, also written r/name
+//	author:name     only by the user, also written u/name
+//	type:post       only posts, or type:comment only comments
+//	after:date      created on or after the date, as YYYY-MM-DD in UTC
+//	before:date     created before the date
+//
+// Words with a colon that is not one of these filters, such as URLs, are
+// searched for as text. A query of filters alone matches every document that
+// passes them.
+func ParseQuery(text string) (Query, error) {
+	var q Query
+	var words []string
+	for _, field := range strings.Fields(text) {
+		key, value, found := strings.Cut(field, ":")
+		switch {
+		case strings.HasPrefix(field, "r/") && len(field) > 2:
+			key, value, found = "subreddit", field[2:], true
+		case strings.HasPrefix(field, "u/") && len(field) > 2:
+			key, value, found = "author", field[2:], true
+		}
+		if !found {
+			words = append(words, field)
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "subreddit":
+			q.Subreddit, err = filterValue(key, value)
+		case "author":
+			q.Author, err = filterValue(key, value)
+		case "type":
+			switch kind := Kind(strings.ToLower(value)); kind {
+			case KindPost, KindComment:
+				q.Kind = kind
+			default:
+				err = fmt.Errorf("%w: type must be post or comment", ErrInvalidQuery)
+			}
+		case "after":
+			q.After, err = filterDate(key, value)
+		case "before":
+			q.Before, err = filterDate(key, value)
+		default:
+			words = append(words, field)
+		}
+		if err != nil {
+			return Query{}, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, term := range Tokenize(strings.Join(words, " ")) {
+		if !seen[term] {
+			seen[term] = true
+			q.Terms = append(q.Terms, term)
+		}
+	}
+	if len(q.Terms) == 0 && q.Subreddit == "" && q.Author == "" && q.Kind == "" && q.After.IsZero() && q.Before.IsZero() {
+		return Query{}, fmt.Errorf("%w: nothing to search for", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+func filterValue(key, value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("%w: %s: needs a value", ErrInvalidQuery, key)
+	}
+	return value, nil
+}
+
+func filterDate(key, value string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: dates are YYYY-MM-DD", ErrInvalidQuery, key)
+	}
+	return date, nil
+}
+
+// matches reports whether a document passes the query's filters
+func (q *Query) matches(doc *Document) bool {
+	return (q.Subreddit == "" || strings.EqualFold(q.Subreddit, doc.Subreddit)) &&
+		(q.Author == "" || strings.EqualFold(q.Author, doc.Author)) &&
+		(q.Kind == "" || q.Kind == doc.Kind) &&
+		(q.After.IsZero() || !doc.CreatedAt.Before(q.After)) &&
+		(q.Before.IsZero() || doc.CreatedAt.Before(q.Before))
+}
@@ -0,0 +1,58 @@
+// search/query_test.go
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, time.March, d, 0, 0, 0, 0, time.UTC) }
+	for _, tt := range []struct {
+		text string
+		want Query
+	}{
+		{"Posting posts", Query{Terms: []string{"post"}}},
+		{"generics r/golang", Query{Terms: []string{"generic"}, Subreddit: "golang"}},
+		{"subreddit:golang author:Alice", Query{Subreddit: "golang", Author: "Alice"}},
+		{"u/alice type:COMMENT", Query{Author: "alice", Kind: KindComment}},
+		{"type:post after:2024-03-01 before:2024-03-08", Query{Kind: KindPost, After: day(1), Before: day(8)}},
+		{"see https://go.dev/doc", Query{Terms: []string{"see", "http", "go", "dev", "doc"}}},
+		{"ratio 16:9", Query{Terms: []string{"ratio", "16", "9"}}},
+		{"r/ u/", Query{Terms: []string{"r", "u"}}},
+	} {
+		got, err := ParseQuery(tt.text)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+
+	for _, text := range []string{
+		"after:yesterday",
+		"before:2024-13-01",
+		"type:link",
+		"go subreddit:",
+		"the and of",
+	} {
+		if _, err := ParseQuery(text); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseQuery(%q): got %v, want %v", text, err, ErrInvalidQuery)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	for name, want := range map[string]Sort{"": SortRelevance, "relevance": SortRelevance, "new": SortNew, "top": SortTop} {
+		if got, err := ParseSort(name); err != nil || got != want {
+			t.Errorf("ParseSort(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseSort("hot"); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("ParseSort(\"hot\"): got %v, want %v", err, ErrInvalidSort)
+	}
+}
@@ -0,0 +1,144 @@
+// search/tokenize.go
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are too common to tell documents apart, so they are neither
+// indexed nor searched for
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"so": true, "that": true, "the": true, "their": true, "then": true,
+	"there": true, "these": true, "they": true, "this": true, "to": true,
+	"was": true, "will": true, "with": true,
+}
+
+// Tokenize splits text into lowercase words, drops stop words and stems the
+// rest. Words are runs of letters and digits.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, word := range words {
+		if !stopWords[word] {
+			terms = append(terms, Stem(word))
+		}
+	}
+	return terms
+}
+
+// Stem reduces an English word to its stem with steps 1a to 1c of the Porter
+// stemmer, which fold plurals and the -ed and -ing forms: "posts", "posted"
+// and "posting" all become "post". Words of fewer than three letters, and
+// words with characters other than a to z, are returned unchanged.
+func Stem(word string) string {
+	if len(word) < 3 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	w := []byte(word)
+
+	// Step 1a: plurals
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		w = w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+	case hasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	// Step 1b: -eed, -ed and -ing
+	switch {
+	case hasSuffix(w, "eed"):
+		if measure(w[:len(w)-3]) > 0 {
+			w = w[:len(w)-1]
+		}
+	case hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		w = tidyStep1b(w[:len(w)-2])
+	case hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		w = tidyStep1b(w[:len(w)-3])
+	}
+
+	// Step 1c: a final y after a vowel in the stem becomes i
+	if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		w[len(w)-1] = 'i'
+	}
+	return string(w)
+}
+
+// tidyStep1b restores the e or removes the doubled consonant that dropping
+// -ed or -ing leaves behind: "hoping" becomes "hope", "hopping" "hop"
+func tidyStep1b(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "at"), hasSuffix(w, "bl"), hasSuffix(w, "iz"):
+		return append(w, 'e')
+	case endsDoubleConsonant(w):
+		if last := w[len(w)-1]; last != 'l' && last != 's' && last != 'z' {
+			return w[:len(w)-1]
+		}
+	case measure(w) == 1 && endsCVC(w):
+		return append(w, 'e')
+	}
+	return w
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+// consonant reports whether w[i] is a consonant. Y is a consonant at the
+// start of a word and after a vowel.
+func consonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !consonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w
+func measure(w []byte) int {
+	m := 0
+	for i := 1; i < len(w); i++ {
+		if consonant(w, i) && !consonant(w, i-1) {
+			m++
+		}
+	}
+	return m
+}
+
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !consonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDoubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && consonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant, vowel, consonant, where the last
+// consonant is not w, x or y, as in "hop" but not "snow"
+func endsCVC(w []byte) bool {
+	n := len(w)
+	if n < 3 || !consonant(w, n-1) || consonant(w, n-2) || !consonant(w, n-3) {
+		return false
+	}
+	last := w[n-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
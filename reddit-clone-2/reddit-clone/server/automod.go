@@ -0,0 +1,221 @@
+// server/automod.go
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"reddit-clone/moderation"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// autoModeratePost runs the AutoModerator rules of a new post's subreddit on
+// it. The caller must hold s.mu for writing.
+func (s *Server) autoModeratePost(post *models.Post) {
+	rules, exists := s.automod[post.SubredditName]
+	if !exists {
+		return
+	}
+	outcome := rules.CheckPost(post, s.users[post.AuthorName])
+	if outcome.Action != "" {
+		moderation.ApplyToPost(post, outcome.Action)
+	}
+	if outcome.Flair != "" {
+		post.Flair = outcome.Flair
+	}
+	s.applyOutcome(outcome, post, nil)
+}
+
+// autoModerateComment runs the AutoModerator rules of a post's subreddit on a
+// new comment of it. The caller must hold s.mu for writing.
+func (s *Server) autoModerateComment(post *models.Post, comment *models.Comment) {
+	rules, exists := s.automod[post.SubredditName]
+	if !exists {
+		return
+	}
+	outcome := rules.CheckComment(comment, s.users[comment.AuthorName])
+	if outcome.Action != "" {
+		moderation.ApplyToComment(comment, outcome.Action)
+	}
+	s.applyOutcome(outcome, post, comment)
+}
+
+// applyOutcome files AutoModerator's reports on a post, or a comment of it,
+// and leaves its replies
+func (s *Server) applyOutcome(outcome moderation.Outcome, post *models.Post, comment *models.Comment) {
+	targetID, createdAt := post.ID, post.CreatedAt
+	var parentID *uuid.UUID
+	if comment != nil {
+		targetID, createdAt = comment.ID, comment.CreatedAt
+		parentID = &comment.ID
+	}
+
+	for _, reason := range outcome.Reports {
+		s.reports[targetID] = append(s.reports[targetID], models.Report{Reporter: moderation.AutoModerator, Reason: reason, CreatedAt: createdAt})
+	}
+	for _, content := range outcome.Replies {
+		reply := &models.Comment{
+			ID:         uuid.New(),
+			Content:    content,
+			PostID:     post.ID,
+			ParentID:   parentID,
+			AuthorName: moderation.AutoModerator,
+			CreatedAt:  createdAt,
+		}
+		s.comments[reply.ID] = reply
+		post.CommentsCount++
+		if comment != nil {
+			comment.RepliesCount++
+		}
+		s.events.Publish(events.CommentCreated{Comment: *reply, Subreddit: post.SubredditName})
+	}
+}
+
+// handleGetAutoModRules serves GET /api/subreddits/{name}/automod for
+// moderators with the config permission
+func (s *Server) handleGetAutoModRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermConfig); !ok {
+			return
+		}
+		response := models.AutoModRulesResponse{Subreddit: name, Rules: []models.AutoModRule{}}
+		if rules, exists := s.automod[name]; exists {
+			response.Rules = rules.Rules()
+		}
+		writeJSON(w, response)
+	}
+}
+
+// handleSetAutoModRules serves PUT /api/subreddits/{name}/automod. The body
+// is a JSON array of rules, which replace the subreddit's rules if they are
+// all valid.
+func (s *Server) handleSetAutoModRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rules, err := moderation.ParseRules(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermConfig); !ok {
+			return
+		}
+		s.automod[name] = rules
+		writeJSON(w, models.AutoModRulesResponse{Subreddit: name, Rules: rules.Rules()})
+	}
+}
+
+// handleValidateAutoModRules serves POST
+// /api/subreddits/{name}/automod/validate, which checks rules without
+// saving them
+func (s *Server) handleValidateAutoModRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		_, ok := s.requireModerator(w, r, name, moderation.PermConfig)
+		s.mu.RUnlock()
+		if !ok {
+			return
+		}
+
+		response := models.AutoModValidationResponse{Valid: true}
+		if _, err := moderation.ParseRules(data); err != nil {
+			response.Valid = false
+			// ParseRules joins one error per invalid rule
+			errs := []error{err}
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				errs = joined.Unwrap()
+			}
+			for _, err := range errs {
+				response.Errors = append(response.Errors, err.Error())
+			}
+		}
+		writeJSON(w, response)
+	}
+}
+
+// handleDryRunAutoMod serves POST /api/subreddits/{name}/automod/dryrun. It
+// reports what the rules in the body, or the subreddit's own rules if there
+// is no body, would match among the subreddit's posts and comments, without
+// acting on them.
+func (s *Server) handleDryRunAutoMod() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var rules *moderation.RuleSet
+		if len(bytes.TrimSpace(data)) > 0 {
+			if rules, err = moderation.ParseRules(data); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermConfig); !ok {
+			return
+		}
+		if rules == nil {
+			if rules = s.automod[name]; rules == nil {
+				http.Error(w, "Subreddit has no AutoModerator rules", http.StatusNotFound)
+				return
+			}
+		}
+
+		var posts []*models.Post
+		for _, post := range s.posts {
+			if post.SubredditName == name && !post.Deleted {
+				posts = append(posts, post)
+			}
+		}
+		var comments []*models.Comment
+		for _, comment := range s.comments {
+			if post, exists := s.posts[comment.PostID]; exists && post.SubredditName == name && !comment.Deleted && comment.AuthorName != moderation.AutoModerator {
+				comments = append(comments, comment)
+			}
+		}
+		// Oldest first, posts before comments
+		sort.Slice(posts, func(i, j int) bool { return posts[i].CreatedAt.Before(posts[j].CreatedAt) })
+		sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+
+		response := models.AutoModDryRunResponse{Subreddit: name, Matches: []models.AutoModMatch{}}
+		for _, post := range posts {
+			for _, rule := range rules.CheckPost(post, s.users[post.AuthorName]).Rules {
+				response.Matches = append(response.Matches, models.AutoModMatch{PostID: &post.ID, Rule: rule})
+			}
+		}
+		for _, comment := range comments {
+			for _, rule := range rules.CheckComment(comment, s.users[comment.AuthorName]).Rules {
+				response.Matches = append(response.Matches, models.AutoModMatch{CommentID: &comment.ID, Rule: rule})
+			}
+		}
+		writeJSON(w, response)
+	}
+}
@@ -0,0 +1,120 @@
+// server/automod_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/moderation"
+	"testing"
+)
+
+var testRules = []models.AutoModRule{
+	{Name: "spam links", Domain: []string{"spam.example"}, Action: "spam"},
+	{Name: "questions", Type: "post", Title: `\?$`, SetFlair: "Question"},
+	{Name: "rude", Type: "comment", Body: "(?i)idiot", Action: "remove", Comment: "Be nice"},
+	{Name: "crypto", Body: "crypto", Report: "Crypto talk"},
+}
+
+func TestAutoModRules(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "poster", "alice", "bob")
+	ts.createSubreddit("golang", "owner")
+	ts.appoint("golang", "owner", "poster", "posts")
+
+	if code := ts.do("PUT", "/api/subreddits/golang/automod", "poster", testRules, nil); code != http.StatusForbidden {
+		t.Errorf("rules set without the config permission: got %d, want %d", code, http.StatusForbidden)
+	}
+	var saved models.AutoModRulesResponse
+	ts.must("PUT", "/api/subreddits/golang/automod", "owner", testRules, &saved)
+	if len(saved.Rules) != len(testRules) {
+		t.Fatalf("saved %d rules, want %d", len(saved.Rules), len(testRules))
+	}
+	invalid := []models.AutoModRule{{Name: "no action", Body: "x"}}
+	if code := ts.do("PUT", "/api/subreddits/golang/automod", "owner", invalid, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid rules: got %d, want %d", code, http.StatusBadRequest)
+	}
+	var rules models.AutoModRulesResponse
+	ts.must("GET", "/api/subreddits/golang/automod", "owner", nil, &rules)
+	if len(rules.Rules) != len(testRules) || rules.Rules[0].Name != "spam links" {
+		t.Errorf("got rules %+v after an invalid update, want the saved ones", rules.Rules)
+	}
+	if code := ts.do("GET", "/api/subreddits/golang/automod", "alice", nil, nil); code != http.StatusForbidden {
+		t.Errorf("rules read by a non-moderator: got %d, want %d", code, http.StatusForbidden)
+	}
+
+	spam := ts.createPost("golang", "alice", "See http://www.spam.example/deal")
+	question := ts.createPost("golang", "alice", "Why?")
+	rude := ts.createComment(question.ID, nil, "bob", "You idiot")
+	crypto := ts.createComment(question.ID, nil, "bob", "Buy crypto")
+
+	if post := ts.posts[spam.ID]; !post.Removed || !post.Spam {
+		t.Errorf("post linking to a spam domain not removed as spam: %+v", post)
+	}
+	if post := ts.posts[question.ID]; post.Flair != "Question" || post.Removed {
+		t.Errorf("question got flair %q, removed %v; want Question and shown", post.Flair, post.Removed)
+	}
+	if comment := ts.comments[rude.ID]; !comment.Removed || comment.RepliesCount != 1 {
+		t.Errorf("rude comment removed %v with %d replies, want removed with AutoModerator's", comment.Removed, comment.RepliesCount)
+	}
+	replied := false
+	for _, comment := range ts.comments {
+		if comment.ParentID != nil && *comment.ParentID == rude.ID {
+			replied = comment.AuthorName == moderation.AutoModerator && comment.Content == "Be nice"
+		}
+	}
+	if !replied {
+		t.Error("AutoModerator did not reply to the rude comment")
+	}
+	if reports := ts.reports[crypto.ID]; len(reports) != 1 || reports[0].Reporter != moderation.AutoModerator || reports[0].Reason != "Crypto talk" {
+		t.Errorf("got reports %+v on the crypto comment, want AutoModerator's", reports)
+	}
+}
+
+func TestAutoModValidateAndDryRun(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice")
+	ts.createSubreddit("golang", "owner")
+	ts.createSubreddit("rust", "owner")
+	question := ts.createPost("golang", "alice", "Why?")
+	ts.createPost("golang", "alice", "Because")
+	comment := ts.createComment(question.ID, nil, "alice", "Buy crypto")
+
+	var validation models.AutoModValidationResponse
+	ts.must("POST", "/api/subreddits/golang/automod/validate", "owner", testRules, &validation)
+	if !validation.Valid || len(validation.Errors) != 0 {
+		t.Errorf("valid rules reported %+v", validation)
+	}
+	invalid := []models.AutoModRule{{Name: "no action", Body: "x"}, {Type: "comment", SetFlair: "Nope"}, {Body: "fine", Report: "Fine"}}
+	ts.must("POST", "/api/subreddits/golang/automod/validate", "owner", invalid, &validation)
+	if validation.Valid || len(validation.Errors) != 2 {
+		t.Errorf("got %+v, want invalid with one error for each of the two bad rules", validation)
+	}
+	if code := ts.do("POST", "/api/subreddits/golang/automod/validate", "alice", testRules, nil); code != http.StatusForbidden {
+		t.Errorf("validation by a non-moderator: got %d, want %d", code, http.StatusForbidden)
+	}
+
+	var dryRun models.AutoModDryRunResponse
+	ts.must("POST", "/api/subreddits/golang/automod/dryrun", "owner", testRules, &dryRun)
+	if len(dryRun.Matches) != 2 {
+		t.Fatalf("dry run matched %+v, want the question and the crypto comment", dryRun.Matches)
+	}
+	if first := dryRun.Matches[0]; first.PostID == nil || *first.PostID != question.ID || first.Rule != "questions" {
+		t.Errorf("first match is %+v, want the question by the questions rule", first)
+	}
+	if second := dryRun.Matches[1]; second.CommentID == nil || *second.CommentID != comment.ID || second.Rule != "crypto" {
+		t.Errorf("second match is %+v, want the comment by the crypto rule", second)
+	}
+	if post := ts.posts[question.ID]; post.Flair != "" {
+		t.Errorf("dry run set the flair %q", post.Flair)
+	}
+
+	// Without rules in the body, the dry run uses the subreddit's own
+	if code := ts.do("POST", "/api/subreddits/rust/automod/dryrun", "owner", nil, nil); code != http.StatusNotFound {
+		t.Errorf("dry run without rules: got %d, want %d", code, http.StatusNotFound)
+	}
+	ts.must("PUT", "/api/subreddits/golang/automod", "owner", testRules[3:], nil)
+	ts.must("POST", "/api/subreddits/golang/automod/dryrun", "owner", nil, &dryRun)
+	if len(dryRun.Matches) != 1 || dryRun.Matches[0].Rule != "crypto" {
+		t.Errorf("dry run of the saved rules matched %+v, want the crypto comment", dryRun.Matches)
+	}
+}
@@ -0,0 +1,216 @@
+// server/bans.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"reddit-clone/moderation"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// banRequest is the body of a ban or mute
+type banRequest struct {
+	Username string `json:"username"`
+	Duration string `json:"duration"` // Such as "72h", empty for a permanent ban
+	Reason   string `json:"reason"`
+	Note     string `json:"note"`
+}
+
+// checkNotBanned writes a 403 if username is banned from subreddit name. The
+// caller must hold s.mu.
+func (s *Server) checkNotBanned(w http.ResponseWriter, name, username string) bool {
+	if bans, exists := s.bans[name]; exists {
+		if _, banned := bans.Get(username, time.Now()); banned {
+			http.Error(w, "You are banned from r/"+name, http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// handleBanUser serves POST /api/subreddits/{name}/bans for moderators with
+// the users permission
+func (s *Server) handleBanUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.restrict(w, r, s.bans)
+	}
+}
+
+// handleUnbanUser serves DELETE /api/subreddits/{name}/bans/{username}
+func (s *Server) handleUnbanUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.lift(w, r, s.bans, "User unbanned")
+	}
+}
+
+// handleGetBans serves GET /api/subreddits/{name}/bans for moderators with
+// the users permission
+func (s *Server) handleGetBans() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermUsers); !ok {
+			return
+		}
+		writeJSON(w, models.BanListResponse{Subreddit: name, Bans: s.bans[name].Bans(time.Now())})
+	}
+}
+
+// handleMuteUser serves POST /api/subreddits/{name}/mutes for moderators
+// with the users permission
+func (s *Server) handleMuteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.restrict(w, r, s.mutes)
+	}
+}
+
+// handleUnmuteUser serves DELETE /api/subreddits/{name}/mutes/{username}
+func (s *Server) handleUnmuteUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.lift(w, r, s.mutes, "User unmuted")
+	}
+}
+
+// handleGetMutes serves GET /api/subreddits/{name}/mutes for moderators with
+// the users permission
+func (s *Server) handleGetMutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermUsers); !ok {
+			return
+		}
+		writeJSON(w, models.MuteListResponse{Subreddit: name, Mutes: s.mutes[name].Bans(time.Now())})
+	}
+}
+
+// restrict adds a ban or mute to the subreddit's entry in lists
+func (s *Server) restrict(w http.ResponseWriter, r *http.Request, lists map[string]*moderation.BanList) {
+	name := mux.Vars(r)["name"]
+	var req banRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roster, ok := s.requireModerator(w, r, name, moderation.PermUsers)
+	if !ok {
+		return
+	}
+	if _, exists := s.users[req.Username]; !exists {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if roster.IsModerator(req.Username) {
+		writeModerationError(w, moderation.ErrBanModerator)
+		return
+	}
+
+	now := time.Now()
+	ban := models.Ban{
+		Username:  req.Username,
+		Moderator: r.Header.Get("X-User"),
+		Reason:    req.Reason,
+		Note:      req.Note,
+		CreatedAt: now,
+	}
+	if duration > 0 {
+		expires := now.Add(duration)
+		ban.ExpiresAt = &expires
+	}
+	lists[name].Add(ban)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ban)
+}
+
+// lift removes a ban or mute from the subreddit's entry in lists
+func (s *Server) lift(w http.ResponseWriter, r *http.Request, lists map[string]*moderation.BanList, message string) {
+	vars := mux.Vars(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.requireModerator(w, r, vars["name"], moderation.PermUsers); !ok {
+		return
+	}
+	if err := lists[vars["name"]].Remove(vars["username"], time.Now()); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"message": message})
+}
+
+// handleSendModmail serves POST /api/subreddits/{name}/modmail. The message
+// goes to every moderator with the mail permission, unless the sender is
+// muted.
+func (s *Server) handleSendModmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		roster, ok := s.roster(w, name)
+		if !ok {
+			return
+		}
+		if _, muted := s.mutes[name].Get(username, time.Now()); muted {
+			http.Error(w, "You are muted in r/"+name, http.StatusForbidden)
+			return
+		}
+
+		response := models.MessageListResponse{Messages: []models.DirectMessage{}}
+		now := time.Now()
+		for _, mod := range roster.Moderators() {
+			if mod.Username == username || roster.Check(mod.Username, moderation.PermMail) != nil {
+				continue
+			}
+			message := &models.DirectMessage{
+				ID:        uuid.New(),
+				FromUser:  username,
+				ToUser:    mod.Username,
+				Content:   req.Content,
+				CreatedAt: now,
+			}
+			s.messages[message.ID] = message
+			s.events.Publish(events.MessageSent{Message: *message})
+			response.Messages = append(response.Messages, *message)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,146 @@
+// server/bans_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice", "bob")
+	ts.createSubreddit("golang", "owner")
+	post := ts.createPost("golang", "owner", "Welcome")
+	comment := ts.createComment(post.ID, nil, "owner", "First")
+
+	for _, tt := range []struct {
+		name, user string
+		body       interface{}
+		want       int
+	}{
+		{"by a non-moderator", "alice", map[string]string{"username": "bob"}, http.StatusForbidden},
+		{"without a username", "owner", map[string]string{}, http.StatusBadRequest},
+		{"with a bad duration", "owner", map[string]string{"username": "alice", "duration": "-1h"}, http.StatusBadRequest},
+		{"of an unknown user", "owner", map[string]string{"username": "nobody"}, http.StatusNotFound},
+		{"of a moderator", "owner", map[string]string{"username": "owner"}, http.StatusForbidden},
+	} {
+		if code := ts.do("POST", "/api/subreddits/golang/bans", tt.user, tt.body, nil); code != tt.want {
+			t.Errorf("ban %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var ban models.Ban
+	ts.must("POST", "/api/subreddits/golang/bans", "owner", map[string]string{"username": "alice", "reason": "Spam", "note": "Third time"}, &ban)
+	if ban.Username != "alice" || ban.Moderator != "owner" || ban.ExpiresAt != nil {
+		t.Errorf("got ban %+v, want a permanent ban of alice by owner", ban)
+	}
+	banned := []struct {
+		action, method, path string
+		body                 interface{}
+	}{
+		{"join", "POST", "/api/subreddits/golang/join", nil},
+		{"post", "POST", "/api/posts", map[string]string{"title": "Again", "content": "Again", "subreddit": "golang"}},
+		{"comment", "POST", "/api/posts/" + post.ID.String() + "/comments", map[string]string{"content": "Again"}},
+		{"vote on a post", "POST", "/api/posts/" + post.ID.String() + "/vote", map[string]bool{"is_upvote": true}},
+		{"vote on a comment", "POST", "/api/comments/" + comment.ID.String() + "/vote", map[string]bool{"is_upvote": true}},
+	}
+	for _, b := range banned {
+		if code := ts.do(b.method, b.path, "alice", b.body, nil); code != http.StatusForbidden {
+			t.Errorf("banned user tried to %s: got %d, want %d", b.action, code, http.StatusForbidden)
+		}
+	}
+
+	var list models.BanListResponse
+	ts.must("GET", "/api/subreddits/golang/bans", "owner", nil, &list)
+	if len(list.Bans) != 1 || list.Bans[0].Username != "alice" || list.Bans[0].Note != "Third time" {
+		t.Errorf("got bans %+v, want alice's", list.Bans)
+	}
+	if code := ts.do("GET", "/api/subreddits/golang/bans", "alice", nil, nil); code != http.StatusForbidden {
+		t.Errorf("ban list read by a non-moderator: got %d, want %d", code, http.StatusForbidden)
+	}
+
+	ts.must("DELETE", "/api/subreddits/golang/bans/alice", "owner", nil, nil)
+	if code := ts.do("DELETE", "/api/subreddits/golang/bans/alice", "owner", nil, nil); code != http.StatusNotFound {
+		t.Errorf("lifting a lifted ban: got %d, want %d", code, http.StatusNotFound)
+	}
+	for _, b := range banned {
+		if code := ts.do(b.method, b.path, "alice", b.body, nil); code >= http.StatusMultipleChoices {
+			t.Errorf("unbanned user tried to %s: got %d", b.action, code)
+		}
+	}
+}
+
+func TestBansExpire(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice", "bob")
+	ts.createSubreddit("golang", "owner")
+
+	var ban models.Ban
+	ts.must("POST", "/api/subreddits/golang/bans", "owner", map[string]string{"username": "alice", "duration": "72h"}, &ban)
+	if ban.ExpiresAt == nil || ban.ExpiresAt.Sub(ban.CreatedAt) != 72*time.Hour {
+		t.Errorf("got ban %+v, want one expiring after 72h", ban)
+	}
+
+	// A ban that ran out lapses on its own
+	created, expired := time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour)
+	ts.bans["golang"].Add(models.Ban{Username: "bob", Moderator: "owner", CreatedAt: created, ExpiresAt: &expired})
+	var list models.BanListResponse
+	ts.must("GET", "/api/subreddits/golang/bans", "owner", nil, &list)
+	if len(list.Bans) != 1 || list.Bans[0].Username != "alice" {
+		t.Errorf("got bans %+v, want alice's alone", list.Bans)
+	}
+	ts.createPost("golang", "bob", "Back")
+	if code := ts.do("DELETE", "/api/subreddits/golang/bans/bob", "owner", nil, nil); code != http.StatusNotFound {
+		t.Errorf("lifting an expired ban: got %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestMutesAndModmail(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "mailer", "poster", "alice")
+	ts.createSubreddit("golang", "owner")
+	ts.appoint("golang", "owner", "mailer", "mail")
+	ts.appoint("golang", "owner", "poster", "posts")
+
+	var sent models.MessageListResponse
+	ts.must("POST", "/api/subreddits/golang/modmail", "alice", map[string]string{"content": "Why?"}, &sent)
+	var recipients []string
+	for _, message := range sent.Messages {
+		recipients = append(recipients, message.ToUser)
+	}
+	sort.Strings(recipients)
+	if len(recipients) != 2 || recipients[0] != "mailer" || recipients[1] != "owner" {
+		t.Errorf("modmail went to %v, want the moderators with the mail permission", recipients)
+	}
+	if code := ts.do("POST", "/api/subreddits/golang/modmail", "", map[string]string{"content": "Why?"}, nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous modmail: got %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := ts.do("POST", "/api/subreddits/rust/modmail", "alice", map[string]string{"content": "Why?"}, nil); code != http.StatusNotFound {
+		t.Errorf("modmail to an unknown subreddit: got %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := ts.do("POST", "/api/subreddits/golang/mutes", "poster", map[string]string{"username": "alice"}, nil); code != http.StatusForbidden {
+		t.Errorf("mute without the users permission: got %d, want %d", code, http.StatusForbidden)
+	}
+	ts.must("POST", "/api/subreddits/golang/mutes", "owner", map[string]string{"username": "alice", "duration": "1h"}, nil)
+	if code := ts.do("POST", "/api/subreddits/golang/modmail", "alice", map[string]string{"content": "Why?"}, nil); code != http.StatusForbidden {
+		t.Errorf("modmail from a muted user: got %d, want %d", code, http.StatusForbidden)
+	}
+	var mutes models.MuteListResponse
+	ts.must("GET", "/api/subreddits/golang/mutes", "owner", nil, &mutes)
+	if len(mutes.Mutes) != 1 || mutes.Mutes[0].Username != "alice" {
+		t.Errorf("got mutes %+v, want alice's", mutes.Mutes)
+	}
+
+	// Muted users may still take part, they just can't write to the moderators
+	ts.createPost("golang", "alice", "Still here")
+
+	ts.must("DELETE", "/api/subreddits/golang/mutes/alice", "owner", nil, nil)
+	ts.must("POST", "/api/subreddits/golang/modmail", "alice", map[string]string{"content": "Thanks"}, nil)
+	if code := ts.do("DELETE", "/api/subreddits/golang/mutes/alice", "owner", nil, nil); code != http.StatusNotFound {
+		t.Errorf("lifting a lifted mute: got %d, want %d", code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,103 @@
+// server/blocks.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/models"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// blocked reports whether blocker has blocked author. Must be called with
+// s.mu held.
+func (s *Server) blocked(blocker, author string) bool {
+	_, blocked := s.blocks[blocker][author]
+	return blocked
+}
+
+// handleGetBlocks serves GET /api/blocks, the signed-in user's block list
+func (s *Server) handleGetBlocks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		response := models.BlockListResponse{Blocked: []models.BlockedUser{}}
+		for blocked, at := range s.blocks[username] {
+			response.Blocked = append(response.Blocked, models.BlockedUser{Username: blocked, BlockedAt: at})
+		}
+		sort.Slice(response.Blocked, func(i, j int) bool {
+			return response.Blocked[i].Username < response.Blocked[j].Username
+		})
+		writeJSON(w, response)
+	}
+}
+
+// handleBlockUser serves POST /api/blocks. The blocked user stops following
+// the signed-in user and can no longer message or follow them, their replies
+// and mentions stop notifying them, and their posts and comments are hidden
+// from the signed-in user's feeds, search results and comment threads.
+func (s *Server) handleBlockUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Username string `json:"username"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		if req.Username == username {
+			http.Error(w, "You cannot block yourself", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.users[req.Username]; !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if s.blocks[username] == nil {
+			s.blocks[username] = make(map[string]time.Time)
+		}
+		at, exists := s.blocks[username][req.Username]
+		if !exists {
+			at = time.Now()
+			s.blocks[username][req.Username] = at
+		}
+		delete(s.follows[req.Username], username)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.BlockedUser{Username: req.Username, BlockedAt: at})
+	}
+}
+
+// handleUnblockUser serves DELETE /api/blocks/{username}
+func (s *Server) handleUnblockUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		blocked := mux.Vars(r)["username"]
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.blocked(username, blocked) {
+			http.Error(w, "User is not blocked", http.StatusNotFound)
+			return
+		}
+		delete(s.blocks[username], blocked)
+		writeJSON(w, map[string]string{"message": "User unblocked"})
+	}
+}
@@ -0,0 +1,103 @@
+// server/blocks_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+)
+
+func TestBlockList(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+
+	for _, tt := range []struct {
+		name, user, blocked string
+		want                int
+	}{
+		{"anonymously", "", "bob", http.StatusUnauthorized},
+		{"of yourself", "alice", "alice", http.StatusBadRequest},
+		{"of an unknown user", "alice", "nobody", http.StatusNotFound},
+	} {
+		if code := ts.do("POST", "/api/blocks", tt.user, map[string]string{"username": tt.blocked}, nil); code != tt.want {
+			t.Errorf("block %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var first, again models.BlockedUser
+	ts.must("POST", "/api/blocks", "alice", map[string]string{"username": "carol"}, &first)
+	ts.must("POST", "/api/blocks", "alice", map[string]string{"username": "carol"}, &again)
+	if !again.BlockedAt.Equal(first.BlockedAt) {
+		t.Errorf("blocking twice moved the block from %v to %v", first.BlockedAt, again.BlockedAt)
+	}
+	ts.must("POST", "/api/blocks", "alice", map[string]string{"username": "bob"}, nil)
+
+	var list models.BlockListResponse
+	ts.must("GET", "/api/blocks", "alice", nil, &list)
+	var blocked []string
+	for _, user := range list.Blocked {
+		blocked = append(blocked, user.Username)
+	}
+	if !reflect.DeepEqual(blocked, []string{"bob", "carol"}) {
+		t.Errorf("alice blocked %v, want bob and carol", blocked)
+	}
+	if code := ts.do("GET", "/api/blocks", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous block list: got %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	ts.must("DELETE", "/api/blocks/bob", "alice", nil, nil)
+	if code := ts.do("DELETE", "/api/blocks/bob", "alice", nil, nil); code != http.StatusNotFound {
+		t.Errorf("unblocking an unblocked user: got %d, want %d", code, http.StatusNotFound)
+	}
+	var after models.BlockListResponse
+	ts.must("GET", "/api/blocks", "alice", nil, &after)
+	if len(after.Blocked) != 1 || after.Blocked[0].Username != "carol" {
+		t.Errorf("alice blocked %+v after unblocking bob, want carol alone", after.Blocked)
+	}
+}
+
+func TestBlockedUserIsShutOut(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "troll")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Hello")
+	ts.createPost("golang", "troll", "Bait")
+	ts.must("POST", "/api/users/alice/follow", "troll", nil, nil)
+
+	ts.must("POST", "/api/blocks", "alice", map[string]string{"username": "troll"}, nil)
+	var followers models.FollowListResponse
+	ts.must("GET", "/api/users/alice/followers", "", nil, &followers)
+	if len(followers.Follows) != 0 {
+		t.Errorf("alice is followed by %+v after blocking troll, want nobody", followers.Follows)
+	}
+	if code := ts.do("POST", "/api/users/alice/follow", "troll", nil, nil); code != http.StatusForbidden {
+		t.Errorf("follow by a blocked user: got %d, want %d", code, http.StatusForbidden)
+	}
+	if code := ts.do("POST", "/api/messages", "troll", map[string]string{"to_user": "alice", "content": "Hi"}, nil); code != http.StatusForbidden {
+		t.Errorf("message from a blocked user: got %d, want %d", code, http.StatusForbidden)
+	}
+	ts.createComment(post.ID, nil, "troll", "Hi u/alice")
+	var inbox models.NotificationListResponse
+	ts.must("GET", "/api/notifications", "alice", nil, &inbox)
+	if len(inbox.Notifications) != 0 {
+		t.Errorf("alice was notified of %+v by a blocked user", inbox.Notifications)
+	}
+
+	for _, path := range []string{"/api/feed?sort=new", "/api/subreddits/golang/posts?sort=new"} {
+		var feed models.FeedResponse
+		ts.must("GET", path, "alice", nil, &feed)
+		if got := titles(feed.Posts); !reflect.DeepEqual(got, []string{"Hello"}) {
+			t.Errorf("%s shows alice %v, want Hello alone", path, got)
+		}
+	}
+
+	// Unblocking lets the user back in
+	ts.must("DELETE", "/api/blocks/troll", "alice", nil, nil)
+	var feed models.FeedResponse
+	ts.must("GET", "/api/feed?sort=new", "alice", nil, &feed)
+	if got := titles(feed.Posts); !reflect.DeepEqual(got, []string{"Bait", "Hello"}) {
+		t.Errorf("feed after unblocking is %v, want Bait and Hello", got)
+	}
+	ts.must("POST", "/api/messages", "troll", map[string]string{"to_user": "alice", "content": "Sorry"}, nil)
+}
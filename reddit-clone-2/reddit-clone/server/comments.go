@@ -0,0 +1,216 @@
+// server/comments.go
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"reddit-clone/ranking"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// moreToken is the decoded form of a models.MoreComments token
+type moreToken struct {
+	PostID   uuid.UUID            `json:"p"`
+	ParentID uuid.UUID            `json:"c"` // uuid.Nil for top-level comments
+	Sort     ranking.CommentSort  `json:"s"`
+	After    *pagination.Position `json:"l,omitempty"` // Last sibling already returned, nil to start from the first
+	AsOf     time.Time            `json:"a"`           // Comments made later are left out
+}
+
+func (t moreToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// commentTree builds a thread from the comments of one post.
+// Must be used with s.mu held.
+type commentTree struct {
+	postID   uuid.UUID
+	sort     ranking.CommentSort
+	depth    int // Levels returned, 0 for every level
+	breadth  int // Replies returned under each comment, 0 for all
+	asOf     time.Time
+	children map[uuid.UUID][]*models.Comment // Replies by parent, uuid.Nil for top level
+	mod      bool                            // Whether removed and filtered comments are shown as written
+	blocked  map[string]time.Time            // Users the viewer has blocked
+}
+
+// handleGetComments serves GET /api/posts/{id}/comments?sort=best|top|new|controversial&depth=&limit=&more=.
+// limit caps the replies returned under each comment; comments left out by
+// either limit are summarised by a "more" object whose token loads them.
+// Comments by users the viewer has blocked are left out, or shown as
+// models.BlockedContent while replies by others hang under them.
+func (s *Server) handleGetComments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		mode, err := ranking.ParseCommentSort(query.Get("sort"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		depth, err := nonNegativeParam(query.Get("depth"))
+		if err != nil {
+			http.Error(w, "Invalid depth", http.StatusBadRequest)
+			return
+		}
+		breadth, err := nonNegativeParam(query.Get("limit"))
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		start := moreToken{PostID: postID, Sort: mode, AsOf: time.Now()}
+		if token := query.Get("more"); token != "" {
+			data, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil || json.Unmarshal(data, &start) != nil || start.PostID != postID || start.Sort != mode {
+				http.Error(w, "Invalid continuation token", http.StatusBadRequest)
+				return
+			}
+		}
+
+		s.mu.RLock()
+		post, exists := s.posts[postID]
+		if !exists {
+			s.mu.RUnlock()
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		viewer := r.Header.Get("X-User")
+		tree := commentTree{
+			postID:   postID,
+			sort:     mode,
+			depth:    depth,
+			breadth:  breadth,
+			asOf:     start.AsOf,
+			children: make(map[uuid.UUID][]*models.Comment),
+			mod:      s.seesRemoved(viewer, post.SubredditName),
+			blocked:  s.blocks[viewer],
+		}
+		for _, comment := range s.comments {
+			if comment.PostID != postID {
+				continue
+			}
+			parent := uuid.Nil
+			if comment.ParentID != nil {
+				parent = *comment.ParentID
+			}
+			tree.children[parent] = append(tree.children[parent], comment)
+		}
+		response := models.CommentTreeResponse{PostID: postID, Sort: string(mode)}
+		response.Comments, response.More = tree.level(start.ParentID, start.After, 1)
+		s.mu.RUnlock()
+
+		writeJSON(w, response)
+	}
+}
+
+// level builds the nodes for the replies to parent at the given depth,
+// starting after the sibling at after, and the summary of the replies left out
+func (t commentTree) level(parent uuid.UUID, after *pagination.Position, depth int) ([]models.CommentNode, *models.MoreComments) {
+	var from *pagination.Cursor
+	if after != nil {
+		from = &pagination.Cursor{Last: *after}
+	}
+	var listed []*models.Comment
+	for _, comment := range t.children[parent] {
+		if !t.hidden(comment) {
+			listed = append(listed, comment)
+		}
+	}
+	siblings, _ := pagination.Page(listed, from, "", 0, t.asOf, ranking.CommentPosition(t.sort))
+
+	shown := siblings
+	if t.breadth > 0 && len(shown) > t.breadth {
+		shown = shown[:t.breadth]
+	}
+	nodes := make([]models.CommentNode, len(shown))
+	for i, comment := range shown {
+		nodes[i] = t.node(comment, depth)
+	}
+	if len(shown) == len(siblings) {
+		return nodes, nil
+	}
+
+	// The breadth limit is at least 1, so some siblings were shown
+	last, _ := ranking.CommentPosition(t.sort)(shown[len(shown)-1])
+	token := moreToken{PostID: t.postID, ParentID: parent, Sort: t.sort, After: &last, AsOf: t.asOf}
+	more := &models.MoreComments{Token: token.encode()}
+	for _, comment := range siblings[len(shown):] {
+		more.Count += 1 + t.countReplies(comment.ID)
+	}
+	return nodes, more
+}
+
+// node builds the node of a comment at the given depth, with its replies
+// unless the depth limit has been reached
+func (t commentTree) node(comment *models.Comment, depth int) models.CommentNode {
+	node := models.CommentNode{Comment: *comment, Replies: []models.CommentNode{}}
+	if (comment.Removed || comment.Filtered) && !t.mod {
+		// Keep the replies in place under a tombstone that does not say who
+		// wrote the comment
+		node.Content, node.AuthorName = models.RemovedContent, ""
+	} else if _, blocked := t.blocked[comment.AuthorName]; blocked {
+		node.Content, node.AuthorName = models.BlockedContent, ""
+	}
+	if t.depth > 0 && depth >= t.depth {
+		if count := t.countReplies(comment.ID); count > 0 {
+			token := moreToken{PostID: t.postID, ParentID: comment.ID, Sort: t.sort, AsOf: t.asOf}
+			node.More = &models.MoreComments{Count: count, Token: token.encode()}
+		}
+		return node
+	}
+	node.Replies, node.More = t.level(comment.ID, nil, depth+1)
+	return node
+}
+
+// hidden reports whether a comment is left out of the thread: it and every
+// reply under it are by users the viewer has blocked
+func (t commentTree) hidden(comment *models.Comment) bool {
+	if _, blocked := t.blocked[comment.AuthorName]; !blocked {
+		return false
+	}
+	for _, reply := range t.children[comment.ID] {
+		if !reply.CreatedAt.After(t.asOf) && !t.hidden(reply) {
+			return false
+		}
+	}
+	return true
+}
+
+// countReplies counts the replies under a comment at every depth, leaving
+// out those the thread hides
+func (t commentTree) countReplies(id uuid.UUID) int {
+	count := 0
+	for _, reply := range t.children[id] {
+		if !reply.CreatedAt.After(t.asOf) && !t.hidden(reply) {
+			count += 1 + t.countReplies(reply.ID)
+		}
+	}
+	return count
+}
+
+// nonNegativeParam parses an optional count parameter, 0 when absent
+func nonNegativeParam(param string) (int, error) {
+	if param == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(param)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count: %s", param)
+	}
+	return n, nil
+}
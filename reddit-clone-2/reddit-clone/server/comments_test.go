@@ -0,0 +1,117 @@
+// server/comments_test.go
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"reddit-clone/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// walkComments counts the comments under nodes, loading every "more" it
+// finds and failing if one loads a different number than it promised
+func (ts *testServer) walkComments(postID uuid.UUID, viewer string, query url.Values, nodes []models.CommentNode, more *models.MoreComments, seen map[uuid.UUID]int) int {
+	ts.t.Helper()
+	loaded := 0
+	for _, node := range nodes {
+		seen[node.ID]++
+		loaded += 1 + ts.walkComments(postID, viewer, query, node.Replies, node.More, seen)
+	}
+	if more != nil {
+		next := url.Values{"more": {more.Token}}
+		for key, values := range query {
+			next[key] = values
+		}
+		var tree models.CommentTreeResponse
+		ts.must("GET", "/api/posts/"+postID.String()+"/comments?"+next.Encode(), viewer, nil, &tree)
+		count := ts.walkComments(postID, viewer, query, tree.Comments, tree.More, seen)
+		if count != more.Count {
+			ts.t.Errorf("more loaded %d comments, it stood for %d", count, more.Count)
+		}
+		loaded += count
+	}
+	return loaded
+}
+
+func TestGetCommentsWithBlocks(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol", "reader")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Thread")
+
+	// Branches where bob's replies hang under bob, and under others
+	authors := []string{"alice", "bob", "carol"}
+	var comments []models.Comment
+	for i := 0; i < 60; i++ {
+		author := authors[i%3]
+		if i%5 == 0 || len(comments) == 0 {
+			comments = append(comments, ts.createComment(post.ID, nil, author, fmt.Sprintf("Top %d", i)))
+			continue
+		}
+		parent := comments[(i*7)%len(comments)]
+		comments = append(comments, ts.createComment(post.ID, &parent, author, fmt.Sprintf("Reply %d", i)))
+	}
+	ts.must("POST", "/api/blocks", "reader", map[string]string{"username": "bob"}, nil)
+
+	var full models.CommentTreeResponse
+	ts.must("GET", "/api/posts/"+post.ID.String()+"/comments", "reader", nil, &full)
+	want := ts.walkComments(post.ID, "reader", nil, full.Comments, full.More, make(map[uuid.UUID]int))
+	if want == len(comments) {
+		t.Fatal("no comment hidden by the block")
+	}
+
+	query := url.Values{"depth": {"2"}, "limit": {"2"}}
+	var tree models.CommentTreeResponse
+	ts.must("GET", "/api/posts/"+post.ID.String()+"/comments?"+query.Encode(), "reader", nil, &tree)
+	seen := make(map[uuid.UUID]int)
+	if loaded := ts.walkComments(post.ID, "reader", query, tree.Comments, tree.More, seen); loaded != want || len(seen) != want {
+		t.Errorf("loaded %d comments, %d distinct, want the %d of the unlimited thread", loaded, len(seen), want)
+	}
+}
+
+func TestTombstonesHideTheAuthor(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol", "dave", "erin", "reader")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "bob", "Thread")
+	removed := ts.createComment(post.ID, nil, "carol", "Removed")
+	blocked := ts.createComment(post.ID, &removed, "dave", "Blocked")
+	ts.createComment(post.ID, &blocked, "erin", "Shown")
+	ts.must("POST", "/api/posts/"+post.ID.String()+"/moderate", "alice", map[string]string{"action": "remove"}, nil)
+	ts.must("POST", "/api/comments/"+removed.ID.String()+"/moderate", "alice", map[string]string{"action": "remove"}, nil)
+	ts.must("POST", "/api/blocks", "reader", map[string]string{"username": "dave"}, nil)
+
+	var shownPost models.Post
+	ts.must("GET", "/api/posts/"+post.ID.String(), "reader", nil, &shownPost)
+	if shownPost.Title != models.RemovedContent || shownPost.AuthorName != "" {
+		t.Errorf("removed post shown as %q by %q, want %q without an author", shownPost.Title, shownPost.AuthorName, models.RemovedContent)
+	}
+	var shownComment models.Comment
+	ts.must("GET", "/api/comments/"+removed.ID.String(), "reader", nil, &shownComment)
+	if shownComment.Content != models.RemovedContent || shownComment.AuthorName != "" {
+		t.Errorf("removed comment shown as %q by %q, want %q without an author", shownComment.Content, shownComment.AuthorName, models.RemovedContent)
+	}
+
+	var tree models.CommentTreeResponse
+	ts.must("GET", "/api/posts/"+post.ID.String()+"/comments", "reader", nil, &tree)
+	if len(tree.Comments) != 1 || len(tree.Comments[0].Replies) != 1 || len(tree.Comments[0].Replies[0].Replies) != 1 {
+		t.Fatalf("got thread %+v, want three levels", tree.Comments)
+	}
+	for _, node := range []models.CommentNode{tree.Comments[0], tree.Comments[0].Replies[0]} {
+		if node.AuthorName != "" {
+			t.Errorf("tombstone %q shows its author %q", node.Content, node.AuthorName)
+		}
+	}
+	if shown := tree.Comments[0].Replies[0].Replies[0]; shown.AuthorName != "erin" {
+		t.Errorf("reply under the tombstones by %q, want erin", shown.AuthorName)
+	}
+
+	// Moderators still see who wrote removed content
+	ts.must("GET", "/api/posts/"+post.ID.String(), "alice", nil, &shownPost)
+	ts.must("GET", "/api/posts/"+post.ID.String()+"/comments", "alice", nil, &tree)
+	if shownPost.AuthorName != "bob" || tree.Comments[0].AuthorName != "carol" {
+		t.Errorf("moderator sees the post by %q and the comment by %q, want bob and carol", shownPost.AuthorName, tree.Comments[0].AuthorName)
+	}
+}
@@ -0,0 +1,127 @@
+// server/crossposts.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleCrosspost serves POST /api/posts/{id}/crosspost, which shares a post
+// into another subreddit. Crossposting a crosspost shares the original it
+// points to, so every crosspost points at a post that is not one itself.
+func (s *Server) handleCrosspost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Subreddit string `json:"subreddit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		original, exists := s.posts[postID]
+		if exists && original.CrosspostOf != nil {
+			original, exists = s.posts[*original.CrosspostOf]
+		}
+		if !exists || !s.postVisible(original, username) {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if original.Deleted {
+			http.Error(w, "Content has been deleted", http.StatusGone)
+			return
+		}
+		subreddit, exists := s.subreddits[req.Subreddit]
+		if !exists {
+			http.Error(w, "Subreddit not found", http.StatusNotFound)
+			return
+		}
+		if subreddit.CrosspostsDisabled && subreddit.Name != original.SubredditName {
+			http.Error(w, "r/"+subreddit.Name+" does not accept crossposts", http.StatusForbidden)
+			return
+		}
+		if !s.checkNotBanned(w, subreddit.Name, username) {
+			return
+		}
+
+		post := &models.Post{
+			ID:            uuid.New(),
+			Title:         original.Title,
+			Content:       original.Content,
+			AuthorName:    username,
+			SubredditName: subreddit.Name,
+			CreatedAt:     time.Now(),
+			CrosspostOf:   &original.ID,
+		}
+		s.posts[post.ID] = post
+		original.CrosspostCount++
+		s.autoModeratePost(post)
+		s.events.Publish(events.PostCreated{Post: *post})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(post)
+	}
+}
+
+// handleGetCrossposts serves GET /api/posts/{id}/crossposts, the crossposts
+// of a post's original, newest first. Given a crosspost, it lists the
+// crossposts of the post it shares.
+func (s *Server) handleGetCrossposts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+		viewer := r.Header.Get("X-User")
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		post, exists := s.posts[postID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if post.CrosspostOf != nil {
+			postID = *post.CrosspostOf
+		}
+		l, err := parseListing(r.URL.Query(), "crossposts:"+postID.String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var crossposts []*models.Post
+		for _, crosspost := range s.posts {
+			if crosspost.CrosspostOf != nil && *crosspost.CrosspostOf == postID && s.postVisible(crosspost, viewer) && !s.blocked(viewer, crosspost.AuthorName) {
+				crossposts = append(crossposts, crosspost)
+			}
+		}
+		page, next := pageOf(l, crossposts, func(post *models.Post) (pagination.Position, bool) {
+			return pagination.Position{Time: post.CreatedAt, ID: post.ID.String()}, true
+		})
+		response := models.FeedResponse{Posts: []models.Post{}, NextCursor: next, HasMore: next != ""}
+		for _, crosspost := range page {
+			response.Posts = append(response.Posts, s.postFor(crosspost, viewer))
+		}
+		writeJSON(w, response)
+	}
+}
@@ -0,0 +1,94 @@
+// server/crossposts_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// crosspost shares post into subreddit as user
+func (ts *testServer) crosspost(post models.Post, subreddit, user string) models.Post {
+	ts.t.Helper()
+	var crosspost models.Post
+	ts.must("POST", "/api/posts/"+post.ID.String()+"/crosspost", user, map[string]string{"subreddit": subreddit}, &crosspost)
+	return crosspost
+}
+
+func TestCrosspost(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.createSubreddit("golang", "alice")
+	ts.createSubreddit("rust", "bob")
+	ts.createSubreddit("zig", "carol")
+	original := ts.createPost("golang", "alice", "Generics")
+
+	shared := ts.crosspost(original, "rust", "bob")
+	if shared.CrosspostOf == nil || *shared.CrosspostOf != original.ID || shared.AuthorName != "bob" || shared.SubredditName != "rust" || shared.Title != "Generics" {
+		t.Errorf("got crosspost %+v, want bob sharing the original into r/rust", shared)
+	}
+	// A crosspost of a crosspost shares the original
+	again := ts.crosspost(shared, "zig", "carol")
+	if again.CrosspostOf == nil || *again.CrosspostOf != original.ID {
+		t.Errorf("crosspost of a crosspost points at %v, want the original", again.CrosspostOf)
+	}
+	if count := ts.posts[original.ID].CrosspostCount; count != 2 {
+		t.Errorf("original has %d crossposts, want 2", count)
+	}
+
+	for _, id := range []uuid.UUID{original.ID, shared.ID} {
+		var list models.FeedResponse
+		ts.must("GET", "/api/posts/"+id.String()+"/crossposts", "", nil, &list)
+		if len(list.Posts) != 2 || list.Posts[0].ID != again.ID || list.Posts[1].ID != shared.ID {
+			t.Errorf("crossposts of %s are %+v, want carol's then bob's", id, list.Posts)
+		}
+	}
+	ts.must("POST", "/api/blocks", "alice", map[string]string{"username": "carol"}, nil)
+	var list models.FeedResponse
+	ts.must("GET", "/api/posts/"+original.ID.String()+"/crossposts", "alice", nil, &list)
+	if len(list.Posts) != 1 || list.Posts[0].ID != shared.ID {
+		t.Errorf("alice sees crossposts %+v after blocking carol, want bob's alone", list.Posts)
+	}
+	if code := ts.do("GET", "/api/posts/not-an-id/crossposts", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("crossposts with a bad ID: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := ts.do("GET", "/api/posts/"+uuid.New().String()+"/crossposts", "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("crossposts of an unknown post: got %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestCrosspostRefused(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "troll")
+	ts.createSubreddit("golang", "alice")
+	ts.createSubreddit("rust", "bob")
+	ts.createSubreddit("closed", "bob")
+	post := ts.createPost("golang", "alice", "Generics")
+	deleted := ts.createPost("golang", "alice", "Gone")
+	ts.must("DELETE", "/api/posts/"+deleted.ID.String(), "alice", nil, nil)
+	ts.must("PATCH", "/api/subreddits/closed", "bob", map[string]bool{"crossposts_disabled": true}, nil)
+	ts.must("POST", "/api/subreddits/rust/bans", "bob", map[string]string{"username": "troll"}, nil)
+
+	for _, tt := range []struct {
+		name, post, subreddit, user string
+		want                        int
+	}{
+		{"anonymously", post.ID.String(), "rust", "", http.StatusUnauthorized},
+		{"with a bad ID", "not-an-id", "rust", "alice", http.StatusBadRequest},
+		{"of an unknown post", uuid.New().String(), "rust", "alice", http.StatusNotFound},
+		{"of a deleted post", deleted.ID.String(), "rust", "alice", http.StatusGone},
+		{"into an unknown subreddit", post.ID.String(), "nowhere", "alice", http.StatusNotFound},
+		{"into a subreddit refusing them", post.ID.String(), "closed", "alice", http.StatusForbidden},
+		{"while banned", post.ID.String(), "rust", "troll", http.StatusForbidden},
+	} {
+		body := map[string]string{"subreddit": tt.subreddit}
+		if code := ts.do("POST", "/api/posts/"+tt.post+"/crosspost", tt.user, body, nil); code != tt.want {
+			t.Errorf("crosspost %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	if count := ts.posts[post.ID].CrosspostCount; count != 0 {
+		t.Errorf("refused crossposts counted %d", count)
+	}
+}
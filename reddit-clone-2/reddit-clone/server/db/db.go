@@ -2,132 +2,384 @@
 package db
 
 import (
-    "database/sql"
-    "fmt"
-    _ "github.com/lib/pq"
-    "github.com/google/uuid"
-    "your-project/models"
+	"database/sql"
+	_ "embed"
+	"reddit-clone/models"
+	"time"
+
+	_ "github.com/lib/pq"
 )
 
+//go:embed schema.sql
+var schema string
+
 type Database struct {
-    db *sql.DB
+	db *sql.DB
 }
 
 func NewDatabase(connStr string) (*Database, error) {
-    db, err := sql.Open("postgres", connStr)
-    if err != nil {
-        return nil, err
-    }
-    
-    if err := db.Ping(); err != nil {
-        return nil, err
-    }
-    
-    return &Database{db: db}, nil
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return &Database{db: db}, nil
+}
+
+// Migrate creates the tables and indexes that don't exist yet
+func (d *Database) Migrate() error {
+	_, err := d.db.Exec(schema)
+	return err
 }
 
 // User methods
 func (d *Database) CreateUser(user *models.User) error {
-    query := `
+	query := `
         INSERT INTO users (username, password_hash, created_at)
         VALUES ($1, $2, $3)
     `
-    _, err := d.db.Exec(query, user.Username, user.PasswordHash, user.CreatedAt)
-    return err
+	_, err := d.db.Exec(query, user.Username, user.PasswordHash, user.CreatedAt)
+	return err
 }
 
 func (d *Database) GetUser(username string) (*models.User, error) {
-    query := `
-        SELECT username, password_hash, karma, created_at
+	query := `
+        SELECT username, password_hash, karma, post_karma, comment_karma, created_at
         FROM users
         WHERE username = $1
     `
-    user := &models.User{}
-    err := d.db.QueryRow(query, username).Scan(
-        &user.Username,
-        &user.PasswordHash,
-        &user.Karma,
-        &user.CreatedAt,
-    )
-    if err != nil {
-        return nil, err
-    }
-    return user, nil
+	user := &models.User{}
+	err := d.db.QueryRow(query, username).Scan(
+		&user.Username,
+		&user.PasswordHash,
+		&user.Karma,
+		&user.PostKarma,
+		&user.CommentKarma,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetProfile returns a user's karma, how much they have posted and
+// commented, and their follower and following counts
+func (d *Database) GetProfile(username string) (*models.Profile, error) {
+	query := `
+        SELECT username, karma, post_karma, comment_karma, created_at,
+            (SELECT COUNT(*) FROM posts WHERE author_name = $1),
+            (SELECT COUNT(*) FROM comments WHERE author_name = $1),
+            (SELECT COUNT(*) FROM follows WHERE followee = $1),
+            (SELECT COUNT(*) FROM follows WHERE follower = $1)
+        FROM users
+        WHERE username = $1
+    `
+	profile := &models.Profile{}
+	err := d.db.QueryRow(query, username).Scan(
+		&profile.Username,
+		&profile.Karma,
+		&profile.PostKarma,
+		&profile.CommentKarma,
+		&profile.CreatedAt,
+		&profile.PostCount,
+		&profile.CommentCount,
+		&profile.FollowerCount,
+		&profile.FollowingCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// Follow methods
+func (d *Database) FollowUser(follow *models.Follow) error {
+	query := `
+        INSERT INTO follows (follower, followee, followed_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (follower, followee) DO NOTHING
+    `
+	_, err := d.db.Exec(query, follow.Follower, follow.Followee, follow.FollowedAt)
+	return err
+}
+
+func (d *Database) UnfollowUser(follower, followee string) error {
+	_, err := d.db.Exec(`DELETE FROM follows WHERE follower = $1 AND followee = $2`, follower, followee)
+	return err
+}
+
+// GetFollowers returns a page of the users following a user, newest first
+func (d *Database) GetFollowers(username string, offset, limit int) ([]models.Follow, error) {
+	return d.queryFollows(`
+        SELECT follower, followee, followed_at
+        FROM follows
+        WHERE followee = $1
+        ORDER BY followed_at DESC, follower
+        OFFSET $2 LIMIT $3
+    `, username, offset, limit)
+}
+
+// GetFollowing returns a page of the users a user follows, newest first
+func (d *Database) GetFollowing(username string, offset, limit int) ([]models.Follow, error) {
+	return d.queryFollows(`
+        SELECT follower, followee, followed_at
+        FROM follows
+        WHERE follower = $1
+        ORDER BY followed_at DESC, followee
+        OFFSET $2 LIMIT $3
+    `, username, offset, limit)
+}
+
+func (d *Database) queryFollows(query string, args ...interface{}) ([]models.Follow, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var follows []models.Follow
+	for rows.Next() {
+		var follow models.Follow
+		if err := rows.Scan(&follow.Follower, &follow.Followee, &follow.FollowedAt); err != nil {
+			return nil, err
+		}
+		follows = append(follows, follow)
+	}
+	return follows, rows.Err()
 }
 
 // Subreddit methods
 func (d *Database) CreateSubreddit(subreddit *models.Subreddit) error {
-    query := `
+	query := `
         INSERT INTO subreddits (name, description, created_at)
         VALUES ($1, $2, $3)
     `
-    _, err := d.db.Exec(query, subreddit.Name, subreddit.Description, subreddit.CreatedAt)
-    return err
+	_, err := d.db.Exec(query, subreddit.Name, subreddit.Description, subreddit.CreatedAt)
+	return err
+}
+
+func (d *Database) JoinSubreddit(subredditName, username string, joinedAt time.Time) error {
+	query := `
+        INSERT INTO subreddit_members (subreddit_name, username, joined_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (subreddit_name, username) DO NOTHING
+    `
+	_, err := d.db.Exec(query, subredditName, username, joinedAt)
+	return err
 }
 
 // Post methods
 func (d *Database) CreatePost(post *models.Post) error {
-    query := `
+	query := `
         INSERT INTO posts (id, title, content, author_name, subreddit_name, created_at)
         VALUES ($1, $2, $3, $4, $5, $6)
     `
-    _, err := d.db.Exec(query,
-        post.ID,
-        post.Title,
-        post.Content,
-        post.AuthorName,
-        post.SubredditName,
-        post.CreatedAt,
-    )
-    return err
+	_, err := d.db.Exec(query,
+		post.ID,
+		post.Title,
+		post.Content,
+		post.AuthorName,
+		post.SubredditName,
+		post.CreatedAt,
+	)
+	return err
+}
+
+// GetFeedPosts returns a page of a user's home feed, newest first: the posts
+// of the subreddits they have joined and of the users they follow
+func (d *Database) GetFeedPosts(username string, offset, limit int) ([]*models.Post, error) {
+	return d.queryPosts(`
+        SELECT id, title, content, author_name, subreddit_name, score, created_at
+        FROM posts
+        WHERE subreddit_name IN (SELECT subreddit_name FROM subreddit_members WHERE username = $1)
+            OR author_name IN (SELECT followee FROM follows WHERE follower = $1)
+        ORDER BY created_at DESC, id DESC
+        OFFSET $2 LIMIT $3
+    `, username, offset, limit)
+}
+
+// GetUserPosts returns a page of a user's posts, newest first
+func (d *Database) GetUserPosts(username string, offset, limit int) ([]*models.Post, error) {
+	return d.queryPosts(`
+        SELECT id, title, content, author_name, subreddit_name, score, created_at
+        FROM posts
+        WHERE author_name = $1
+        ORDER BY created_at DESC, id DESC
+        OFFSET $2 LIMIT $3
+    `, username, offset, limit)
+}
+
+func (d *Database) queryPosts(query string, args ...interface{}) ([]*models.Post, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.Post
+	for rows.Next() {
+		post := &models.Post{}
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			&post.AuthorName,
+			&post.SubredditName,
+			&post.Score,
+			&post.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
 }
 
 // Comment methods
 func (d *Database) CreateComment(comment *models.Comment) error {
-    query := `
+	query := `
         INSERT INTO comments (id, content, author_name, post_id, parent_id, created_at)
         VALUES ($1, $2, $3, $4, $5, $6)
     `
-    _, err := d.db.Exec(query,
-        comment.ID,
-        comment.Content,
-        comment.AuthorName,
-        comment.PostID,
-        comment.ParentID,
-        comment.CreatedAt,
-    )
-    return err
+	_, err := d.db.Exec(query,
+		comment.ID,
+		comment.Content,
+		comment.AuthorName,
+		comment.PostID,
+		comment.ParentID,
+		comment.CreatedAt,
+	)
+	return err
+}
+
+// GetUserComments returns a page of a user's comments, newest first
+func (d *Database) GetUserComments(username string, offset, limit int) ([]*models.Comment, error) {
+	query := `
+        SELECT id, content, author_name, post_id, parent_id, score, created_at
+        FROM comments
+        WHERE author_name = $1
+        ORDER BY created_at DESC, id DESC
+        OFFSET $2 LIMIT $3
+    `
+	rows, err := d.db.Query(query, username, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*models.Comment
+	for rows.Next() {
+		comment := &models.Comment{}
+		err := rows.Scan(
+			&comment.ID,
+			&comment.Content,
+			&comment.AuthorName,
+			&comment.PostID,
+			&comment.ParentID,
+			&comment.Score,
+			&comment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
 }
 
 // Vote methods
+
+// recomputeKarmaQuery sets the karma of the user named $1, or of every user
+// when $1 is empty, to the votes others cast on their posts and comments. It
+// only touches users whose karma was wrong.
+const recomputeKarmaQuery = `
+    WITH earned AS (
+        SELECT u.username,
+            COALESCE((SELECT SUM(CASE WHEN v.is_upvote THEN 1 ELSE -1 END)
+                FROM votes v JOIN posts p ON p.id = v.target_id
+                WHERE p.author_name = u.username AND v.username <> u.username), 0) AS post_karma,
+            COALESCE((SELECT SUM(CASE WHEN v.is_upvote THEN 1 ELSE -1 END)
+                FROM votes v JOIN comments c ON c.id = v.target_id
+                WHERE c.author_name = u.username AND v.username <> u.username), 0) AS comment_karma
+        FROM users u
+        WHERE $1 = '' OR u.username = $1
+    )
+    UPDATE users
+    SET post_karma = earned.post_karma, comment_karma = earned.comment_karma
+    FROM earned
+    WHERE users.username = earned.username
+        AND (users.post_karma, users.comment_karma) <> (earned.post_karma, earned.comment_karma)
+`
+
+// AddVote records a user's vote on a post or comment, replacing any earlier
+// vote of theirs on it, and brings the author's karma up to date. Voting
+// earns the voter no karma.
 func (d *Database) AddVote(vote *models.Vote) error {
-    query := `
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
         INSERT INTO votes (username, target_id, is_upvote, created_at)
         VALUES ($1, $2, $3, $4)
         ON CONFLICT (username, target_id) 
         DO UPDATE SET is_upvote = $3
     `
-    _, err := d.db.Exec(query,
-        vote.UserName,
-        vote.TargetID,
-        vote.IsUpvote,
-        vote.CreatedAt,
-    )
-    return err
+	if _, err := tx.Exec(query,
+		vote.UserName,
+		vote.TargetID,
+		vote.IsUpvote,
+		vote.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	var author string
+	err = tx.QueryRow(`
+        SELECT author_name FROM posts WHERE id = $1
+        UNION ALL
+        SELECT author_name FROM comments WHERE id = $1
+    `, vote.TargetID).Scan(&author)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(recomputeKarmaQuery, author); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecomputeKarma rebuilds every user's karma from the votes table, repairing
+// any drift from it. It returns the number of users whose karma was
+// corrected.
+func (d *Database) RecomputeKarma() (int64, error) {
+	result, err := d.db.Exec(recomputeKarmaQuery, "")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // Message methods
 func (d *Database) CreateMessage(message *models.DirectMessage) error {
-    query := `
+	query := `
         INSERT INTO messages (id, from_user, to_user, content, created_at)
         VALUES ($1, $2, $3, $4, $5)
     `
-    _, err := d.db.Exec(query,
-        message.ID,
-        message.FromUser,
-        message.ToUser,
-        message.Content,
-        message.CreatedAt,
-    )
-    return err
+	_, err := d.db.Exec(query,
+		message.ID,
+		message.FromUser,
+		message.ToUser,
+		message.Content,
+		message.CreatedAt,
+	)
+	return err
 }
@@ -0,0 +1,82 @@
+-- server/db/schema.sql
+-- Applied by Database.Migrate. Every statement is safe to run again.
+
+-- Karma is kept in step with the votes table by Database.AddVote, and can be
+-- rebuilt from it with Database.RecomputeKarma
+CREATE TABLE IF NOT EXISTS users (
+    username      TEXT PRIMARY KEY,
+    password_hash TEXT NOT NULL,
+    post_karma    INTEGER NOT NULL DEFAULT 0,
+    comment_karma INTEGER NOT NULL DEFAULT 0,
+    karma         INTEGER GENERATED ALWAYS AS (post_karma + comment_karma) STORED,
+    created_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS subreddits (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS subreddit_members (
+    subreddit_name TEXT NOT NULL REFERENCES subreddits (name),
+    username       TEXT NOT NULL REFERENCES users (username),
+    joined_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (subreddit_name, username)
+);
+
+CREATE INDEX IF NOT EXISTS subreddit_members_username ON subreddit_members (username);
+
+CREATE TABLE IF NOT EXISTS posts (
+    id             UUID PRIMARY KEY,
+    title          TEXT NOT NULL,
+    content        TEXT NOT NULL,
+    author_name    TEXT NOT NULL REFERENCES users (username),
+    subreddit_name TEXT NOT NULL REFERENCES subreddits (name),
+    score          INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL
+);
+
+-- Profile and home feed listings, newest first
+CREATE INDEX IF NOT EXISTS posts_author ON posts (author_name, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS posts_subreddit ON posts (subreddit_name, created_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id          UUID PRIMARY KEY,
+    content     TEXT NOT NULL,
+    author_name TEXT NOT NULL REFERENCES users (username),
+    post_id     UUID NOT NULL REFERENCES posts (id),
+    parent_id   UUID REFERENCES comments (id),
+    score       INTEGER NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS comments_post ON comments (post_id);
+CREATE INDEX IF NOT EXISTS comments_author ON comments (author_name, created_at DESC, id DESC);
+
+CREATE TABLE IF NOT EXISTS votes (
+    username   TEXT NOT NULL REFERENCES users (username),
+    target_id  UUID NOT NULL,
+    is_upvote  BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (username, target_id)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id         UUID PRIMARY KEY,
+    from_user  TEXT NOT NULL REFERENCES users (username),
+    to_user    TEXT NOT NULL REFERENCES users (username),
+    content    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS follows (
+    follower    TEXT NOT NULL REFERENCES users (username),
+    followee    TEXT NOT NULL REFERENCES users (username),
+    followed_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (follower, followee),
+    CHECK (follower <> followee)
+);
+
+-- Follower listings, newest first; following listings use the primary key
+CREATE INDEX IF NOT EXISTS follows_followee ON follows (followee, followed_at DESC);
@@ -0,0 +1,240 @@
+// server/edit.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// handleEditPost serves PATCH /api/posts/{id}. Only the author may edit a
+// post, and the version it replaces is kept in its revision history.
+func (s *Server) handleEditPost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Title   *string `json:"title"`
+			Content *string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Title == nil && req.Content == nil {
+			http.Error(w, "Nothing to edit", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		post, exists := s.posts[postID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if !checkEditable(w, r, post.AuthorName, post.Deleted) {
+			return
+		}
+
+		now := time.Now()
+		s.revisions[postID] = append(s.revisions[postID], models.Revision{
+			Title:     post.Title,
+			Content:   post.Content,
+			CreatedAt: lastWritten(post.CreatedAt, post.EditedAt),
+		})
+		if req.Title != nil {
+			post.Title = *req.Title
+		}
+		if req.Content != nil {
+			post.Content = *req.Content
+		}
+		post.EditedAt = &now
+		s.index.Add(postDocument(post))
+
+		writeJSON(w, post)
+	}
+}
+
+// handleDeletePost serves DELETE /api/posts/{id}. The post is left as a
+// tombstone so its comments stay reachable, and its revisions are dropped.
+func (s *Server) handleDeletePost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		post, exists := s.posts[postID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if !checkEditable(w, r, post.AuthorName, post.Deleted) {
+			return
+		}
+
+		now := time.Now()
+		post.Title, post.Content = models.DeletedContent, models.DeletedContent
+		post.Deleted, post.EditedAt = true, &now
+		delete(s.revisions, postID)
+		s.index.Remove(postID)
+
+		writeJSON(w, post)
+	}
+}
+
+// handleEditComment serves PATCH /api/comments/{id}. Only the author may edit
+// a comment, and the version it replaces is kept in its revision history.
+func (s *Server) handleEditComment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		comment, exists := s.comments[commentID]
+		if !exists {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		if !checkEditable(w, r, comment.AuthorName, comment.Deleted) {
+			return
+		}
+
+		now := time.Now()
+		s.revisions[commentID] = append(s.revisions[commentID], models.Revision{
+			Content:   comment.Content,
+			CreatedAt: lastWritten(comment.CreatedAt, comment.EditedAt),
+		})
+		comment.Content = req.Content
+		comment.EditedAt = &now
+		if post, exists := s.posts[comment.PostID]; exists {
+			s.index.Add(commentDocument(comment, post.SubredditName))
+		}
+
+		writeJSON(w, comment)
+	}
+}
+
+// handleDeleteComment serves DELETE /api/comments/{id}. The comment is left
+// as a tombstone so the replies under it stay in place.
+func (s *Server) handleDeleteComment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		comment, exists := s.comments[commentID]
+		if !exists {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		if !checkEditable(w, r, comment.AuthorName, comment.Deleted) {
+			return
+		}
+
+		now := time.Now()
+		comment.Content = models.DeletedContent
+		comment.Deleted, comment.EditedAt = true, &now
+		delete(s.revisions, commentID)
+		s.index.Remove(commentID)
+
+		writeJSON(w, comment)
+	}
+}
+
+// handleGetPostRevisions serves GET /api/posts/{id}/revisions, oldest first
+func (s *Server) handleGetPostRevisions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, exists := s.posts[postID]; !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, s.revisionsOf(postID))
+	}
+}
+
+// handleGetCommentRevisions serves GET /api/comments/{id}/revisions, oldest first
+func (s *Server) handleGetCommentRevisions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, exists := s.comments[commentID]; !exists {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, s.revisionsOf(commentID))
+	}
+}
+
+// revisionsOf returns the revisions of a post or comment, never nil
+func (s *Server) revisionsOf(id uuid.UUID) []models.Revision {
+	revisions := s.revisions[id]
+	if revisions == nil {
+		revisions = []models.Revision{}
+	}
+	return revisions
+}
+
+// checkEditable reports whether the requesting user may change content
+// written by author, writing the error response if not
+func checkEditable(w http.ResponseWriter, r *http.Request, author string, deleted bool) bool {
+	if r.Header.Get("X-User") != author {
+		http.Error(w, "Only the author can change this", http.StatusForbidden)
+		return false
+	}
+	if deleted {
+		http.Error(w, "Content has been deleted", http.StatusGone)
+		return false
+	}
+	return true
+}
+
+// lastWritten returns when the current version was written
+func lastWritten(created time.Time, edited *time.Time) time.Time {
+	if edited == nil {
+		return created
+	}
+	return *edited
+}
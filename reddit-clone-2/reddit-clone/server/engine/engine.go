@@ -76,6 +76,12 @@ func (e *RedditEngine) Vote(ctx context.Context, username string, targetID uuid.
 	return e.db.AddVote(vote)
 }
 
+// RecomputeKarma rebuilds every user's karma from the recorded votes and
+// returns the number of users whose karma was corrected
+func (e *RedditEngine) RecomputeKarma(ctx context.Context) (int64, error) {
+	return e.db.RecomputeKarma()
+}
+
 // Message operations
 func (e *RedditEngine) SendMessage(ctx context.Context, fromUser, toUser, content string) error {
 	message := &models.DirectMessage{
@@ -88,14 +94,42 @@ func (e *RedditEngine) SendMessage(ctx context.Context, fromUser, toUser, conten
 	return e.db.CreateMessage(message)
 }
 
+func (e *RedditEngine) JoinSubreddit(ctx context.Context, username, subredditName string) error {
+	return e.db.JoinSubreddit(subredditName, username, time.Now())
+}
+
+// Follow operations
+func (e *RedditEngine) FollowUser(ctx context.Context, follower, followee string) error {
+	return e.db.FollowUser(&models.Follow{Follower: follower, Followee: followee, FollowedAt: time.Now()})
+}
+
+func (e *RedditEngine) UnfollowUser(ctx context.Context, follower, followee string) error {
+	return e.db.UnfollowUser(follower, followee)
+}
+
+func (e *RedditEngine) GetFollowers(ctx context.Context, username string, offset, limit int) ([]models.Follow, error) {
+	return e.db.GetFollowers(username, offset, limit)
+}
+
+func (e *RedditEngine) GetFollowing(ctx context.Context, username string, offset, limit int) ([]models.Follow, error) {
+	return e.db.GetFollowing(username, offset, limit)
+}
+
+// Profile operations
+func (e *RedditEngine) GetProfile(ctx context.Context, username string) (*models.Profile, error) {
+	return e.db.GetProfile(username)
+}
+
+func (e *RedditEngine) GetUserPosts(ctx context.Context, username string, offset, limit int) ([]*models.Post, error) {
+	return e.db.GetUserPosts(username, offset, limit)
+}
+
+func (e *RedditEngine) GetUserComments(ctx context.Context, username string, offset, limit int) ([]*models.Comment, error) {
+	return e.db.GetUserComments(username, offset, limit)
+}
+
 // Feed operations
 func (e *RedditEngine) GetFeed(ctx context.Context, username string, offset, limit int) ([]*models.Post, error) {
-	// Get user's subscribed subreddits
-	user, err := e.db.GetUser(username)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get posts from subscribed subreddits
-	return e.db.GetFeedPosts(user.Subreddits, offset, limit)
+	// Posts from subscribed subreddits and followed users
+	return e.db.GetFeedPosts(username, offset, limit)
 }
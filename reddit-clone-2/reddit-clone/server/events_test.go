@@ -0,0 +1,69 @@
+// server/events_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/events"
+	"testing"
+)
+
+func TestJoinSubredditEvents(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice", "troll")
+	ts.createSubreddit("golang", "owner")
+	ts.must("POST", "/api/subreddits/golang/bans", "owner", map[string]string{"username": "troll"}, nil)
+	var joined []events.UserJoined
+	ts.events.Subscribe(func(event events.Event) { joined = append(joined, event.(events.UserJoined)) }, events.TypeUserJoined)
+
+	for _, tt := range []struct {
+		name, subreddit, user string
+		want                  int
+	}{
+		{"anonymously", "golang", "", http.StatusUnauthorized},
+		{"an unknown subreddit", "rust", "alice", http.StatusNotFound},
+		{"as an unknown user", "golang", "nobody", http.StatusNotFound},
+		{"while banned", "golang", "troll", http.StatusForbidden},
+	} {
+		if code := ts.do("POST", "/api/subreddits/"+tt.subreddit+"/join", tt.user, nil, nil); code != tt.want {
+			t.Errorf("join %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	if len(joined) != 0 {
+		t.Errorf("refused joins published %+v", joined)
+	}
+	if _, exists := ts.subreddits["rust"]; exists {
+		t.Error("joining an unknown subreddit created it")
+	}
+
+	ts.must("POST", "/api/subreddits/golang/join", "alice", nil, nil)
+	if len(joined) != 1 || joined[0].Username != "alice" || joined[0].Subreddit != "golang" {
+		t.Errorf("got %+v, want alice joining golang", joined)
+	}
+	if subreddits := ts.users["alice"].Subreddits; len(subreddits) != 1 || subreddits[0] != "golang" {
+		t.Errorf("alice is subscribed to %v, want golang", subreddits)
+	}
+}
+
+func TestHandlersPublishEvents(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	ts.createSubreddit("golang", "alice")
+	var published []events.Type
+	ts.events.Subscribe(func(event events.Event) { published = append(published, event.Type()) })
+
+	post := ts.createPost("golang", "alice", "Hello")
+	comment := ts.createComment(post.ID, nil, "bob", "Hi")
+	ts.votePost(post, "bob", true)
+	ts.must("POST", "/api/comments/"+comment.ID.String()+"/vote", "alice", map[string]bool{"is_upvote": true}, nil)
+	ts.must("POST", "/api/messages", "alice", map[string]string{"to_user": "bob", "content": "Hello"}, nil)
+
+	want := []events.Type{events.TypePostCreated, events.TypeCommentCreated, events.TypeVoteCast, events.TypeVoteCast, events.TypeMessageSent}
+	if len(published) != len(want) {
+		t.Fatalf("published %v, want %v", published, want)
+	}
+	for i := range want {
+		if published[i] != want[i] {
+			t.Errorf("event %d is %s, want %s", i, published[i], want[i])
+		}
+	}
+}
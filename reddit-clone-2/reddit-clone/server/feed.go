@@ -0,0 +1,247 @@
+// server/feed.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"reddit-clone/ranking"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageLimit = 25
+	maxPageLimit     = 100
+)
+
+// listing holds the paging parameters of a list request
+type listing struct {
+	scope string // Listing the cursors belong to
+	after *pagination.Cursor
+	limit int
+}
+
+// parseListing reads the cursor and limit query parameters of a listing
+func parseListing(query url.Values, scope string) (listing, error) {
+	l := listing{scope: scope, limit: defaultPageLimit}
+	if limitParam := query.Get("limit"); limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			return l, fmt.Errorf("invalid limit: %s", limitParam)
+		}
+		l.limit = limit
+	}
+	if l.limit > maxPageLimit {
+		l.limit = maxPageLimit
+	}
+
+	var err error
+	l.after, err = pagination.Decode(query.Get("cursor"), scope)
+	return l, err
+}
+
+// parseSortedListing reads the sort, t, cursor and limit query parameters of
+// a post listing. Cursors are only valid for the sort they were issued with.
+func parseSortedListing(query url.Values, defaultMode ranking.SortMode, scope string) (ranking.SortMode, ranking.TimeWindow, listing, error) {
+	mode := defaultMode
+	if query.Get("sort") != "" {
+		var err error
+		if mode, err = ranking.ParseSortMode(query.Get("sort")); err != nil {
+			return "", "", listing{}, err
+		}
+	}
+	window, err := ranking.ParseTimeWindow(query.Get("t"))
+	if err != nil {
+		return "", "", listing{}, err
+	}
+	l, err := parseListing(query, fmt.Sprintf("%s:%s:%s", scope, mode, window))
+	return mode, window, l, err
+}
+
+// pageOf returns the page of items the listing asks for, and the cursor of
+// the next page, empty on the last one
+func pageOf[T any](l listing, items []T, position func(T) (pagination.Position, bool)) ([]T, string) {
+	page, next := pagination.Page(items, l.after, l.scope, l.limit, pagination.Now(l.after), position)
+	if next == nil {
+		return page, ""
+	}
+	return page, next.Encode()
+}
+
+// rankedPageOf is pageOf for listings ranked by keys that change between
+// pages, such as vote scores. Their order is frozen in orders for viewer at
+// the first page, so a cursor fails once its order has been dropped.
+func rankedPageOf[T any](orders *pagination.Orders, viewer string, l listing, items []T, position func(T) (pagination.Position, bool)) ([]T, string, error) {
+	page, next, err := pagination.PageFrozen(orders, viewer, items, l.after, l.scope, l.limit, pagination.Now(l.after), position)
+	if err != nil || next == nil {
+		return page, "", err
+	}
+	return page, next.Encode(), nil
+}
+
+// postPage sorts posts by mode and returns the page the listing asks viewer
+// for. Must be called with s.mu held.
+func (s *Server) postPage(posts []*models.Post, viewer string, mode ranking.SortMode, window ranking.TimeWindow, l listing) (models.FeedResponse, error) {
+	position := ranking.PostPosition(mode, window, pagination.Now(l.after))
+	var page []*models.Post
+	var next string
+	if mode == ranking.SortNew {
+		page, next = pageOf(l, posts, position)
+	} else {
+		var err error
+		if page, next, err = rankedPageOf(s.orders, viewer, l, posts, position); err != nil {
+			return models.FeedResponse{}, err
+		}
+	}
+	response := models.FeedResponse{Posts: []models.Post{}, NextCursor: next, HasMore: next != ""}
+	for _, post := range page {
+		response.Posts = append(response.Posts, *post)
+	}
+	return response, nil
+}
+
+func writeJSON(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetFeed serves GET /api/feed?sort=hot|top|new|controversial|rising&t=hour|day|week|all.
+// Signed-in users with subscriptions or follows see their subreddits and the users they
+// follow, everyone else sees every post. Posts the viewer hid or by users they blocked are left out.
+func (s *Server) handleGetFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.Header.Get("X-User")
+		mode, window, l, err := parseSortedListing(r.URL.Query(), ranking.SortHot, "feed:"+username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		var candidates []*models.Post
+		user := s.users[username]
+		following := s.follows[username]
+		personal := user != nil && (len(user.Subreddits) > 0 || len(following) > 0)
+		for _, post := range s.posts {
+			if !s.postVisible(post, username) || s.blocked(username, post.AuthorName) || s.isHidden(username, post.ID) {
+				continue
+			}
+			_, followed := following[post.AuthorName]
+			if !personal || followed || isSubscribed(user, post.SubredditName) {
+				candidates = append(candidates, post)
+			}
+		}
+		response, err := s.postPage(candidates, username, mode, window, l)
+		s.mu.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// handleGetSubredditPosts serves GET /api/subreddits/{name}/posts with the same sorting as the feed
+func (s *Server) handleGetSubredditPosts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		mode, window, l, err := parseSortedListing(r.URL.Query(), ranking.SortHot, "r/"+name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewer := r.Header.Get("X-User")
+		s.mu.RLock()
+		var candidates []*models.Post
+		for _, post := range s.posts {
+			if post.SubredditName == name && s.postVisible(post, viewer) && !s.blocked(viewer, post.AuthorName) && !s.isHidden(viewer, post.ID) {
+				candidates = append(candidates, post)
+			}
+		}
+		response, err := s.postPage(candidates, viewer, mode, window, l)
+		s.mu.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// handleGetUserPosts serves GET /api/users/{username}/posts, newest first unless sorted otherwise
+func (s *Server) handleGetUserPosts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+		mode, window, l, err := parseSortedListing(r.URL.Query(), ranking.SortNew, "u/"+username+"/posts")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewer := r.Header.Get("X-User")
+		s.mu.RLock()
+		var candidates []*models.Post
+		for _, post := range s.posts {
+			if post.AuthorName == username && s.postVisible(post, viewer) {
+				candidates = append(candidates, post)
+			}
+		}
+		response, err := s.postPage(candidates, viewer, mode, window, l)
+		s.mu.RUnlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// handleGetUserComments serves GET /api/users/{username}/comments, newest first
+func (s *Server) handleGetUserComments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+		l, err := parseListing(r.URL.Query(), "u/"+username+"/comments")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		viewer := r.Header.Get("X-User")
+		s.mu.RLock()
+		var candidates []*models.Comment
+		for _, comment := range s.comments {
+			if comment.AuthorName == username && s.commentVisible(comment, viewer) {
+				candidates = append(candidates, comment)
+			}
+		}
+		page, next := pageOf(l, candidates, func(comment *models.Comment) (pagination.Position, bool) {
+			return pagination.Position{Time: comment.CreatedAt, ID: comment.ID.String()}, true
+		})
+		response := models.CommentListResponse{Comments: []models.Comment{}, NextCursor: next, HasMore: next != ""}
+		for _, comment := range page {
+			response.Comments = append(response.Comments, *comment)
+		}
+		s.mu.RUnlock()
+
+		writeJSON(w, response)
+	}
+}
+
+// isSubscribed reports whether the user has joined the subreddit
+func isSubscribed(user *models.User, subreddit string) bool {
+	for _, name := range user.Subreddits {
+		if name == subreddit {
+			return true
+		}
+	}
+	return false
+}
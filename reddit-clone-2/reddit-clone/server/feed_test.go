@@ -0,0 +1,118 @@
+// server/feed_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+)
+
+func titles(posts []models.Post) []string {
+	names := []string{}
+	for _, post := range posts {
+		names = append(names, post.Title)
+	}
+	return names
+}
+
+// votePost votes on a post as user, up or down
+func (ts *testServer) votePost(post models.Post, user string, up bool) {
+	ts.t.Helper()
+	ts.must("POST", "/api/posts/"+post.ID.String()+"/vote", user, map[string]bool{"is_upvote": up}, nil)
+}
+
+// seedFeed posts old, mid and new in r/golang and rusty in r/rust, in that
+// order, voted so that mid tops them and rusty comes last
+func seedFeed(ts *testServer) {
+	ts.t.Helper()
+	ts.register("alice", "bob", "carol", "reader")
+	ts.createSubreddit("golang", "alice")
+	ts.createSubreddit("rust", "bob")
+	old := ts.createPost("golang", "alice", "old")
+	mid := ts.createPost("golang", "alice", "mid")
+	ts.createPost("golang", "alice", "new")
+	rusty := ts.createPost("rust", "bob", "rusty")
+	ts.votePost(mid, "bob", true)
+	ts.votePost(mid, "carol", true)
+	ts.votePost(old, "carol", true)
+	ts.votePost(rusty, "carol", false)
+}
+
+func TestGetFeedSorting(t *testing.T) {
+	ts := newTestServer(t)
+	seedFeed(ts)
+
+	tests := []struct {
+		path, viewer string
+		want         []string
+	}{
+		{"/api/feed?sort=new", "", []string{"rusty", "new", "mid", "old"}},
+		{"/api/feed?sort=top", "", []string{"mid", "old", "new", "rusty"}},
+		{"/api/feed?sort=top&t=hour", "", []string{"mid", "old", "new", "rusty"}},
+		{"/api/subreddits/golang/posts?sort=new", "", []string{"new", "mid", "old"}},
+		{"/api/subreddits/golang/posts?sort=top", "", []string{"mid", "old", "new"}},
+		{"/api/users/alice/posts", "", []string{"new", "mid", "old"}},
+		{"/api/users/alice/posts?sort=top", "", []string{"mid", "old", "new"}},
+	}
+	for _, tt := range tests {
+		var feed models.FeedResponse
+		ts.must("GET", tt.path, tt.viewer, nil, &feed)
+		if got := titles(feed.Posts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	for _, sort := range []string{"hot", "controversial", "rising"} {
+		var feed models.FeedResponse
+		ts.must("GET", "/api/feed?sort="+sort, "", nil, &feed)
+		if len(feed.Posts) != 4 {
+			t.Errorf("sort=%s: got %v, want all four posts", sort, titles(feed.Posts))
+		}
+	}
+	for _, path := range []string{"/api/feed?sort=best", "/api/feed?sort=top&t=decade", "/api/subreddits/golang/posts?sort=best", "/api/feed?limit=0"} {
+		if code := ts.do("GET", path, "", nil, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: got %d, want %d", path, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetFeedSubscriptions(t *testing.T) {
+	ts := newTestServer(t)
+	seedFeed(ts)
+
+	// Without subscriptions the feed holds every post
+	var feed models.FeedResponse
+	ts.must("GET", "/api/feed?sort=new", "reader", nil, &feed)
+	if got := titles(feed.Posts); len(got) != 4 {
+		t.Errorf("feed without subscriptions is %v, want every post", got)
+	}
+	ts.must("POST", "/api/subreddits/rust/join", "reader", nil, nil)
+	ts.must("GET", "/api/feed?sort=new", "reader", nil, &feed)
+	if got := titles(feed.Posts); !reflect.DeepEqual(got, []string{"rusty"}) {
+		t.Errorf("feed of a r/rust member is %v, want [rusty]", got)
+	}
+}
+
+func TestGetFeedPages(t *testing.T) {
+	ts := newTestServer(t)
+	seedFeed(ts)
+
+	var served []string
+	path := "/api/feed?sort=top&limit=3"
+	for {
+		var feed models.FeedResponse
+		ts.must("GET", path, "", nil, &feed)
+		served = append(served, titles(feed.Posts)...)
+		if !feed.HasMore {
+			break
+		}
+		path = "/api/feed?sort=top&limit=3&cursor=" + feed.NextCursor
+	}
+	if !reflect.DeepEqual(served, []string{"mid", "old", "new", "rusty"}) {
+		t.Errorf("pages served %v, want mid, old, new and rusty", served)
+	}
+	if code := ts.do("GET", "/api/feed?sort=new&cursor=not-a-cursor", "", nil, nil); code != http.StatusBadRequest {
+		t.Errorf("malformed cursor: got %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,163 @@
+// server/karma.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// tally is a post or comment's vote counters
+type tally struct {
+	upvotes, downvotes, score *int
+}
+
+// castVote moves a user's vote on a post or comment to direction (1, -1, or
+// 0 to retract) and applies the difference to its counters and its author's
+// karma. Voting earns the voter no karma, so votes on one's own content leave
+// karma unchanged. It reports whether the user's vote changed. Must be called
+// with s.mu held for writing.
+func (s *Server) castVote(username string, targetID uuid.UUID, author string, comment bool, counts tally, direction int) bool {
+	previous := s.votes[targetID][username]
+	if previous == direction {
+		return false
+	}
+
+	switch previous {
+	case 1:
+		*counts.upvotes--
+	case -1:
+		*counts.downvotes--
+	}
+	switch direction {
+	case 1:
+		*counts.upvotes++
+	case -1:
+		*counts.downvotes++
+	}
+	*counts.score += direction - previous
+
+	if direction == 0 {
+		delete(s.votes[targetID], username)
+	} else {
+		if s.votes[targetID] == nil {
+			s.votes[targetID] = make(map[string]int)
+		}
+		s.votes[targetID][username] = direction
+	}
+
+	if user, exists := s.users[author]; exists && author != username {
+		addKarma(user, direction-previous, comment)
+	}
+	return true
+}
+
+// voteDirection returns the ledger value of an up or down vote
+func voteDirection(upvote bool) int {
+	if upvote {
+		return 1
+	}
+	return -1
+}
+
+// addKarma adjusts a user's post or comment karma, and their total
+func addKarma(user *models.User, delta int, comment bool) {
+	if comment {
+		user.CommentKarma += delta
+	} else {
+		user.PostKarma += delta
+	}
+	user.Karma += delta
+}
+
+// recomputeKarma rebuilds every user's karma from the vote ledger, repairing
+// any drift from it, and returns the number of users whose karma was
+// corrected. Must be called with s.mu held for writing.
+func (s *Server) recomputeKarma() int {
+	earned := make(map[string]*models.User)
+	for targetID, votes := range s.votes {
+		var author string
+		comment := false
+		if post, exists := s.posts[targetID]; exists {
+			author = post.AuthorName
+		} else if c, exists := s.comments[targetID]; exists {
+			author, comment = c.AuthorName, true
+		} else {
+			continue
+		}
+		if earned[author] == nil {
+			earned[author] = &models.User{}
+		}
+		for username, direction := range votes {
+			if username != author {
+				addKarma(earned[author], direction, comment)
+			}
+		}
+	}
+
+	corrected := 0
+	for username, user := range s.users {
+		want := models.User{}
+		if k, exists := earned[username]; exists {
+			want = *k
+		}
+		if user.PostKarma != want.PostKarma || user.CommentKarma != want.CommentKarma || user.Karma != want.Karma {
+			user.PostKarma, user.CommentKarma, user.Karma = want.PostKarma, want.CommentKarma, want.Karma
+			corrected++
+		}
+	}
+	return corrected
+}
+
+// handleRetractVote serves DELETE /api/posts/{id}/vote, or
+// /api/comments/{id}/vote when comment is true, which removes the signed-in
+// user's vote
+func (s *Server) handleRetractVote(comment bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		targetID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if comment {
+			target, exists := s.comments[targetID]
+			if !exists {
+				http.Error(w, "Comment not found", http.StatusNotFound)
+				return
+			}
+			s.castVote(username, targetID, target.AuthorName, true, tally{&target.Upvotes, &target.Downvotes, &target.Score}, 0)
+			writeJSON(w, target)
+			return
+		}
+		target, exists := s.posts[targetID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		s.castVote(username, targetID, target.AuthorName, false, tally{&target.Upvotes, &target.Downvotes, &target.Score}, 0)
+		writeJSON(w, target)
+	}
+}
+
+// handleRecomputeKarma serves POST /api/karma/recompute, which rebuilds every
+// user's karma from the vote ledger
+func (s *Server) handleRecomputeKarma() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireUser(w, r); !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		writeJSON(w, map[string]int{"corrected": s.recomputeKarma()})
+	}
+}
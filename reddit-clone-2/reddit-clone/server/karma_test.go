@@ -0,0 +1,101 @@
+// server/karma_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRetractVote(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Hello")
+	comment := ts.createComment(post.ID, nil, "alice", "First")
+	commentPath := "/api/comments/" + comment.ID.String() + "/vote"
+
+	ts.votePost(post, "bob", true)
+	ts.votePost(post, "carol", true)
+	ts.votePost(post, "alice", true)
+	ts.must("POST", commentPath, "bob", map[string]bool{"is_upvote": false}, nil)
+	if alice := ts.users["alice"]; alice.PostKarma != 2 || alice.CommentKarma != -1 || alice.Karma != 1 {
+		t.Errorf("alice has karma %d (post %d, comment %d), want 1 (2, -1)", alice.Karma, alice.PostKarma, alice.CommentKarma)
+	}
+
+	var retracted models.Post
+	ts.must("DELETE", "/api/posts/"+post.ID.String()+"/vote", "bob", nil, &retracted)
+	if retracted.Upvotes != 2 || retracted.Score != 2 {
+		t.Errorf("post has %d upvotes, score %d after bob retracted, want 2 and 2", retracted.Upvotes, retracted.Score)
+	}
+	var again models.Post
+	ts.must("DELETE", "/api/posts/"+post.ID.String()+"/vote", "bob", nil, &again)
+	if again.Score != 2 {
+		t.Errorf("retracting twice moved the score to %d", again.Score)
+	}
+	// Retracting a vote on your own content leaves your karma alone
+	ts.must("DELETE", "/api/posts/"+post.ID.String()+"/vote", "alice", nil, nil)
+	var uncounted models.Comment
+	ts.must("DELETE", commentPath, "bob", nil, &uncounted)
+	if uncounted.Downvotes != 0 || uncounted.Score != 0 {
+		t.Errorf("comment has %d downvotes, score %d after bob retracted, want 0 and 0", uncounted.Downvotes, uncounted.Score)
+	}
+	if alice := ts.users["alice"]; alice.PostKarma != 1 || alice.CommentKarma != 0 || alice.Karma != 1 {
+		t.Errorf("alice has karma %d (post %d, comment %d) after the retractions, want 1 (1, 0)", alice.Karma, alice.PostKarma, alice.CommentKarma)
+	}
+
+	for _, tt := range []struct {
+		name, path, user string
+		want             int
+	}{
+		{"anonymously", commentPath, "", http.StatusUnauthorized},
+		{"with a bad ID", "/api/posts/not-an-id/vote", "bob", http.StatusBadRequest},
+		{"on an unknown post", "/api/posts/" + uuid.New().String() + "/vote", "bob", http.StatusNotFound},
+		{"on an unknown comment", "/api/comments/" + uuid.New().String() + "/vote", "bob", http.StatusNotFound},
+	} {
+		if code := ts.do("DELETE", tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("retract %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestRecomputeKarma(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Hello")
+	comment := ts.createComment(post.ID, nil, "bob", "Hi")
+	ts.votePost(post, "bob", true)
+	ts.votePost(post, "carol", false)
+	ts.votePost(post, "carol", true)
+	ts.must("POST", "/api/comments/"+comment.ID.String()+"/vote", "alice", map[string]bool{"is_upvote": true}, nil)
+	ts.must("POST", "/api/comments/"+comment.ID.String()+"/vote", "bob", map[string]bool{"is_upvote": true}, nil)
+
+	var result map[string]int
+	ts.must("POST", "/api/karma/recompute", "alice", nil, &result)
+	if result["corrected"] != 0 {
+		t.Errorf("recompute corrected %d users whose karma matched the ledger", result["corrected"])
+	}
+
+	ts.users["alice"].PostKarma, ts.users["alice"].Karma = 40, 40
+	ts.users["carol"].CommentKarma, ts.users["carol"].Karma = 3, 3
+	ts.must("POST", "/api/karma/recompute", "alice", nil, &result)
+	if result["corrected"] != 2 {
+		t.Errorf("recompute corrected %d users, want alice and carol", result["corrected"])
+	}
+	for username, want := range map[string]models.User{
+		"alice": {Karma: 2, PostKarma: 2},
+		"bob":   {Karma: 1, CommentKarma: 1},
+		"carol": {},
+	} {
+		user := ts.users[username]
+		if user.Karma != want.Karma || user.PostKarma != want.PostKarma || user.CommentKarma != want.CommentKarma {
+			t.Errorf("%s has karma %d (post %d, comment %d), want %d (%d, %d)", username, user.Karma, user.PostKarma, user.CommentKarma, want.Karma, want.PostKarma, want.CommentKarma)
+		}
+	}
+	if code := ts.do("POST", "/api/karma/recompute", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous recompute: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"reddit-clone/events"
 	"reddit-clone/models"
-	"sort"
-	"strings"
+	"reddit-clone/moderation"
+	"reddit-clone/pagination"
+	"reddit-clone/search"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,12 +21,30 @@ import (
 )
 
 type Server struct {
-	router   *mux.Router
-	posts    map[uuid.UUID]*models.Post
-	comments map[uuid.UUID]*models.Comment
-	messages map[uuid.UUID]*models.DirectMessage
-	users    map[string]*models.User
-	hub      *Hub
+	router     *mux.Router
+	posts      map[uuid.UUID]*models.Post
+	comments   map[uuid.UUID]*models.Comment
+	messages   map[uuid.UUID]*models.DirectMessage
+	users      map[string]*models.User
+	subreddits map[string]*models.Subreddit
+	moderators map[string]*moderation.Roster      // Mod team of each subreddit
+	reports    map[uuid.UUID][]models.Report      // Reports on posts and comments until approved
+	bans       map[string]*moderation.BanList     // Users banned from each subreddit
+	mutes      map[string]*moderation.BanList     // Users muted in each subreddit
+	automod    map[string]*moderation.RuleSet     // AutoModerator rules of each subreddit that has some
+	revisions  map[uuid.UUID][]models.Revision    // Earlier versions of edited posts and comments
+	inboxes    map[string][]*models.Notification  // Notifications of each user, oldest first
+	blocks     map[string]map[string]time.Time    // Users each user has blocked, and when
+	follows    map[string]map[string]time.Time    // Users each user follows, and since when
+	saved      map[string]map[uuid.UUID]time.Time // Posts and comments each user saved, and when
+	hidden     map[string]map[uuid.UUID]time.Time // Posts each user hid, and when
+	votes      map[uuid.UUID]map[string]int       // Each user's vote on each post and comment: 1 up, -1 down
+	presence   map[string]*presence               // Connections, last-seen time and queued deliveries of each user
+	index      *search.Index                      // Posts and comments by the words in them, except deleted ones
+	orders     *pagination.Orders                 // Frozen orders of the ranked listings being paged through
+	events     *events.Bus                        // Handlers publish to it while holding mu
+	hub        *Hub
+	mu         sync.RWMutex // Guards the maps above and the values they hold
 }
 
 func NewServer() *Server {
@@ -31,28 +52,57 @@ func NewServer() *Server {
 	go hub.run()
 
 	s := &Server{
-		router:   mux.NewRouter(),
-		posts:    make(map[uuid.UUID]*models.Post),
-		comments: make(map[uuid.UUID]*models.Comment),
-		messages: make(map[uuid.UUID]*models.DirectMessage),
-		users:    make(map[string]*models.User),
-		hub:      hub,
+		router:     mux.NewRouter(),
+		posts:      make(map[uuid.UUID]*models.Post),
+		comments:   make(map[uuid.UUID]*models.Comment),
+		messages:   make(map[uuid.UUID]*models.DirectMessage),
+		users:      make(map[string]*models.User),
+		subreddits: make(map[string]*models.Subreddit),
+		moderators: make(map[string]*moderation.Roster),
+		reports:    make(map[uuid.UUID][]models.Report),
+		bans:       make(map[string]*moderation.BanList),
+		mutes:      make(map[string]*moderation.BanList),
+		automod:    make(map[string]*moderation.RuleSet),
+		revisions:  make(map[uuid.UUID][]models.Revision),
+		inboxes:    make(map[string][]*models.Notification),
+		blocks:     make(map[string]map[string]time.Time),
+		follows:    make(map[string]map[string]time.Time),
+		saved:      make(map[string]map[uuid.UUID]time.Time),
+		hidden:     make(map[string]map[uuid.UUID]time.Time),
+		votes:      make(map[uuid.UUID]map[string]int),
+		presence:   make(map[string]*presence),
+		index:      search.NewIndex(),
+		orders:     new(pagination.Orders),
+		events:     events.NewBus(),
+		hub:        hub,
 	}
+	s.events.Subscribe(s.notify, events.TypePostCreated, events.TypeCommentCreated)
+	s.events.Subscribe(s.pushMessage, events.TypeMessageSent)
+	s.events.Subscribe(s.indexContent, events.TypePostCreated, events.TypeCommentCreated)
 	s.routes()
 	return s
 }
 
 type Hub struct {
 	clients    map[*Client]bool
+	userMap    map[string]map[*Client]bool // Open connections of each signed-in user
 	broadcast  chan []byte
+	direct     chan delivery
 	register   chan *Client
 	unregister chan *Client
 }
 
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub      *Hub
+	conn     *websocket.Conn
+	send     chan []byte
+	username string // Empty for anonymous connections
+}
+
+// delivery is a message for the connection of a single user
+type delivery struct {
+	username string
+	message  []byte
 }
 
 var upgrader = websocket.Upgrader{
@@ -66,9 +116,11 @@ var upgrader = websocket.Upgrader{
 func newHub() *Hub {
 	return &Hub{
 		broadcast:  make(chan []byte),
+		direct:     make(chan delivery),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		userMap:    make(map[string]map[*Client]bool),
 	}
 }
 
@@ -83,24 +135,54 @@ func (h *Hub) run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+			if client.username != "" {
+				if h.userMap[client.username] == nil {
+					h.userMap[client.username] = make(map[*Client]bool)
+				}
+				h.userMap[client.username][client] = true
+			}
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.remove(client)
 			}
 		case message := <-h.broadcast:
 			for client := range h.clients {
 				select {
 				case client.send <- message:
 				default:
-					close(client.send)
-					delete(h.clients, client)
+					h.remove(client)
+				}
+			}
+		case d := <-h.direct:
+			for client := range h.userMap[d.username] {
+				select {
+				case client.send <- d.message:
+				default:
+					h.remove(client)
 				}
 			}
 		}
 	}
 }
 
+// remove drops a client and closes its send channel. Must only be called
+// from run.
+func (h *Hub) remove(client *Client) {
+	delete(h.clients, client)
+	if connections := h.userMap[client.username]; connections != nil {
+		delete(connections, client)
+		if len(connections) == 0 {
+			delete(h.userMap, client.username)
+		}
+	}
+	close(client.send)
+}
+
+// sendTo delivers a message to every connection of a user
+func (h *Hub) sendTo(username string, message []byte) {
+	h.direct <- delivery{username: username, message: message}
+}
+
 func (s *Server) routes() {
 	// Auth routes
 	s.router.HandleFunc("/api/register", s.handleRegister()).Methods("POST")
@@ -109,16 +191,87 @@ func (s *Server) routes() {
 	// Subreddit routes
 	s.router.HandleFunc("/api/subreddits", s.handleCreateSubreddit()).Methods("POST")
 	s.router.HandleFunc("/api/subreddits/{name}/join", s.handleJoinSubreddit()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/posts", s.handleGetSubredditPosts()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}", s.handleGetSubreddit()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}", s.handleUpdateSubreddit()).Methods("PATCH")
+
+	// Moderator routes
+	s.router.HandleFunc("/api/subreddits/{name}/moderators", s.handleGetModerators()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/invite", s.handleInviteModerator()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/invite/{username}", s.handleRevokeModeratorInvite()).Methods("DELETE")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/accept", s.handleAcceptModeratorInvite()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/resign", s.handleResignModerator()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/transfer", s.handleTransferOwnership()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/{username}", s.handleSetModeratorPermissions()).Methods("PUT")
+	s.router.HandleFunc("/api/subreddits/{name}/moderators/{username}", s.handleRemoveModerator()).Methods("DELETE")
+	s.router.HandleFunc("/api/subreddits/{name}/modqueue", s.handleGetModQueue()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}/bans", s.handleGetBans()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}/bans", s.handleBanUser()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/bans/{username}", s.handleUnbanUser()).Methods("DELETE")
+	s.router.HandleFunc("/api/subreddits/{name}/mutes", s.handleGetMutes()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}/mutes", s.handleMuteUser()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/mutes/{username}", s.handleUnmuteUser()).Methods("DELETE")
+	s.router.HandleFunc("/api/subreddits/{name}/modmail", s.handleSendModmail()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/automod", s.handleGetAutoModRules()).Methods("GET")
+	s.router.HandleFunc("/api/subreddits/{name}/automod", s.handleSetAutoModRules()).Methods("PUT")
+	s.router.HandleFunc("/api/subreddits/{name}/automod/validate", s.handleValidateAutoModRules()).Methods("POST")
+	s.router.HandleFunc("/api/subreddits/{name}/automod/dryrun", s.handleDryRunAutoMod()).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/report", s.handleReportPost()).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/moderate", s.handleModeratePost()).Methods("POST")
+	s.router.HandleFunc("/api/comments/{id}/report", s.handleReportComment()).Methods("POST")
+	s.router.HandleFunc("/api/comments/{id}/moderate", s.handleModerateComment()).Methods("POST")
+
+	// User profile routes
+	s.router.HandleFunc("/api/users/{username}", s.handleGetProfile()).Methods("GET")
+	s.router.HandleFunc("/api/users/{username}/posts", s.handleGetUserPosts()).Methods("GET")
+	s.router.HandleFunc("/api/users/{username}/comments", s.handleGetUserComments()).Methods("GET")
+	s.router.HandleFunc("/api/users/{username}/follow", s.handleFollowUser()).Methods("POST")
+	s.router.HandleFunc("/api/users/{username}/follow", s.handleUnfollowUser()).Methods("DELETE")
+	s.router.HandleFunc("/api/users/{username}/followers", s.handleGetFollows(true)).Methods("GET")
+	s.router.HandleFunc("/api/users/{username}/following", s.handleGetFollows(false)).Methods("GET")
+	s.router.HandleFunc("/api/users/{username}/presence", s.handleGetPresence()).Methods("GET")
 
 	// Post routes
 	s.router.HandleFunc("/api/posts", s.handleCreatePost()).Methods("POST")
 	s.router.HandleFunc("/api/posts/{id}", s.handleGetPost()).Methods("GET")
+	s.router.HandleFunc("/api/posts/{id}", s.handleEditPost()).Methods("PATCH")
+	s.router.HandleFunc("/api/posts/{id}", s.handleDeletePost()).Methods("DELETE")
+	s.router.HandleFunc("/api/posts/{id}/revisions", s.handleGetPostRevisions()).Methods("GET")
 	s.router.HandleFunc("/api/posts/{id}/vote", s.handleVotePost()).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/vote", s.handleRetractVote(false)).Methods("DELETE")
+	s.router.HandleFunc("/api/posts/{id}/save", s.handleSavePost(true)).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/save", s.handleSavePost(false)).Methods("DELETE")
+	s.router.HandleFunc("/api/posts/{id}/hide", s.handleHidePost(true)).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/hide", s.handleHidePost(false)).Methods("DELETE")
+	s.router.HandleFunc("/api/posts/{id}/crosspost", s.handleCrosspost()).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/crossposts", s.handleGetCrossposts()).Methods("GET")
 
 	// Comment routes
 	s.router.HandleFunc("/api/posts/{id}/comments", s.handleCreateComment()).Methods("POST")
+	s.router.HandleFunc("/api/posts/{id}/comments", s.handleGetComments()).Methods("GET")
 	s.router.HandleFunc("/api/comments/{id}", s.handleGetComment()).Methods("GET")
+	s.router.HandleFunc("/api/comments/{id}", s.handleEditComment()).Methods("PATCH")
+	s.router.HandleFunc("/api/comments/{id}", s.handleDeleteComment()).Methods("DELETE")
+	s.router.HandleFunc("/api/comments/{id}/revisions", s.handleGetCommentRevisions()).Methods("GET")
 	s.router.HandleFunc("/api/comments/{id}/vote", s.handleVoteComment()).Methods("POST")
+	s.router.HandleFunc("/api/comments/{id}/vote", s.handleRetractVote(true)).Methods("DELETE")
+	s.router.HandleFunc("/api/comments/{id}/save", s.handleSaveComment(true)).Methods("POST")
+	s.router.HandleFunc("/api/comments/{id}/save", s.handleSaveComment(false)).Methods("DELETE")
+
+	// Saved and hidden list routes
+	s.router.HandleFunc("/api/saved", s.handleGetSaved()).Methods("GET")
+	s.router.HandleFunc("/api/hidden", s.handleGetHidden()).Methods("GET")
+
+	// Notification routes
+	s.router.HandleFunc("/api/notifications", s.handleGetNotifications()).Methods("GET")
+	s.router.HandleFunc("/api/notifications/read", s.handleMarkAllNotificationsRead()).Methods("POST")
+	s.router.HandleFunc("/api/notifications/{id}/read", s.handleMarkNotification(true)).Methods("POST")
+	s.router.HandleFunc("/api/notifications/{id}/unread", s.handleMarkNotification(false)).Methods("POST")
+
+	// Block list routes
+	s.router.HandleFunc("/api/blocks", s.handleGetBlocks()).Methods("GET")
+	s.router.HandleFunc("/api/blocks", s.handleBlockUser()).Methods("POST")
+	s.router.HandleFunc("/api/blocks/{username}", s.handleUnblockUser()).Methods("DELETE")
 
 	// WebSocket
 	s.router.HandleFunc("/ws", s.handleWebSocket())
@@ -128,43 +281,18 @@ func (s *Server) routes() {
 
 	s.router.HandleFunc("/api/messages", s.handleGetMessages()).Methods("GET")
 	s.router.HandleFunc("/api/messages", s.handleSendMessage()).Methods("POST")
+	s.router.HandleFunc("/api/messages/conversations", s.handleGetConversations()).Methods("GET")
+	s.router.HandleFunc("/api/messages/conversations/{username}", s.handleGetConversation()).Methods("GET")
+	s.router.HandleFunc("/api/messages/conversations/{username}/read", s.handleMarkConversationRead()).Methods("POST")
+	s.router.HandleFunc("/api/messages/{id}/read", s.handleMarkMessageRead()).Methods("POST")
+	s.router.HandleFunc("/api/messages/{id}/thread", s.handleGetMessageThread()).Methods("GET")
 
+	s.router.HandleFunc("/api/karma/recompute", s.handleRecomputeKarma()).Methods("POST")
 	s.router.HandleFunc("/api/search", s.handleSearch()).Methods("GET")
+	s.router.HandleFunc("/api/feed", s.handleGetFeed()).Methods("GET")
 
 }
 
-func (s *Server) handleSearch() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get search query from URL parameters
-		query := r.URL.Query().Get("q")
-		if query == "" {
-			http.Error(w, "Search query is required", http.StatusBadRequest)
-			return
-		}
-
-		query = strings.ToLower(query)
-		var results []*models.Post
-
-		// Search through all posts
-		for _, post := range s.posts {
-			// Search in title and content
-			if strings.Contains(strings.ToLower(post.Title), query) ||
-				strings.Contains(strings.ToLower(post.Content), query) ||
-				strings.Contains(strings.ToLower(post.SubredditName), query) {
-				results = append(results, post)
-			}
-		}
-
-		// Sort results by creation time (newest first)
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].CreatedAt.After(results[j].CreatedAt)
-		})
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(results)
-	}
-}
-
 func (s *Server) handleLogin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
@@ -178,7 +306,9 @@ func (s *Server) handleLogin() http.HandlerFunc {
 		}
 
 		// Check if user exists and password matches
+		s.mu.RLock()
 		user, exists := s.users[req.Username]
+		s.mu.RUnlock()
 		if !exists {
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
@@ -212,12 +342,53 @@ func (s *Server) handleCreateSubreddit() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if req.Name == "" {
+			http.Error(w, "Subreddit name is required", http.StatusBadRequest)
+			return
+		}
+		creator, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.subreddits[req.Name]; exists {
+			http.Error(w, "Subreddit already exists", http.StatusConflict)
+			return
+		}
+
+		// The creator owns the subreddit as its first moderator
+		now := time.Now()
+		s.moderators[req.Name] = moderation.NewRoster(creator, now)
+		s.bans[req.Name] = moderation.NewBanList()
+		s.mutes[req.Name] = moderation.NewBanList()
+		subreddit := &models.Subreddit{
+			Name:        req.Name,
+			Description: req.Description,
+			CreatedAt:   now,
+			Moderators:  s.moderators[req.Name].Usernames(),
+		}
+		s.subreddits[req.Name] = subreddit
 
-		// For development, return success
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{
-			"message": "Subreddit created successfully",
-		})
+		json.NewEncoder(w).Encode(subreddit)
+	}
+}
+
+func (s *Server) handleGetSubreddit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		subreddit, exists := s.subreddits[name]
+		if !exists {
+			http.Error(w, "Subreddit not found", http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(subreddit)
 	}
 }
 
@@ -225,8 +396,31 @@ func (s *Server) handleJoinSubreddit() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		name := vars["name"]
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		// Remember the subscription so the user's feed includes the subreddit
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.subreddits[name]; !exists {
+			http.Error(w, "Subreddit not found", http.StatusNotFound)
+			return
+		}
+		user, exists := s.users[username]
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if !s.checkNotBanned(w, name, username) {
+			return
+		}
+		if !isSubscribed(user, name) {
+			user.Subreddits = append(user.Subreddits, name)
+		}
+		s.events.Publish(events.UserJoined{Username: username, Subreddit: name, Time: time.Now()})
 
-		// For development, return success
 		json.NewEncoder(w).Encode(map[string]string{
 			"message": "Joined subreddit: " + name,
 		})
@@ -260,15 +454,40 @@ func (s *Server) handleCreateComment() http.HandlerFunc {
 			PostID:     postID,
 			ParentID:   req.ParentID,
 			AuthorName: r.Header.Get("X-User"), // In production, get from auth token
+			CreatedAt:  time.Now(),
 		}
 
-		// Store comment
-		s.comments[comment.ID] = comment
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		post, exists := s.posts[postID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		var parent *models.Comment
+		if req.ParentID != nil {
+			parent, exists = s.comments[*req.ParentID]
+			if !exists || parent.PostID != postID {
+				http.Error(w, "Parent comment not found", http.StatusNotFound)
+				return
+			}
+		}
+		if post.Locked {
+			http.Error(w, "Post is locked", http.StatusForbidden)
+			return
+		}
+		if !s.checkNotBanned(w, post.SubredditName, comment.AuthorName) {
+			return
+		}
 
-		// Update post's comments if it exists
-		if post, exists := s.posts[postID]; exists {
-			post.CommentsCount++
+		// Store comment and update the counts of the post and the parent
+		s.comments[comment.ID] = comment
+		post.CommentsCount++
+		if parent != nil {
+			parent.RepliesCount++
 		}
+		s.autoModerateComment(post, comment)
+		s.events.Publish(events.CommentCreated{Comment: *comment, Subreddit: post.SubredditName})
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(comment)
@@ -294,9 +513,17 @@ func (s *Server) handleCreatePost() http.HandlerFunc {
 			Content:       req.Content,
 			SubredditName: req.Subreddit,
 			AuthorName:    r.Header.Get("X-User"), // In production, get from auth token
+			CreatedAt:     time.Now(),
 		}
 
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.checkNotBanned(w, post.SubredditName, post.AuthorName) {
+			return
+		}
 		s.posts[post.ID] = post
+		s.autoModeratePost(post)
+		s.events.Publish(events.PostCreated{Post: *post})
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(post)
@@ -312,13 +539,15 @@ func (s *Server) handleGetPost() http.HandlerFunc {
 			return
 		}
 
+		s.mu.RLock()
+		defer s.mu.RUnlock()
 		post, exists := s.posts[postID]
 		if !exists {
 			http.Error(w, "Post not found", http.StatusNotFound)
 			return
 		}
 
-		json.NewEncoder(w).Encode(post)
+		json.NewEncoder(w).Encode(s.postFor(post, r.Header.Get("X-User")))
 	}
 }
 
@@ -348,19 +577,24 @@ func (s *Server) handleVotePost() http.HandlerFunc {
 		}
 
 		// Find the post
+		s.mu.Lock()
+		defer s.mu.Unlock()
 		post, exists := s.posts[postID]
 		if !exists {
 			http.Error(w, "Post not found", http.StatusNotFound)
 			return
 		}
+		if post.Locked {
+			http.Error(w, "Post is locked", http.StatusForbidden)
+			return
+		}
+		if !s.checkNotBanned(w, post.SubredditName, username) {
+			return
+		}
 
-		// Record the vote
-		if req.IsUpvote {
-			post.Upvotes++
-			post.Score++
-		} else {
-			post.Downvotes++
-			post.Score--
+		// Record the vote, at most one per user
+		if s.castVote(username, post.ID, post.AuthorName, false, tally{&post.Upvotes, &post.Downvotes, &post.Score}, voteDirection(req.IsUpvote)) {
+			s.events.Publish(events.VoteCast{Username: username, PostID: &post.ID, Upvote: req.IsUpvote, Time: time.Now()})
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -394,19 +628,26 @@ func (s *Server) handleVoteComment() http.HandlerFunc {
 		}
 
 		// Find the comment
+		s.mu.Lock()
+		defer s.mu.Unlock()
 		comment, exists := s.comments[commentID]
 		if !exists {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
+		if post, exists := s.posts[comment.PostID]; exists {
+			if post.Locked {
+				http.Error(w, "Post is locked", http.StatusForbidden)
+				return
+			}
+			if !s.checkNotBanned(w, post.SubredditName, username) {
+				return
+			}
+		}
 
-		// Record the vote
-		if req.IsUpvote {
-			comment.Upvotes++
-			comment.Score++
-		} else {
-			comment.Downvotes++
-			comment.Score--
+		// Record the vote, at most one per user
+		if s.castVote(username, comment.ID, comment.AuthorName, true, tally{&comment.Upvotes, &comment.Downvotes, &comment.Score}, voteDirection(req.IsUpvote)) {
+			s.events.Publish(events.VoteCast{Username: username, CommentID: &comment.ID, Upvote: req.IsUpvote, Time: time.Now()})
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -422,13 +663,15 @@ func (s *Server) handleGetComment() http.HandlerFunc {
 			return
 		}
 
+		s.mu.RLock()
+		defer s.mu.RUnlock()
 		comment, exists := s.comments[commentID]
 		if !exists {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
 
-		json.NewEncoder(w).Encode(comment)
+		json.NewEncoder(w).Encode(s.commentFor(comment, r.Header.Get("X-User")))
 	}
 }
 
@@ -440,23 +683,38 @@ func (s *Server) handleWebSocket() http.HandlerFunc {
 			return
 		}
 
+		// Browsers cannot set headers on websocket requests, so the user
+		// may also be named in the query string
+		username := r.Header.Get("X-User")
+		if username == "" {
+			username = r.URL.Query().Get("username")
+		}
 		client := &Client{
-			hub:  s.hub,
-			conn: conn,
-			send: make(chan []byte, 256),
+			hub:      s.hub,
+			conn:     conn,
+			send:     make(chan []byte, 256),
+			username: username,
 		}
-		client.hub.register <- client
+		s.mu.Lock()
+		s.connect(client)
+		s.mu.Unlock()
 
 		go client.writePump()
-		go client.readPump()
+		go func() {
+			client.readPump()
+			s.mu.Lock()
+			s.disconnect(client)
+			s.mu.Unlock()
+		}()
 	}
 }
 
 func (s *Server) handleSendMessage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
-			ToUser  string `json:"to_user"`
-			Content string `json:"content"`
+			ToUser    string     `json:"to_user"`
+			Content   string     `json:"content"`
+			ReplyToID *uuid.UUID `json:"reply_to_id"` // Either user of the message may reply
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -477,10 +735,39 @@ func (s *Server) handleSendMessage() http.HandlerFunc {
 			ToUser:    req.ToUser,
 			Content:   req.Content,
 			CreatedAt: time.Now(),
+			ReplyToID: req.ReplyToID,
 		}
 
 		// Store the message
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if req.ReplyToID != nil {
+			original, exists := s.messages[*req.ReplyToID]
+			if !exists || (original.FromUser != fromUser && original.ToUser != fromUser) {
+				http.Error(w, "Message not found", http.StatusNotFound)
+				return
+			}
+			// The reply goes to the other user of the message
+			other := original.FromUser
+			if other == fromUser {
+				other = original.ToUser
+			}
+			if req.ToUser != "" && req.ToUser != other {
+				http.Error(w, "A reply must go to the other user of the message", http.StatusBadRequest)
+				return
+			}
+			message.ToUser = other
+		}
+		if message.ToUser == "" {
+			http.Error(w, "Recipient required", http.StatusBadRequest)
+			return
+		}
+		if s.blocked(message.ToUser, fromUser) {
+			http.Error(w, "The recipient has blocked you", http.StatusForbidden)
+			return
+		}
 		s.messages[message.ID] = message
+		s.events.Publish(events.MessageSent{Message: *message})
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(message)
@@ -496,16 +783,33 @@ func (s *Server) handleGetMessages() http.HandlerFunc {
 			return
 		}
 
+		l, err := parseListing(r.URL.Query(), "dm:"+username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Collect all messages for this user
 		var userMessages []*models.DirectMessage
+		s.mu.RLock()
+		defer s.mu.RUnlock()
 		for _, msg := range s.messages {
 			if msg.ToUser == username || msg.FromUser == username {
 				userMessages = append(userMessages, msg)
 			}
 		}
 
+		// Newest first
+		page, next := pageOf(l, userMessages, func(msg *models.DirectMessage) (pagination.Position, bool) {
+			return pagination.Position{Time: msg.CreatedAt, ID: msg.ID.String()}, true
+		})
+		response := models.MessageListResponse{Messages: []models.DirectMessage{}, NextCursor: next, HasMore: next != ""}
+		for _, msg := range page {
+			response.Messages = append(response.Messages, *msg)
+		}
+
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(userMessages)
+		json.NewEncoder(w).Encode(response)
 	}
 }
 
@@ -522,6 +826,8 @@ func (s *Server) handleRegister() http.HandlerFunc {
 		}
 
 		// Check if user already exists
+		s.mu.Lock()
+		defer s.mu.Unlock()
 		if _, exists := s.users[req.Username]; exists {
 			http.Error(w, "Username already taken", http.StatusConflict)
 			return
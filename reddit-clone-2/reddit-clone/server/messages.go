@@ -0,0 +1,200 @@
+// server/messages.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// between reports whether a message was sent between two users, either way
+func between(message *models.DirectMessage, username, with string) bool {
+	return (message.FromUser == username && message.ToUser == with) ||
+		(message.FromUser == with && message.ToUser == username)
+}
+
+// unreadBy reports whether a message to username is still unread
+func unreadBy(message *models.DirectMessage, username string) bool {
+	return message.ToUser == username && message.ReadAt == nil
+}
+
+// handleGetConversations serves GET /api/messages/conversations, a summary
+// of each conversation of the signed-in user
+func (s *Server) handleGetConversations() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		byUser := make(map[string]*models.Conversation)
+		response := models.ConversationListResponse{Conversations: []models.Conversation{}}
+		for _, message := range s.messages {
+			with := message.FromUser
+			if with == username {
+				with = message.ToUser
+			} else if message.ToUser != username {
+				continue
+			}
+
+			conversation, exists := byUser[with]
+			if !exists {
+				conversation = &models.Conversation{With: with}
+				byUser[with] = conversation
+			}
+			conversation.MessageCount++
+			if conversation.MessageCount == 1 || message.CreatedAt.After(conversation.LastMessage.CreatedAt) {
+				conversation.LastMessage = *message
+			}
+			if unreadBy(message, username) {
+				conversation.UnreadCount++
+				response.UnreadCount++
+			}
+		}
+
+		for _, conversation := range byUser {
+			response.Conversations = append(response.Conversations, *conversation)
+		}
+		sort.Slice(response.Conversations, func(i, j int) bool {
+			return response.Conversations[i].LastMessage.CreatedAt.After(response.Conversations[j].LastMessage.CreatedAt)
+		})
+		writeJSON(w, response)
+	}
+}
+
+// handleGetConversation serves GET /api/messages/conversations/{username},
+// the messages between the signed-in user and another user, newest first
+func (s *Server) handleGetConversation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		with := mux.Vars(r)["username"]
+		l, err := parseListing(r.URL.Query(), "dm:"+username+":"+with)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		var messages []*models.DirectMessage
+		response := models.ConversationResponse{With: with, Messages: []models.DirectMessage{}}
+		for _, message := range s.messages {
+			if between(message, username, with) {
+				messages = append(messages, message)
+				if unreadBy(message, username) {
+					response.UnreadCount++
+				}
+			}
+		}
+
+		page, next := pageOf(l, messages, func(message *models.DirectMessage) (pagination.Position, bool) {
+			return pagination.Position{Time: message.CreatedAt, ID: message.ID.String()}, true
+		})
+		for _, message := range page {
+			response.Messages = append(response.Messages, *message)
+		}
+		response.NextCursor, response.HasMore = next, next != ""
+		writeJSON(w, response)
+	}
+}
+
+// handleMarkConversationRead serves POST
+// /api/messages/conversations/{username}/read, which marks every message
+// from that user to the signed-in user read
+func (s *Server) handleMarkConversationRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		with := mux.Vars(r)["username"]
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now()
+		marked := 0
+		for _, message := range s.messages {
+			if message.FromUser == with && unreadBy(message, username) {
+				message.ReadAt = &now
+				marked++
+			}
+		}
+		writeJSON(w, map[string]int{"marked": marked})
+	}
+}
+
+// handleMarkMessageRead serves POST /api/messages/{id}/read. Only the
+// recipient may mark a message read.
+func (s *Server) handleMarkMessageRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		messageID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		message, exists := s.messages[messageID]
+		if !exists || message.ToUser != username {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		if message.ReadAt == nil {
+			now := time.Now()
+			message.ReadAt = &now
+		}
+		writeJSON(w, message)
+	}
+}
+
+// handleGetMessageThread serves GET /api/messages/{id}/thread, the chain of
+// replies that ends with a message, starting from the one that opened it
+func (s *Server) handleGetMessageThread() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		messageID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		message, exists := s.messages[messageID]
+		if !exists || (message.FromUser != username && message.ToUser != username) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		thread := []models.DirectMessage{*message}
+		for message.ReplyToID != nil {
+			if message, exists = s.messages[*message.ReplyToID]; !exists {
+				break
+			}
+			thread = append(thread, *message)
+		}
+
+		// Oldest first
+		for i, j := 0, len(thread)-1; i < j; i, j = i+1, j-1 {
+			thread[i], thread[j] = thread[j], thread[i]
+		}
+		writeJSON(w, models.MessageListResponse{Messages: thread})
+	}
+}
@@ -0,0 +1,157 @@
+// server/messages_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// sendMessage sends a direct message as from, replying to replyTo unless it
+// is nil, in which case it goes to to
+func (ts *testServer) sendMessage(from, to, content string, replyTo *models.DirectMessage) models.DirectMessage {
+	ts.t.Helper()
+	body := map[string]interface{}{"to_user": to, "content": content}
+	if replyTo != nil {
+		body["reply_to_id"] = replyTo.ID
+	}
+	var message models.DirectMessage
+	ts.must("POST", "/api/messages", from, body, &message)
+	return message
+}
+
+func TestConversations(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.sendMessage("bob", "alice", "One", nil)
+	ts.sendMessage("alice", "bob", "Two", nil)
+	ts.sendMessage("bob", "alice", "Three", nil)
+	ts.sendMessage("carol", "alice", "Four", nil)
+	ts.sendMessage("bob", "carol", "Elsewhere", nil)
+
+	var list models.ConversationListResponse
+	ts.must("GET", "/api/messages/conversations", "alice", nil, &list)
+	if len(list.Conversations) != 2 || list.UnreadCount != 3 {
+		t.Fatalf("got %+v, want two conversations with three unread messages", list)
+	}
+	if carol := list.Conversations[0]; carol.With != "carol" || carol.MessageCount != 1 || carol.LastMessage.Content != "Four" {
+		t.Errorf("first conversation is %+v, want carol's, the newest", carol)
+	}
+	if bob := list.Conversations[1]; bob.With != "bob" || bob.MessageCount != 3 || bob.UnreadCount != 2 || bob.LastMessage.Content != "Three" {
+		t.Errorf("second conversation is %+v, want bob's with 3 messages, 2 unread", bob)
+	}
+
+	var conversation models.ConversationResponse
+	ts.must("GET", "/api/messages/conversations/bob?limit=2", "alice", nil, &conversation)
+	if len(conversation.Messages) != 2 || !conversation.HasMore || conversation.UnreadCount != 2 {
+		t.Fatalf("first page is %+v, want two messages of three, two unread", conversation)
+	}
+	if conversation.Messages[0].Content != "Three" || conversation.Messages[1].Content != "Two" {
+		t.Errorf("first page holds %q and %q, want Three and Two", conversation.Messages[0].Content, conversation.Messages[1].Content)
+	}
+	var rest models.ConversationResponse
+	ts.must("GET", "/api/messages/conversations/bob?limit=2&cursor="+conversation.NextCursor, "alice", nil, &rest)
+	if len(rest.Messages) != 1 || rest.Messages[0].Content != "One" || rest.HasMore {
+		t.Errorf("second page is %+v, want One alone", rest)
+	}
+
+	var marked map[string]int
+	ts.must("POST", "/api/messages/conversations/bob/read", "alice", nil, &marked)
+	if marked["marked"] != 2 {
+		t.Errorf("marked %d messages read, want 2", marked["marked"])
+	}
+	ts.must("GET", "/api/messages/conversations", "alice", nil, &list)
+	if list.UnreadCount != 1 {
+		t.Errorf("%d messages unread after reading bob's, want carol's alone", list.UnreadCount)
+	}
+
+	for _, endpoint := range []struct{ method, path string }{
+		{"GET", "/api/messages/conversations"},
+		{"GET", "/api/messages/conversations/bob"},
+		{"POST", "/api/messages/conversations/bob/read"},
+	} {
+		if code := ts.do(endpoint.method, endpoint.path, "", nil, nil); code != http.StatusUnauthorized {
+			t.Errorf("anonymous %s %s: got %d, want %d", endpoint.method, endpoint.path, code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestMarkMessageRead(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	message := ts.sendMessage("bob", "alice", "Hello", nil)
+	path := "/api/messages/" + message.ID.String() + "/read"
+
+	for _, tt := range []struct {
+		name, path, user string
+		want             int
+	}{
+		{"anonymously", path, "", http.StatusUnauthorized},
+		{"with a bad ID", "/api/messages/not-an-id/read", "alice", http.StatusBadRequest},
+		{"as its sender", path, "bob", http.StatusNotFound},
+		{"that does not exist", "/api/messages/" + uuid.New().String() + "/read", "alice", http.StatusNotFound},
+	} {
+		if code := ts.do("POST", tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("mark a message read %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var read models.DirectMessage
+	ts.must("POST", path, "alice", nil, &read)
+	if read.ReadAt == nil {
+		t.Fatal("message marked read has no read time")
+	}
+	var again models.DirectMessage
+	ts.must("POST", path, "alice", nil, &again)
+	if !again.ReadAt.Equal(*read.ReadAt) {
+		t.Errorf("marking a read message read moved its read time from %v to %v", read.ReadAt, again.ReadAt)
+	}
+}
+
+func TestMessageThread(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	first := ts.sendMessage("alice", "bob", "Lunch?", nil)
+	second := ts.sendMessage("bob", "", "Sure", &first)
+	third := ts.sendMessage("alice", "", "Noon", &second)
+	if second.ToUser != "alice" || third.ToUser != "bob" {
+		t.Errorf("replies went to %s and %s, want the other user of each message", second.ToUser, third.ToUser)
+	}
+
+	var thread models.MessageListResponse
+	ts.must("GET", "/api/messages/"+third.ID.String()+"/thread", "bob", nil, &thread)
+	var contents []string
+	for _, message := range thread.Messages {
+		contents = append(contents, message.Content)
+	}
+	if len(contents) != 3 || contents[0] != "Lunch?" || contents[2] != "Noon" {
+		t.Errorf("thread is %v, want Lunch?, Sure and Noon", contents)
+	}
+
+	for _, tt := range []struct {
+		name, path, user string
+		want             int
+	}{
+		{"anonymously", "/api/messages/" + third.ID.String() + "/thread", "", http.StatusUnauthorized},
+		{"with a bad ID", "/api/messages/not-an-id/thread", "bob", http.StatusBadRequest},
+		{"of other users", "/api/messages/" + third.ID.String() + "/thread", "carol", http.StatusNotFound},
+	} {
+		if code := ts.do("GET", tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("thread %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		name, user, to string
+		want           int
+	}{
+		{"from a stranger", "carol", "", http.StatusNotFound},
+		{"to a third user", "bob", "carol", http.StatusBadRequest},
+	} {
+		body := map[string]interface{}{"to_user": tt.to, "content": "Me too", "reply_to_id": first.ID}
+		if code := ts.do("POST", "/api/messages", tt.user, body, nil); code != tt.want {
+			t.Errorf("reply %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// server/moderation.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/moderation"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// requireModerator checks that the requesting user moderates subreddit name
+// with perm, writing the error response if not. The caller must hold s.mu.
+func (s *Server) requireModerator(w http.ResponseWriter, r *http.Request, name string, perm moderation.Permission) (*moderation.Roster, bool) {
+	roster, ok := s.roster(w, name)
+	if !ok {
+		return nil, false
+	}
+	username, ok := requireUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if err := roster.Check(username, perm); err != nil {
+		writeModerationError(w, err)
+		return nil, false
+	}
+	return roster, true
+}
+
+// roster returns the mod team of subreddit name, writing a 404 if there is no
+// such subreddit. The caller must hold s.mu.
+func (s *Server) roster(w http.ResponseWriter, name string) (*moderation.Roster, bool) {
+	roster, exists := s.moderators[name]
+	if !exists {
+		http.Error(w, "Subreddit not found", http.StatusNotFound)
+		return nil, false
+	}
+	return roster, true
+}
+
+// syncModerators copies the mod team of a subreddit into its model
+func (s *Server) syncModerators(name string) {
+	s.subreddits[name].Moderators = s.moderators[name].Usernames()
+}
+
+// requireUser returns the requesting user, writing a 401 if there is none
+func requireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username := r.Header.Get("X-User")
+	if username == "" {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return "", false
+	}
+	return username, true
+}
+
+// writeModerationError maps a moderation error to its HTTP status
+func writeModerationError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, moderation.ErrNotModerator),
+		errors.Is(err, moderation.ErrPermissionDenied),
+		errors.Is(err, moderation.ErrOutranked),
+		errors.Is(err, moderation.ErrBanModerator):
+		status = http.StatusForbidden
+	case errors.Is(err, moderation.ErrNoInvite),
+		errors.Is(err, moderation.ErrNotBanned):
+		status = http.StatusNotFound
+	case errors.Is(err, moderation.ErrAlreadyModerator),
+		errors.Is(err, moderation.ErrLastModerator):
+		status = http.StatusConflict
+	case errors.Is(err, moderation.ErrInvalidPermission),
+		errors.Is(err, moderation.ErrInvalidAction):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleUpdateSubreddit serves PATCH /api/subreddits/{name} for moderators
+// with the config permission. Fields left out of the request are unchanged.
+func (s *Server) handleUpdateSubreddit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		var req struct {
+			Description        *string `json:"description"`
+			CrosspostsDisabled *bool   `json:"crossposts_disabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermConfig); !ok {
+			return
+		}
+		subreddit := s.subreddits[name]
+		if req.Description != nil {
+			subreddit.Description = *req.Description
+		}
+		if req.CrosspostsDisabled != nil {
+			subreddit.CrosspostsDisabled = *req.CrosspostsDisabled
+		}
+		writeJSON(w, subreddit)
+	}
+}
+
+// handleGetModerators serves GET /api/subreddits/{name}/moderators. Pending
+// invites are only listed for moderators.
+func (s *Server) handleGetModerators() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		roster, ok := s.roster(w, name)
+		if !ok {
+			return
+		}
+		response := models.ModeratorListResponse{Subreddit: name, Moderators: roster.Moderators()}
+		if roster.IsModerator(r.Header.Get("X-User")) {
+			response.Invites = roster.Invites()
+		}
+		writeJSON(w, response)
+	}
+}
+
+// handleInviteModerator serves POST /api/subreddits/{name}/moderators/invite.
+// No permissions in the request invite a full moderator.
+func (s *Server) handleInviteModerator() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		var req struct {
+			Username    string   `json:"username"`
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		perms, err := moderation.ParsePermissions(req.Permissions)
+		if err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		roster, ok := s.roster(w, name)
+		if !ok {
+			return
+		}
+		if _, exists := s.users[req.Username]; !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err := roster.Invite(username, req.Username, perms, time.Now()); err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Invited " + req.Username + " to moderate " + name,
+		})
+	}
+}
+
+// handleRevokeModeratorInvite serves DELETE /api/subreddits/{name}/moderators/invite/{username}
+func (s *Server) handleRevokeModeratorInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		roster, ok := s.roster(w, vars["name"])
+		if !ok {
+			return
+		}
+		if err := roster.RevokeInvite(username, vars["username"]); err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		writeJSON(w, map[string]string{"message": "Invite revoked"})
+	}
+}
+
+// handleAcceptModeratorInvite serves POST /api/subreddits/{name}/moderators/accept
+func (s *Server) handleAcceptModeratorInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.changeModerators(w, r, func(roster *moderation.Roster, username string) error {
+			return roster.Accept(username, time.Now())
+		})
+	}
+}
+
+// handleResignModerator serves POST /api/subreddits/{name}/moderators/resign
+func (s *Server) handleResignModerator() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.changeModerators(w, r, func(roster *moderation.Roster, username string) error {
+			return roster.Resign(username)
+		})
+	}
+}
+
+// handleTransferOwnership serves POST /api/subreddits/{name}/moderators/transfer
+// with the username of the moderator to take over
+func (s *Server) handleTransferOwnership() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Username string `json:"username"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.changeModerators(w, r, func(roster *moderation.Roster, username string) error {
+			return roster.Transfer(username, req.Username)
+		})
+	}
+}
+
+// handleSetModeratorPermissions serves PUT /api/subreddits/{name}/moderators/{username}
+func (s *Server) handleSetModeratorPermissions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Permissions []string `json:"permissions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		perms, err := moderation.ParsePermissions(req.Permissions)
+		if err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		s.changeModerators(w, r, func(roster *moderation.Roster, username string) error {
+			return roster.SetPermissions(username, mux.Vars(r)["username"], perms)
+		})
+	}
+}
+
+// handleRemoveModerator serves DELETE /api/subreddits/{name}/moderators/{username}
+func (s *Server) handleRemoveModerator() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.changeModerators(w, r, func(roster *moderation.Roster, username string) error {
+			return roster.Remove(username, mux.Vars(r)["username"])
+		})
+	}
+}
+
+// changeModerators applies a change to the mod team of a subreddit on behalf
+// of the requesting user, then responds with the new team
+func (s *Server) changeModerators(w http.ResponseWriter, r *http.Request, change func(roster *moderation.Roster, username string) error) {
+	name := mux.Vars(r)["name"]
+	username, ok := requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roster, ok := s.roster(w, name)
+	if !ok {
+		return
+	}
+	if err := change(roster, username); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+	s.syncModerators(name)
+	writeJSON(w, models.ModeratorListResponse{Subreddit: name, Moderators: roster.Moderators()})
+}
@@ -0,0 +1,80 @@
+// server/moderation_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"testing"
+)
+
+// appoint invites username to moderate subreddit with perms, as its owner,
+// and accepts the invite
+func (ts *testServer) appoint(subreddit, owner, username string, perms ...string) {
+	ts.t.Helper()
+	ts.must("POST", "/api/subreddits/"+subreddit+"/moderators/invite", owner, map[string]interface{}{"username": username, "permissions": perms}, nil)
+	ts.must("POST", "/api/subreddits/"+subreddit+"/moderators/accept", username, nil, nil)
+}
+
+func TestModeratorPermissionsGateEndpoints(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "posts-mod", "users-mod", "config-mod")
+	ts.createSubreddit("golang", "owner")
+	for _, perm := range []string{"posts", "users", "config"} {
+		ts.appoint("golang", "owner", perm+"-mod", perm)
+	}
+
+	endpoints := map[string]struct {
+		method, path string
+		body         interface{}
+	}{
+		"posts":  {"GET", "/api/subreddits/golang/modqueue", nil},
+		"users":  {"GET", "/api/subreddits/golang/bans", nil},
+		"config": {"PATCH", "/api/subreddits/golang", map[string]string{"description": "Go"}},
+	}
+	for held := range endpoints {
+		for needed, endpoint := range endpoints {
+			want := http.StatusForbidden
+			if held == needed {
+				want = http.StatusOK
+			}
+			if code := ts.do(endpoint.method, endpoint.path, held+"-mod", endpoint.body, nil); code != want {
+				t.Errorf("%s moderator on %s %s: got %d, want %d", held, endpoint.method, endpoint.path, code, want)
+			}
+		}
+	}
+}
+
+func TestModeratorInviteNeedsAccepting(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice")
+	ts.createSubreddit("golang", "owner")
+	ts.must("POST", "/api/subreddits/golang/moderators/invite", "owner", map[string]interface{}{"username": "alice"}, nil)
+
+	if code := ts.do("GET", "/api/subreddits/golang/modqueue", "alice", nil, nil); code != http.StatusForbidden {
+		t.Errorf("invited user reading the mod queue: got %d, want %d", code, http.StatusForbidden)
+	}
+	var team models.ModeratorListResponse
+	ts.must("POST", "/api/subreddits/golang/moderators/accept", "alice", nil, &team)
+	if len(team.Moderators) != 2 || team.Moderators[1].Username != "alice" {
+		t.Errorf("team is %+v after accepting, want owner then alice", team.Moderators)
+	}
+	if code := ts.do("GET", "/api/subreddits/golang/modqueue", "alice", nil, nil); code != http.StatusOK {
+		t.Errorf("moderator reading the mod queue: got %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestModeratorLastOwnerCannotResign(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("owner", "alice")
+	ts.createSubreddit("golang", "owner")
+	if code := ts.do("POST", "/api/subreddits/golang/moderators/resign", "owner", nil, nil); code != http.StatusConflict {
+		t.Errorf("last owner resigning: got %d, want %d", code, http.StatusConflict)
+	}
+
+	ts.appoint("golang", "owner", "alice")
+	var team models.ModeratorListResponse
+	ts.must("POST", "/api/subreddits/golang/moderators/resign", "owner", nil, &team)
+	if len(team.Moderators) != 1 || team.Moderators[0].Username != "alice" {
+		t.Errorf("team is %+v after the owner resigned, want alice alone", team.Moderators)
+	}
+}
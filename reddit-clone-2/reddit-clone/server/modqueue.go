@@ -0,0 +1,266 @@
+// server/modqueue.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/moderation"
+	"reddit-clone/pagination"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// seesRemoved reports whether viewer moderates subreddit with the posts
+// permission, and so sees its removed and filtered content. Must be called
+// with s.mu held.
+func (s *Server) seesRemoved(viewer, subreddit string) bool {
+	roster, exists := s.moderators[subreddit]
+	return exists && roster.Check(viewer, moderation.PermPosts) == nil
+}
+
+// postVisible reports whether a post is listed for viewer. Must be called
+// with s.mu held.
+func (s *Server) postVisible(post *models.Post, viewer string) bool {
+	return !post.Removed && !post.Filtered || s.seesRemoved(viewer, post.SubredditName)
+}
+
+// commentVisible reports whether a comment is listed for viewer. Must be
+// called with s.mu held.
+func (s *Server) commentVisible(comment *models.Comment, viewer string) bool {
+	if !comment.Removed && !comment.Filtered {
+		return true
+	}
+	post, exists := s.posts[comment.PostID]
+	return exists && s.seesRemoved(viewer, post.SubredditName)
+}
+
+// postFor returns a post as shown to viewer, with the title and content of
+// hidden posts replaced and their author left out. Must be called with s.mu
+// held.
+func (s *Server) postFor(post *models.Post, viewer string) models.Post {
+	shown := *post
+	if !s.postVisible(post, viewer) {
+		shown.Title, shown.Content = models.RemovedContent, models.RemovedContent
+		shown.AuthorName = ""
+	}
+	return shown
+}
+
+// commentFor returns a comment as shown to viewer, with the content of
+// hidden comments replaced and their author left out. Must be called with
+// s.mu held.
+func (s *Server) commentFor(comment *models.Comment, viewer string) models.Comment {
+	shown := *comment
+	if !s.commentVisible(comment, viewer) {
+		shown.Content, shown.AuthorName = models.RemovedContent, ""
+	}
+	return shown
+}
+
+// handleReportPost serves POST /api/posts/{id}/report. Each user reports a
+// post at most once.
+func (s *Server) handleReportPost() http.HandlerFunc {
+	return s.handleReport(func(id uuid.UUID) bool {
+		_, exists := s.posts[id]
+		return exists
+	})
+}
+
+// handleReportComment serves POST /api/comments/{id}/report. Each user
+// reports a comment at most once.
+func (s *Server) handleReportComment() http.HandlerFunc {
+	return s.handleReport(func(id uuid.UUID) bool {
+		_, exists := s.comments[id]
+		return exists
+	})
+}
+
+// handleReport records a report on the post or comment named in the path.
+// exists is called with s.mu held.
+func (s *Server) handleReport(exists func(id uuid.UUID) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reporter, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !exists(id) {
+			http.Error(w, "Post or comment not found", http.StatusNotFound)
+			return
+		}
+		if !hasReported(s.reports[id], reporter) {
+			s.reports[id] = append(s.reports[id], models.Report{Reporter: reporter, Reason: req.Reason, CreatedAt: time.Now()})
+		}
+		writeJSON(w, map[string]string{"message": "Report received"})
+	}
+}
+
+// handleModeratePost serves POST /api/posts/{id}/moderate with an action of
+// remove, spam, approve, lock or unlock. It needs the posts permission.
+func (s *Server) handleModeratePost() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+		action, ok := parseAction(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		post, exists := s.posts[postID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if _, ok := s.requireModerator(w, r, post.SubredditName, moderation.PermPosts); !ok {
+			return
+		}
+		if err := moderation.ApplyToPost(post, action); err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		if action == moderation.ActionApprove {
+			delete(s.reports, postID)
+		}
+		writeJSON(w, post)
+	}
+}
+
+// handleModerateComment serves POST /api/comments/{id}/moderate with an
+// action of remove, spam or approve. It needs the posts permission.
+func (s *Server) handleModerateComment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+		action, ok := parseAction(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		comment, exists := s.comments[commentID]
+		if !exists {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		post, exists := s.posts[comment.PostID]
+		if !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		if _, ok := s.requireModerator(w, r, post.SubredditName, moderation.PermPosts); !ok {
+			return
+		}
+		if err := moderation.ApplyToComment(comment, action); err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		if action == moderation.ActionApprove {
+			delete(s.reports, commentID)
+		}
+		writeJSON(w, comment)
+	}
+}
+
+// handleGetModQueue serves GET /api/subreddits/{name}/modqueue: the reported
+// and filtered posts and comments that are not removed, newest first. It
+// needs the posts permission.
+func (s *Server) handleGetModQueue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		l, err := parseListing(r.URL.Query(), "modqueue/"+name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, ok := s.requireModerator(w, r, name, moderation.PermPosts); !ok {
+			return
+		}
+
+		var queue []models.ModQueueItem
+		for _, post := range s.posts {
+			if post.SubredditName == name && !post.Removed && (post.Filtered || len(s.reports[post.ID]) > 0) {
+				shown := *post
+				queue = append(queue, models.ModQueueItem{Post: &shown, Reports: s.reportsOf(post.ID)})
+			}
+		}
+		for _, comment := range s.comments {
+			post, exists := s.posts[comment.PostID]
+			if exists && post.SubredditName == name && !comment.Removed && (comment.Filtered || len(s.reports[comment.ID]) > 0) {
+				shown := *comment
+				queue = append(queue, models.ModQueueItem{Comment: &shown, Reports: s.reportsOf(comment.ID)})
+			}
+		}
+
+		page, next := pageOf(l, queue, func(item models.ModQueueItem) (pagination.Position, bool) {
+			if item.Post != nil {
+				return pagination.Position{Time: item.Post.CreatedAt, ID: item.Post.ID.String()}, true
+			}
+			return pagination.Position{Time: item.Comment.CreatedAt, ID: item.Comment.ID.String()}, true
+		})
+		if page == nil {
+			page = []models.ModQueueItem{}
+		}
+		writeJSON(w, models.ModQueueResponse{Items: page, NextCursor: next, HasMore: next != ""})
+	}
+}
+
+// reportsOf returns the reports on a post or comment, never nil
+func (s *Server) reportsOf(id uuid.UUID) []models.Report {
+	return append([]models.Report{}, s.reports[id]...)
+}
+
+// parseAction reads the action of a moderation request, writing the error
+// response if it is missing or unknown
+func parseAction(w http.ResponseWriter, r *http.Request) (moderation.Action, bool) {
+	var req struct {
+		Action moderation.Action `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	switch req.Action {
+	case moderation.ActionRemove, moderation.ActionSpam, moderation.ActionApprove, moderation.ActionLock, moderation.ActionUnlock:
+		return req.Action, true
+	}
+	http.Error(w, "Unknown moderator action: "+string(req.Action), http.StatusBadRequest)
+	return "", false
+}
+
+func hasReported(reports []models.Report, reporter string) bool {
+	for _, report := range reports {
+		if report.Reporter == reporter {
+			return true
+		}
+	}
+	return false
+}
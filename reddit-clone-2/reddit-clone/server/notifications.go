@@ -0,0 +1,218 @@
+// server/notifications.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// mentionPattern matches u/name and /u/name, but not inside a word or a URL
+// path such as example.com/u/name
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_/])/?u/([A-Za-z0-9_-]+)`)
+
+// mentions returns the usernames mentioned in texts, each once, in order
+func mentions(texts ...string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+			if username := match[1]; !seen[username] {
+				seen[username] = true
+				usernames = append(usernames, username)
+			}
+		}
+	}
+	return usernames
+}
+
+// notify subscribes to new posts and comments. A comment notifies the author
+// of the post or comment it replies to, and both notify every registered
+// user they mention. Nobody is notified about their own content, content by
+// users they have blocked, or content AutoModerator removed or held. It runs
+// with s.mu held by the handler that published the event.
+func (s *Server) notify(event events.Event) {
+	var base models.Notification
+	var recipients []string
+	var types []string
+	notified := make(map[string]bool)
+	add := func(username, kind string) {
+		if username != "" && !notified[username] && !s.blocked(username, base.FromUser) {
+			notified[username] = true
+			recipients = append(recipients, username)
+			types = append(types, kind)
+		}
+	}
+
+	switch event := event.(type) {
+	case events.PostCreated:
+		post := event.Post
+		if post.Removed || post.Filtered {
+			return
+		}
+		base = models.Notification{FromUser: post.AuthorName, Subreddit: post.SubredditName, PostID: post.ID, CreatedAt: post.CreatedAt}
+		notified[post.AuthorName] = true
+		for _, username := range mentions(post.Title, post.Content) {
+			if _, exists := s.users[username]; exists {
+				add(username, models.NotificationMention)
+			}
+		}
+	case events.CommentCreated:
+		comment := event.Comment
+		if comment.Removed || comment.Filtered {
+			return
+		}
+		commentID := comment.ID
+		base = models.Notification{FromUser: comment.AuthorName, Subreddit: event.Subreddit, PostID: comment.PostID, CommentID: &commentID, CreatedAt: comment.CreatedAt}
+		notified[comment.AuthorName] = true
+		if comment.ParentID != nil {
+			if parent, exists := s.comments[*comment.ParentID]; exists {
+				add(parent.AuthorName, models.NotificationCommentReply)
+			}
+		} else if post, exists := s.posts[comment.PostID]; exists {
+			add(post.AuthorName, models.NotificationPostReply)
+		}
+		for _, username := range mentions(comment.Content) {
+			if _, exists := s.users[username]; exists {
+				add(username, models.NotificationMention)
+			}
+		}
+	}
+
+	for i, username := range recipients {
+		notification := base
+		notification.ID = uuid.New()
+		notification.Type = types[i]
+		notification.Username = username
+		s.inboxes[username] = append(s.inboxes[username], &notification)
+		s.push(&notification)
+	}
+}
+
+// push sends a new notification to its recipient if they are online, or
+// queues it until they connect. Must be called with s.mu held for writing.
+func (s *Server) push(notification *models.Notification) {
+	message, err := json.Marshal(models.NotificationPush{
+		Type:         "notification",
+		Notification: *notification,
+		UnreadCount:  s.unreadCount(notification.Username),
+	})
+	if err != nil {
+		return
+	}
+	queued := *notification
+	s.sendOrQueue(notification.Username, message, models.Delivery{Notification: &queued})
+}
+
+// unreadCount returns how many of a user's notifications are unread. Must be
+// called with s.mu held.
+func (s *Server) unreadCount(username string) int {
+	unread := 0
+	for _, notification := range s.inboxes[username] {
+		if notification.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread
+}
+
+// handleGetNotifications serves GET /api/notifications?unread=true, the
+// signed-in user's notifications newest first with their unread count
+func (s *Server) handleGetNotifications() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		unreadOnly := r.URL.Query().Get("unread") == "true"
+		scope := "notifications:" + username
+		if unreadOnly {
+			scope += ":unread"
+		}
+		l, err := parseListing(r.URL.Query(), scope)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		page, next := pageOf(l, s.inboxes[username], func(notification *models.Notification) (pagination.Position, bool) {
+			return pagination.Position{Time: notification.CreatedAt, ID: notification.ID.String()}, !unreadOnly || notification.ReadAt == nil
+		})
+		response := models.NotificationListResponse{
+			Notifications: []models.Notification{},
+			UnreadCount:   s.unreadCount(username),
+			NextCursor:    next,
+			HasMore:       next != "",
+		}
+		for _, notification := range page {
+			response.Notifications = append(response.Notifications, *notification)
+		}
+		writeJSON(w, response)
+	}
+}
+
+// handleMarkNotification serves POST /api/notifications/{id}/read and
+// /unread for the signed-in user's notifications
+func (s *Server) handleMarkNotification(read bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		id, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, notification := range s.inboxes[username] {
+			if notification.ID != id {
+				continue
+			}
+			switch {
+			case read && notification.ReadAt == nil:
+				now := time.Now()
+				notification.ReadAt = &now
+			case !read:
+				notification.ReadAt = nil
+			}
+			writeJSON(w, notification)
+			return
+		}
+		http.Error(w, "Notification not found", http.StatusNotFound)
+	}
+}
+
+// handleMarkAllNotificationsRead serves POST /api/notifications/read, which
+// marks every notification of the signed-in user read
+func (s *Server) handleMarkAllNotificationsRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now()
+		marked := 0
+		for _, notification := range s.inboxes[username] {
+			if notification.ReadAt == nil {
+				notification.ReadAt = &now
+				marked++
+			}
+		}
+		writeJSON(w, map[string]int{"marked": marked})
+	}
+}
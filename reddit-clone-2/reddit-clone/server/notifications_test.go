@@ -0,0 +1,119 @@
+// server/notifications_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want []string
+	}{
+		{"Thanks u/alice and /u/bob", []string{"alice", "bob"}},
+		{"u/alice, again u/alice", []string{"alice"}},
+		{"see example.com/u/alice or menu/carol", nil},
+		{"nobody here", nil},
+	} {
+		if got := mentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mentions(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.createSubreddit("golang", "alice")
+
+	post := ts.createPost("golang", "alice", "Hello u/carol and u/nobody")
+	comment := ts.createComment(post.ID, nil, "bob", "Hi u/alice")
+	ts.createComment(post.ID, &comment, "carol", "Hi u/bob")
+	ts.createComment(post.ID, nil, "alice", "My own post")
+
+	var inbox models.NotificationListResponse
+	ts.must("GET", "/api/notifications", "alice", nil, &inbox)
+	if len(inbox.Notifications) != 1 || inbox.UnreadCount != 1 {
+		t.Fatalf("alice got %+v, want one unread notification", inbox)
+	}
+	if n := inbox.Notifications[0]; n.Type != models.NotificationPostReply || n.FromUser != "bob" || *n.CommentID != comment.ID {
+		t.Errorf("alice got %+v, want bob's reply to her post, which mentioned her once", n)
+	}
+	ts.must("GET", "/api/notifications", "bob", nil, &inbox)
+	if len(inbox.Notifications) != 1 || inbox.Notifications[0].Type != models.NotificationCommentReply {
+		t.Errorf("bob got %+v, want carol's reply to his comment, which mentioned him once", inbox.Notifications)
+	}
+	var mentioned models.NotificationListResponse
+	ts.must("GET", "/api/notifications", "carol", nil, &mentioned)
+	if len(mentioned.Notifications) != 1 || mentioned.Notifications[0].Type != models.NotificationMention || mentioned.Notifications[0].CommentID != nil {
+		t.Errorf("carol got %+v, want her mention in alice's post", mentioned.Notifications)
+	}
+
+	if code := ts.do("GET", "/api/notifications", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous notifications: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestMarkNotifications(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Hello")
+	for _, content := range []string{"One", "Two", "Three"} {
+		ts.createComment(post.ID, nil, "bob", content)
+	}
+
+	var inbox models.NotificationListResponse
+	ts.must("GET", "/api/notifications", "alice", nil, &inbox)
+	if len(inbox.Notifications) != 3 || inbox.UnreadCount != 3 {
+		t.Fatalf("got %d notifications, %d unread; want 3 unread", len(inbox.Notifications), inbox.UnreadCount)
+	}
+	newest := inbox.Notifications[0]
+	path := "/api/notifications/" + newest.ID.String()
+
+	var marked models.Notification
+	ts.must("POST", path+"/read", "alice", nil, &marked)
+	if marked.ReadAt == nil {
+		t.Error("notification marked read has no read time")
+	}
+	ts.must("GET", "/api/notifications?unread=true", "alice", nil, &inbox)
+	if len(inbox.Notifications) != 2 || inbox.UnreadCount != 2 {
+		t.Errorf("got %d unread notifications, count %d; want 2", len(inbox.Notifications), inbox.UnreadCount)
+	}
+	for _, n := range inbox.Notifications {
+		if n.ID == newest.ID {
+			t.Error("unread notifications hold the one marked read")
+		}
+	}
+	var unmarked models.Notification
+	ts.must("POST", path+"/unread", "alice", nil, &unmarked)
+	if unmarked.ReadAt != nil {
+		t.Error("notification marked unread kept its read time")
+	}
+
+	for _, tt := range []struct {
+		name, path, user string
+		want             int
+	}{
+		{"anonymously", path + "/read", "", http.StatusUnauthorized},
+		{"with a bad ID", "/api/notifications/not-an-id/read", "alice", http.StatusBadRequest},
+		{"of another user", path + "/read", "bob", http.StatusNotFound},
+	} {
+		if code := ts.do("POST", tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("mark %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var all map[string]int
+	ts.must("POST", "/api/notifications/read", "alice", nil, &all)
+	if all["marked"] != 3 {
+		t.Errorf("marked %d notifications read, want 3", all["marked"])
+	}
+	ts.must("GET", "/api/notifications?unread=true", "alice", nil, &inbox)
+	if len(inbox.Notifications) != 0 || inbox.UnreadCount != 0 {
+		t.Errorf("got %+v after marking all read, want none unread", inbox)
+	}
+}
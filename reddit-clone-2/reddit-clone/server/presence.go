@@ -0,0 +1,125 @@
+// server/presence.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// presence is a user's websocket connections, when they were last seen, and
+// what they received while offline
+type presence struct {
+	sessions map[*Client]time.Time // Open connections, and when each opened
+	lastSeen time.Time             // When the last connection closed, zero if never
+	pending  []models.Delivery     // Received while offline, oldest first
+}
+
+// presenceOf returns a user's presence, creating it on first use. Must be
+// called with s.mu held for writing.
+func (s *Server) presenceOf(username string) *presence {
+	p, exists := s.presence[username]
+	if !exists {
+		p = &presence{sessions: make(map[*Client]time.Time)}
+		s.presence[username] = p
+	}
+	return p
+}
+
+// connect registers a new websocket connection with the hub. The first
+// connection of a signed-in user brings them online and is sent everything
+// queued for them, in one pending push ahead of any live one. Must be called
+// with s.mu held for writing.
+func (s *Server) connect(client *Client) {
+	s.hub.register <- client
+	if client.username == "" {
+		return
+	}
+
+	now := time.Now()
+	p := s.presenceOf(client.username)
+	p.sessions[client] = now
+	if len(p.sessions) > 1 {
+		return
+	}
+	s.events.Publish(events.UserOnline{Username: client.username, Time: now})
+	if len(p.pending) == 0 {
+		return
+	}
+	message, err := json.Marshal(models.PendingPush{Type: "pending", Deliveries: p.pending})
+	if err != nil {
+		return
+	}
+	s.hub.sendTo(client.username, message)
+	p.pending = nil
+}
+
+// disconnect forgets a closed websocket connection. Closing the last one
+// takes the user offline. Must be called with s.mu held for writing.
+func (s *Server) disconnect(client *Client) {
+	p, exists := s.presence[client.username]
+	if !exists {
+		return
+	}
+	if _, open := p.sessions[client]; !open {
+		return
+	}
+	delete(p.sessions, client)
+	if len(p.sessions) > 0 {
+		return
+	}
+	p.lastSeen = time.Now()
+	s.events.Publish(events.UserOffline{Username: client.username, Time: p.lastSeen})
+}
+
+// sendOrQueue sends a push to a user's connections, or queues the delivery
+// until they connect if they have none. Must be called with s.mu held for
+// writing.
+func (s *Server) sendOrQueue(username string, message []byte, delivery models.Delivery) {
+	p := s.presenceOf(username)
+	if len(p.sessions) == 0 {
+		p.pending = append(p.pending, delivery)
+		return
+	}
+	s.hub.sendTo(username, message)
+}
+
+// pushMessage subscribes to direct messages and sends each to its recipient.
+// It runs with s.mu held by the handler that published the event.
+func (s *Server) pushMessage(event events.Event) {
+	sent := event.(events.MessageSent).Message
+	message, err := json.Marshal(models.MessagePush{Type: "message", Message: sent})
+	if err != nil {
+		return
+	}
+	s.sendOrQueue(sent.ToUser, message, models.Delivery{Message: &sent})
+}
+
+// handleGetPresence serves GET /api/users/{username}/presence, whether the
+// user is connected and when they were last seen
+func (s *Server) handleGetPresence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, exists := s.users[username]; !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		response := models.Presence{Username: username}
+		if p, exists := s.presence[username]; exists {
+			response.Online = len(p.sessions) > 0
+			response.Sessions = len(p.sessions)
+			if !p.lastSeen.IsZero() {
+				lastSeen := p.lastSeen
+				response.LastSeen = &lastSeen
+			}
+		}
+		writeJSON(w, response)
+	}
+}
@@ -0,0 +1,108 @@
+// server/presence_test.go
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reddit-clone/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dial opens a websocket connection to server as user
+func dial(t *testing.T, server *httptest.Server, user string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?username=" + user
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// awaitPresence polls a user's presence until it has sessions open
+// connections, failing the test if it does not within a second
+func (ts *testServer) awaitPresence(username string, sessions int) models.Presence {
+	ts.t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		var presence models.Presence
+		ts.must("GET", "/api/users/"+username+"/presence", "", nil, &presence)
+		if presence.Sessions == sessions {
+			return presence
+		}
+		if time.Now().After(deadline) {
+			ts.t.Fatalf("%s has %d sessions, want %d", username, presence.Sessions, sessions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice")
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+
+	if presence := ts.awaitPresence("alice", 0); presence.Online || presence.LastSeen != nil {
+		t.Errorf("got %+v before alice connected, want offline and never seen", presence)
+	}
+	if code := ts.do("GET", "/api/users/nobody/presence", "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("presence of an unknown user: got %d, want %d", code, http.StatusNotFound)
+	}
+
+	first, second := dial(t, server, "alice"), dial(t, server, "alice")
+	if presence := ts.awaitPresence("alice", 2); !presence.Online {
+		t.Errorf("got %+v with two connections open, want online", presence)
+	}
+	first.Close()
+	if presence := ts.awaitPresence("alice", 1); !presence.Online || presence.LastSeen != nil {
+		t.Errorf("got %+v with a connection still open, want online and never seen", presence)
+	}
+	before := time.Now()
+	second.Close()
+	presence := ts.awaitPresence("alice", 0)
+	if presence.Online || presence.LastSeen == nil || presence.LastSeen.Before(before) {
+		t.Errorf("got %+v after the last connection closed, want offline and last seen just now", presence)
+	}
+}
+
+func TestPendingDeliveries(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	server := httptest.NewServer(ts.router)
+	defer server.Close()
+
+	ts.must("POST", "/api/messages", "bob", map[string]string{"to_user": "alice", "content": "One"}, nil)
+	ts.must("POST", "/api/messages", "bob", map[string]string{"to_user": "alice", "content": "Two"}, nil)
+
+	conn := dial(t, server, "alice")
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var pending models.PendingPush
+	if err := conn.ReadJSON(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending.Type != "pending" || len(pending.Deliveries) != 2 || pending.Deliveries[0].Message.Content != "One" {
+		t.Fatalf("got %+v on connecting, want both messages queued, oldest first", pending)
+	}
+
+	// Once online, messages arrive as they are sent
+	ts.must("POST", "/api/messages", "bob", map[string]string{"to_user": "alice", "content": "Three"}, nil)
+	var push models.MessagePush
+	if err := conn.ReadJSON(&push); err != nil {
+		t.Fatal(err)
+	}
+	if push.Type != "message" || push.Message.Content != "Three" {
+		t.Errorf("got %+v, want the live message", push)
+	}
+	ts.mu.RLock()
+	queued := len(ts.presence["alice"].pending)
+	ts.mu.RUnlock()
+	if queued != 0 {
+		t.Errorf("%d deliveries still queued for alice while online", queued)
+	}
+}
@@ -0,0 +1,154 @@
+// server/profiles.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetProfile serves GET /api/users/{username}, the user's karma, how
+// much they have posted and commented, and their follower and following
+// counts
+func (s *Server) handleGetProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+		viewer := r.Header.Get("X-User")
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		user, exists := s.users[username]
+		if !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		_, followed := s.follows[viewer][username]
+		profile := models.Profile{
+			Username:       username,
+			Karma:          user.Karma,
+			PostKarma:      user.PostKarma,
+			CommentKarma:   user.CommentKarma,
+			CreatedAt:      user.CreatedAt,
+			FollowingCount: len(s.follows[username]),
+			Followed:       followed,
+		}
+		for _, post := range s.posts {
+			if post.AuthorName == username {
+				profile.PostCount++
+			}
+		}
+		for _, comment := range s.comments {
+			if comment.AuthorName == username {
+				profile.CommentCount++
+			}
+		}
+		for _, followees := range s.follows {
+			if _, follows := followees[username]; follows {
+				profile.FollowerCount++
+			}
+		}
+		writeJSON(w, profile)
+	}
+}
+
+// handleFollowUser serves POST /api/users/{username}/follow. The user's posts
+// then appear in the signed-in user's home feed, unless the user has blocked
+// them.
+func (s *Server) handleFollowUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		follower, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		followee := mux.Vars(r)["username"]
+		if followee == follower {
+			http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.users[followee]; !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if s.blocked(followee, follower) {
+			http.Error(w, "The user has blocked you", http.StatusForbidden)
+			return
+		}
+		if s.follows[follower] == nil {
+			s.follows[follower] = make(map[string]time.Time)
+		}
+		at, exists := s.follows[follower][followee]
+		if !exists {
+			at = time.Now()
+			s.follows[follower][followee] = at
+		}
+		writeJSON(w, models.Follow{Follower: follower, Followee: followee, FollowedAt: at})
+	}
+}
+
+// handleUnfollowUser serves DELETE /api/users/{username}/follow
+func (s *Server) handleUnfollowUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		follower, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		followee := mux.Vars(r)["username"]
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, follows := s.follows[follower][followee]; !follows {
+			http.Error(w, "You do not follow this user", http.StatusNotFound)
+			return
+		}
+		delete(s.follows[follower], followee)
+		writeJSON(w, map[string]string{"message": "User unfollowed"})
+	}
+}
+
+// handleGetFollows serves GET /api/users/{username}/followers, or
+// /following when followers is false, newest follow first
+func (s *Server) handleGetFollows(followers bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := mux.Vars(r)["username"]
+		scope := "following:" + username
+		if followers {
+			scope = "followers:" + username
+		}
+		l, err := parseListing(r.URL.Query(), scope)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		if _, exists := s.users[username]; !exists {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		var follows []models.Follow
+		if followers {
+			for follower, followees := range s.follows {
+				if at, followed := followees[username]; followed {
+					follows = append(follows, models.Follow{Follower: follower, Followee: username, FollowedAt: at})
+				}
+			}
+		} else {
+			for followee, at := range s.follows[username] {
+				follows = append(follows, models.Follow{Follower: username, Followee: followee, FollowedAt: at})
+			}
+		}
+
+		page, next := pageOf(l, follows, func(follow models.Follow) (pagination.Position, bool) {
+			return pagination.Position{Time: follow.FollowedAt, ID: follow.Follower + ":" + follow.Followee}, true
+		})
+		response := models.FollowListResponse{Follows: append([]models.Follow{}, page...), NextCursor: next, HasMore: next != ""}
+		writeJSON(w, response)
+	}
+}
@@ -0,0 +1,125 @@
+// server/profiles_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Hello")
+	ts.createPost("golang", "alice", "Again")
+	comment := ts.createComment(post.ID, nil, "alice", "First")
+	ts.votePost(post, "bob", true)
+	ts.must("POST", "/api/comments/"+comment.ID.String()+"/vote", "carol", map[string]bool{"is_upvote": true}, nil)
+	ts.must("POST", "/api/users/alice/follow", "bob", nil, nil)
+	ts.must("POST", "/api/users/carol/follow", "alice", nil, nil)
+
+	var profile models.Profile
+	ts.must("GET", "/api/users/alice", "bob", nil, &profile)
+	want := models.Profile{
+		Username: "alice", Karma: 2, PostKarma: 1, CommentKarma: 1, CreatedAt: profile.CreatedAt,
+		PostCount: 2, CommentCount: 1, FollowerCount: 1, FollowingCount: 1, Followed: true,
+	}
+	if profile != want {
+		t.Errorf("got profile %+v, want %+v", profile, want)
+	}
+	var anonymous models.Profile
+	ts.must("GET", "/api/users/alice", "", nil, &anonymous)
+	if anonymous.Followed {
+		t.Error("anonymous viewer follows alice")
+	}
+	if code := ts.do("GET", "/api/users/nobody", "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("profile of an unknown user: got %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol", "dave")
+
+	for _, tt := range []struct {
+		name, user, followee string
+		want                 int
+	}{
+		{"anonymously", "", "alice", http.StatusUnauthorized},
+		{"yourself", "alice", "alice", http.StatusBadRequest},
+		{"an unknown user", "alice", "nobody", http.StatusNotFound},
+	} {
+		if code := ts.do("POST", "/api/users/"+tt.followee+"/follow", tt.user, nil, nil); code != tt.want {
+			t.Errorf("follow %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	var first, again models.Follow
+	ts.must("POST", "/api/users/alice/follow", "bob", nil, &first)
+	ts.must("POST", "/api/users/alice/follow", "bob", nil, &again)
+	if first.Follower != "bob" || first.Followee != "alice" || !again.FollowedAt.Equal(first.FollowedAt) {
+		t.Errorf("following twice gave %+v then %+v, want the same follow", first, again)
+	}
+	ts.must("POST", "/api/users/alice/follow", "carol", nil, nil)
+	ts.must("POST", "/api/users/alice/follow", "dave", nil, nil)
+	ts.must("POST", "/api/users/carol/follow", "bob", nil, nil)
+
+	users := func(path string) []string {
+		t.Helper()
+		var served []string
+		next := path + "?limit=2"
+		for {
+			var list models.FollowListResponse
+			ts.must("GET", next, "", nil, &list)
+			for _, follow := range list.Follows {
+				if path == "/api/users/alice/followers" {
+					served = append(served, follow.Follower)
+				} else {
+					served = append(served, follow.Followee)
+				}
+			}
+			if !list.HasMore {
+				return served
+			}
+			next = path + "?limit=2&cursor=" + list.NextCursor
+		}
+	}
+	if got := users("/api/users/alice/followers"); !reflect.DeepEqual(got, []string{"dave", "carol", "bob"}) {
+		t.Errorf("alice's followers are %v, want dave, carol and bob", got)
+	}
+	if got := users("/api/users/bob/following"); !reflect.DeepEqual(got, []string{"carol", "alice"}) {
+		t.Errorf("bob follows %v, want carol and alice", got)
+	}
+	for _, path := range []string{"/api/users/nobody/followers", "/api/users/nobody/following"} {
+		if code := ts.do("GET", path, "", nil, nil); code != http.StatusNotFound {
+			t.Errorf("%s: got %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+
+	ts.must("DELETE", "/api/users/alice/follow", "bob", nil, nil)
+	if code := ts.do("DELETE", "/api/users/alice/follow", "bob", nil, nil); code != http.StatusNotFound {
+		t.Errorf("unfollowing twice: got %d, want %d", code, http.StatusNotFound)
+	}
+	if got := users("/api/users/alice/followers"); !reflect.DeepEqual(got, []string{"dave", "carol"}) {
+		t.Errorf("alice's followers are %v after bob unfollowed, want dave and carol", got)
+	}
+}
+
+func TestFollowedUsersFillTheFeed(t *testing.T) {
+	ts := newTestServer(t)
+	seedFeed(ts)
+	ts.must("POST", "/api/users/alice/follow", "reader", nil, nil)
+
+	var feed models.FeedResponse
+	ts.must("GET", "/api/feed?sort=new", "reader", nil, &feed)
+	if got := titles(feed.Posts); !reflect.DeepEqual(got, []string{"new", "mid", "old"}) {
+		t.Errorf("feed of a user following alice alone is %v, want alice's posts", got)
+	}
+	ts.must("POST", "/api/subreddits/rust/join", "reader", nil, nil)
+	ts.must("GET", "/api/feed?sort=new", "reader", nil, &feed)
+	if got := titles(feed.Posts); !reflect.DeepEqual(got, []string{"rusty", "new", "mid", "old"}) {
+		t.Errorf("feed of a r/rust member following alice is %v, want rusty and alice's posts", got)
+	}
+}
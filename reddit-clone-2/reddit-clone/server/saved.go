@@ -0,0 +1,188 @@
+// server/saved.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// isHidden reports whether a user has hidden a post. Must be called with
+// s.mu held.
+func (s *Server) isHidden(username string, postID uuid.UUID) bool {
+	_, hidden := s.hidden[username][postID]
+	return hidden
+}
+
+// mark adds id to a user's entry in lists, or removes it with set false. An
+// id already listed keeps the time it was added. Must be called with s.mu
+// held for writing.
+func mark(lists map[string]map[uuid.UUID]time.Time, username string, id uuid.UUID, set bool) {
+	if !set {
+		delete(lists[username], id)
+		return
+	}
+	if lists[username] == nil {
+		lists[username] = make(map[uuid.UUID]time.Time)
+	}
+	if _, exists := lists[username][id]; !exists {
+		lists[username][id] = time.Now()
+	}
+}
+
+// handleSavePost serves POST /api/posts/{id}/save, and DELETE to unsave
+func (s *Server) handleSavePost(save bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.posts[postID]; !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		mark(s.saved, username, postID, save)
+		writeJSON(w, map[string]bool{"saved": save})
+	}
+}
+
+// handleSaveComment serves POST /api/comments/{id}/save, and DELETE to unsave
+func (s *Server) handleSaveComment(save bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		commentID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.comments[commentID]; !exists {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+			return
+		}
+		mark(s.saved, username, commentID, save)
+		writeJSON(w, map[string]bool{"saved": save})
+	}
+}
+
+// handleGetSaved serves GET /api/saved, the signed-in user's saved posts and
+// comments, most recently saved first. Deleted content stays in the list as
+// its tombstone.
+func (s *Server) handleGetSaved() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		l, err := parseListing(r.URL.Query(), "saved:"+username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		var items []models.SavedItem
+		for id, at := range s.saved[username] {
+			item := models.SavedItem{SavedAt: at}
+			if post, exists := s.posts[id]; exists {
+				shown := s.postFor(post, username)
+				item.Post = &shown
+			} else if comment, exists := s.comments[id]; exists {
+				shown := s.commentFor(comment, username)
+				item.Comment = &shown
+			} else {
+				continue
+			}
+			items = append(items, item)
+		}
+
+		page, next := pageOf(l, items, func(item models.SavedItem) (pagination.Position, bool) {
+			var id uuid.UUID
+			if item.Post != nil {
+				id = item.Post.ID
+			} else {
+				id = item.Comment.ID
+			}
+			return pagination.Position{Time: item.SavedAt, ID: id.String()}, true
+		})
+		response := models.SavedListResponse{Items: append([]models.SavedItem{}, page...), NextCursor: next, HasMore: next != ""}
+		writeJSON(w, response)
+	}
+}
+
+// handleHidePost serves POST /api/posts/{id}/hide, which keeps the post out
+// of the signed-in user's feed and subreddit listings, and DELETE to unhide
+func (s *Server) handleHidePost(hide bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		postID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid post ID", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, exists := s.posts[postID]; !exists {
+			http.Error(w, "Post not found", http.StatusNotFound)
+			return
+		}
+		mark(s.hidden, username, postID, hide)
+		writeJSON(w, map[string]bool{"hidden": hide})
+	}
+}
+
+// handleGetHidden serves GET /api/hidden, the posts the signed-in user has
+// hidden, most recently hidden first
+func (s *Server) handleGetHidden() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := requireUser(w, r)
+		if !ok {
+			return
+		}
+		l, err := parseListing(r.URL.Query(), "hidden:"+username)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		var posts []*models.Post
+		for id := range s.hidden[username] {
+			if post, exists := s.posts[id]; exists {
+				posts = append(posts, post)
+			}
+		}
+		hidden := s.hidden[username]
+		page, next := pageOf(l, posts, func(post *models.Post) (pagination.Position, bool) {
+			return pagination.Position{Time: hidden[post.ID], ID: post.ID.String()}, true
+		})
+		response := models.FeedResponse{Posts: []models.Post{}, NextCursor: next, HasMore: next != ""}
+		for _, post := range page {
+			response.Posts = append(response.Posts, s.postFor(post, username))
+		}
+		writeJSON(w, response)
+	}
+}
@@ -0,0 +1,123 @@
+// server/saved_test.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSaved(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	ts.createSubreddit("golang", "alice")
+	kept := ts.createPost("golang", "alice", "Kept")
+	deleted := ts.createPost("golang", "alice", "Deleted")
+	comment := ts.createComment(kept.ID, nil, "alice", "Noted")
+
+	for _, tt := range []struct {
+		name, path, user string
+		want             int
+	}{
+		{"anonymously", "/api/posts/" + kept.ID.String() + "/save", "", http.StatusUnauthorized},
+		{"with a bad ID", "/api/posts/not-an-id/save", "bob", http.StatusBadRequest},
+		{"of an unknown post", "/api/posts/" + uuid.New().String() + "/save", "bob", http.StatusNotFound},
+		{"of an unknown comment", "/api/comments/" + uuid.New().String() + "/save", "bob", http.StatusNotFound},
+	} {
+		if code := ts.do("POST", tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("save %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	ts.must("POST", "/api/posts/"+kept.ID.String()+"/save", "bob", nil, nil)
+	ts.must("POST", "/api/comments/"+comment.ID.String()+"/save", "bob", nil, nil)
+	ts.must("POST", "/api/posts/"+deleted.ID.String()+"/save", "bob", nil, nil)
+	// Saving again keeps the item where it was
+	ts.must("POST", "/api/posts/"+kept.ID.String()+"/save", "bob", nil, nil)
+	ts.must("PATCH", "/api/posts/"+kept.ID.String(), "alice", map[string]string{"content": "Edited"}, nil)
+	ts.must("DELETE", "/api/posts/"+deleted.ID.String(), "alice", nil, nil)
+
+	var saved models.SavedListResponse
+	ts.must("GET", "/api/saved", "bob", nil, &saved)
+	if len(saved.Items) != 3 {
+		t.Fatalf("bob saved %+v, want three items", saved.Items)
+	}
+	if post := saved.Items[0].Post; post == nil || post.Title != models.DeletedContent {
+		t.Errorf("newest saved item is %+v, want the deleted post's tombstone", saved.Items[0])
+	}
+	if c := saved.Items[1].Comment; c == nil || c.ID != comment.ID || saved.Items[1].Post != nil {
+		t.Errorf("second saved item is %+v, want the comment alone", saved.Items[1])
+	}
+	if post := saved.Items[2].Post; post == nil || post.ID != kept.ID || post.Content != "Edited" {
+		t.Errorf("oldest saved item is %+v, want the kept post as edited", saved.Items[2])
+	}
+	var other models.SavedListResponse
+	ts.must("GET", "/api/saved", "alice", nil, &other)
+	if len(other.Items) != 0 {
+		t.Errorf("alice sees bob's saved items %+v", other.Items)
+	}
+
+	ts.must("DELETE", "/api/posts/"+kept.ID.String()+"/save", "bob", nil, nil)
+	ts.must("DELETE", "/api/comments/"+comment.ID.String()+"/save", "bob", nil, nil)
+	var after models.SavedListResponse
+	ts.must("GET", "/api/saved", "bob", nil, &after)
+	if len(after.Items) != 1 || after.Items[0].Post == nil || after.Items[0].Post.ID != deleted.ID {
+		t.Errorf("bob saved %+v after unsaving, want the deleted post alone", after.Items)
+	}
+	if code := ts.do("GET", "/api/saved", "", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous saved list: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestHidden(t *testing.T) {
+	ts := newTestServer(t)
+	seedFeed(ts)
+	var feed models.FeedResponse
+	ts.must("GET", "/api/subreddits/golang/posts?sort=new", "", nil, &feed)
+	newest, oldest := feed.Posts[0], feed.Posts[2]
+
+	ts.must("POST", "/api/posts/"+oldest.ID.String()+"/hide", "reader", nil, nil)
+	ts.must("POST", "/api/posts/"+newest.ID.String()+"/hide", "reader", nil, nil)
+	for _, path := range []string{"/api/feed?sort=new", "/api/subreddits/golang/posts?sort=new"} {
+		var shown models.FeedResponse
+		ts.must("GET", path, "reader", nil, &shown)
+		for _, title := range titles(shown.Posts) {
+			if title == "new" || title == "old" {
+				t.Errorf("%s shows the hidden post %q", path, title)
+			}
+		}
+	}
+	var other models.FeedResponse
+	ts.must("GET", "/api/feed?sort=new", "carol", nil, &other)
+	if len(other.Posts) != 4 {
+		t.Errorf("carol's feed is %v, want all four posts whatever reader hid", titles(other.Posts))
+	}
+
+	var hidden models.FeedResponse
+	ts.must("GET", "/api/hidden", "reader", nil, &hidden)
+	if got := titles(hidden.Posts); !reflect.DeepEqual(got, []string{"new", "old"}) {
+		t.Errorf("reader hid %v, want new then old, the most recently hidden first", got)
+	}
+
+	ts.must("DELETE", "/api/posts/"+newest.ID.String()+"/hide", "reader", nil, nil)
+	var after models.FeedResponse
+	ts.must("GET", "/api/hidden", "reader", nil, &after)
+	if got := titles(after.Posts); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("reader hid %v after unhiding new, want old alone", got)
+	}
+	for _, tt := range []struct {
+		name, method, path, user string
+		want                     int
+	}{
+		{"hide anonymously", "POST", "/api/posts/" + newest.ID.String() + "/hide", "", http.StatusUnauthorized},
+		{"hide an unknown post", "POST", "/api/posts/" + uuid.New().String() + "/hide", "reader", http.StatusNotFound},
+		{"list anonymously", "GET", "/api/hidden", "", http.StatusUnauthorized},
+	} {
+		if code := ts.do(tt.method, tt.path, tt.user, nil, nil); code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// server/search.go
+package main
+
+import (
+	"net/http"
+	"reddit-clone/events"
+	"reddit-clone/models"
+	"reddit-clone/pagination"
+	"reddit-clone/search"
+	"strings"
+)
+
+// indexContent subscribes to new posts and comments and adds them to the
+// search index. It runs with s.mu held by the handler that published the
+// event.
+func (s *Server) indexContent(event events.Event) {
+	switch event := event.(type) {
+	case events.PostCreated:
+		s.index.Add(postDocument(&event.Post))
+	case events.CommentCreated:
+		s.index.Add(commentDocument(&event.Comment, event.Subreddit))
+	}
+}
+
+// postDocument returns the searchable form of a post
+func postDocument(post *models.Post) search.Document {
+	return search.Document{
+		ID:        post.ID,
+		Kind:      search.KindPost,
+		Subreddit: post.SubredditName,
+		Author:    post.AuthorName,
+		CreatedAt: post.CreatedAt,
+		Text:      post.Title + "\n" + post.Content,
+	}
+}
+
+// commentDocument returns the searchable form of a comment made in subreddit
+func commentDocument(comment *models.Comment, subreddit string) search.Document {
+	return search.Document{
+		ID:        comment.ID,
+		Kind:      search.KindComment,
+		Subreddit: subreddit,
+		Author:    comment.AuthorName,
+		CreatedAt: comment.CreatedAt,
+		Text:      comment.Content,
+	}
+}
+
+// handleSearch serves GET /api/search?q=...&sort=relevance|new|top, posts and
+// comments holding every word of q, page by page. The query may filter with
+// subreddit:, author:, type:, after: and before:, as search.ParseQuery
+// describes. Content hidden from the viewer or by users they blocked is left
+// out.
+func (s *Server) handleSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		text := strings.Join(strings.Fields(r.URL.Query().Get("q")), " ")
+		if text == "" {
+			http.Error(w, "Search query is required", http.StatusBadRequest)
+			return
+		}
+		query, err := search.ParseQuery(text)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		order, err := search.ParseSort(r.URL.Query().Get("sort"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		l, err := parseListing(r.URL.Query(), "search:"+string(order)+":"+text)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		viewer := r.Header.Get("X-User")
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		var results []search.Result
+		for _, result := range s.index.Search(query) {
+			if s.searchable(result, viewer) {
+				results = append(results, result)
+			}
+		}
+
+		position := func(result search.Result) (pagination.Position, bool) {
+			pos := pagination.Position{Time: result.CreatedAt, ID: result.ID.String()}
+			switch order {
+			case search.SortRelevance:
+				pos.Key = result.Score
+			case search.SortTop:
+				if result.Kind == search.KindPost {
+					pos.Key = float64(s.posts[result.ID].Score)
+				} else {
+					pos.Key = float64(s.comments[result.ID].Score)
+				}
+			}
+			return pos, true
+		}
+		var page []search.Result
+		var next string
+		if order == search.SortNew {
+			page, next = pageOf(l, results, position)
+		} else if page, next, err = rankedPageOf(s.orders, viewer, l, results, position); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := models.SearchResponse{Results: []models.SearchResult{}, NextCursor: next, HasMore: next != ""}
+		for _, result := range page {
+			found := models.SearchResult{Type: string(result.Kind), Score: result.Score}
+			if result.Kind == search.KindPost {
+				post := *s.posts[result.ID]
+				found.Post = &post
+			} else {
+				comment := *s.comments[result.ID]
+				found.Comment = &comment
+			}
+			response.Results = append(response.Results, found)
+		}
+		writeJSON(w, response)
+	}
+}
+
+// searchable reports whether a search result is shown to viewer: it must
+// still exist and be visible to them, as must the post of a comment, and its
+// author must not be blocked by them. Must be called with s.mu held.
+func (s *Server) searchable(result search.Result, viewer string) bool {
+	if result.Kind == search.KindPost {
+		post, exists := s.posts[result.ID]
+		return exists && s.postVisible(post, viewer) && !s.blocked(viewer, post.AuthorName)
+	}
+	comment, exists := s.comments[result.ID]
+	if !exists || !s.commentVisible(comment, viewer) || s.blocked(viewer, comment.AuthorName) {
+		return false
+	}
+	post, exists := s.posts[comment.PostID]
+	return exists && s.postVisible(post, viewer)
+}
@@ -0,0 +1,162 @@
+// server/search_test.go
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reddit-clone/models"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// search runs a search as user and returns the IDs of the posts and
+// comments found, in the order served
+func (ts *testServer) search(q, user string) []uuid.UUID {
+	ts.t.Helper()
+	var response models.SearchResponse
+	ts.must("GET", "/api/search?q="+url.QueryEscape(q), user, nil, &response)
+	var ids []uuid.UUID
+	for _, result := range response.Results {
+		if result.Post != nil {
+			ids = append(ids, result.Post.ID)
+		} else {
+			ids = append(ids, result.Comment.ID)
+		}
+	}
+	return ids
+}
+
+// sameIDs reports whether got and want hold the same IDs in any order
+func sameIDs(got, want []uuid.UUID) bool {
+	counts := make(map[uuid.UUID]int)
+	for _, id := range got {
+		counts[id]++
+	}
+	for _, id := range want {
+		counts[id]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSearch(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol", "reader")
+	ts.createSubreddit("golang", "alice")
+	kept := ts.createPost("golang", "alice", "Generics explained")
+	removed := ts.createPost("golang", "bob", "Generics removed")
+	blocked := ts.createPost("golang", "carol", "Generics by carol")
+	comment := ts.createComment(kept.ID, nil, "bob", "Generics are great")
+	orphaned := ts.createComment(removed.ID, nil, "bob", "Generics under a removed post")
+	ts.must("POST", "/api/posts/"+removed.ID.String()+"/moderate", "alice", map[string]string{"action": "remove"}, nil)
+	ts.must("POST", "/api/blocks", "reader", map[string]string{"username": "carol"}, nil)
+
+	for _, tt := range []struct {
+		name, q, user string
+		want          []uuid.UUID
+	}{
+		{"reader", "generics", "reader", []uuid.UUID{kept.ID, comment.ID}},
+		{"anonymously", "generics", "", []uuid.UUID{kept.ID, blocked.ID, comment.ID}},
+		{"as a moderator", "generics", "alice", []uuid.UUID{kept.ID, removed.ID, blocked.ID, comment.ID, orphaned.ID}},
+		{"for comments", "generics type:comment", "reader", []uuid.UUID{comment.ID}},
+		{"by author", "u/alice", "reader", []uuid.UUID{kept.ID}},
+	} {
+		if got := ts.search(tt.q, tt.user); !sameIDs(got, tt.want) {
+			t.Errorf("search %s for %q found %v, want %v", tt.name, tt.q, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name, path string
+		want       int
+	}{
+		{"without a query", "/api/search?q=+", http.StatusBadRequest},
+		{"with a bad date", "/api/search?q=after:yesterday", http.StatusBadRequest},
+		{"of stop words alone", "/api/search?q=the", http.StatusBadRequest},
+		{"with an unknown sort", "/api/search?q=go&sort=hot", http.StatusBadRequest},
+		{"with a bad cursor", "/api/search?q=go&cursor=not-a-cursor", http.StatusBadRequest},
+	} {
+		if code := ts.do("GET", tt.path, "", nil, nil); code != tt.want {
+			t.Errorf("search %s: got %d, want %d", tt.name, code, tt.want)
+		}
+	}
+}
+
+func TestSearchFollowsEdits(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob")
+	ts.createSubreddit("golang", "alice")
+	post := ts.createPost("golang", "alice", "Generics")
+	comment := ts.createComment(post.ID, nil, "bob", "Channels")
+
+	ts.must("PATCH", "/api/posts/"+post.ID.String(), "alice", map[string]string{"content": "Lifetimes"}, nil)
+	ts.must("PATCH", "/api/comments/"+comment.ID.String(), "bob", map[string]string{"content": "Goroutines"}, nil)
+	for _, tt := range []struct {
+		q    string
+		want []uuid.UUID
+	}{
+		{"lifetimes", []uuid.UUID{post.ID}},
+		{"generics", []uuid.UUID{post.ID}}, // The title is kept
+		{"goroutines", []uuid.UUID{comment.ID}},
+		{"channels", nil},
+	} {
+		if got := ts.search(tt.q, ""); !sameIDs(got, tt.want) {
+			t.Errorf("search for %q after the edits found %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	ts.must("DELETE", "/api/comments/"+comment.ID.String(), "bob", nil, nil)
+	if got := ts.search("goroutines", ""); len(got) != 0 {
+		t.Errorf("search found the deleted comment: %v", got)
+	}
+	ts.must("DELETE", "/api/posts/"+post.ID.String(), "alice", nil, nil)
+	if got := ts.search("generics", ""); len(got) != 0 {
+		t.Errorf("search found the deleted post: %v", got)
+	}
+	if n := ts.index.Len(); n != 0 {
+		t.Errorf("index still holds %d documents", n)
+	}
+}
+
+func TestSearchTopPages(t *testing.T) {
+	ts := newTestServer(t)
+	ts.register("alice", "bob", "carol", "dave")
+	ts.createSubreddit("golang", "alice")
+	var posts []models.Post
+	for _, title := range []string{"Generics one", "Generics two", "Generics three", "Generics four"} {
+		posts = append(posts, ts.createPost("golang", "alice", title))
+	}
+	for _, voter := range []string{"bob", "carol", "dave"} {
+		ts.votePost(posts[2], voter, true)
+	}
+	ts.votePost(posts[0], "bob", true)
+	ts.votePost(posts[0], "carol", true)
+	ts.votePost(posts[3], "bob", true)
+
+	var served []string
+	path := "/api/search?q=generics&sort=top&limit=2"
+	for {
+		var response models.SearchResponse
+		ts.must("GET", path, "", nil, &response)
+		for _, result := range response.Results {
+			served = append(served, result.Post.Title)
+		}
+		if !response.HasMore {
+			break
+		}
+		// Votes cast between pages leave the order of the listing alone
+		ts.votePost(posts[1], "bob", true)
+		ts.votePost(posts[1], "carol", true)
+		ts.votePost(posts[1], "dave", true)
+		path = "/api/search?q=generics&sort=top&limit=2&cursor=" + response.NextCursor
+	}
+	if want := []string{"Generics three", "Generics one", "Generics four", "Generics two"}; !reflect.DeepEqual(served, want) {
+		t.Errorf("pages served %v, want %v", served, want)
+	}
+}
@@ -0,0 +1,106 @@
+// server/server_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reddit-clone/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testServer drives a Server through its routes, as a client would
+type testServer struct {
+	*Server
+	t *testing.T
+}
+
+func newTestServer(t *testing.T) *testServer {
+	return &testServer{Server: NewServer(), t: t}
+}
+
+// send makes a request as user, anonymous if empty, with body encoded as
+// JSON unless it is nil
+func (ts *testServer) send(method, path, user string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	rec := httptest.NewRecorder()
+	ts.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// do makes a request and returns its status code, decoding a successful
+// response into out unless it is nil
+func (ts *testServer) do(method, path, user string, body, out interface{}) int {
+	ts.t.Helper()
+	rec := ts.send(method, path, user, body)
+	if out != nil && rec.Code < http.StatusMultipleChoices {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			ts.t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+// must is do for requests that have to succeed
+func (ts *testServer) must(method, path, user string, body, out interface{}) {
+	ts.t.Helper()
+	rec := ts.send(method, path, user, body)
+	if rec.Code >= http.StatusMultipleChoices {
+		ts.t.Fatalf("%s %s as %q: %d %s", method, path, user, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			ts.t.Fatalf("%s %s: decode %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+}
+
+func (ts *testServer) register(usernames ...string) {
+	ts.t.Helper()
+	for _, username := range usernames {
+		ts.must("POST", "/api/register", "", map[string]string{"username": username, "password": "secret"}, nil)
+	}
+}
+
+// createSubreddit creates a subreddit owned by creator, who joins it
+func (ts *testServer) createSubreddit(name, creator string) {
+	ts.t.Helper()
+	ts.must("POST", "/api/subreddits", creator, map[string]string{"name": name}, nil)
+	ts.must("POST", "/api/subreddits/"+name+"/join", creator, nil, nil)
+}
+
+func (ts *testServer) createPost(subreddit, author, title string) models.Post {
+	ts.t.Helper()
+	var post models.Post
+	ts.must("POST", "/api/posts", author, map[string]string{"title": title, "content": title, "subreddit": subreddit}, &post)
+	return post
+}
+
+// createComment comments on a post, or replies to parent unless it is nil
+func (ts *testServer) createComment(postID uuid.UUID, parent *models.Comment, author, content string) models.Comment {
+	ts.t.Helper()
+	body := map[string]interface{}{"content": content}
+	if parent != nil {
+		body["parent_id"] = parent.ID
+	}
+	var comment models.Comment
+	ts.must("POST", "/api/posts/"+postID.String()+"/comments", author, body, &comment)
+	return comment
+}
@@ -190,7 +190,7 @@ func (s *Simulator) simulateCreatePost(ctx context.Context, client *reddit.Clien
 
 func (s *Simulator) simulateCreateComment(ctx context.Context, client *reddit.Client, username string) {
 	// Simulate commenting on a random post
-	feed, err := client.GetFeed(ctx, 0, 10)
+	feed, err := client.GetFeed(ctx, "", 10)
 	if err != nil || len(feed.Posts) == 0 {
 		return
 	}
@@ -209,7 +209,7 @@ func (s *Simulator) simulateCreateComment(ctx context.Context, client *reddit.Cl
 }
 
 func (s *Simulator) simulateVote(ctx context.Context, client *reddit.Client, username string) {
-	feed, err := client.GetFeed(ctx, 0, 10)
+	feed, err := client.GetFeed(ctx, "", 10)
 	if err != nil || len(feed.Posts) == 0 {
 		return
 	}
@@ -248,7 +248,7 @@ func (s *Simulator) simulateMessage(ctx context.Context, client *reddit.Client,
 }
 
 func (s *Simulator) simulateReadFeed(ctx context.Context, client *reddit.Client) {
-	_, err := client.GetFeed(ctx, 0, 20)
+	_, err := client.GetFeed(ctx, "", 20)
 	if err != nil {
 		log.Printf("Failed to read feed: %v", err)
 	}
@@ -96,7 +96,7 @@ func testClient(clientID int) {
 
 	// 7. Get Feed
 	log.Printf("Client %d: Fetching feed", clientID)
-	feed, err := client.GetFeed(ctx, 0, 10)
+	feed, err := client.GetFeed(ctx, "", 10)
 	if err != nil {
 		log.Printf("Client %d: Get feed failed: %v", clientID, err)
 		return
@@ -115,12 +115,12 @@ func testClient(clientID int) {
 
 	// 9. View Messages
 	log.Printf("Client %d: Checking messages", clientID)
-	messages, err := client.GetMessages(ctx)
+	messages, err := client.GetMessages(ctx, "", 0)
 	if err != nil {
 		log.Printf("Client %d: Get messages failed: %v", clientID, err)
 		return
 	}
-	log.Printf("Client %d: Retrieved %d messages", clientID, len(messages))
+	log.Printf("Client %d: Retrieved %d messages", clientID, len(messages.Messages))
 
 	log.Printf("Client %d: All tests completed successfully", clientID)
 }
@@ -41,11 +41,11 @@ func main() {
 	engine := engine.NewEngine()
 
 	// Register some users
-	user1 := engine.RegisterAccount("user1")
-	user2 := engine.RegisterAccount("user2")
+	user1, _ := engine.RegisterAccount("user1")
+	user2, _ := engine.RegisterAccount("user2")
 
 	// Create a subreddit
-	engine.CreateSubreddit("golang")
+	engine.CreateSubreddit("golang", user1.Username)
 
 	// Simulate user activity
 	go simulateUserActivity(engine, user1)
@@ -0,0 +1,368 @@
+package actor
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"reddit-clone/engine"
+)
+
+// mailboxSize is the buffer of every actor's mailbox.
+const mailboxSize = 64
+
+// registry owns the name and ID directories. It only ever replies to
+// messages and never waits on another actor, so any actor may call it.
+type registry struct {
+	mailbox    chan interface{}
+	users      map[string]*userActor
+	subreddits map[string]*subredditActor
+	owners     map[string]*subredditActor // Owning subreddit of every post and comment
+	tier       *actorTiers
+}
+
+func (r *registry) run() {
+	for msg := range r.mailbox {
+		switch msg := msg.(type) {
+		case registerUserMsg:
+			if _, exists := r.users[msg.username]; exists {
+				msg.reply <- registerUserReply{err: fmt.Errorf("%w: %s", engine.ErrUserExists, msg.username)}
+				continue
+			}
+			user := r.tier.spawnUser(msg.username)
+			r.users[msg.username] = user
+			msg.reply <- registerUserReply{user: user.state}
+
+		case createSubredditMsg:
+			if _, exists := r.users[msg.creator]; !exists {
+				msg.reply <- fmt.Errorf("%w: %s", engine.ErrUserNotFound, msg.creator)
+				continue
+			}
+			if _, exists := r.subreddits[msg.name]; exists {
+				msg.reply <- fmt.Errorf("%w: %s", engine.ErrSubredditExists, msg.name)
+				continue
+			}
+			r.subreddits[msg.name] = r.tier.spawnSubreddit(msg.name, msg.creator)
+			msg.reply <- nil
+
+		case lookupMsg:
+			msg.reply <- r.lookup(msg)
+
+		case indexMsg:
+			r.owners[msg.id] = msg.owner
+			msg.reply <- struct{}{}
+
+		case listSubredditsMsg:
+			subreddits := make([]*subredditActor, 0, len(r.subreddits))
+			for _, subreddit := range r.subreddits {
+				subreddits = append(subreddits, subreddit)
+			}
+			msg.reply <- subreddits
+		}
+	}
+}
+
+func (r *registry) lookup(msg lookupMsg) lookupReply {
+	var reply lookupReply
+	for _, username := range msg.usernames {
+		user, exists := r.users[username]
+		if !exists {
+			return lookupReply{err: fmt.Errorf("%w: %s", engine.ErrUserNotFound, username)}
+		}
+		reply.users = append(reply.users, user)
+	}
+	if msg.subreddit != "" {
+		subreddit, exists := r.subreddits[msg.subreddit]
+		if !exists {
+			return lookupReply{err: fmt.Errorf("%w: %s", engine.ErrSubredditNotFound, msg.subreddit)}
+		}
+		reply.subreddit = subreddit
+	}
+	if msg.target != "" {
+		owner, exists := r.owners[msg.target]
+		if !exists {
+			return lookupReply{err: fmt.Errorf("%w: %s", engine.ErrTargetNotFound, msg.target)}
+		}
+		reply.owner = owner
+	}
+	return reply
+}
+
+// voteKey identifies a single user's vote on a post or comment
+type voteKey struct {
+	username string
+	targetID string
+}
+
+// subredditActor owns a subreddit together with its posts, comments and votes.
+// It only sends fire-and-forget messages to user actors, so it never blocks
+// on anything but its own mailbox.
+type subredditActor struct {
+	mailbox  chan interface{}
+	state    *engine.Subreddit
+	posts    map[string]*engine.Post
+	comments map[string]*engine.Comment
+	votes    map[voteKey]int
+	users    map[string]*userActor // Actors of the authors seen in this subreddit
+}
+
+func (s *subredditActor) run() {
+	for msg := range s.mailbox {
+		switch msg := msg.(type) {
+		case joinMsg:
+			s.state.Members[msg.user.Username] = msg.user
+			msg.reply <- struct{}{}
+
+		case leaveMsg:
+			if _, isMember := s.state.Members[msg.username]; !isMember {
+				msg.reply <- fmt.Errorf("%w: %s in %s", engine.ErrNotMember, msg.username, s.state.Name)
+				continue
+			}
+			delete(s.state.Members, msg.username)
+			msg.reply <- nil
+
+		case createPostMsg:
+			msg.reply <- s.createPost(msg)
+
+		case getPostMsg:
+			msg.reply <- s.posts[msg.postID]
+
+		case createCommentMsg:
+			msg.reply <- s.createComment(msg)
+
+		case voteMsg:
+			msg.reply <- s.vote(msg)
+
+		case memberPostsMsg:
+			if _, isMember := s.state.Members[msg.username]; !isMember {
+				msg.reply <- nil
+				continue
+			}
+			msg.reply <- append([]*engine.Post(nil), s.state.Posts...)
+		}
+	}
+}
+
+func (s *subredditActor) createPost(msg createPostMsg) *engine.Post {
+	post := &engine.Post{
+		ID:        fmt.Sprintf("%d", rand.Int()),
+		Author:    msg.author.state,
+		Subreddit: s.state,
+		Content:   msg.content,
+		Timestamp: time.Now(),
+	}
+	if msg.original != nil {
+		post.ID = fmt.Sprintf("post-%d", rand.Int())
+		post.IsRepost = true
+		post.OriginalPost = msg.original
+	}
+	s.state.Posts = append(s.state.Posts, post)
+	s.posts[post.ID] = post
+	s.users[msg.author.state.Username] = msg.author
+	msg.author.mailbox <- recordPostMsg{post: post}
+	return post
+}
+
+func (s *subredditActor) createComment(msg createCommentMsg) commentReply {
+	post, exists := s.posts[msg.postID]
+	if !exists {
+		return commentReply{err: fmt.Errorf("%w: %s in %s", engine.ErrPostNotFound, msg.postID, s.state.Name)}
+	}
+
+	comment := &engine.Comment{
+		ID:        fmt.Sprintf("comment-%d", rand.Int()),
+		Author:    msg.author.state,
+		Content:   msg.content,
+		Timestamp: time.Now(),
+		Parent:    post,
+	}
+	if msg.parentCommentID == "" {
+		post.Comments = append(post.Comments, comment)
+	} else {
+		parent, exists := s.comments[msg.parentCommentID]
+		if !exists || parent.Parent != post {
+			return commentReply{err: fmt.Errorf("%w: %s on post %s", engine.ErrCommentNotFound, msg.parentCommentID, msg.postID)}
+		}
+		comment.ReplyTo = parent
+		parent.Replies = append(parent.Replies, comment)
+	}
+	s.comments[comment.ID] = comment
+	s.users[msg.author.state.Username] = msg.author
+	msg.author.mailbox <- recordCommentMsg{comment: comment}
+	return commentReply{comment: comment}
+}
+
+func (s *subredditActor) vote(msg voteMsg) error {
+	var author string
+	var upvotes, downvotes *int
+	var isComment bool
+	if post, exists := s.posts[msg.targetID]; exists {
+		author, upvotes, downvotes = post.Author.Username, &post.Upvotes, &post.Downvotes
+	} else if comment, exists := s.comments[msg.targetID]; exists {
+		author, upvotes, downvotes = comment.Author.Username, &comment.Upvotes, &comment.Downvotes
+		isComment = true
+	} else {
+		return fmt.Errorf("%w: %s", engine.ErrTargetNotFound, msg.targetID)
+	}
+
+	key := voteKey{username: msg.voter.state.Username, targetID: msg.targetID}
+	previous := s.votes[key]
+	if previous == msg.direction {
+		return nil
+	}
+
+	switch previous {
+	case 1:
+		*upvotes--
+	case -1:
+		*downvotes--
+	}
+	switch msg.direction {
+	case 1:
+		*upvotes++
+	case -1:
+		*downvotes++
+	}
+
+	if msg.direction == 0 {
+		delete(s.votes, key)
+	} else {
+		s.votes[key] = msg.direction
+	}
+
+	// Voting earns the voter no karma, even on their own content
+	if author != msg.voter.state.Username {
+		s.users[author].mailbox <- karmaMsg{delta: msg.direction - previous, comment: isComment}
+	}
+	return nil
+}
+
+// userActor owns a user's karma, history and connection state.
+type userActor struct {
+	mailbox chan interface{}
+	state   *engine.User
+}
+
+func (u *userActor) run() {
+	for msg := range u.mailbox {
+		switch msg := msg.(type) {
+		case recordPostMsg:
+			u.state.Posts = append(u.state.Posts, msg.post)
+		case recordCommentMsg:
+			u.state.Comments = append(u.state.Comments, msg.comment)
+		case karmaMsg:
+			if msg.comment {
+				u.state.CommentKarma += msg.delta
+			} else {
+				u.state.PostKarma += msg.delta
+			}
+			u.state.Karma += msg.delta
+		case connectionMsg:
+			if u.state.Connected && !msg.connected {
+				u.state.LastSeen = time.Now()
+			}
+			u.state.Connected = msg.connected
+			msg.reply <- struct{}{}
+		}
+	}
+}
+
+// inboxActor owns every user's direct messages.
+type inboxActor struct {
+	mailbox  chan interface{}
+	messages map[string][]*engine.DirectMessage
+}
+
+func (i *inboxActor) run() {
+	for msg := range i.mailbox {
+		switch msg := msg.(type) {
+		case deliverMsg:
+			to := msg.message.To.Username
+			i.messages[to] = append(i.messages[to], msg.message)
+			msg.reply <- struct{}{}
+
+		case replyDirectMsg:
+			msg.reply <- i.replyTo(msg)
+
+		case inboxMsg:
+			msg.reply <- append([]*engine.DirectMessage(nil), i.messages[msg.username]...)
+		}
+	}
+}
+
+func (i *inboxActor) replyTo(msg replyDirectMsg) error {
+	// Find original message and recipient
+	var original *engine.DirectMessage
+	for _, messages := range i.messages {
+		for _, message := range messages {
+			if message.ID == msg.messageID && (message.From == msg.from || message.To == msg.from) {
+				original = message
+				break
+			}
+		}
+	}
+	if original == nil {
+		return fmt.Errorf("%w: %s", engine.ErrMessageNotFound, msg.messageID)
+	}
+	to := original.From
+	if to == msg.from {
+		to = original.To
+	}
+
+	reply := &engine.DirectMessage{
+		ID:        fmt.Sprintf("msg-%d", rand.Int()),
+		From:      msg.from,
+		To:        to,
+		Content:   msg.content,
+		Timestamp: time.Now(),
+		ReplyTo:   msg.messageID,
+	}
+	i.messages[reply.To.Username] = append(i.messages[reply.To.Username], reply)
+	return nil
+}
+
+// actorTiers tracks the subreddit and user actors spawned by the registry so
+// they can be stopped in an order that lets in-flight messages drain: the
+// subreddits first, since they still send to users, then the users.
+// Only the registry goroutine spawns actors.
+type actorTiers struct {
+	subreddits []*subredditActor
+	users      []*userActor
+	subWG      sync.WaitGroup
+	userWG     sync.WaitGroup
+}
+
+func (t *actorTiers) spawnUser(username string) *userActor {
+	user := &userActor{
+		mailbox: make(chan interface{}, mailboxSize),
+		state:   &engine.User{Username: username},
+	}
+	t.users = append(t.users, user)
+
+	t.userWG.Add(1)
+	go func() {
+		defer t.userWG.Done()
+		user.run()
+	}()
+	return user
+}
+
+func (t *actorTiers) spawnSubreddit(name, creator string) *subredditActor {
+	subreddit := &subredditActor{
+		mailbox:  make(chan interface{}, mailboxSize),
+		state:    &engine.Subreddit{Name: name, Creator: creator, Members: make(map[string]*engine.User)},
+		posts:    make(map[string]*engine.Post),
+		comments: make(map[string]*engine.Comment),
+		votes:    make(map[voteKey]int),
+		users:    make(map[string]*userActor),
+	}
+	t.subreddits = append(t.subreddits, subreddit)
+
+	t.subWG.Add(1)
+	go func() {
+		defer t.subWG.Done()
+		subreddit.run()
+	}()
+	return subreddit
+}
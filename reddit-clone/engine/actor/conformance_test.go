@@ -0,0 +1,311 @@
+package actor
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"reddit-clone/engine"
+)
+
+// call is one operation made through engine.Reddit. ids maps the names of
+// the posts and comments created so far to their IDs; names not in it are
+// used as IDs as they are, so they name nothing.
+type call func(r engine.Reddit, ids map[string]string) error
+
+func idOf(ids map[string]string, name string) string {
+	if id, exists := ids[name]; exists {
+		return id
+	}
+	return name
+}
+
+func register(username string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		_, err := r.RegisterAccount(username)
+		return err
+	}
+}
+
+func createSubreddit(name, creator string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.CreateSubreddit(name, creator)
+	}
+}
+
+func join(username, subreddit string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.JoinSubreddit(username, subreddit)
+	}
+}
+
+func leave(username, subreddit string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.LeaveSubreddit(username, subreddit)
+	}
+}
+
+// post creates a post whose content is its name
+func post(name, subreddit, username string) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		post, err := r.PostInSubreddit(subreddit, username, name)
+		if err == nil {
+			ids[name] = post.ID
+		}
+		return err
+	}
+}
+
+func comment(name, subreddit, postName, username string) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		comment, err := r.CommentOnPost(subreddit, idOf(ids, postName), username, name)
+		if err == nil {
+			ids[name] = comment.ID
+		}
+		return err
+	}
+}
+
+func reply(name, postName, parentName, username string) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		reply, err := r.ReplyToComment(idOf(ids, postName), idOf(ids, parentName), username, name)
+		if err == nil {
+			ids[name] = reply.ID
+		}
+		return err
+	}
+}
+
+func repost(name, originalName, username, subreddit string) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		repost, err := r.Repost(idOf(ids, originalName), username, subreddit)
+		if err == nil {
+			ids[name] = repost.ID
+		}
+		return err
+	}
+}
+
+func vote(targetName, username string, direction int) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		if direction == 0 {
+			return r.RetractVote(idOf(ids, targetName), username)
+		}
+		return r.Upvote(idOf(ids, targetName), direction > 0, username)
+	}
+}
+
+func message(from, to string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.SendDirectMessage(from, to, "Hello")
+	}
+}
+
+// replyToLast replies as username to the last message in owner's inbox
+func replyToLast(owner, username string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		messages, err := r.GetDirectMessages(owner)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return fmt.Errorf("%s has no message to reply to", owner)
+		}
+		return r.ReplyToDirectMessage(messages[len(messages)-1].ID, username, "Hi")
+	}
+}
+
+func replyTo(messageID, username string) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.ReplyToDirectMessage(messageID, username, "Hi")
+	}
+}
+
+// inbox checks that username has received want messages
+func inbox(username string, want int) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		messages, err := r.GetDirectMessages(username)
+		if err == nil && len(messages) != want {
+			err = fmt.Errorf("%s has %d messages, want %d", username, len(messages), want)
+		}
+		return err
+	}
+}
+
+// feed checks that GetUserFeed holds the posts named in want, newest first
+func feed(username string, limit int, want ...string) call {
+	return func(r engine.Reddit, ids map[string]string) error {
+		posts, err := r.GetUserFeed(username, limit)
+		if err != nil {
+			return err
+		}
+		var got []string
+		for _, post := range posts {
+			got = append(got, post.Content)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return fmt.Errorf("feed of %s is %v, want %v", username, got, want)
+		}
+		return nil
+	}
+}
+
+func connect(username string, connected bool) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		return r.SetUserConnection(username, connected)
+	}
+}
+
+// online checks the connection state right after it was set, without waiting
+// for the engine to settle
+func online(username string, want bool) call {
+	return func(r engine.Reddit, _ map[string]string) error {
+		user, err := r.GetUser(username)
+		if err != nil {
+			return err
+		}
+		if user.Connected != want || !want && user.LastSeen.IsZero() {
+			return fmt.Errorf("%s connected is %v, last seen %v, want %v", username, user.Connected, user.LastSeen, want)
+		}
+		return nil
+	}
+}
+
+// conformanceCases run in order against each engine, each call returning the
+// sentinel error in want, or nil
+var conformanceCases = []struct {
+	name string
+	call call
+	want error
+}{
+	{"register alice", register("alice"), nil},
+	{"register bob", register("bob"), nil},
+	{"register carol", register("carol"), nil},
+	{"register a taken name", register("alice"), engine.ErrUserExists},
+	{"create golang", createSubreddit("golang", "alice"), nil},
+	{"create rust", createSubreddit("rust", "bob"), nil},
+	{"create a taken name", createSubreddit("golang", "bob"), engine.ErrSubredditExists},
+	{"create as an unknown user", createSubreddit("java", "dave"), engine.ErrUserNotFound},
+
+	{"alice joins golang", join("alice", "golang"), nil},
+	{"bob joins golang", join("bob", "golang"), nil},
+	{"carol joins rust", join("carol", "rust"), nil},
+	{"unknown user joins", join("dave", "golang"), engine.ErrUserNotFound},
+	{"join an unknown subreddit", join("alice", "java"), engine.ErrSubredditNotFound},
+	{"leave without being a member", leave("carol", "golang"), engine.ErrNotMember},
+	{"leave an unknown subreddit", leave("carol", "java"), engine.ErrSubredditNotFound},
+
+	{"post", post("hello", "golang", "alice"), nil},
+	{"post by a non-member", post("elsewhere", "rust", "bob"), nil},
+	{"post in an unknown subreddit", post("lost", "java", "alice"), engine.ErrSubredditNotFound},
+	{"post as an unknown user", post("lost", "golang", "dave"), engine.ErrUserNotFound},
+	{"comment", comment("first", "golang", "hello", "bob"), nil},
+	{"comment through another subreddit", comment("lost", "rust", "hello", "bob"), engine.ErrPostNotFound},
+	{"comment on an unknown post", comment("lost", "golang", "no-such-post", "bob"), engine.ErrPostNotFound},
+	{"reply", reply("second", "hello", "first", "alice"), nil},
+	{"reply on an unknown post", reply("lost", "no-such-post", "first", "alice"), engine.ErrPostNotFound},
+	{"reply to an unknown comment", reply("lost", "hello", "no-such-comment", "alice"), engine.ErrCommentNotFound},
+	{"reply to a comment of another post", reply("lost", "elsewhere", "first", "alice"), engine.ErrCommentNotFound},
+	{"repost", repost("shared", "hello", "carol", "rust"), nil},
+	{"repost an unknown post", repost("lost", "no-such-post", "carol", "rust"), engine.ErrPostNotFound},
+	{"repost a comment", repost("lost", "first", "carol", "rust"), engine.ErrPostNotFound},
+	{"repost into an unknown subreddit", repost("lost", "hello", "carol", "java"), engine.ErrSubredditNotFound},
+
+	{"upvote a post", vote("hello", "bob", 1), nil},
+	{"repeat the upvote", vote("hello", "bob", 1), nil},
+	{"downvote a comment", vote("first", "alice", -1), nil},
+	{"upvote a reply", vote("second", "carol", 1), nil},
+	{"upvote one's own repost", vote("shared", "carol", 1), nil},
+	{"upvote a repost", vote("shared", "bob", 1), nil},
+	{"retract the upvote", vote("shared", "bob", 0), nil},
+	{"vote on an unknown target", vote("no-such-post", "bob", 1), engine.ErrTargetNotFound},
+	{"vote as an unknown user", vote("hello", "dave", 1), engine.ErrUserNotFound},
+
+	{"message", message("alice", "bob"), nil},
+	{"message an unknown user", message("alice", "dave"), engine.ErrUserNotFound},
+	{"message as an unknown user", message("dave", "alice"), engine.ErrUserNotFound},
+	{"message arrives before SendDirectMessage returns", inbox("bob", 1), nil},
+	{"reply to a message", replyToLast("bob", "bob"), nil},
+	{"sender replies in the thread", replyToLast("alice", "alice"), nil},
+	{"reply to a message of others", replyToLast("bob", "carol"), engine.ErrMessageNotFound},
+	{"reply to an unknown message", replyTo("no-such-message", "bob"), engine.ErrMessageNotFound},
+	{"alice's inbox", inbox("alice", 1), nil},
+	{"bob's inbox", inbox("bob", 2), nil},
+	{"carol's inbox", inbox("carol", 0), nil},
+	{"inbox of an unknown user", inbox("dave", 0), engine.ErrUserNotFound},
+
+	{"later post", post("later", "golang", "bob"), nil},
+	{"feed", feed("alice", 0, "later", "hello"), nil},
+	{"limited feed", feed("alice", 1, "later"), nil},
+	{"feed of another subreddit", feed("carol", 0, "hello", "elsewhere"), nil},
+	{"feed without subreddits", feed("dave", 0), engine.ErrUserNotFound},
+	{"bob leaves golang", leave("bob", "golang"), nil},
+	{"feed after leaving", feed("bob", 0), nil},
+
+	{"connect", connect("alice", true), nil},
+	{"connect bob", connect("bob", true), nil},
+	{"bob online", online("bob", true), nil},
+	{"disconnect bob", connect("bob", false), nil},
+	{"bob offline", online("bob", false), nil},
+	{"connect an unknown user", connect("dave", true), engine.ErrUserNotFound},
+}
+
+// TestConformance runs the same calls against Engine and the actor engine,
+// which must agree on every result
+func TestConformance(t *testing.T) {
+	engines := []struct {
+		name string
+		new  func() engine.Reddit
+	}{
+		{"locked", func() engine.Reddit { return engine.NewEngine() }},
+		{"actor", func() engine.Reddit { return NewEngine() }},
+	}
+	for _, impl := range engines {
+		t.Run(impl.name, func(t *testing.T) {
+			r := impl.new()
+			ids := make(map[string]string)
+			for _, tc := range conformanceCases {
+				if err := tc.call(r, ids); !errors.Is(err, tc.want) {
+					t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+				}
+			}
+
+			users := make(map[string]*engine.User)
+			for _, username := range []string{"alice", "bob", "carol"} {
+				user, err := r.GetUser(username)
+				if err != nil {
+					t.Fatal(err)
+				}
+				users[username] = user
+			}
+			// Actors own the values they return until they stop
+			if actors, ok := r.(*Engine); ok {
+				actors.Stop()
+			}
+
+			want := []struct {
+				username                string
+				postKarma, commentKarma int
+				posts, comments         int
+				connected               bool
+			}{
+				{username: "alice", postKarma: 1, commentKarma: 1, posts: 1, comments: 1, connected: true},
+				{username: "bob", commentKarma: -1, posts: 2, comments: 1},
+				{username: "carol", posts: 1},
+			}
+			for _, w := range want {
+				user := users[w.username]
+				if user.PostKarma != w.postKarma || user.CommentKarma != w.commentKarma || user.Karma != w.postKarma+w.commentKarma {
+					t.Errorf("%s has karma %d (%d post, %d comment), want %d post and %d comment",
+						w.username, user.Karma, user.PostKarma, user.CommentKarma, w.postKarma, w.commentKarma)
+				}
+				if len(user.Posts) != w.posts || len(user.Comments) != w.comments {
+					t.Errorf("%s has %d posts and %d comments, want %d and %d", w.username, len(user.Posts), len(user.Comments), w.posts, w.comments)
+				}
+				if user.Connected != w.connected {
+					t.Errorf("%s connected is %v, want %v", w.username, user.Connected, w.connected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+// Package actor implements the Reddit engine as a set of goroutine actors.
+//
+// A registry actor owns the user, subreddit and post directories, every
+// subreddit and every user is an actor of its own, and a single inbox actor
+// holds the direct messages. Actors only share state by exchanging typed
+// messages over their mailboxes, so no locks are involved. Engine exposes the
+// same operations as engine.Engine through the engine.Reddit interface.
+package actor
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"reddit-clone/engine"
+)
+
+// Engine is the actor-based counterpart of engine.Engine.
+//
+// Values it returns are owned by the actors: their fields may change until
+// Stop has returned.
+type Engine struct {
+	registry *registry
+	inbox    *inboxActor
+	tiers    *actorTiers
+	regWG    sync.WaitGroup
+	inboxWG  sync.WaitGroup
+}
+
+var _ engine.Reddit = (*Engine)(nil)
+
+// NewEngine starts the registry and inbox actors of a new engine
+func NewEngine() *Engine {
+	e := &Engine{
+		tiers: &actorTiers{},
+		inbox: &inboxActor{
+			mailbox:  make(chan interface{}, mailboxSize),
+			messages: make(map[string][]*engine.DirectMessage),
+		},
+	}
+	e.registry = &registry{
+		mailbox:    make(chan interface{}, mailboxSize),
+		users:      make(map[string]*userActor),
+		subreddits: make(map[string]*subredditActor),
+		owners:     make(map[string]*subredditActor),
+		tier:       e.tiers,
+	}
+
+	e.regWG.Add(1)
+	go func() {
+		defer e.regWG.Done()
+		e.registry.run()
+	}()
+	e.inboxWG.Add(1)
+	go func() {
+		defer e.inboxWG.Done()
+		e.inbox.run()
+	}()
+	return e
+}
+
+// Stop shuts every actor down once its mailbox has drained. No other method
+// may be called once Stop has started.
+func (e *Engine) Stop() {
+	// With the registry gone no new actors can be spawned
+	close(e.registry.mailbox)
+	e.regWG.Wait()
+
+	for _, subreddit := range e.tiers.subreddits {
+		close(subreddit.mailbox)
+	}
+	e.tiers.subWG.Wait()
+	for _, user := range e.tiers.users {
+		close(user.mailbox)
+	}
+	e.tiers.userWG.Wait()
+	close(e.inbox.mailbox)
+	e.inboxWG.Wait()
+}
+
+// lookup resolves the actors named in msg through the registry
+func (e *Engine) lookup(msg lookupMsg) lookupReply {
+	msg.reply = make(chan lookupReply, 1)
+	e.registry.mailbox <- msg
+	return <-msg.reply
+}
+
+// lookupPost is lookup for operations on the post named by msg.target,
+// which fail with engine.ErrPostNotFound when it is unknown
+func (e *Engine) lookupPost(msg lookupMsg) lookupReply {
+	found := e.lookup(msg)
+	if errors.Is(found.err, engine.ErrTargetNotFound) {
+		found.err = fmt.Errorf("%w: %s", engine.ErrPostNotFound, msg.target)
+	}
+	return found
+}
+
+// index makes a new post or comment visible to later lookups
+func (e *Engine) index(id string, owner *subredditActor) {
+	reply := make(chan struct{}, 1)
+	e.registry.mailbox <- indexMsg{id: id, owner: owner, reply: reply}
+	<-reply
+}
+
+// RegisterAccount registers a new user and starts its actor
+func (e *Engine) RegisterAccount(username string) (*engine.User, error) {
+	reply := make(chan registerUserReply, 1)
+	e.registry.mailbox <- registerUserMsg{username: username, reply: reply}
+	r := <-reply
+	return r.user, r.err
+}
+
+// GetUser returns a registered user
+func (e *Engine) GetUser(username string) (*engine.User, error) {
+	found := e.lookup(lookupMsg{usernames: []string{username}})
+	if found.err != nil {
+		return nil, found.err
+	}
+	return found.users[0].state, nil
+}
+
+// CreateSubreddit creates a new subreddit and starts its actor
+func (e *Engine) CreateSubreddit(name, creator string) error {
+	reply := make(chan error, 1)
+	e.registry.mailbox <- createSubredditMsg{name: name, creator: creator, reply: reply}
+	return <-reply
+}
+
+// JoinSubreddit allows a user to join a subreddit
+func (e *Engine) JoinSubreddit(username, subredditName string) error {
+	found := e.lookup(lookupMsg{usernames: []string{username}, subreddit: subredditName})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan struct{}, 1)
+	found.subreddit.mailbox <- joinMsg{user: found.users[0].state, reply: reply}
+	<-reply
+	return nil
+}
+
+// LeaveSubreddit removes a user from a subreddit's members
+func (e *Engine) LeaveSubreddit(username, subredditName string) error {
+	found := e.lookup(lookupMsg{subreddit: subredditName})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan error, 1)
+	found.subreddit.mailbox <- leaveMsg{username: username, reply: reply}
+	return <-reply
+}
+
+// PostInSubreddit allows a user to post in a subreddit
+func (e *Engine) PostInSubreddit(subredditName, username, content string) (*engine.Post, error) {
+	found := e.lookup(lookupMsg{usernames: []string{username}, subreddit: subredditName})
+	if found.err != nil {
+		return nil, found.err
+	}
+
+	reply := make(chan *engine.Post, 1)
+	found.subreddit.mailbox <- createPostMsg{author: found.users[0], content: content, reply: reply}
+	post := <-reply
+	e.index(post.ID, found.subreddit)
+	return post, nil
+}
+
+// CommentOnPost allows a user to comment on a post
+func (e *Engine) CommentOnPost(subredditName string, postID string, username string, content string) (*engine.Comment, error) {
+	found := e.lookup(lookupMsg{usernames: []string{username}, subreddit: subredditName})
+	if found.err != nil {
+		return nil, found.err
+	}
+	return e.comment(found.subreddit, postID, "", found.users[0], content)
+}
+
+// ReplyToComment adds a reply under an existing comment of a post
+func (e *Engine) ReplyToComment(postID, parentCommentID, username, content string) (*engine.Comment, error) {
+	found := e.lookupPost(lookupMsg{usernames: []string{username}, target: postID})
+	if found.err != nil {
+		return nil, found.err
+	}
+	return e.comment(found.owner, postID, parentCommentID, found.users[0], content)
+}
+
+func (e *Engine) comment(subreddit *subredditActor, postID, parentCommentID string, author *userActor, content string) (*engine.Comment, error) {
+	reply := make(chan commentReply, 1)
+	subreddit.mailbox <- createCommentMsg{
+		postID:          postID,
+		parentCommentID: parentCommentID,
+		author:          author,
+		content:         content,
+		reply:           reply,
+	}
+	r := <-reply
+	if r.err != nil {
+		return nil, r.err
+	}
+	e.index(r.comment.ID, subreddit)
+	return r.comment, nil
+}
+
+// Repost shares an existing post into another subreddit. Reposting a repost
+// shares the root original it points to.
+func (e *Engine) Repost(originalPostID, username, subredditName string) (*engine.Post, error) {
+	found := e.lookupPost(lookupMsg{usernames: []string{username}, subreddit: subredditName, target: originalPostID})
+	if found.err != nil {
+		return nil, found.err
+	}
+
+	original := make(chan *engine.Post, 1)
+	found.owner.mailbox <- getPostMsg{postID: originalPostID, reply: original}
+	originalPost := <-original
+	if originalPost == nil {
+		return nil, fmt.Errorf("%w: %s", engine.ErrPostNotFound, originalPostID)
+	}
+	if originalPost.OriginalPost != nil {
+		originalPost = originalPost.OriginalPost
+	}
+
+	reply := make(chan *engine.Post, 1)
+	found.subreddit.mailbox <- createPostMsg{
+		author:   found.users[0],
+		content:  originalPost.Content,
+		original: originalPost,
+		reply:    reply,
+	}
+	repost := <-reply
+	e.index(repost.ID, found.subreddit)
+	return repost, nil
+}
+
+// Upvote - Upvote or downvote a post or comment, one vote per user and target
+func (e *Engine) Upvote(postID string, upvote bool, username string) error {
+	direction := -1
+	if upvote {
+		direction = 1
+	}
+	return e.vote(postID, username, direction)
+}
+
+// RetractVote removes a user's vote on a post or comment
+func (e *Engine) RetractVote(targetID string, username string) error {
+	return e.vote(targetID, username, 0)
+}
+
+func (e *Engine) vote(targetID, username string, direction int) error {
+	found := e.lookup(lookupMsg{usernames: []string{username}, target: targetID})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan error, 1)
+	found.owner.mailbox <- voteMsg{voter: found.users[0], targetID: targetID, direction: direction, reply: reply}
+	return <-reply
+}
+
+// SendDirectMessage allows one user to send a message to another
+func (e *Engine) SendDirectMessage(fromUsername, toUsername, content string) error {
+	found := e.lookup(lookupMsg{usernames: []string{fromUsername, toUsername}})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan struct{}, 1)
+	e.inbox.mailbox <- deliverMsg{message: &engine.DirectMessage{
+		ID:        fmt.Sprintf("msg-%d", rand.Int()),
+		From:      found.users[0].state,
+		To:        found.users[1].state,
+		Content:   content,
+		Timestamp: time.Now(),
+	}, reply: reply}
+	<-reply
+	return nil
+}
+
+// ReplyToDirectMessage allows either user of a direct message to reply to it.
+// The reply goes to the other one.
+func (e *Engine) ReplyToDirectMessage(messageID string, fromUsername string, content string) error {
+	found := e.lookup(lookupMsg{usernames: []string{fromUsername}})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan error, 1)
+	e.inbox.mailbox <- replyDirectMsg{messageID: messageID, from: found.users[0].state, content: content, reply: reply}
+	return <-reply
+}
+
+// GetDirectMessages retrieves all direct messages for a user
+func (e *Engine) GetDirectMessages(username string) ([]*engine.DirectMessage, error) {
+	found := e.lookup(lookupMsg{usernames: []string{username}})
+	if found.err != nil {
+		return nil, found.err
+	}
+
+	reply := make(chan []*engine.DirectMessage, 1)
+	e.inbox.mailbox <- inboxMsg{username: username, reply: reply}
+	return <-reply, nil
+}
+
+// GetFeed returns every post from the subreddits a user has joined
+func (e *Engine) GetFeed(username string) ([]*engine.Post, error) {
+	found := e.lookup(lookupMsg{usernames: []string{username}})
+	if found.err != nil {
+		return nil, found.err
+	}
+
+	list := make(chan []*subredditActor, 1)
+	e.registry.mailbox <- listSubredditsMsg{reply: list}
+	subreddits := <-list
+
+	// Ask every subreddit at once, then gather the answers
+	reply := make(chan []*engine.Post, len(subreddits))
+	for _, subreddit := range subreddits {
+		subreddit.mailbox <- memberPostsMsg{username: username, reply: reply}
+	}
+	var feed []*engine.Post
+	for range subreddits {
+		feed = append(feed, <-reply...)
+	}
+	return feed, nil
+}
+
+// GetUserFeed returns recent posts from subscribed subreddits
+func (e *Engine) GetUserFeed(username string, limit int) ([]*engine.Post, error) {
+	feed, err := e.GetFeed(username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort by timestamp (newest first)
+	sort.Slice(feed, func(i, j int) bool {
+		return feed[i].Timestamp.After(feed[j].Timestamp)
+	})
+
+	// Apply limit if specified
+	if limit > 0 && len(feed) > limit {
+		return feed[:limit], nil
+	}
+	return feed, nil
+}
+
+// SetUserConnection records whether a user is currently connected, returning
+// once the user's actor has applied it
+func (e *Engine) SetUserConnection(username string, connected bool) error {
+	found := e.lookup(lookupMsg{usernames: []string{username}})
+	if found.err != nil {
+		return found.err
+	}
+
+	reply := make(chan struct{}, 1)
+	found.users[0].mailbox <- connectionMsg{connected: connected, reply: reply}
+	<-reply
+	return nil
+}
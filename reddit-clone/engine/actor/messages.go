@@ -0,0 +1,137 @@
+package actor
+
+import "reddit-clone/engine"
+
+// Messages accepted by the registry actor.
+
+type registerUserMsg struct {
+	username string
+	reply    chan registerUserReply
+}
+
+type registerUserReply struct {
+	user *engine.User
+	err  error
+}
+
+type createSubredditMsg struct {
+	name    string
+	creator string
+	reply   chan error
+}
+
+// lookupMsg resolves the actors an operation needs. Empty fields are skipped.
+type lookupMsg struct {
+	usernames []string
+	subreddit string
+	target    string // post or comment ID whose owning subreddit is wanted
+	reply     chan lookupReply
+}
+
+type lookupReply struct {
+	users     []*userActor
+	subreddit *subredditActor
+	owner     *subredditActor
+	err       error
+}
+
+// indexMsg records which subreddit actor owns a new post or comment.
+type indexMsg struct {
+	id    string
+	owner *subredditActor
+	reply chan struct{}
+}
+
+type listSubredditsMsg struct {
+	reply chan []*subredditActor
+}
+
+// Messages accepted by subreddit actors.
+
+type joinMsg struct {
+	user  *engine.User
+	reply chan struct{}
+}
+
+type leaveMsg struct {
+	username string
+	reply    chan error
+}
+
+type createPostMsg struct {
+	author   *userActor
+	content  string
+	original *engine.Post // Set for reposts
+	reply    chan *engine.Post
+}
+
+type getPostMsg struct {
+	postID string
+	reply  chan *engine.Post
+}
+
+type createCommentMsg struct {
+	postID          string
+	parentCommentID string // Empty for top-level comments
+	author          *userActor
+	content         string
+	reply           chan commentReply
+}
+
+type commentReply struct {
+	comment *engine.Comment
+	err     error
+}
+
+type voteMsg struct {
+	voter     *userActor
+	targetID  string
+	direction int // 1 for up, -1 for down, 0 to retract
+	reply     chan error
+}
+
+// memberPostsMsg asks for the subreddit's posts if username is a member.
+type memberPostsMsg struct {
+	username string
+	reply    chan []*engine.Post
+}
+
+// Messages accepted by user actors. Apart from connectionMsg, they need no
+// reply.
+
+type recordPostMsg struct {
+	post *engine.Post
+}
+
+type recordCommentMsg struct {
+	comment *engine.Comment
+}
+
+type karmaMsg struct {
+	delta   int
+	comment bool // Comment karma rather than post karma
+}
+
+type connectionMsg struct {
+	connected bool
+	reply     chan struct{}
+}
+
+// Messages accepted by the DM inbox actor.
+
+type deliverMsg struct {
+	message *engine.DirectMessage
+	reply   chan struct{}
+}
+
+type replyDirectMsg struct {
+	messageID string
+	from      *engine.User
+	content   string
+	reply     chan error
+}
+
+type inboxMsg struct {
+	username string
+	reply    chan []*engine.DirectMessage
+}
@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AutoModerator is the account AutoModerator rules act as. It is registered
+// the first time a subreddit sets rules.
+const AutoModerator = "AutoModerator"
+
+// AutoModRule is one AutoModerator rule of a subreddit. It matches new posts
+// and comments that pass every check it sets, then takes every action it
+// sets. Rules are written as a JSON array and must be parsed with
+// ParseAutoModRules.
+type AutoModRule struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"` // "post", "comment" or "any", the default
+
+	// Checks
+	Author *AutoModAuthor `json:"author,omitempty"`
+	Body   string         `json:"body,omitempty"`   // Regular expression on the content
+	Domain []string       `json:"domain,omitempty"` // Domains of links in the content, subdomains included
+
+	// Actions
+	Action   ModAction `json:"action,omitempty"`    // ModRemove, ModSpam or ModFilter
+	Comment  string    `json:"comment,omitempty"`   // Reply left by AutoModerator
+	SetFlair string    `json:"set_flair,omitempty"` // Posts only
+	Report   string    `json:"report,omitempty"`    // Reason of a report filed by AutoModerator
+
+	body      *regexp.Regexp
+	maxAge    time.Duration
+	isComment bool
+	isPost    bool
+}
+
+// AutoModAuthor checks the author of new content. Both checks must hold.
+type AutoModAuthor struct {
+	KarmaBelow      *int   `json:"karma_below,omitempty"`
+	AccountAgeBelow string `json:"account_age_below,omitempty"` // Such as "72h"
+}
+
+// AutoModMatch is a post or comment matched by a rule during a dry run.
+// Exactly one of Post and Comment is set.
+type AutoModMatch struct {
+	Post    *Post
+	Comment *Comment
+	Rule    string // Name of the rule, or its position if it has none
+}
+
+// linkPattern finds the host of every link in some content
+var linkPattern = regexp.MustCompile(`(?i)https?://([^/\s?#:]+)`)
+
+// ParseAutoModRules parses and validates a JSON array of rules
+func ParseAutoModRules(data []byte) ([]AutoModRule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var rules []AutoModRule
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidRule, rules[i].label(i), err)
+		}
+	}
+	return rules, nil
+}
+
+// compile validates the rule and prepares its checks
+func (r *AutoModRule) compile() error {
+	switch r.Type {
+	case "", "any":
+		r.isPost, r.isComment = true, true
+	case "post":
+		r.isPost = true
+	case "comment":
+		r.isComment = true
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+
+	if r.Body != "" {
+		body, err := regexp.Compile(r.Body)
+		if err != nil {
+			return fmt.Errorf("body: %v", err)
+		}
+		r.body = body
+	}
+	if r.Author != nil && r.Author.AccountAgeBelow != "" {
+		age, err := time.ParseDuration(r.Author.AccountAgeBelow)
+		if err != nil || age <= 0 {
+			return fmt.Errorf("invalid account age %q", r.Author.AccountAgeBelow)
+		}
+		r.maxAge = age
+	}
+	for i, domain := range r.Domain {
+		r.Domain[i] = strings.ToLower(strings.TrimPrefix(domain, "www."))
+	}
+
+	switch r.Action {
+	case "", ModRemove, ModSpam, ModFilter:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if r.SetFlair != "" && !r.isPost {
+		return errors.New("only posts take a flair")
+	}
+	if r.Action == "" && r.Comment == "" && r.SetFlair == "" && r.Report == "" {
+		return errors.New("no action")
+	}
+	return nil
+}
+
+// label names the rule at position i in error messages and dry runs
+func (r *AutoModRule) label(i int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", i+1)
+}
+
+// matches reports whether content by author, posted at t, passes every
+// check of the rule
+func (r *AutoModRule) matches(author *User, content string, isComment bool, t time.Time) bool {
+	if isComment && !r.isComment || !isComment && !r.isPost {
+		return false
+	}
+	if r.body != nil && !r.body.MatchString(content) {
+		return false
+	}
+	if len(r.Domain) > 0 && !linksTo(content, r.Domain) {
+		return false
+	}
+	if r.Author != nil {
+		author.mu.Lock()
+		karma, created := author.Karma, author.Created
+		author.mu.Unlock()
+		if r.Author.KarmaBelow != nil && karma >= *r.Author.KarmaBelow {
+			return false
+		}
+		if r.maxAge > 0 && t.Sub(created) >= r.maxAge {
+			return false
+		}
+	}
+	return true
+}
+
+// linksTo reports whether content links to any of domains or their subdomains
+func linksTo(content string, domains []string) bool {
+	for _, link := range linkPattern.FindAllStringSubmatch(content, -1) {
+		host := strings.ToLower(link[1])
+		for _, domain := range domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetAutoModRules replaces the AutoModerator rules of a subreddit with the
+// JSON array of rules in data
+func (e *Engine) SetAutoModRules(subredditName, moderator string, data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.setAutoModRules(journalEntry{Type: entryAutoModRules, Time: time.Now(), Username: moderator, Subreddit: subredditName, Content: string(data)})
+}
+
+// setAutoModRules applies a rules entry, registering the AutoModerator
+// account if needed. Must be called with e.mu held for writing.
+func (e *Engine) setAutoModRules(entry journalEntry) error {
+	rules, err := ParseAutoModRules([]byte(entry.Content))
+	if err != nil {
+		return err
+	}
+	subreddit, err := e.moderatedSubreddit(entry)
+	if err != nil {
+		return err
+	}
+	defer subreddit.mu.Unlock()
+
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	if _, exists := e.users[AutoModerator]; !exists {
+		e.users[AutoModerator] = &User{Username: AutoModerator, Created: entry.Time}
+	}
+	subreddit.automod = rules
+	return nil
+}
+
+// GetAutoModRules returns the AutoModerator rules of a subreddit
+func (e *Engine) GetAutoModRules(subredditName, moderator string) ([]AutoModRule, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return nil, err
+	}
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	if !subreddit.moderators[moderator] {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotModerator, moderator, subredditName)
+	}
+	return append([]AutoModRule(nil), subreddit.automod...), nil
+}
+
+// DryRunAutoMod reports which of the existing posts and comments of a
+// subreddit the rules in data would match, without acting on them. With nil
+// data the subreddit's own rules are tried.
+func (e *Engine) DryRunAutoMod(subredditName, moderator string, data []byte) ([]AutoModMatch, error) {
+	var rules []AutoModRule
+	if data != nil {
+		var err error
+		if rules, err = ParseAutoModRules(data); err != nil {
+			return nil, err
+		}
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return nil, err
+	}
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	if !subreddit.moderators[moderator] {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotModerator, moderator, subredditName)
+	}
+	if data == nil {
+		rules = subreddit.automod
+	}
+
+	var matches []AutoModMatch
+	var check func(comments []*Comment)
+	check = func(comments []*Comment) {
+		for _, comment := range comments {
+			for i := range rules {
+				if !comment.Deleted && rules[i].matches(comment.Author, comment.Content, true, comment.Timestamp) {
+					matches = append(matches, AutoModMatch{Comment: comment, Rule: rules[i].label(i)})
+				}
+			}
+			check(comment.Replies)
+		}
+	}
+	for _, post := range subreddit.Posts {
+		for i := range rules {
+			if !post.Deleted && rules[i].matches(post.Author, post.Content, false, post.Timestamp) {
+				matches = append(matches, AutoModMatch{Post: post, Rule: rules[i].label(i)})
+			}
+		}
+		check(post.Comments)
+	}
+	return matches, nil
+}
+
+// autoModerate runs the subreddit's rules on a new post, or a new comment on
+// it, and takes the actions of those that match. Auto-replies get IDs derived
+// from the entry's, so replaying the entry gives the same result. Must be
+// called with e.mu held and the subreddit locked for writing.
+func (e *Engine) autoModerate(subreddit *Subreddit, post *Post, comment *Comment, entry journalEntry) {
+	author, content := post.Author, post.Content
+	removed, spam, filtered, reports := &post.Removed, &post.Spam, &post.Filtered, &post.Reports
+	if comment != nil {
+		author, content = comment.Author, comment.Content
+		removed, spam, filtered, reports = &comment.Removed, &comment.Spam, &comment.Filtered, &comment.Reports
+	}
+
+	for i := range subreddit.automod {
+		rule := &subreddit.automod[i]
+		if !rule.matches(author, content, comment != nil, entry.Time) {
+			continue
+		}
+		switch rule.Action {
+		case ModRemove:
+			*removed, *filtered = true, false
+		case ModSpam:
+			*removed, *spam, *filtered = true, true, false
+		case ModFilter:
+			*filtered = !*removed
+		}
+		if rule.Report != "" {
+			*reports = append(*reports, Report{Username: AutoModerator, Reason: rule.Report, Timestamp: entry.Time})
+		}
+		if rule.SetFlair != "" && comment == nil {
+			post.Flair = rule.SetFlair
+		}
+		if rule.Comment != "" {
+			e.autoReply(post, comment, rule.Comment, fmt.Sprintf("%s-automod-%d", entry.ID, i), entry.Time)
+		}
+	}
+}
+
+// autoReply leaves a reply by AutoModerator under a post, or a comment of it.
+// Must be called with e.mu held and the post's subreddit locked for writing.
+func (e *Engine) autoReply(post *Post, comment *Comment, content, id string, t time.Time) {
+	reply := &Comment{
+		ID:        id,
+		Author:    e.users[AutoModerator],
+		Content:   content,
+		Timestamp: t,
+		Parent:    post,
+		ReplyTo:   comment,
+	}
+	if comment == nil {
+		post.Comments = append(post.Comments, reply)
+	} else {
+		comment.Replies = append(comment.Replies, reply)
+	}
+	e.indexComment(reply)
+	e.notify(post, reply)
+}
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Ban keeps a user from taking part in a subreddit. Mutes share its shape and
+// keep a user from messaging the subreddit's moderators instead.
+type Ban struct {
+	Username  string    `json:"username"`
+	Moderator string    `json:"moderator"` // Who issued it
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note,omitempty"` // Only shown to moderators
+	Timestamp time.Time `json:"timestamp"`
+	Expires   time.Time `json:"expires"` // Zero for a permanent ban
+}
+
+// activeAt reports whether the ban is in force at t
+func (b Ban) activeAt(t time.Time) bool {
+	return b.Expires.IsZero() || t.Before(b.Expires)
+}
+
+// BanUser bans a user from a subreddit for duration, or permanently if
+// duration is 0. Banning a user again replaces their ban.
+func (e *Engine) BanUser(subredditName, moderator, username string, duration time.Duration, reason, note string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.restrict(restrictionEntry(entryBan, subredditName, moderator, username, duration, reason, note))
+}
+
+// UnbanUser lifts a user's ban from a subreddit
+func (e *Engine) UnbanUser(subredditName, moderator, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lift(journalEntry{Type: entryUnban, Time: time.Now(), Username: moderator, Subreddit: subredditName, Recipient: username})
+}
+
+// MuteUser keeps a user from messaging a subreddit's moderators for
+// duration, or permanently if duration is 0
+func (e *Engine) MuteUser(subredditName, moderator, username string, duration time.Duration, reason string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.restrict(restrictionEntry(entryMute, subredditName, moderator, username, duration, reason, ""))
+}
+
+// UnmuteUser lifts a user's mute in a subreddit
+func (e *Engine) UnmuteUser(subredditName, moderator, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lift(journalEntry{Type: entryUnmute, Time: time.Now(), Username: moderator, Subreddit: subredditName, Recipient: username})
+}
+
+func restrictionEntry(kind entryType, subredditName, moderator, username string, duration time.Duration, reason, note string) journalEntry {
+	entry := journalEntry{
+		Type:      kind,
+		Time:      time.Now(),
+		Username:  moderator,
+		Subreddit: subredditName,
+		Recipient: username,
+		Content:   reason,
+		Note:      note,
+	}
+	if duration > 0 {
+		entry.Expires = entry.Time.Add(duration)
+	}
+	return entry
+}
+
+// restrict applies a ban or mute entry. Must be called with e.mu held.
+func (e *Engine) restrict(entry journalEntry) error {
+	if _, err := e.user(entry.Recipient); err != nil {
+		return err
+	}
+	subreddit, err := e.moderatedSubreddit(entry)
+	if err != nil {
+		return err
+	}
+	defer subreddit.mu.Unlock()
+
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	restrictions := subreddit.bans
+	if entry.Type == entryMute {
+		restrictions = subreddit.mutes
+	}
+	restrictions[entry.Recipient] = Ban{
+		Username:  entry.Recipient,
+		Moderator: entry.Username,
+		Reason:    entry.Content,
+		Note:      entry.Note,
+		Timestamp: entry.Time,
+		Expires:   entry.Expires,
+	}
+	return nil
+}
+
+// lift applies an unban or unmute entry. Must be called with e.mu held.
+func (e *Engine) lift(entry journalEntry) error {
+	subreddit, err := e.moderatedSubreddit(entry)
+	if err != nil {
+		return err
+	}
+	defer subreddit.mu.Unlock()
+
+	restrictions := subreddit.bans
+	if entry.Type == entryUnmute {
+		restrictions = subreddit.mutes
+	}
+	if _, exists := restrictions[entry.Recipient]; !exists {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	delete(restrictions, entry.Recipient)
+	return nil
+}
+
+// moderatedSubreddit returns the subreddit of entry locked for writing once
+// it has checked that the entry's user moderates it. Must be called with
+// e.mu held.
+func (e *Engine) moderatedSubreddit(entry journalEntry) (*Subreddit, error) {
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return nil, err
+	}
+	subreddit.mu.Lock()
+	if !subreddit.moderators[entry.Username] {
+		subreddit.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotModerator, entry.Username, entry.Subreddit)
+	}
+	return subreddit, nil
+}
+
+// GetBans returns the bans in force in a subreddit, oldest first
+func (e *Engine) GetBans(subredditName, moderator string) ([]Ban, error) {
+	return e.restrictions(subredditName, moderator, func(subreddit *Subreddit) map[string]Ban { return subreddit.bans })
+}
+
+// GetMutes returns the mutes in force in a subreddit, oldest first
+func (e *Engine) GetMutes(subredditName, moderator string) ([]Ban, error) {
+	return e.restrictions(subredditName, moderator, func(subreddit *Subreddit) map[string]Ban { return subreddit.mutes })
+}
+
+func (e *Engine) restrictions(subredditName, moderator string, of func(*Subreddit) map[string]Ban) ([]Ban, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return nil, err
+	}
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	if !subreddit.moderators[moderator] {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotModerator, moderator, subredditName)
+	}
+
+	return activeBans(of(subreddit), time.Now()), nil
+}
+
+// activeBans returns the bans in force at t, oldest first
+func activeBans(bans map[string]Ban, t time.Time) []Ban {
+	var active []Ban
+	for _, ban := range bans {
+		if ban.activeAt(t) {
+			active = append(active, ban)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Timestamp.Before(active[j].Timestamp) })
+	return active
+}
+
+// MessageModerators sends a direct message to every moderator of a
+// subreddit, unless the sender is muted there
+func (e *Engine) MessageModerators(subredditName, username, content string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	messages, err := e.messageModerators(journalEntry{
+		Type:      entryModmail,
+		Time:      time.Now(),
+		ID:        fmt.Sprintf("msg-%d", rand.Int()),
+		Username:  username,
+		Subreddit: subredditName,
+		Content:   content,
+	})
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		e.events.Publish(messageSent(message))
+	}
+	return nil
+}
+
+// messageModerators applies a modmail entry. Must be called with e.mu held.
+func (e *Engine) messageModerators(entry journalEntry) ([]*DirectMessage, error) {
+	sender, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
+	}
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return nil, err
+	}
+
+	subreddit.mu.RLock()
+	if err := subreddit.checkNotMuted(entry.Username, entry.Time); err != nil {
+		subreddit.mu.RUnlock()
+		return nil, err
+	}
+	var moderators []*User
+	for username := range subreddit.moderators {
+		if moderator, err := e.user(username); err == nil {
+			moderators = append(moderators, moderator)
+		}
+	}
+	subreddit.mu.RUnlock()
+
+	e.messagesMu.Lock()
+	if err := e.record(&entry); err != nil {
+		e.messagesMu.Unlock()
+		return nil, err
+	}
+	messages := make([]*DirectMessage, len(moderators))
+	for i, moderator := range moderators {
+		messages[i] = &DirectMessage{
+			ID:        entry.ID + "-" + moderator.Username,
+			From:      sender,
+			To:        moderator,
+			Content:   entry.Content,
+			Timestamp: entry.Time,
+		}
+		e.messages[moderator.Username] = append(e.messages[moderator.Username], messages[i])
+	}
+	e.messagesMu.Unlock()
+
+	// messagesMu is taken inside User.mu, so queue once it is released
+	for i, moderator := range moderators {
+		moderator.mu.Lock()
+		moderator.queue(Delivery{Message: messages[i]})
+		moderator.mu.Unlock()
+	}
+	return messages, nil
+}
+
+// checkNotBanned fails with ErrBanned if username is banned from the
+// subreddit at t. Must be called with the subreddit locked.
+func (s *Subreddit) checkNotBanned(username string, t time.Time) error {
+	if ban, banned := s.bans[username]; banned && ban.activeAt(t) {
+		return fmt.Errorf("%w: %s from %s", ErrBanned, username, s.Name)
+	}
+	return nil
+}
+
+// checkNotMuted fails with ErrMuted if username is muted in the subreddit at
+// t. Must be called with the subreddit locked.
+func (s *Subreddit) checkNotMuted(username string, t time.Time) error {
+	if mute, muted := s.mutes[username]; muted && mute.activeAt(t) {
+		return fmt.Errorf("%w: %s in %s", ErrMuted, username, s.Name)
+	}
+	return nil
+}
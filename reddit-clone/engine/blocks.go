@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BlockedContent replaces the content of comments by a blocked user that
+// stay in a blocker's comment tree to hold up replies
+const BlockedContent = "[blocked]"
+
+// BlockUser keeps blocked from messaging or following username, and makes
+// them unfollow username. Posts and comments by blocked are hidden from
+// username's feeds and comment trees, and their replies and mentions no
+// longer notify username.
+func (e *Engine) BlockUser(username, blocked string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.block(journalEntry{Type: entryBlock, Time: time.Now(), Username: username, Recipient: blocked})
+}
+
+// UnblockUser lifts a block
+func (e *Engine) UnblockUser(username, blocked string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.block(journalEntry{Type: entryUnblock, Time: time.Now(), Username: username, Recipient: blocked})
+}
+
+// block applies a block or unblock entry. Must be called with e.mu held.
+func (e *Engine) block(entry journalEntry) error {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return err
+	}
+	if _, err := e.user(entry.Recipient); err != nil {
+		return err
+	}
+	if entry.Username == entry.Recipient {
+		return fmt.Errorf("%w: %s", ErrBlockSelf, entry.Username)
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	if user.blocked[entry.Recipient] == (entry.Type == entryBlock) {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	if entry.Type == entryUnblock {
+		delete(user.blocked, entry.Recipient)
+		return nil
+	}
+	if user.blocked == nil {
+		user.blocked = make(map[string]bool)
+	}
+	user.blocked[entry.Recipient] = true
+	e.followsMu.Lock()
+	e.removeFollow(entry.Recipient, entry.Username)
+	e.followsMu.Unlock()
+	return nil
+}
+
+// GetBlockedUsers returns the users a user has blocked, sorted by name
+func (e *Engine) GetBlockedUsers(username string) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	blocked := make([]string, 0, len(user.blocked))
+	for name := range user.blocked {
+		blocked = append(blocked, name)
+	}
+	sort.Strings(blocked)
+	return blocked, nil
+}
+
+// blockedBy returns a copy of the set of users username has blocked, nil for
+// unknown users. Must be called with e.mu held and no user locked.
+func (e *Engine) blockedBy(username string) map[string]bool {
+	user, exists := e.users[username]
+	if !exists {
+		return nil
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	blocked := make(map[string]bool, len(user.blocked))
+	for name := range user.blocked {
+		blocked[name] = true
+	}
+	return blocked
+}
+
+// checkNotBlocked fails with ErrBlocked if user has blocked username. Must be
+// called with user locked.
+func (u *User) checkNotBlocked(username string) error {
+	if u.blocked[username] {
+		return fmt.Errorf("%w: %s by %s", ErrBlocked, username, u.Username)
+	}
+	return nil
+}
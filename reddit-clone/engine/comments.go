@@ -0,0 +1,278 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// CommentSort selects how sibling comments are ordered in a tree
+type CommentSort string
+
+const (
+	CommentBest          CommentSort = "best"          // Most confidently well received
+	CommentTop           CommentSort = "top"           // Highest score
+	CommentNew           CommentSort = "new"           // Newest first
+	CommentControversial CommentSort = "controversial" // Many votes, evenly split
+)
+
+// bestConfidence is the z-score of the confidence level used by BestScore (80%)
+const bestConfidence = 1.281551565545
+
+// ParseCommentSort validates a comment sort name. An empty name means CommentBest.
+func ParseCommentSort(name string) (CommentSort, error) {
+	switch mode := CommentSort(name); mode {
+	case "":
+		return CommentBest, nil
+	case CommentBest, CommentTop, CommentNew, CommentControversial:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// BestScore is the lower bound of the Wilson score interval for the share of
+// upvotes. Unlike the raw score it does not let a comment with a single
+// upvote outrank one with 90 up and 10 down.
+func BestScore(upvotes, downvotes int) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	z := bestConfidence
+	phat := float64(upvotes) / n
+	return (phat + z*z/(2*n) - z*math.Sqrt((phat*(1-phat)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// CommentTreeOptions selects the order and shape of a comment tree
+type CommentTreeOptions struct {
+	Sort       CommentSort
+	MaxDepth   int    // Levels of comments returned, 0 for every level
+	MaxBreadth int    // Replies returned under each comment and at the top, 0 for all
+	Continue   string // Token of a MoreComments, to load the comments it stands for
+	Viewer     string // User the tree is for; others than moderators see removed and filtered comments as RemovedContent
+}
+
+// CommentTree is a thread of comments, or the part of one a continuation
+// token stands for
+type CommentTree struct {
+	Comments []*CommentNode
+	More     *MoreComments // Top-level comments left out, nil if none
+}
+
+// CommentNode is a comment with the replies returned under it
+type CommentNode struct {
+	Comment   *Comment // A tombstone without an Author for removed and blocked comments
+	Upvotes   int      // Counters as of when the tree was built
+	Downvotes int
+	Replies   []*CommentNode
+	More      *MoreComments // Replies left out by the depth or breadth limit, nil if none
+}
+
+// MoreComments stands in for comments left out of a tree
+type MoreComments struct {
+	Count int    // Comments left out, replies included
+	Token string // Pass as CommentTreeOptions.Continue to load them
+}
+
+// moreToken is the decoded form of MoreComments.Token
+type moreToken struct {
+	PostID   string      `json:"p"`
+	ParentID string      `json:"c,omitempty"` // Empty for top-level comments
+	Sort     CommentSort `json:"s"`
+	After    *position   `json:"l,omitempty"` // Last sibling already returned, nil to start from the first
+	AsOf     time.Time   `json:"a"`           // Comments made later are left out
+}
+
+func (t moreToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// GetCommentTree returns the comments of a post as a tree. With a continuation
+// token it returns the comments that token stands for instead, which must
+// belong to the same post. Comments by users the viewer has blocked are left
+// out, or shown as BlockedContent while replies by others hang under them.
+func (e *Engine) GetCommentTree(postID string, opts CommentTreeOptions) (*CommentTree, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	post, err := e.post(postID)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Sort == "" {
+		opts.Sort = CommentBest
+	}
+
+	start := moreToken{PostID: postID, Sort: opts.Sort, AsOf: time.Now()}
+	if opts.Continue != "" {
+		data, err := base64.RawURLEncoding.DecodeString(opts.Continue)
+		if err != nil || json.Unmarshal(data, &start) != nil || start.PostID != postID || start.Sort != opts.Sort {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, opts.Continue)
+		}
+	}
+
+	blocked := e.blockedBy(opts.Viewer)
+	post.Subreddit.mu.RLock()
+	defer post.Subreddit.mu.RUnlock()
+
+	siblings := post.Comments
+	if start.ParentID != "" {
+		parent, err := e.comment(start.ParentID)
+		if err != nil || parent.Parent != post {
+			return nil, fmt.Errorf("%w: %s on post %s", ErrCommentNotFound, start.ParentID, postID)
+		}
+		siblings = parent.Replies
+	}
+
+	b := treeBuilder{opts: opts, asOf: start.AsOf, moderator: post.Subreddit.moderators[opts.Viewer], blocked: blocked}
+	tree := &CommentTree{}
+	tree.Comments, tree.More = b.level(postID, start.ParentID, siblings, start.After, 1)
+	return tree, nil
+}
+
+// treeBuilder builds a comment tree. Its methods must be called with the
+// post's subreddit locked for reading.
+type treeBuilder struct {
+	opts      CommentTreeOptions
+	asOf      time.Time
+	moderator bool            // Whether the viewer sees removed comments
+	blocked   map[string]bool // Users the viewer has blocked
+}
+
+// level builds the nodes for one set of siblings at the given depth, starting
+// after the sibling at after, and the MoreComments for the siblings left out.
+func (b treeBuilder) level(postID, parentID string, siblings []*Comment, after *position, depth int) ([]*CommentNode, *MoreComments) {
+	var listed []*Comment
+	var positions []position
+	for _, comment := range siblings {
+		if !comment.Timestamp.After(b.asOf) && !b.hidden(comment) {
+			listed = append(listed, comment)
+			positions = append(positions, b.position(comment))
+		}
+	}
+	order := make([]int, len(listed))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return positions[order[i]].before(positions[order[j]])
+	})
+	if after != nil {
+		first := sort.Search(len(order), func(i int) bool {
+			return after.before(positions[order[i]])
+		})
+		order = order[first:]
+	}
+
+	shown := order
+	if b.opts.MaxBreadth > 0 && len(shown) > b.opts.MaxBreadth {
+		shown = shown[:b.opts.MaxBreadth]
+	}
+	nodes := make([]*CommentNode, len(shown))
+	for i, index := range shown {
+		nodes[i] = b.node(postID, listed[index], depth)
+	}
+
+	if len(shown) == len(order) {
+		return nodes, nil
+	}
+	// The breadth limit is at least 1, so some siblings were shown
+	last := positions[shown[len(shown)-1]]
+	token := moreToken{PostID: postID, ParentID: parentID, Sort: b.opts.Sort, After: &last, AsOf: b.asOf}
+	more := &MoreComments{Token: token.encode()}
+	for _, index := range order[len(shown):] {
+		more.Count += 1 + b.countReplies(listed[index])
+	}
+	return nodes, more
+}
+
+// node builds the node of a comment at the given depth, with its replies
+// unless the depth limit has been reached
+func (b treeBuilder) node(postID string, comment *Comment, depth int) *CommentNode {
+	node := &CommentNode{Comment: comment, Upvotes: comment.Upvotes, Downvotes: comment.Downvotes}
+	if comment.Deleted {
+		// The author took it down, so not even moderators see who they were
+		node.Comment = &Comment{
+			ID:        comment.ID,
+			Content:   DeletedContent,
+			Timestamp: comment.Timestamp,
+			Parent:    comment.Parent,
+			ReplyTo:   comment.ReplyTo,
+			Deleted:   true,
+		}
+	} else if (comment.Removed || comment.Filtered) && !b.moderator {
+		// Keep the replies in place under a tombstone that does not say who
+		// wrote the comment
+		node.Comment = &Comment{
+			ID:        comment.ID,
+			Content:   RemovedContent,
+			Timestamp: comment.Timestamp,
+			Parent:    comment.Parent,
+			ReplyTo:   comment.ReplyTo,
+			Removed:   true,
+		}
+	} else if b.blocked[comment.Author.Username] {
+		node.Comment = &Comment{
+			ID:        comment.ID,
+			Content:   BlockedContent,
+			Timestamp: comment.Timestamp,
+			Parent:    comment.Parent,
+			ReplyTo:   comment.ReplyTo,
+		}
+	}
+	if b.opts.MaxDepth > 0 && depth >= b.opts.MaxDepth {
+		if count := b.countReplies(comment); count > 0 {
+			token := moreToken{PostID: postID, ParentID: comment.ID, Sort: b.opts.Sort, AsOf: b.asOf}
+			node.More = &MoreComments{Count: count, Token: token.encode()}
+		}
+		return node
+	}
+	node.Replies, node.More = b.level(postID, comment.ID, comment.Replies, nil, depth+1)
+	return node
+}
+
+// hidden reports whether a comment is left out of the tree: it and every
+// reply under it are by users the viewer has blocked
+func (b treeBuilder) hidden(comment *Comment) bool {
+	if !b.blocked[comment.Author.Username] {
+		return false
+	}
+	for _, reply := range comment.Replies {
+		if !reply.Timestamp.After(b.asOf) && !b.hidden(reply) {
+			return false
+		}
+	}
+	return true
+}
+
+// countReplies counts the replies under a comment at every depth, leaving
+// out those the tree hides
+func (b treeBuilder) countReplies(comment *Comment) int {
+	count := 0
+	for _, reply := range comment.Replies {
+		if !reply.Timestamp.After(b.asOf) && !b.hidden(reply) {
+			count += 1 + b.countReplies(reply)
+		}
+	}
+	return count
+}
+
+// position places a comment among its siblings
+func (b treeBuilder) position(comment *Comment) position {
+	var key float64
+	switch b.opts.Sort {
+	case CommentTop:
+		key = float64(comment.Upvotes - comment.Downvotes)
+	case CommentControversial:
+		key = ControversialScore(comment.Upvotes, comment.Downvotes)
+	case CommentNew:
+		// Ordered by time alone
+	default:
+		key = BestScore(comment.Upvotes, comment.Downvotes)
+	}
+	return position{Key: key, Time: comment.Timestamp, ID: comment.ID}
+}
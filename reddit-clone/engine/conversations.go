@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Conversation summarises the direct messages between a user and one other user
+type Conversation struct {
+	With        string        // The other user
+	LastMessage DirectMessage // Newest message in either direction
+	Messages    int
+	Unread      int // Messages to the user they have not read
+}
+
+// ConversationPage is one page of the messages between two users
+type ConversationPage struct {
+	Messages   []DirectMessage // Newest first
+	Unread     int             // Across the whole conversation
+	NextCursor string          // Empty on the last page
+}
+
+// message looks up a direct message sent or received by username. Must be
+// called with e.messagesMu held.
+func (e *Engine) message(messageID, username string) (*DirectMessage, error) {
+	for _, messages := range e.messages {
+		for _, message := range messages {
+			if message.ID == messageID && (message.From.Username == username || message.To.Username == username) {
+				return message, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+}
+
+// conversation collects the messages between two users, oldest first. Must
+// be called with e.messagesMu held.
+func (e *Engine) conversation(username, with string) []*DirectMessage {
+	var messages []*DirectMessage
+	for _, message := range e.messages[username] {
+		if message.From.Username == with {
+			messages = append(messages, message)
+		}
+	}
+	if with != username {
+		for _, message := range e.messages[with] {
+			if message.From.Username == username {
+				messages = append(messages, message)
+			}
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+	return messages
+}
+
+// unread counts the messages to username that they have not read
+func unread(messages []*DirectMessage, username string) int {
+	count := 0
+	for _, message := range messages {
+		if message.To.Username == username && message.ReadAt.IsZero() {
+			count++
+		}
+	}
+	return count
+}
+
+// GetConversations returns a summary of each conversation a user takes part
+// in, the one with the newest message first
+func (e *Engine) GetConversations(username string) ([]Conversation, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	others := make(map[string]bool)
+	for _, message := range e.messages[username] {
+		others[message.From.Username] = true
+	}
+	for inbox, messages := range e.messages {
+		for _, message := range messages {
+			if message.From.Username == username {
+				others[inbox] = true
+				break
+			}
+		}
+	}
+
+	conversations := make([]Conversation, 0, len(others))
+	for with := range others {
+		messages := e.conversation(username, with)
+		conversations = append(conversations, Conversation{
+			With:        with,
+			LastMessage: *messages[len(messages)-1],
+			Messages:    len(messages),
+			Unread:      unread(messages, username),
+		})
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastMessage.Timestamp.After(conversations[j].LastMessage.Timestamp)
+	})
+	return conversations, nil
+}
+
+// GetConversation returns a page of the messages between two users, newest
+// first
+func (e *Engine) GetConversation(username, with, cursorToken string, limit int) (*ConversationPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+	if _, err := e.user(with); err != nil {
+		return nil, err
+	}
+
+	scope := "dm/" + username + "/" + with
+	after, err := decodeCursor(cursorToken, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	messages := e.conversation(username, with)
+
+	// The conversation is in sending order, which also orders messages that
+	// share a timestamp
+	positions := make([]position, len(messages))
+	for i, message := range messages {
+		positions[i] = position{Key: float64(i), Time: message.Timestamp, ID: message.ID}
+	}
+	selected, next := paginate(positions, after, scope, limit, listingTime(after))
+	page := &ConversationPage{Messages: make([]DirectMessage, len(selected)), Unread: unread(messages, username), NextCursor: next}
+	for i, index := range selected {
+		page.Messages[i] = *messages[index]
+	}
+	return page, nil
+}
+
+// MarkConversationRead marks every message from with to username read and
+// returns how many were unread
+func (e *Engine) MarkConversationRead(username, with string) (int, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.markConversationRead(journalEntry{Type: entryMarkConversationRead, Time: time.Now(), Username: username, Recipient: with})
+}
+
+// markConversationRead applies a mark_conversation_read entry. Must be
+// called with e.mu held.
+func (e *Engine) markConversationRead(entry journalEntry) (int, error) {
+	if _, err := e.user(entry.Username); err != nil {
+		return 0, err
+	}
+	if _, err := e.user(entry.Recipient); err != nil {
+		return 0, err
+	}
+
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	var unread []*DirectMessage
+	for _, message := range e.messages[entry.Username] {
+		if message.From.Username == entry.Recipient && message.ReadAt.IsZero() {
+			unread = append(unread, message)
+		}
+	}
+	if len(unread) == 0 {
+		return 0, nil
+	}
+	if err := e.record(&entry); err != nil {
+		return 0, err
+	}
+	for _, message := range unread {
+		message.ReadAt = entry.Time
+	}
+	return len(unread), nil
+}
+
+// GetMessageThread returns the reply chain that ends with a message sent or
+// received by username, starting from the message that opened it
+func (e *Engine) GetMessageThread(username, messageID string) ([]DirectMessage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	message, err := e.message(messageID, username)
+	if err != nil {
+		return nil, err
+	}
+	thread := []DirectMessage{*message}
+	for message.ReplyTo != "" {
+		if message, err = e.message(message.ReplyTo, username); err != nil {
+			break // The chain leaves the user's messages
+		}
+		thread = append(thread, *message)
+	}
+
+	// Oldest first
+	for i, j := 0, len(thread)-1; i < j; i, j = i+1, j-1 {
+		thread[i], thread[j] = thread[j], thread[i]
+	}
+	return thread, nil
+}
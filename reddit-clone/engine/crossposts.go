@@ -0,0 +1,83 @@
+package engine
+
+import "time"
+
+// SetCrosspostsAllowed lets moderators decide whether their subreddit takes
+// reposts of posts made in other subreddits. Subreddits accept them by
+// default.
+func (e *Engine) SetCrosspostsAllowed(subredditName, moderator string, allowed bool) error {
+	entry := journalEntry{Type: entryAllowCrossposts, Time: time.Now(), Username: moderator, Subreddit: subredditName}
+	if !allowed {
+		entry.Type = entryDisallowCrossposts
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.setCrosspostsAllowed(entry)
+}
+
+// setCrosspostsAllowed applies an allow or disallow crossposts entry. Must be
+// called with e.mu held.
+func (e *Engine) setCrosspostsAllowed(entry journalEntry) error {
+	subreddit, err := e.moderatedSubreddit(entry)
+	if err != nil {
+		return err
+	}
+	defer subreddit.mu.Unlock()
+
+	disallowed := entry.Type == entryDisallowCrossposts
+	if subreddit.noCrossposts == disallowed {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	subreddit.noCrossposts = disallowed
+	return nil
+}
+
+// CrosspostsAllowed reports whether a subreddit accepts reposts from other
+// subreddits
+func (e *Engine) CrosspostsAllowed(subredditName string) (bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return false, err
+	}
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	return !subreddit.noCrossposts, nil
+}
+
+// GetCrossposts returns the reposts of a post's root original, oldest first.
+// Given a repost, it lists the reposts of the post it shares, including the
+// repost itself. Reposts removed by moderators are left out unless viewer
+// moderates their subreddit.
+func (e *Engine) GetCrossposts(postID, viewer string) ([]*Post, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	post, err := e.post(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.OriginalPost != nil {
+		post = post.OriginalPost
+	}
+
+	e.indexMu.RLock()
+	crossposts := append([]*Post(nil), post.Crossposts...)
+	e.indexMu.RUnlock()
+
+	visible := crossposts[:0]
+	for _, crosspost := range crossposts {
+		crosspost.Subreddit.mu.RLock()
+		shown := crosspost.visibleTo(viewer)
+		crosspost.Subreddit.mu.RUnlock()
+		if shown {
+			visible = append(visible, crosspost)
+		}
+	}
+	return visible, nil
+}
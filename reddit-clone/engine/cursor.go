@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Frozen orders are kept for frozenOrderTTL. Each viewer keeps at most
+// maxFrozenOrders of a listing, dropping their oldest first, and at most
+// maxFrozenListings listings and viewers are kept in all, dropping the least
+// recently frozen first. Only the first maxFrozenPositions items of a listing
+// are frozen.
+const (
+	frozenOrderTTL     = 30 * time.Minute
+	maxFrozenOrders    = 4
+	maxFrozenListings  = 1024
+	maxFrozenPositions = 250
+)
+
+// position places an item in a paginated listing. Listings run from the
+// highest key down, with ties going to the newer item and then the higher ID,
+// so every item has exactly one place.
+type position struct {
+	Key  float64   `json:"k,omitempty"`
+	Time time.Time `json:"t"`
+	ID   string    `json:"i"`
+}
+
+// before reports whether p is listed ahead of q
+func (p position) before(q position) bool {
+	if p.Key != q.Key {
+		return p.Key > q.Key
+	}
+	if !p.Time.Equal(q.Time) {
+		return p.Time.After(q.Time)
+	}
+	return p.ID > q.ID
+}
+
+// cursor is the opaque token handed out with a page. It remembers the last
+// item served rather than a count, so items added or removed ahead of it do
+// not shift the next page, and the time of the first page, so items created
+// since then are left out instead of pushing others across the page boundary.
+type cursor struct {
+	Scope string    `json:"s"` // Listing the cursor was issued for
+	Last  position  `json:"l"`
+	AsOf  time.Time `json:"a"`
+	Order string    `json:"o,omitempty"` // Frozen order of a ranked listing
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor issued for scope. An empty token starts a
+// listing from the beginning and returns nil.
+func decodeCursor(token, scope string) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, token)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Scope != scope {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCursor, token)
+	}
+	return &c, nil
+}
+
+// listingTime returns the time a listing is computed at: the time its first
+// page was served, or now for a first page.
+func listingTime(after *cursor) time.Time {
+	if after != nil {
+		return after.AsOf
+	}
+	return time.Now()
+}
+
+// paginate orders positions and selects up to limit of them following the
+// cursor, leaving out items newer than asOf. It returns the indices of the
+// selected positions in listing order, and the cursor for the next page, or
+// an empty string on the last page. A limit of 0 selects every remaining item.
+func paginate(positions []position, after *cursor, scope string, limit int, asOf time.Time) ([]int, string) {
+	order := listingOrder(positions, asOf)
+	if after != nil {
+		start := sort.Search(len(order), func(i int) bool {
+			return after.Last.before(positions[order[i]])
+		})
+		order = order[start:]
+	}
+	if limit <= 0 || len(order) <= limit {
+		return order, ""
+	}
+	order = order[:limit]
+	return order, encodeCursor(cursor{Scope: scope, Last: positions[order[limit-1]], AsOf: asOf})
+}
+
+// listingOrder returns the indices of the positions not newer than asOf, in
+// listing order
+func listingOrder(positions []position, asOf time.Time) []int {
+	order := make([]int, 0, len(positions))
+	for i, p := range positions {
+		if !p.Time.After(asOf) {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return positions[order[a]].before(positions[order[b]])
+	})
+	return order
+}
+
+// paginateFrozen is paginate for listings ranked by keys that change over
+// time, such as vote scores. A first page with more to come freezes the order
+// of the top of the listing for the viewer, and later pages follow it, so
+// votes cast between pages neither repeat nor skip items. Past the frozen
+// items, pages follow the current order. Items gone since the first page are
+// left out. Cursors stop working once their order has been dropped.
+func (e *Engine) paginateFrozen(positions []position, after *cursor, scope, viewer string, limit int, asOf time.Time) ([]int, string, error) {
+	if after == nil {
+		order := listingOrder(positions, asOf)
+		if limit <= 0 || len(order) <= limit {
+			return order, "", nil
+		}
+		frozen := make([]position, 0, maxFrozenPositions)
+		for _, index := range order {
+			if len(frozen) == maxFrozenPositions {
+				break
+			}
+			frozen = append(frozen, positions[index])
+		}
+		id := e.orders.freeze(scope, viewer, frozen)
+		return order[:limit], encodeCursor(cursor{Scope: scope, Last: positions[order[limit-1]], AsOf: asOf, Order: id}), nil
+	}
+
+	frozen, exists := e.orders.get(after.Order, scope, viewer)
+	if !exists {
+		return nil, "", fmt.Errorf("%w: listing expired, start again from the first page", ErrInvalidCursor)
+	}
+	isFrozen := make(map[string]bool, len(frozen))
+	for _, p := range frozen {
+		isFrozen[p.ID] = true
+	}
+	current := make(map[string]int, len(positions))
+	for i, p := range positions {
+		current[p.ID] = i
+	}
+
+	// The rest of the listing is what is left of the frozen items, then the
+	// items past them in their current order
+	var rest []int
+	var restPositions []position
+	inFrozen := isFrozen[after.Last.ID]
+	if inFrozen {
+		start := sort.Search(len(frozen), func(i int) bool {
+			return after.Last.before(frozen[i])
+		})
+		for _, p := range frozen[start:] {
+			if index, exists := current[p.ID]; exists {
+				rest = append(rest, index)
+				restPositions = append(restPositions, p)
+			}
+		}
+	}
+	for _, index := range listingOrder(positions, after.AsOf) {
+		if p := positions[index]; !isFrozen[p.ID] && (inFrozen || after.Last.before(p)) {
+			rest = append(rest, index)
+			restPositions = append(restPositions, p)
+		}
+	}
+	if limit <= 0 || len(rest) <= limit {
+		return rest, "", nil
+	}
+	return rest[:limit], encodeCursor(cursor{Scope: scope, Last: restPositions[limit-1], AsOf: after.AsOf, Order: after.Order}), nil
+}
+
+// frozenOrder is the order of the top of a ranked listing as of its first
+// page
+type frozenOrder struct {
+	id        string
+	positions []position
+	frozen    time.Time
+}
+
+// orderKey names the listing and viewer a frozen order was made for, so that
+// viewers paging through listings never drop each other's orders
+type orderKey struct {
+	scope, viewer string
+}
+
+// orders keeps the frozen orders of the ranked listings being paged through.
+// Its lock is a leaf. The zero value is ready to use.
+type orders struct {
+	mu    sync.Mutex
+	byKey map[orderKey][]*frozenOrder // Oldest first
+}
+
+// freeze keeps the order of a listing for viewer and returns its ID
+func (o *orders) freeze(scope, viewer string, positions []position) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	key := orderKey{scope: scope, viewer: viewer}
+	kept, exists := o.byKey[key]
+	for len(kept) > 0 && (len(kept) >= maxFrozenOrders || now.Sub(kept[0].frozen) > frozenOrderTTL) {
+		kept = kept[1:]
+	}
+	if o.byKey == nil {
+		o.byKey = make(map[orderKey][]*frozenOrder)
+	}
+	if !exists && len(o.byKey) >= maxFrozenListings {
+		o.dropStalest(now)
+	}
+	id := fmt.Sprintf("order-%d", rand.Int())
+	o.byKey[key] = append(kept, &frozenOrder{id: id, positions: positions, frozen: now})
+	return id
+}
+
+// dropStalest makes room for another listing by dropping those whose orders
+// have all expired, or else the one frozen least recently. Must be called with
+// o.mu held.
+func (o *orders) dropStalest(now time.Time) {
+	var stalest orderKey
+	var stalestFrozen time.Time
+	for key, kept := range o.byKey {
+		newest := kept[len(kept)-1].frozen
+		if now.Sub(newest) > frozenOrderTTL {
+			delete(o.byKey, key)
+		} else if stalestFrozen.IsZero() || newest.Before(stalestFrozen) {
+			stalest, stalestFrozen = key, newest
+		}
+	}
+	if len(o.byKey) >= maxFrozenListings {
+		delete(o.byKey, stalest)
+	}
+}
+
+// get returns the frozen order of a listing for viewer, if it is still kept
+func (o *orders) get(id, scope, viewer string) ([]position, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, order := range o.byKey[orderKey{scope: scope, viewer: viewer}] {
+		if order.id == id {
+			return order.positions, time.Since(order.frozen) <= frozenOrderTTL
+		}
+	}
+	return nil, false
+}
+
+// clear drops every frozen order
+func (o *orders) clear() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.byKey = nil
+}
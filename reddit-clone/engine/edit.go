@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeletedContent replaces the content of deleted posts and comments
+const DeletedContent = "[deleted]"
+
+// Revision is an earlier content of an edited post or comment
+type Revision struct {
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"` // When this content was written
+}
+
+// EditPost replaces the content of a post. Only its author may edit it, and
+// the previous content is kept in its revision history.
+func (e *Engine) EditPost(postID, username, content string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.editPost(journalEntry{Type: entryEditPost, Time: time.Now(), Username: username, TargetID: postID, Content: content})
+}
+
+// editPost applies a post edit entry. Must be called with e.mu held.
+func (e *Engine) editPost(entry journalEntry) error {
+	post, err := e.post(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	post.Subreddit.mu.Lock()
+	defer post.Subreddit.mu.Unlock()
+	if err := checkEditable(post.Author, post.Deleted, entry); err != nil {
+		return err
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	post.Revisions = append(post.Revisions, Revision{Content: post.Content, Timestamp: lastWritten(post.Timestamp, post.Edited)})
+	post.Content = entry.Content
+	post.Edited = entry.Time
+	return nil
+}
+
+// DeletePost replaces a post with a tombstone. Its comments, votes and
+// reposts stay in place, but its content and revision history are dropped.
+func (e *Engine) DeletePost(postID, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.deletePost(journalEntry{Type: entryDeletePost, Time: time.Now(), Username: username, TargetID: postID})
+}
+
+// deletePost applies a post deletion entry. Must be called with e.mu held.
+func (e *Engine) deletePost(entry journalEntry) error {
+	post, err := e.post(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	post.Subreddit.mu.Lock()
+	defer post.Subreddit.mu.Unlock()
+	if err := checkEditable(post.Author, post.Deleted, entry); err != nil {
+		return err
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	post.Content, post.Revisions, post.Deleted = DeletedContent, nil, true
+	post.Edited = entry.Time
+	return nil
+}
+
+// EditComment replaces the content of a comment. Only its author may edit
+// it, and the previous content is kept in its revision history.
+func (e *Engine) EditComment(commentID, username, content string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.editComment(journalEntry{Type: entryEditComment, Time: time.Now(), Username: username, TargetID: commentID, Content: content})
+}
+
+// editComment applies a comment edit entry. Must be called with e.mu held.
+func (e *Engine) editComment(entry journalEntry) error {
+	comment, err := e.comment(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	subreddit := comment.Parent.Subreddit
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if err := checkEditable(comment.Author, comment.Deleted, entry); err != nil {
+		return err
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	comment.Revisions = append(comment.Revisions, Revision{Content: comment.Content, Timestamp: lastWritten(comment.Timestamp, comment.Edited)})
+	comment.Content = entry.Content
+	comment.Edited = entry.Time
+	return nil
+}
+
+// DeleteComment replaces a comment with a tombstone, so the replies under it
+// stay in place. Its content and revision history are dropped.
+func (e *Engine) DeleteComment(commentID, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.deleteComment(journalEntry{Type: entryDeleteComment, Time: time.Now(), Username: username, TargetID: commentID})
+}
+
+// deleteComment applies a comment deletion entry. Must be called with e.mu held.
+func (e *Engine) deleteComment(entry journalEntry) error {
+	comment, err := e.comment(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	subreddit := comment.Parent.Subreddit
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if err := checkEditable(comment.Author, comment.Deleted, entry); err != nil {
+		return err
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	comment.Content, comment.Revisions, comment.Deleted = DeletedContent, nil, true
+	comment.Edited = entry.Time
+	return nil
+}
+
+// GetPostRevisions returns the earlier contents of a post, oldest first
+func (e *Engine) GetPostRevisions(postID string) ([]Revision, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	post, err := e.post(postID)
+	if err != nil {
+		return nil, err
+	}
+	post.Subreddit.mu.RLock()
+	defer post.Subreddit.mu.RUnlock()
+	return append([]Revision(nil), post.Revisions...), nil
+}
+
+// GetCommentRevisions returns the earlier contents of a comment, oldest first
+func (e *Engine) GetCommentRevisions(commentID string) ([]Revision, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	comment, err := e.comment(commentID)
+	if err != nil {
+		return nil, err
+	}
+	comment.Parent.Subreddit.mu.RLock()
+	defer comment.Parent.Subreddit.mu.RUnlock()
+	return append([]Revision(nil), comment.Revisions...), nil
+}
+
+// checkEditable verifies that the user in entry may change content by author
+func checkEditable(author *User, deleted bool, entry journalEntry) error {
+	if author.Username != entry.Username {
+		return fmt.Errorf("%w: %s on %s", ErrNotAuthor, entry.Username, entry.TargetID)
+	}
+	if deleted {
+		return fmt.Errorf("%w: %s", ErrDeleted, entry.TargetID)
+	}
+	return nil
+}
+
+// lastWritten returns when the current content was written
+func lastWritten(created, edited time.Time) time.Time {
+	if edited.IsZero() {
+		return created
+	}
+	return edited
+}
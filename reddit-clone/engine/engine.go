@@ -8,26 +8,87 @@ import (
 	"time"
 )
 
-// Engine struct holds all subreddits, users, and messages
+// Engine struct holds all subreddits, users, and messages.
+//
+// Locking is split so that unrelated operations run in parallel. Locks are
+// always taken in this order, and at most one of each kind is held at a time:
+//
+//	Engine.mu -> Subreddit.mu -> Engine.indexMu -> User.mu
+//
+// Engine.mu only guards the users and subreddits registries. Every operation
+// holds it for reading for its whole duration, and only account registration
+// and subreddit creation take it for writing. Engine.messagesMu guards the DM
+// inboxes and Engine.followsMu the follow graph. Each is always taken last,
+// and only ever inside the User.mu of a message's recipient or of the user
+// being followed, which guards their block list.
+//
+// An engine opened with OpenEngine also has a journal, whose lock is a leaf:
+// mutations are recorded while the locks guarding the change are held. The
+// lock of the frozen listing orders is a leaf too.
 type Engine struct {
 	subreddits map[string]*Subreddit
 	users      map[string]*User
+	mu         sync.RWMutex
+
+	posts    map[string]*Post    // Index of every post (including reposts) by ID
+	comments map[string]*Comment // Index of every comment at any depth by ID
+	indexMu  sync.RWMutex        // Also guards Post.Crossposts
+
 	messages   map[string][]*DirectMessage
-	mu         sync.Mutex
+	messagesMu sync.Mutex
+
+	following map[string]map[string]bool // Users each user follows
+	followers map[string]map[string]bool // The reverse of following
+	followsMu sync.Mutex
+
+	journal *journal // Nil unless opened with OpenEngine
+	events  *EventBus
+	orders  orders // Frozen orders of the ranked listings being paged through
 }
 
+// voteKey identifies a single user's vote on a post or comment, matching the
+// (username, target_id) uniqueness rule of the votes table
+type voteKey struct {
+	username string
+	targetID string
+}
+
+// Subreddit holds its posts and members. Its mu also guards the comments,
+// vote counters, vote ledger and moderation state of every post made in it.
 type Subreddit struct {
-	Name    string
-	Posts   []*Post
-	Members map[string]*User
+	Name         string
+	Creator      string // Username of its creator, empty if created before creators were recorded
+	Posts        []*Post
+	Members      map[string]*User
+	votes        map[voteKey]int // One vote per (user, target): 1 for up, -1 for down
+	moderators   map[string]bool
+	bans         map[string]Ban // Expired bans are kept until lifted or replaced
+	mutes        map[string]Ban
+	automod      []AutoModRule
+	noCrossposts bool // Refuses reposts from other subreddits
+	mu           sync.RWMutex
 }
 
+// User is a registered account. Its mu guards the karma, Posts, Comments,
+// the presence, the notifications, the block list and the saved and hidden
+// lists.
 type User struct {
-	Username  string
-	Karma     int
-	Posts     []*Post
-	Comments  []*Comment
-	Connected bool
+	Username      string
+	Karma         int // PostKarma plus CommentKarma
+	PostKarma     int // Net votes from other users on the user's posts
+	CommentKarma  int // Net votes from other users on the user's comments
+	Created       time.Time
+	Posts         []*Post
+	Comments      []*Comment
+	Connected     bool                 // Has at least one open session
+	LastSeen      time.Time            // When the last session closed, zero if never
+	notifications []*Notification      // Oldest first
+	blocked       map[string]bool      // Users this one has blocked
+	saved         []*SavedItem         // Oldest first
+	hidden        map[string]time.Time // When each hidden post was hidden, by ID
+	sessions      map[string]time.Time // When each open session was opened, by ID
+	pending       []Delivery           // Received while offline, oldest first
+	mu            sync.Mutex
 }
 
 type Post struct {
@@ -40,7 +101,17 @@ type Post struct {
 	Downvotes    int
 	Comments     []*Comment
 	IsRepost     bool
-	OriginalPost *Post
+	OriginalPost *Post      // Always a root original, never another repost
+	Crossposts   []*Post    // Reposts of a root original, oldest first
+	Edited       time.Time  // Time of the last edit or the deletion, zero if never edited
+	Deleted      bool       // Content replaced with DeletedContent
+	Revisions    []Revision // Earlier contents, oldest first
+	Removed      bool       // Hidden by a moderator
+	Spam         bool       // Removed as spam
+	Filtered     bool       // Held by AutoModerator for review
+	Locked       bool       // Takes no new comments or votes
+	Flair        string
+	Reports      []Report
 }
 
 type Comment struct {
@@ -53,6 +124,13 @@ type Comment struct {
 	Replies   []*Comment
 	Upvotes   int
 	Downvotes int
+	Edited    time.Time  // Time of the last edit or the deletion, zero if never edited
+	Deleted   bool       // Content replaced with DeletedContent
+	Revisions []Revision // Earlier contents, oldest first
+	Removed   bool       // Hidden by a moderator
+	Spam      bool       // Removed as spam
+	Filtered  bool       // Held by AutoModerator for review
+	Reports   []Report
 }
 
 type DirectMessage struct {
@@ -61,122 +139,244 @@ type DirectMessage struct {
 	To        *User
 	Content   string
 	Timestamp time.Time
-	ReplyTo   string
+	ReplyTo   string    // ID of the message replied to, empty for a new thread
+	ReadAt    time.Time // Zero until the recipient reads it. Guarded by the engine's messagesMu.
 }
 
-func (e *Engine) LeaveSubreddit(username, subredditName string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// LeaveSubreddit removes a user from a subreddit's members
+func (e *Engine) LeaveSubreddit(username, subredditName string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leaveSubreddit(journalEntry{Type: entryLeave, Time: time.Now(), Username: username, Subreddit: subredditName})
+}
 
-	subreddit, exists := e.subreddits[subredditName]
-	if !exists {
-		return
+// leaveSubreddit applies a leave entry. Must be called with e.mu held.
+func (e *Engine) leaveSubreddit(entry journalEntry) error {
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return err
+	}
+
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if _, isMember := subreddit.Members[entry.Username]; !isMember {
+		return fmt.Errorf("%w: %s in %s", ErrNotMember, entry.Username, entry.Subreddit)
 	}
-	delete(subreddit.Members, username)
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	delete(subreddit.Members, entry.Username)
+	return nil
 }
 
-func (e *Engine) GetFeed(username string) []*Post {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// GetFeed returns every post from the subreddits a user has joined and the
+// users they follow
+func (e *Engine) GetFeed(username string) ([]*Post, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	var feed []*Post
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+	ranked := e.homePosts(username)
+	feed := make([]*Post, len(ranked))
+	for i, post := range ranked {
+		feed[i] = post.post
+	}
+	return feed, nil
+}
+
+// homePosts collects the posts of every subreddit the user has joined and of
+// every user they follow, with their current votes, except those they have
+// hidden and those by users they have blocked. Must be called with e.mu held
+// and no lock taken.
+func (e *Engine) homePosts(username string) []rankedPost {
+	var feed []rankedPost
+	blocked, hidden := e.blockedBy(username), e.hiddenBy(username)
 	for _, subreddit := range e.subreddits {
+		subreddit.mu.RLock()
 		if _, isMember := subreddit.Members[username]; isMember {
-			feed = append(feed, subreddit.Posts...)
+			for _, post := range subreddit.Posts {
+				if post.visibleTo(username) && !blocked[post.Author.Username] && !hidden[post.ID] {
+					feed = append(feed, rankedPost{post: post, upvotes: post.Upvotes, downvotes: post.Downvotes})
+				}
+			}
+		}
+		subreddit.mu.RUnlock()
+	}
+
+	for followee := range e.followedBy(username) {
+		author, exists := e.users[followee]
+		if !exists || blocked[followee] {
+			continue
+		}
+		author.mu.Lock()
+		history := append([]*Post(nil), author.Posts...)
+		author.mu.Unlock()
+
+		for _, post := range history {
+			post.Subreddit.mu.RLock()
+			// Posts in joined subreddits were collected above
+			if _, isMember := post.Subreddit.Members[username]; !isMember && post.visibleTo(username) && !hidden[post.ID] {
+				feed = append(feed, rankedPost{post: post, upvotes: post.Upvotes, downvotes: post.Downvotes})
+			}
+			post.Subreddit.mu.RUnlock()
 		}
 	}
 	return feed
 }
 
-func (e *Engine) ReplyToComment(postID, parentCommentID, username, content string) *Comment {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	user, exists := e.users[username]
-	if !exists {
-		return nil
+// ReplyToComment adds a reply under an existing comment of a post
+func (e *Engine) ReplyToComment(postID, parentCommentID, username, content string) (*Comment, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	reply, err := e.replyToComment(journalEntry{
+		Type:     entryReply,
+		Time:     time.Now(),
+		ID:       fmt.Sprintf("comment-%d", rand.Int()),
+		Username: username,
+		TargetID: postID,
+		ParentID: parentCommentID,
+		Content:  content,
+	})
+	if err != nil {
+		return nil, err
 	}
+	e.events.Publish(commentCreated(reply))
+	return reply, nil
+}
 
-	var parentComment *Comment
-	var parentPost *Post
+// replyToComment applies a reply entry. Must be called with e.mu held.
+func (e *Engine) replyToComment(entry journalEntry) (*Comment, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
+	}
 
 	// Find the parent post and comment
-	for _, subreddit := range e.subreddits {
-		for _, post := range subreddit.Posts {
-			if post.ID == postID {
-				parentPost = post
-				for _, comment := range post.Comments {
-					if comment.ID == parentCommentID {
-						parentComment = comment
-						break
-					}
-				}
-				break
-			}
-		}
+	parentPost, err := e.post(entry.TargetID)
+	if err != nil {
+		return nil, err
 	}
-
-	if parentComment == nil || parentPost == nil {
-		return nil
+	parentComment, err := e.comment(entry.ParentID)
+	if err != nil || parentComment.Parent != parentPost {
+		return nil, fmt.Errorf("%w: %s on post %s", ErrCommentNotFound, entry.ParentID, entry.TargetID)
 	}
 
 	reply := &Comment{
-		ID:        fmt.Sprintf("comment-%d", rand.Int()),
+		ID:        entry.ID,
 		Author:    user,
-		Content:   content,
-		Timestamp: time.Now(),
+		Content:   entry.Content,
+		Timestamp: entry.Time,
 		Parent:    parentPost,
 		ReplyTo:   parentComment,
 	}
 
+	subreddit := parentPost.Subreddit
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if parentPost.Locked {
+		return nil, fmt.Errorf("%w: %s", ErrLocked, parentPost.ID)
+	}
+	if err := subreddit.checkNotBanned(entry.Username, entry.Time); err != nil {
+		return nil, err
+	}
+	if err := e.record(&entry); err != nil {
+		return nil, err
+	}
 	parentComment.Replies = append(parentComment.Replies, reply)
-	user.Comments = append(user.Comments, reply)
-	return reply
+	e.indexComment(reply)
+	e.autoModerate(subreddit, parentPost, reply, entry)
+	e.notify(parentPost, reply)
+	return reply, nil
 }
 
-func (e *Engine) Repost(originalPostID, username, subredditName string) *Post {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// Repost shares an existing post into another subreddit. Reposting a repost
+// shares the root original it points to.
+func (e *Engine) Repost(originalPostID, username, subredditName string) (*Post, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	repost, err := e.repost(journalEntry{
+		Type:      entryRepost,
+		Time:      time.Now(),
+		ID:        fmt.Sprintf("post-%d", rand.Int()),
+		Username:  username,
+		Subreddit: subredditName,
+		TargetID:  originalPostID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.events.Publish(postCreated(repost))
+	return repost, nil
+}
 
-	var originalPost *Post
-	for _, subreddit := range e.subreddits {
-		for _, post := range subreddit.Posts {
-			if post.ID == originalPostID {
-				originalPost = post
-				break
-			}
-		}
+// repost applies a repost entry. Must be called with e.mu held.
+func (e *Engine) repost(entry journalEntry) (*Post, error) {
+	originalPost, err := e.post(entry.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if originalPost.OriginalPost != nil {
+		originalPost = originalPost.OriginalPost
 	}
 
-	if originalPost == nil {
-		return nil
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
+	}
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return nil, err
 	}
 
-	user := e.users[username]
-	subreddit := e.subreddits[subredditName]
+	// The original may be edited or deleted concurrently
+	originalPost.Subreddit.mu.RLock()
+	content, deleted := originalPost.Content, originalPost.Deleted
+	originalPost.Subreddit.mu.RUnlock()
+	if deleted {
+		return nil, fmt.Errorf("%w: %s", ErrDeleted, originalPost.ID)
+	}
 
 	repost := &Post{
-		ID:           fmt.Sprintf("post-%d", rand.Int()),
+		ID:           entry.ID,
 		Author:       user,
-		Content:      originalPost.Content,
+		Content:      content,
 		Subreddit:    subreddit,
-		Timestamp:    time.Now(),
+		Timestamp:    entry.Time,
 		IsRepost:     true,
 		OriginalPost: originalPost,
 	}
 
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if subreddit.noCrossposts && subreddit != originalPost.Subreddit {
+		return nil, fmt.Errorf("%w: %s", ErrCrosspostsDisabled, subreddit.Name)
+	}
+	if err := subreddit.checkNotBanned(entry.Username, entry.Time); err != nil {
+		return nil, err
+	}
+	if err := e.record(&entry); err != nil {
+		return nil, err
+	}
 	subreddit.Posts = append(subreddit.Posts, repost)
-	user.Posts = append(user.Posts, repost)
-	return repost
+	e.indexPost(repost)
+	e.autoModerate(subreddit, repost, nil, entry)
+	return repost, nil
 }
 
-func (e *Engine) SetUserConnection(username string, connected bool) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if user, exists := e.users[username]; exists {
-		user.Connected = connected
-	}
+// SetUserConnection opens a session for a user without one, or closes every
+// session of the user. Deliveries queued for the user are dropped from the
+// queue when it brings them online; they stay in their inbox and
+// notifications.
+func (e *Engine) SetUserConnection(username string, connected bool) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if !connected {
+		return e.publishDisconnect(journalEntry{Type: entryDisconnect, Time: time.Now(), Username: username})
+	}
+	_, err := e.publishConnect(username, true)
+	return err
 }
 
 // NewEngine creates and initializes a new Reddit-like engine
@@ -185,306 +385,579 @@ func NewEngine() *Engine {
 		subreddits: make(map[string]*Subreddit),
 		users:      make(map[string]*User),
 		messages:   make(map[string][]*DirectMessage),
+		following:  make(map[string]map[string]bool),
+		followers:  make(map[string]map[string]bool),
+		posts:      make(map[string]*Post),
+		comments:   make(map[string]*Comment),
+		events:     NewEventBus(),
+	}
+}
+
+// user looks up a registered user. Must be called with e.mu held.
+func (e *Engine) user(username string) (*User, error) {
+	user, exists := e.users[username]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	return user, nil
+}
+
+// subreddit looks up an existing subreddit. Must be called with e.mu held.
+func (e *Engine) subreddit(name string) (*Subreddit, error) {
+	subreddit, exists := e.subreddits[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSubredditNotFound, name)
 	}
+	return subreddit, nil
+}
+
+// post looks up a post by ID in the index
+func (e *Engine) post(postID string) (*Post, error) {
+	e.indexMu.RLock()
+	defer e.indexMu.RUnlock()
+
+	post, exists := e.posts[postID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	return post, nil
+}
+
+// comment looks up a comment at any depth by ID in the index
+func (e *Engine) comment(commentID string) (*Comment, error) {
+	e.indexMu.RLock()
+	defer e.indexMu.RUnlock()
+
+	comment, exists := e.comments[commentID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCommentNotFound, commentID)
+	}
+	return comment, nil
+}
+
+// indexPost makes a new post visible to lookups, adds a repost to its
+// original's crossposts and adds the post to its author's history. Must be
+// called with the post's subreddit locked.
+func (e *Engine) indexPost(post *Post) {
+	e.indexMu.Lock()
+	e.posts[post.ID] = post
+	if post.OriginalPost != nil {
+		post.OriginalPost.Crossposts = append(post.OriginalPost.Crossposts, post)
+	}
+	e.indexMu.Unlock()
+
+	post.Author.mu.Lock()
+	post.Author.Posts = append(post.Author.Posts, post)
+	post.Author.mu.Unlock()
 }
 
-// CreateSubreddit creates a new subreddit if it doesn't already exist
-func (e *Engine) CreateSubreddit(name string) {
+// indexComment makes a new comment visible to lookups and adds it to its
+// author's history. Must be called with the comment's subreddit locked.
+func (e *Engine) indexComment(comment *Comment) {
+	e.indexMu.Lock()
+	e.comments[comment.ID] = comment
+	e.indexMu.Unlock()
+
+	comment.Author.mu.Lock()
+	comment.Author.Comments = append(comment.Author.Comments, comment)
+	comment.Author.mu.Unlock()
+}
+
+// CreateSubreddit creates a new subreddit if it doesn't already exist. Its
+// creator may appoint its first moderator.
+func (e *Engine) CreateSubreddit(name, creator string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	if _, err := e.user(creator); err != nil {
+		return err
+	}
+	return e.createSubreddit(journalEntry{Type: entryCreateSubreddit, Time: time.Now(), Username: creator, Subreddit: name})
+}
 
-	if _, exists := e.subreddits[name]; exists {
-		fmt.Println("Subreddit already exists!")
-		return
+// createSubreddit applies a subreddit creation entry. Must be called with
+// e.mu held for writing.
+func (e *Engine) createSubreddit(entry journalEntry) error {
+	if _, exists := e.subreddits[entry.Subreddit]; exists {
+		return fmt.Errorf("%w: %s", ErrSubredditExists, entry.Subreddit)
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	e.subreddits[entry.Subreddit] = &Subreddit{
+		Name:       entry.Subreddit,
+		Creator:    entry.Username,
+		Members:    make(map[string]*User),
+		votes:      make(map[voteKey]int),
+		moderators: make(map[string]bool),
+		bans:       make(map[string]Ban),
+		mutes:      make(map[string]Ban),
 	}
-	e.subreddits[name] = &Subreddit{Name: name, Members: make(map[string]*User)}
-	fmt.Printf("Subreddit %s created.\n", name)
+	return nil
 }
 
 // RegisterAccount registers a new user in the engine
-func (e *Engine) RegisterAccount(username string) *User {
+func (e *Engine) RegisterAccount(username string) (*User, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	return e.registerAccount(journalEntry{Type: entryRegister, Time: time.Now(), Username: username})
+}
 
-	if _, exists := e.users[username]; exists {
-		fmt.Println("Account already exists!")
-		return nil
+// registerAccount applies a registration entry. Must be called with e.mu held
+// for writing.
+func (e *Engine) registerAccount(entry journalEntry) (*User, error) {
+	if _, exists := e.users[entry.Username]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, entry.Username)
 	}
-	user := &User{Username: username, Karma: 0}
-	e.users[username] = user
-	fmt.Printf("User %s registered.\n", username)
-	return user
+	if err := e.record(&entry); err != nil {
+		return nil, err
+	}
+	user := &User{Username: entry.Username, Karma: 0, Created: entry.Time}
+	e.users[entry.Username] = user
+	return user, nil
+}
+
+// GetUser returns a registered user
+func (e *Engine) GetUser(username string) (*User, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.user(username)
 }
 
 // JoinSubreddit allows a user to join a subreddit
-func (e *Engine) JoinSubreddit(username, subredditName string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Engine) JoinSubreddit(username, subredditName string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	entry := journalEntry{Type: entryJoin, Time: time.Now(), Username: username, Subreddit: subredditName}
+	if err := e.joinSubreddit(entry); err != nil {
+		return err
+	}
+	e.events.Publish(UserJoined{Username: username, Subreddit: subredditName, Time: entry.Time})
+	return nil
+}
 
-	user, exists := e.users[username]
-	if !exists {
-		fmt.Println("User not found!")
-		return
+// joinSubreddit applies a join entry. Must be called with e.mu held.
+func (e *Engine) joinSubreddit(entry journalEntry) error {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return err
 	}
 
-	subreddit, exists := e.subreddits[subredditName]
-	if !exists {
-		fmt.Println("Subreddit not found!")
-		return
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return err
 	}
 
-	subreddit.Members[username] = user
-	fmt.Printf("%s joined the subreddit %s.\n", username, subredditName)
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if err := subreddit.checkNotBanned(entry.Username, entry.Time); err != nil {
+		return err
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	subreddit.Members[entry.Username] = user
+	return nil
 }
 
 // PostInSubreddit allows a user to post in a subreddit
-func (e *Engine) PostInSubreddit(subredditName, username, content string) *Post {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Engine) PostInSubreddit(subredditName, username, content string) (*Post, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	post, err := e.postInSubreddit(journalEntry{
+		Type:      entryPost,
+		Time:      time.Now(),
+		ID:        fmt.Sprintf("%d", rand.Int()), // Generate a random post ID
+		Username:  username,
+		Subreddit: subredditName,
+		Content:   content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.events.Publish(postCreated(post))
+	return post, nil
+}
 
-	user, exists := e.users[username]
-	if !exists {
-		fmt.Println("User not found!")
-		return nil
+// postInSubreddit applies a post entry. Must be called with e.mu held.
+func (e *Engine) postInSubreddit(entry journalEntry) (*Post, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
 	}
 
-	subreddit, exists := e.subreddits[subredditName]
-	if !exists {
-		fmt.Println("Subreddit not found!")
-		return nil
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return nil, err
 	}
 
 	post := &Post{
-		ID:        fmt.Sprintf("%d", rand.Int()), // Generate a random post ID
+		ID:        entry.ID,
 		Author:    user,
 		Subreddit: subreddit,
-		Content:   content,
+		Content:   entry.Content,
+		Timestamp: entry.Time,
 		Upvotes:   0,
 		Downvotes: 0,
 	}
-	subreddit.Posts = append(subreddit.Posts, post)
-	user.Posts = append(user.Posts, post)
 
-	fmt.Printf("%s posted in %s: %s\n", username, subredditName, content)
-	return post
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if err := subreddit.checkNotBanned(entry.Username, entry.Time); err != nil {
+		return nil, err
+	}
+	if err := e.record(&entry); err != nil {
+		return nil, err
+	}
+	subreddit.Posts = append(subreddit.Posts, post)
+	e.indexPost(post)
+	e.autoModerate(subreddit, post, nil, entry)
+	e.notify(post, nil)
+	return post, nil
 }
 
 // CommentOnPost allows a user to comment on a post
-func (e *Engine) CommentOnPost(subredditName string, postID string, username string, content string) *Comment {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	user, exists := e.users[username]
-	if !exists {
-		fmt.Println("User not found!")
-		return nil
+func (e *Engine) CommentOnPost(subredditName string, postID string, username string, content string) (*Comment, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	comment, err := e.commentOnPost(journalEntry{
+		Type:      entryComment,
+		Time:      time.Now(),
+		ID:        fmt.Sprintf("comment-%d", rand.Int()),
+		Username:  username,
+		Subreddit: subredditName,
+		TargetID:  postID,
+		Content:   content,
+	})
+	if err != nil {
+		return nil, err
 	}
+	e.events.Publish(commentCreated(comment))
+	return comment, nil
+}
 
-	subreddit, exists := e.subreddits[subredditName]
-	if !exists {
-		fmt.Println("Subreddit not found!")
-		return nil
+// commentOnPost applies a comment entry. Must be called with e.mu held.
+func (e *Engine) commentOnPost(entry journalEntry) (*Comment, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
 	}
 
-	var post *Post
-	for _, p := range subreddit.Posts {
-		if p.ID == postID {
-			post = p
-			break
-		}
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return nil, err
 	}
 
-	if post == nil {
-		fmt.Println("Post not found!")
-		return nil
+	post, err := e.post(entry.TargetID)
+	if err != nil || post.Subreddit != subreddit {
+		return nil, fmt.Errorf("%w: %s in %s", ErrPostNotFound, entry.TargetID, entry.Subreddit)
 	}
 
 	comment := &Comment{
-		Author:  user,
-		Content: content,
-		Parent:  post,
+		ID:        entry.ID,
+		Author:    user,
+		Content:   entry.Content,
+		Timestamp: entry.Time,
+		Parent:    post,
+	}
+
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if post.Locked {
+		return nil, fmt.Errorf("%w: %s", ErrLocked, post.ID)
+	}
+	if err := subreddit.checkNotBanned(entry.Username, entry.Time); err != nil {
+		return nil, err
+	}
+	if err := e.record(&entry); err != nil {
+		return nil, err
 	}
 	post.Comments = append(post.Comments, comment)
-	user.Comments = append(user.Comments, comment)
+	e.indexComment(comment)
+	e.autoModerate(subreddit, post, comment, entry)
+	e.notify(post, comment)
+	return comment, nil
+}
+
+// Upvote - Upvote or downvote a post or comment. Each user holds at most one
+// vote per target: repeating a vote is a no-op and flipping it moves the score by two.
+func (e *Engine) Upvote(postID string, upvote bool, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	fmt.Printf("%s commented on post %s: %s\n", username, postID, content)
-	return comment
+	direction := -1
+	if upvote {
+		direction = 1
+	}
+	return e.publishVote(journalEntry{Type: entryVote, Time: time.Now(), Username: username, TargetID: postID, Direction: direction})
 }
 
-// Upvote - Upvote or downvote a post or comment
-// Upvote - Upvote or downvote a post or comment
-func (e *Engine) Upvote(postID string, upvote bool, username string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// RetractVote removes a user's vote on a post or comment, undoing its effect on karma
+func (e *Engine) RetractVote(targetID string, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.publishVote(journalEntry{Type: entryVote, Time: time.Now(), Username: username, TargetID: targetID})
+}
 
-	user, exists := e.users[username]
-	if !exists {
-		fmt.Println("User not found!")
-		return
+// publishVote applies a vote entry and publishes it if it changed the
+// user's vote. Must be called with e.mu held.
+func (e *Engine) publishVote(entry journalEntry) error {
+	changed, err := e.vote(entry)
+	if err != nil || !changed {
+		return err
 	}
+	e.events.Publish(VoteCast{Username: entry.Username, TargetID: entry.TargetID, Direction: entry.Direction, Time: entry.Time})
+	return nil
+}
 
-	var post *Post
-	for _, subreddit := range e.subreddits {
-		for _, p := range subreddit.Posts {
-			if p.ID == postID {
-				post = p
-				break
-			}
-		}
+// vote applies a vote entry, reporting whether it changed the user's vote.
+// Must be called with e.mu held.
+func (e *Engine) vote(entry journalEntry) (bool, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return false, err
 	}
-	if post != nil {
-		if upvote {
-			post.Upvotes++
-			post.Author.Karma++ // Update post author's karma
-			user.Karma++        // Update voting user's karma
-		} else {
-			post.Downvotes++
-			post.Author.Karma-- // Update post author's karma
-			user.Karma--        // Update voting user's karma
-		}
-		fmt.Printf("%s voted on post %s. Upvotes: %d, Downvotes: %d\n", user.Username, postID, post.Upvotes, post.Downvotes)
-		return
+	return e.castVote(user, entry)
+}
+
+// castVote moves a user's recorded vote on a target to the entry's direction
+// (1, -1, or 0 to retract) and applies the difference to the vote counters and
+// the author's karma. Voting earns the voter no karma, so votes on one's own
+// content leave karma unchanged. It reports whether the user's vote changed.
+// Must be called with e.mu held.
+func (e *Engine) castVote(user *User, entry journalEntry) (bool, error) {
+	targetID, direction := entry.TargetID, entry.Direction
+	post, comment, err := e.content(targetID)
+	if err != nil {
+		return false, err
+	}
+	subreddit, author := post.Subreddit, post.Author
+	upvotes, downvotes := &post.Upvotes, &post.Downvotes
+	if comment != nil {
+		author = comment.Author
+		upvotes, downvotes = &comment.Upvotes, &comment.Downvotes
 	}
 
-	// Check for comment
-	var comment *Comment
-	for _, subreddit := range e.subreddits {
-		for _, p := range subreddit.Posts {
-			for _, c := range p.Comments {
-				if c.Parent.ID == postID {
-					comment = c
-					break
-				}
-			}
-		}
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if post.Locked {
+		return false, fmt.Errorf("%w: %s", ErrLocked, post.ID)
 	}
-	if comment != nil {
-		if upvote {
-			comment.Upvotes++
-			comment.Author.Karma++ // Update comment author's karma
-			user.Karma++           // Update voting user's karma
-		} else {
-			comment.Downvotes++
-			comment.Author.Karma-- // Update comment author's karma
-			user.Karma--           // Update voting user's karma
-		}
-		fmt.Printf("%s voted on comment. Upvotes: %d, Downvotes: %d\n", user.Username, comment.Upvotes, comment.Downvotes)
+	if err := subreddit.checkNotBanned(user.Username, entry.Time); err != nil {
+		return false, err
+	}
+
+	key := voteKey{username: user.Username, targetID: targetID}
+	previous := subreddit.votes[key]
+	if previous == direction {
+		return false, nil
+	}
+	if err := e.record(&entry); err != nil {
+		return false, err
+	}
+
+	switch previous {
+	case 1:
+		*upvotes--
+	case -1:
+		*downvotes--
+	}
+	switch direction {
+	case 1:
+		*upvotes++
+	case -1:
+		*downvotes++
+	}
+
+	if direction == 0 {
+		delete(subreddit.votes, key)
+	} else {
+		subreddit.votes[key] = direction
 	}
+
+	if author != user {
+		author.addKarma(direction-previous, comment != nil)
+	}
+	return true, nil
+}
+
+// addKarma adjusts a user's post or comment karma, and their total, under
+// the user's lock
+func (u *User) addKarma(delta int, comment bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if comment {
+		u.CommentKarma += delta
+	} else {
+		u.PostKarma += delta
+	}
+	u.Karma += delta
 }
 
 // SendDirectMessage allows one user to send a message to another
-func (e *Engine) SendDirectMessage(fromUsername, toUsername, content string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Engine) SendDirectMessage(fromUsername, toUsername, content string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	message, err := e.sendDirectMessage(journalEntry{
+		Type:      entryDirectMessage,
+		Time:      time.Now(),
+		ID:        fmt.Sprintf("msg-%d", rand.Int()),
+		Username:  fromUsername,
+		Recipient: toUsername,
+		Content:   content,
+	})
+	if err != nil {
+		return err
+	}
+	e.events.Publish(messageSent(message))
+	return nil
+}
 
-	fromUser, exists := e.users[fromUsername]
-	if !exists {
-		fmt.Println("Sender user not found!")
-		return
+// sendDirectMessage applies a direct message entry. Must be called with e.mu held.
+func (e *Engine) sendDirectMessage(entry journalEntry) (*DirectMessage, error) {
+	fromUser, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
 	}
 
-	toUser, exists := e.users[toUsername]
-	if !exists {
-		fmt.Println("Recipient user not found!")
-		return
+	toUser, err := e.user(entry.Recipient)
+	if err != nil {
+		return nil, err
 	}
 
 	message := &DirectMessage{
-		From:    fromUser,
-		To:      toUser,
-		Content: content,
+		ID:        entry.ID,
+		From:      fromUser,
+		To:        toUser,
+		Content:   entry.Content,
+		Timestamp: entry.Time,
 	}
-	e.messages[toUsername] = append(e.messages[toUsername], message)
 
-	fmt.Printf("Message sent from %s to %s: %s\n", fromUsername, toUsername, content)
+	toUser.mu.Lock()
+	defer toUser.mu.Unlock()
+	if err := toUser.checkNotBlocked(entry.Username); err != nil {
+		return nil, err
+	}
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	if err := e.record(&entry); err != nil {
+		return nil, err
+	}
+	e.messages[entry.Recipient] = append(e.messages[entry.Recipient], message)
+	toUser.queue(Delivery{Message: message})
+	return message, nil
 }
 
 // GetDirectMessages retrieves all direct messages for a user
-func (e *Engine) GetDirectMessages(username string) []*DirectMessage {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func (e *Engine) GetDirectMessages(username string) ([]*DirectMessage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
 
-	return e.messages[username]
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	return append([]*DirectMessage(nil), e.messages[username]...), nil
 }
 
 // GetSubreddits returns all subreddits
 func (e *Engine) GetSubreddits() map[string]*Subreddit {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	return e.subreddits
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddits := make(map[string]*Subreddit, len(e.subreddits))
+	for name, subreddit := range e.subreddits {
+		subreddits[name] = subreddit
+	}
+	return subreddits
 }
 
-// ReplyToDirectMessage allows replying to a direct message
-func (e *Engine) ReplyToDirectMessage(messageID string, fromUsername string, content string) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// ReplyToDirectMessage allows either user of a direct message to reply to it.
+// The reply goes to the other one.
+func (e *Engine) ReplyToDirectMessage(messageID string, fromUsername string, content string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	reply, err := e.replyToDirectMessage(journalEntry{
+		Type:     entryReplyMessage,
+		Time:     time.Now(),
+		ID:       fmt.Sprintf("msg-%d", rand.Int()),
+		Username: fromUsername,
+		TargetID: messageID,
+		Content:  content,
+	})
+	if err != nil {
+		return err
+	}
+	e.events.Publish(messageSent(reply))
+	return nil
+}
 
-	fromUser, exists := e.users[fromUsername]
-	if !exists {
-		fmt.Println("Sender user not found!")
-		return
-	}
-
-	// Find original message and recipient
-	var originalMessage *DirectMessage
-	var toUser *User
-	for _, messages := range e.messages {
-		for _, msg := range messages {
-			if msg.ID == messageID {
-				originalMessage = msg
-				toUser = msg.From // Reply goes to original sender
-				break
-			}
-		}
+// replyToDirectMessage applies a direct message reply entry. Must be called
+// with e.mu held.
+func (e *Engine) replyToDirectMessage(entry journalEntry) (*DirectMessage, error) {
+	fromUser, err := e.user(entry.Username)
+	if err != nil {
+		return nil, err
 	}
 
-	if originalMessage == nil || toUser == nil {
-		fmt.Println("Original message not found!")
-		return
+	// Find original message and recipient. Messages are never removed, so
+	// the recipient stays valid once the lock is released.
+	e.messagesMu.Lock()
+	originalMessage, err := e.message(entry.TargetID, entry.Username)
+	e.messagesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	toUser := originalMessage.From
+	if toUser == fromUser {
+		toUser = originalMessage.To
+	}
+
+	toUser.mu.Lock()
+	defer toUser.mu.Unlock()
+	if err := toUser.checkNotBlocked(entry.Username); err != nil {
+		return nil, err
+	}
+	e.messagesMu.Lock()
+	defer e.messagesMu.Unlock()
+	if err := e.record(&entry); err != nil {
+		return nil, err
 	}
 
 	reply := &DirectMessage{
-		ID:        fmt.Sprintf("msg-%d", rand.Int()),
+		ID:        entry.ID,
 		From:      fromUser,
 		To:        toUser,
-		Content:   content,
-		Timestamp: time.Now(),
-		ReplyTo:   messageID,
+		Content:   entry.Content,
+		Timestamp: entry.Time,
+		ReplyTo:   entry.TargetID,
 	}
 
 	e.messages[toUser.Username] = append(e.messages[toUser.Username], reply)
-	fmt.Printf("Reply sent from %s to %s: %s\n", fromUsername, toUser.Username, content)
+	toUser.queue(Delivery{Message: reply})
+	return reply, nil
 }
 
-// GetUserFeed returns recent posts from subscribed subreddits
-func (e *Engine) GetUserFeed(username string, limit int) []*Post {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	var feed []*Post
-	// Remove the unused variable declaration
-	_, exists := e.users[username]
-	if !exists {
-		return feed
-	}
-
-	// Collect posts from subscribed subreddits
-	for _, subreddit := range e.subreddits {
-		if _, isMember := subreddit.Members[username]; isMember {
-			feed = append(feed, subreddit.Posts...)
-		}
+// GetUserFeed returns recent posts from subscribed subreddits and followed users
+func (e *Engine) GetUserFeed(username string, limit int) ([]*Post, error) {
+	page, err := e.GetSortedFeed(username, FeedOptions{Sort: SortNew, Limit: limit})
+	if err != nil {
+		return nil, err
 	}
+	return page.Posts, nil
+}
 
-	// Sort by timestamp (newest first)
-	sort.Slice(feed, func(i, j int) bool {
-		return feed[i].Timestamp.After(feed[j].Timestamp)
-	})
+// GetSortedFeed returns a page of posts from subscribed subreddits and
+// followed users in the order selected by opts
+func (e *Engine) GetSortedFeed(username string, opts FeedOptions) (*FeedPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	// Apply limit if specified
-	if limit > 0 && len(feed) > limit {
-		return feed[:limit]
+	if _, err := e.user(username); err != nil {
+		return nil, err
 	}
-	return feed
+	return e.pagePosts(e.homePosts(username), opts, "feed/"+username)
 }
 
 // Helper function to find a comment in a post's comment tree
@@ -505,16 +978,20 @@ func findComment(comments []*Comment, commentID string) *Comment {
 
 // GetPopularSubreddits returns subreddits sorted by member count
 func (e *Engine) GetPopularSubreddits() []*Subreddit {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
 	subreddits := make([]*Subreddit, 0, len(e.subreddits))
+	memberCounts := make(map[*Subreddit]int, len(e.subreddits))
 	for _, s := range e.subreddits {
+		s.mu.RLock()
+		memberCounts[s] = len(s.Members)
+		s.mu.RUnlock()
 		subreddits = append(subreddits, s)
 	}
 
 	sort.Slice(subreddits, func(i, j int) bool {
-		return len(subreddits[i].Members) > len(subreddits[j].Members)
+		return memberCounts[subreddits[i]] > memberCounts[subreddits[j]]
 	})
 
 	return subreddits
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+const testRules = `[
+	{"name": "link spam", "domain": ["spam.example"], "action": "spam"},
+	{"name": "new accounts", "type": "post", "author": {"karma_below": 1, "account_age_below": "1h"},
+	 "action": "filter", "set_flair": "Needs review", "comment": "Held for review"},
+	{"type": "comment", "body": "(?i)idiot", "report": "insult"}
+]`
+
+func TestParseAutoModRules(t *testing.T) {
+	for _, rules := range []string{
+		`[{"body": "(", "action": "remove"}]`,
+		`[{"action": "approve"}]`,
+		`[{"type": "comment", "set_flair": "x"}]`,
+		`[{"body": "x"}]`,
+		`[{"author": {"account_age_below": "3 days"}, "action": "remove"}]`,
+		`[{"title": "x", "action": "remove"}]`,
+	} {
+		if _, err := ParseAutoModRules([]byte(rules)); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("ParseAutoModRules(%s): got %v, want ErrInvalidRule", rules, err)
+		}
+	}
+	if rules, err := ParseAutoModRules([]byte(testRules)); err != nil || len(rules) != 3 {
+		t.Errorf("ParseAutoModRules: got %d rules (%v), want 3", len(rules), err)
+	}
+}
+
+func TestAutoModerate(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+
+	if err := e.SetAutoModRules("golang", "bob", []byte(testRules)); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("SetAutoModRules by a non-moderator: got %v, want ErrNotModerator", err)
+	}
+	if err := e.SetAutoModRules("golang", "mod", []byte(testRules)); err != nil {
+		t.Fatalf("SetAutoModRules: %v", err)
+	}
+
+	spam, _ := e.PostInSubreddit("golang", "alice", "cheap at https://www.spam.example/buy")
+	if !spam.Removed || !spam.Spam {
+		t.Errorf("link spam not removed: %+v", spam)
+	}
+
+	held, _ := e.PostInSubreddit("golang", "bob", "hello")
+	if !held.Filtered || held.Flair != "Needs review" || len(held.Comments) != 1 || held.Comments[0].Author.Username != AutoModerator {
+		t.Errorf("post by a new account not filtered, flaired and replied to: %+v", held)
+	}
+	if feedIDs(t, e, "alice")[held.ID] || !feedIDs(t, e, "mod")[held.ID] {
+		t.Error("filtered post shown to a regular user or hidden from the moderator")
+	}
+	if queue, _ := e.GetModQueue("golang", "mod"); len(queue) != 1 || queue[0].Post != held {
+		t.Errorf("got mod queue %+v, want the filtered post", queue)
+	}
+	e.Moderate(held.ID, "mod", ModApprove)
+	if held.Filtered || !feedIDs(t, e, "alice")[held.ID] {
+		t.Error("approved post still filtered")
+	}
+
+	insult, _ := e.CommentOnPost("golang", post.ID, "alice", "you IDIOT")
+	if len(insult.Reports) != 1 || insult.Reports[0].Username != AutoModerator {
+		t.Errorf("insult not reported: %+v", insult.Reports)
+	}
+
+	matches, err := e.DryRunAutoMod("golang", "mod", []byte(`[{"body": "post", "report": "x"}]`))
+	if err != nil || len(matches) != 1 || matches[0].Post != post || matches[0].Rule != "rule 1" {
+		t.Errorf("got dry run matches %+v (%v), want the seeded post", matches, err)
+	}
+	if post.Reports != nil {
+		t.Error("dry run acted on the post")
+	}
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBans(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+
+	if err := e.BanUser("golang", "alice", "bob", 0, "spam", ""); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("BanUser by a non-moderator: got %v, want ErrNotModerator", err)
+	}
+	if err := e.BanUser("golang", "mod", "bob", 0, "spam", "third strike"); err != nil {
+		t.Fatalf("BanUser: %v", err)
+	}
+	if _, err := e.PostInSubreddit("golang", "bob", "post"); !errors.Is(err, ErrBanned) {
+		t.Errorf("PostInSubreddit while banned: got %v, want ErrBanned", err)
+	}
+	if _, err := e.CommentOnPost("golang", post.ID, "bob", "comment"); !errors.Is(err, ErrBanned) {
+		t.Errorf("CommentOnPost while banned: got %v, want ErrBanned", err)
+	}
+	if _, err := e.ReplyToComment(post.ID, comment.ID, "bob", "reply"); !errors.Is(err, ErrBanned) {
+		t.Errorf("ReplyToComment while banned: got %v, want ErrBanned", err)
+	}
+	if err := e.Upvote(post.ID, true, "bob"); !errors.Is(err, ErrBanned) {
+		t.Errorf("Upvote while banned: got %v, want ErrBanned", err)
+	}
+	e.LeaveSubreddit("bob", "golang")
+	if err := e.JoinSubreddit("bob", "golang"); !errors.Is(err, ErrBanned) {
+		t.Errorf("JoinSubreddit while banned: got %v, want ErrBanned", err)
+	}
+	bans, err := e.GetBans("golang", "mod")
+	if err != nil || len(bans) != 1 || bans[0].Note != "third strike" || !bans[0].Expires.IsZero() {
+		t.Errorf("got bans %+v (%v), want bob's permanent ban", bans, err)
+	}
+
+	if err := e.UnbanUser("golang", "mod", "bob"); err != nil {
+		t.Fatalf("UnbanUser: %v", err)
+	}
+	if err := e.JoinSubreddit("bob", "golang"); err != nil {
+		t.Errorf("JoinSubreddit after unban: %v", err)
+	}
+
+	e.BanUser("golang", "mod", "alice", time.Millisecond, "cool off", "")
+	if _, err := e.PostInSubreddit("golang", "alice", "post"); !errors.Is(err, ErrBanned) {
+		t.Errorf("PostInSubreddit while temporarily banned: got %v, want ErrBanned", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := e.PostInSubreddit("golang", "alice", "post"); err != nil {
+		t.Errorf("PostInSubreddit after the ban expired: %v", err)
+	}
+	if bans, _ := e.GetBans("golang", "mod"); len(bans) != 0 {
+		t.Errorf("expired ban still listed: %+v", bans)
+	}
+}
+
+func TestMutes(t *testing.T) {
+	e := NewEngine()
+	seedModeration(t, e)
+
+	if err := e.MessageModerators("golang", "bob", "why was my post removed?"); err != nil {
+		t.Fatalf("MessageModerators: %v", err)
+	}
+	if messages, _ := e.GetDirectMessages("mod"); len(messages) != 1 || messages[0].From.Username != "bob" {
+		t.Errorf("got mod inbox %+v, want bob's message", messages)
+	}
+
+	if err := e.MuteUser("golang", "mod", "bob", 0, "harassment"); err != nil {
+		t.Fatalf("MuteUser: %v", err)
+	}
+	if err := e.MessageModerators("golang", "bob", "hello?"); !errors.Is(err, ErrMuted) {
+		t.Errorf("MessageModerators while muted: got %v, want ErrMuted", err)
+	}
+	if _, err := e.PostInSubreddit("golang", "bob", "post"); err != nil {
+		t.Errorf("a mute kept bob from posting: %v", err)
+	}
+	e.UnmuteUser("golang", "mod", "bob")
+	if err := e.MessageModerators("golang", "bob", "sorry"); err != nil {
+		t.Errorf("MessageModerators after unmute: %v", err)
+	}
+}
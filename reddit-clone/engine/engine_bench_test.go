@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+// lookupSizes are the content volumes the lookup benchmarks are run against.
+// With indexed lookups the per-operation cost should stay flat across them.
+var lookupSizes = []int{100, 1000, 10000}
+
+// seedEngine builds an engine holding numPosts posts spread over a handful of
+// subreddits, each with one comment, and returns the post and comment IDs.
+func seedEngine(numPosts int) (*Engine, []string, []string) {
+	e := NewEngine()
+	var postIDs, commentIDs []string
+	e.RegisterAccount("author")
+	e.RegisterAccount("voter")
+	subreddits := []string{"golang", "python", "java", "csharp", "rust"}
+	for _, name := range subreddits {
+		e.CreateSubreddit(name, "author")
+	}
+	for i := 0; i < numPosts; i++ {
+		subreddit := subreddits[i%len(subreddits)]
+		post, _ := e.PostInSubreddit(subreddit, "author", fmt.Sprintf("Post %d", i))
+		comment, _ := e.CommentOnPost(subreddit, post.ID, "author", "Comment")
+		postIDs = append(postIDs, post.ID)
+		commentIDs = append(commentIDs, comment.ID)
+	}
+	return e, postIDs, commentIDs
+}
+
+func BenchmarkUpvotePost(b *testing.B) {
+	for _, n := range lookupSizes {
+		b.Run(fmt.Sprintf("posts=%d", n), func(b *testing.B) {
+			e, postIDs, _ := seedEngine(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Upvote(postIDs[i%len(postIDs)], true, "voter")
+			}
+		})
+	}
+}
+
+func BenchmarkUpvoteComment(b *testing.B) {
+	for _, n := range lookupSizes {
+		b.Run(fmt.Sprintf("posts=%d", n), func(b *testing.B) {
+			e, _, commentIDs := seedEngine(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Upvote(commentIDs[i%len(commentIDs)], true, "voter")
+			}
+		})
+	}
+}
+
+func BenchmarkRepost(b *testing.B) {
+	for _, n := range lookupSizes {
+		b.Run(fmt.Sprintf("posts=%d", n), func(b *testing.B) {
+			e, postIDs, _ := seedEngine(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e.Repost(postIDs[i%len(postIDs)], "voter", "golang")
+			}
+		})
+	}
+}
+
+func BenchmarkReplyToComment(b *testing.B) {
+	for _, n := range lookupSizes {
+		b.Run(fmt.Sprintf("posts=%d", n), func(b *testing.B) {
+			e, postIDs, commentIDs := seedEngine(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				j := i % len(postIDs)
+				e.ReplyToComment(postIDs[j], commentIDs[j], "voter", "Reply")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBlockDirectMessages(t *testing.T) {
+	e := NewEngine()
+	seedConversation(t, e)
+	page, _ := e.GetConversation("bob", "alice", "", 0)
+	opening := page.Messages[2]
+
+	if err := e.BlockUser("bob", "bob"); !errors.Is(err, ErrBlockSelf) {
+		t.Errorf("blocking yourself: got %v, want ErrBlockSelf", err)
+	}
+	if err := e.BlockUser("bob", "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("blocking an unknown user: got %v, want ErrUserNotFound", err)
+	}
+	if err := e.BlockUser("bob", "alice"); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	if err := e.SendDirectMessage("alice", "bob", "hello?"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("message to a blocker: got %v, want ErrBlocked", err)
+	}
+	if err := e.ReplyToDirectMessage(opening.ID, "alice", "hello?"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("reply to a blocker: got %v, want ErrBlocked", err)
+	}
+	// The blocker can still write to the user they blocked
+	if err := e.SendDirectMessage("bob", "alice", "go away"); err != nil {
+		t.Errorf("message from the blocker: %v", err)
+	}
+	if page, _ := e.GetConversation("bob", "alice", "", 0); len(page.Messages) != 4 {
+		t.Errorf("got %d messages, want 4", len(page.Messages))
+	}
+
+	if blocked, err := e.GetBlockedUsers("bob"); err != nil || !reflect.DeepEqual(blocked, []string{"alice"}) {
+		t.Errorf("got blocked users %v (%v), want [alice]", blocked, err)
+	}
+	if err := e.UnblockUser("bob", "alice"); err != nil {
+		t.Fatalf("UnblockUser: %v", err)
+	}
+	if err := e.SendDirectMessage("alice", "bob", "friends?"); err != nil {
+		t.Errorf("message after unblocking: %v", err)
+	}
+}
+
+func TestBlockHidesContent(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	// alice's comment has bob's reply under it; this one has no replies
+	e.CommentOnPost("golang", post.ID, "alice", "another")
+	if err := e.BlockUser("mod", "alice"); err != nil {
+		t.Fatalf("BlockUser: %v", err)
+	}
+
+	if feed := feedIDs(t, e, "mod"); feed[post.ID] {
+		t.Errorf("feed %v shows the blocked user's post", feed)
+	}
+	page, _ := e.GetSubredditPosts("golang", FeedOptions{Viewer: "mod"})
+	if len(page.Posts) != 0 {
+		t.Errorf("subreddit listing has %d posts, want none", len(page.Posts))
+	}
+	if feed := feedIDs(t, e, "bob"); !feed[post.ID] {
+		t.Errorf("feed of another user %v misses the post", feed)
+	}
+
+	tree, err := e.GetCommentTree(post.ID, CommentTreeOptions{Viewer: "mod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Comments) != 1 {
+		t.Fatalf("got %d top level comments, want only the one with a reply", len(tree.Comments))
+	}
+	node := tree.Comments[0]
+	if node.Comment.ID != comment.ID || node.Comment.Content != BlockedContent || node.Comment.Author != nil || len(node.Replies) != 1 {
+		t.Errorf("got %+v, want a tombstone without an author holding bob's reply", node.Comment)
+	}
+	if comment.Content == BlockedContent || comment.Author == nil {
+		t.Error("the tombstone overwrote the comment")
+	}
+
+	// Replies and mentions by alice no longer notify mod
+	before, _ := e.UnreadNotificationCount("mod")
+	e.PostInSubreddit("golang", "bob", "hi u/mod")
+	e.CommentOnPost("golang", post.ID, "alice", "hi u/mod")
+	if unread, _ := e.UnreadNotificationCount("mod"); unread != before+1 {
+		t.Errorf("got %d unread, want %d", unread, before+1)
+	}
+}
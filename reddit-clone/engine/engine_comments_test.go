@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// seedThread builds a post with a randomly shaped, randomly voted comment tree
+func seedThread(t *testing.T, numComments int) (*Engine, *Post) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(4))
+	e := NewEngine()
+	for i := 0; i < 10; i++ {
+		e.RegisterAccount(fmt.Sprintf("user%d", i))
+	}
+	e.CreateSubreddit("golang", "user0")
+	post, err := e.PostInSubreddit("golang", "user0", "Thread")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var comments []*Comment
+	for i := 0; i < numComments; i++ {
+		author := fmt.Sprintf("user%d", rng.Intn(10))
+		var comment *Comment
+		if len(comments) == 0 || rng.Intn(4) == 0 {
+			comment, err = e.CommentOnPost("golang", post.ID, author, "Top level")
+		} else {
+			parent := comments[rng.Intn(len(comments))]
+			comment, err = e.ReplyToComment(post.ID, parent.ID, author, "Reply")
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		comments = append(comments, comment)
+		for v := rng.Intn(5); v > 0; v-- {
+			e.Upvote(comment.ID, rng.Intn(3) > 0, fmt.Sprintf("user%d", rng.Intn(10)))
+		}
+	}
+	return e, post
+}
+
+// walkTree collects the IDs of every comment reachable from nodes, following
+// continuation tokens, and returns how many comments were loaded.
+func walkTree(t *testing.T, e *Engine, postID string, opts CommentTreeOptions, nodes []*CommentNode, more *MoreComments, seen map[string]int) int {
+	t.Helper()
+	loaded := 0
+	for _, node := range nodes {
+		seen[node.Comment.ID]++
+		loaded += 1 + walkTree(t, e, postID, opts, node.Replies, node.More, seen)
+	}
+	if more != nil {
+		opts.Continue = more.Token
+		tree, err := e.GetCommentTree(postID, opts)
+		if err != nil {
+			t.Fatalf("continuation: %v", err)
+		}
+		count := walkTree(t, e, postID, opts, tree.Comments, tree.More, seen)
+		if count != more.Count {
+			t.Errorf("continuation loaded %d comments, it stood for %d", count, more.Count)
+		}
+		loaded += count
+	}
+	return loaded
+}
+
+func TestCommentTreeContinuations(t *testing.T) {
+	e, post := seedThread(t, 300)
+	for _, sort := range []CommentSort{CommentBest, CommentTop, CommentNew, CommentControversial} {
+		opts := CommentTreeOptions{Sort: sort, MaxDepth: 2, MaxBreadth: 3}
+		tree, err := e.GetCommentTree(post.ID, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]int)
+		walkTree(t, e, post.ID, opts, tree.Comments, tree.More, seen)
+		if len(seen) != 300 {
+			t.Errorf("%s: reached %d comments, want 300", sort, len(seen))
+		}
+		for id, times := range seen {
+			if times != 1 {
+				t.Errorf("%s: comment %s returned %d times", sort, id, times)
+			}
+		}
+	}
+}
+
+func TestCommentTreeContinuationsWithBlocks(t *testing.T) {
+	e, post := seedThread(t, 300)
+	e.RegisterAccount("reader")
+	e.BlockUser("reader", "user3")
+	e.BlockUser("reader", "user7")
+
+	full, err := e.GetCommentTree(post.ID, CommentTreeOptions{Viewer: "reader"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := walkTree(t, e, post.ID, CommentTreeOptions{Viewer: "reader"}, full.Comments, full.More, make(map[string]int))
+	if want == 300 {
+		t.Fatal("no comment hidden by the blocks")
+	}
+
+	// Every MoreComments must promise only what its continuation loads
+	opts := CommentTreeOptions{MaxDepth: 2, MaxBreadth: 2, Viewer: "reader"}
+	tree, err := e.GetCommentTree(post.ID, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	if loaded := walkTree(t, e, post.ID, opts, tree.Comments, tree.More, seen); loaded != want || len(seen) != want {
+		t.Errorf("loaded %d comments, %d distinct, want the %d of the unlimited tree", loaded, len(seen), want)
+	}
+}
+
+func TestCommentTreeOrder(t *testing.T) {
+	e, post := seedThread(t, 100)
+	tree, err := e.GetCommentTree(post.ID, CommentTreeOptions{Sort: CommentTop})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.More != nil {
+		t.Error("unlimited tree left comments out")
+	}
+	for i := 1; i < len(tree.Comments); i++ {
+		previous, current := tree.Comments[i-1], tree.Comments[i]
+		if previous.Upvotes-previous.Downvotes < current.Upvotes-current.Downvotes {
+			t.Errorf("top comment %d scores below the next one", i-1)
+		}
+	}
+
+	other, _ := e.PostInSubreddit("golang", "user0", "Other thread")
+	limited, _ := e.GetCommentTree(post.ID, CommentTreeOptions{MaxBreadth: 1})
+	if _, err := e.GetCommentTree(other.ID, CommentTreeOptions{Continue: limited.More.Token}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("continuation accepted by another post: %v", err)
+	}
+}
+
+func TestCommentTreeDeleted(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("alice")
+	e.RegisterAccount("bob")
+	e.CreateSubreddit("golang", "alice")
+	post, _ := e.PostInSubreddit("golang", "alice", "Thread")
+	comment, _ := e.CommentOnPost("golang", post.ID, "bob", "Gone soon")
+	reply, _ := e.ReplyToComment(post.ID, comment.ID, "alice", "Kept")
+	if err := e.DeleteComment(comment.ID, "bob"); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+
+	// Moderators see the same tombstone as everyone else
+	for _, viewer := range []string{"", "alice"} {
+		tree, err := e.GetCommentTree(post.ID, CommentTreeOptions{Viewer: viewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tree.Comments) != 1 {
+			t.Fatalf("viewer %q: got %d top-level comments, want 1", viewer, len(tree.Comments))
+		}
+		node := tree.Comments[0]
+		if node.Comment.ID != comment.ID || node.Comment.Content != DeletedContent || !node.Comment.Deleted || node.Comment.Author != nil {
+			t.Errorf("viewer %q: deleted comment shown as %+v, want a tombstone without its author", viewer, node.Comment)
+		}
+		if len(node.Replies) != 1 || node.Replies[0].Comment != reply {
+			t.Errorf("viewer %q: replies under the deleted comment were not kept in place", viewer)
+		}
+	}
+	if comment.Author == nil || comment.Author.Username != "bob" {
+		t.Error("tombstone replaced the author of the stored comment")
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var concurrencySubreddits = []string{"golang", "python", "java", "csharp", "rust", "haskell", "elixir", "scala"}
+
+// workload is the subset of engine operations the parallel benchmarks drive.
+type workload interface {
+	PostInSubreddit(subredditName, username, content string) (*Post, error)
+	CommentOnPost(subredditName string, postID string, username string, content string) (*Comment, error)
+	Upvote(postID string, upvote bool, username string) error
+	GetUserFeed(username string, limit int) ([]*Post, error)
+}
+
+// globalLockEngine serializes every call behind one mutex, reproducing the
+// engine's previous single-lock design as a throughput baseline.
+type globalLockEngine struct {
+	mu sync.Mutex
+	e  *Engine
+}
+
+func (g *globalLockEngine) PostInSubreddit(subredditName, username, content string) (*Post, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.e.PostInSubreddit(subredditName, username, content)
+}
+
+func (g *globalLockEngine) CommentOnPost(subredditName string, postID string, username string, content string) (*Comment, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.e.CommentOnPost(subredditName, postID, username, content)
+}
+
+func (g *globalLockEngine) Upvote(postID string, upvote bool, username string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.e.Upvote(postID, upvote, username)
+}
+
+func (g *globalLockEngine) GetUserFeed(username string, limit int) ([]*Post, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.e.GetUserFeed(username, limit)
+}
+
+// seedCommunity registers numUsers users spread over the concurrency
+// subreddits, each subreddit holding a few starter posts.
+func seedCommunity(numUsers int) (*Engine, []string) {
+	e := NewEngine()
+	usernames := make([]string, numUsers)
+	for i := range usernames {
+		usernames[i] = fmt.Sprintf("user%d", i)
+		e.RegisterAccount(usernames[i])
+		if i < len(concurrencySubreddits) {
+			e.CreateSubreddit(concurrencySubreddits[i], usernames[i])
+		}
+		e.JoinSubreddit(usernames[i], concurrencySubreddits[i%len(concurrencySubreddits)])
+	}
+	for i, name := range concurrencySubreddits {
+		for j := 0; j < 10; j++ {
+			e.PostInSubreddit(name, usernames[i], fmt.Sprintf("Seed %d", j))
+		}
+	}
+	return e, usernames
+}
+
+// runActivity performs one simulated user action: mostly feed reads and votes,
+// with some posts and comments, all within the user's own subreddit.
+func runActivity(w workload, rng *rand.Rand, username, subreddit string) {
+	feed, _ := w.GetUserFeed(username, 10)
+	switch n := rng.Intn(10); {
+	case n < 2:
+		w.PostInSubreddit(subreddit, username, "Parallel post")
+	case n < 4 && len(feed) > 0:
+		w.CommentOnPost(subreddit, feed[rng.Intn(len(feed))].ID, username, "Parallel comment")
+	case len(feed) > 0:
+		w.Upvote(feed[rng.Intn(len(feed))].ID, rng.Intn(2) == 0, username)
+	}
+}
+
+func benchmarkActivity(b *testing.B, wrap func(*Engine) workload) {
+	e, usernames := seedCommunity(256)
+	w := wrap(e)
+	var next int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&next, 1))
+		rng := rand.New(rand.NewSource(int64(i)))
+		username := usernames[i%len(usernames)]
+		subreddit := concurrencySubreddits[i%len(concurrencySubreddits)]
+		for pb.Next() {
+			runActivity(w, rng, username, subreddit)
+		}
+	})
+}
+
+func BenchmarkParallelActivity(b *testing.B) {
+	b.Run("global-mutex", func(b *testing.B) {
+		benchmarkActivity(b, func(e *Engine) workload { return &globalLockEngine{e: e} })
+	})
+	b.Run("fine-grained", func(b *testing.B) {
+		benchmarkActivity(b, func(e *Engine) workload { return e })
+	})
+}
+
+// TestConcurrentActivity drives every engine operation from many goroutines
+// so that `go test -race` can flag unsynchronized access.
+func TestConcurrentActivity(t *testing.T) {
+	e, usernames := seedCommunity(64)
+
+	// Snapshots taken mid-activity must still be consistent cuts
+	done := make(chan struct{})
+	snapshotsChecked := make(chan int)
+	go func() {
+		checked := 0
+		for {
+			select {
+			case <-done:
+				snapshotsChecked <- checked
+				return
+			default:
+			}
+			var buf bytes.Buffer
+			if err := e.Snapshot(&buf); err != nil {
+				t.Errorf("Snapshot: %v", err)
+			}
+			restored := NewEngine()
+			if err := restored.Restore(&buf); err != nil {
+				t.Errorf("Restore: %v", err)
+			}
+			checkKarma(t, restored)
+			checked++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i, username := range usernames {
+		wg.Add(1)
+		go func(i int, username string) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(i)))
+			subreddit := concurrencySubreddits[rng.Intn(len(concurrencySubreddits))]
+			for j := 0; j < 50; j++ {
+				runActivity(e, rng, username, subreddit)
+				switch rng.Intn(6) {
+				case 0:
+					e.JoinSubreddit(username, subreddit)
+				case 1:
+					e.LeaveSubreddit(username, subreddit)
+				case 2:
+					if feed, _ := e.GetFeed(username); len(feed) > 0 {
+						post := feed[rng.Intn(len(feed))]
+						if _, err := e.Repost(post.ID, username, subreddit); err != nil {
+							t.Errorf("Repost: %v", err)
+						}
+						if comment, err := e.CommentOnPost(post.Subreddit.Name, post.ID, username, "Comment"); err == nil {
+							e.ReplyToComment(post.ID, comment.ID, username, "Reply")
+						}
+					}
+				case 3:
+					e.SendDirectMessage(username, usernames[rng.Intn(len(usernames))], "Hello")
+					e.GetDirectMessages(username)
+				case 4:
+					e.SetUserConnection(username, rng.Intn(2) == 0)
+					e.GetPopularSubreddits()
+				case 5:
+					e.RegisterAccount(fmt.Sprintf("%s-alt%d", username, j))
+				}
+			}
+		}(i, username)
+	}
+	wg.Wait()
+	close(done)
+	if <-snapshotsChecked == 0 {
+		t.Error("no snapshot was taken during the activity")
+	}
+	checkKarma(t, e)
+}
+
+// TestConcurrentHideSnapshot hides and unhides posts while snapshots are
+// taken, since a snapshot is encoded after the engine lock is released and
+// must not share the hidden sets that hiding writes to
+func TestConcurrentHideSnapshot(t *testing.T) {
+	e, usernames := seedCommunity(16)
+	done := make(chan struct{})
+	snapshots := make(chan int)
+	go func() {
+		taken := 0
+		for {
+			select {
+			case <-done:
+				snapshots <- taken
+				return
+			default:
+			}
+			if err := e.Snapshot(io.Discard); err != nil {
+				t.Errorf("Snapshot: %v", err)
+			}
+			taken++
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i, username := range usernames {
+		wg.Add(1)
+		go func(i int, username string) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(i)))
+			posts := e.subreddits[concurrencySubreddits[i%len(concurrencySubreddits)]].Posts
+			for j := 0; j < 200; j++ {
+				post := posts[rng.Intn(len(posts))]
+				if rng.Intn(2) == 0 {
+					e.HidePost(username, post.ID)
+				} else {
+					e.UnhidePost(username, post.ID)
+				}
+			}
+		}(i, username)
+	}
+	wg.Wait()
+	close(done)
+	if <-snapshots == 0 {
+		t.Error("no snapshot was taken while posts were hidden")
+	}
+}
+
+// checkKarma verifies that karma adds up to the vote ledgers: every vote
+// moves the post or comment karma of the author, unless they cast it
+// themselves, and never the voter's.
+func checkKarma(t *testing.T, e *Engine) {
+	t.Helper()
+	earned := e.ledgerKarma()
+	for _, user := range e.users {
+		k := earned[user.Username]
+		if user.PostKarma != k.post || user.CommentKarma != k.comment || user.Karma != k.post+k.comment {
+			t.Errorf("%s has karma %d (%d post, %d comment), want %d post and %d comment",
+				user.Username, user.Karma, user.PostKarma, user.CommentKarma, k.post, k.comment)
+		}
+	}
+}
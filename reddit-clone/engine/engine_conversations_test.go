@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func seedConversation(t *testing.T, e *Engine) {
+	t.Helper()
+	for _, username := range []string{"alice", "bob", "carol"} {
+		e.RegisterAccount(username)
+	}
+	for _, message := range [][3]string{
+		{"alice", "bob", "hi"},
+		{"bob", "alice", "hello"},
+		{"alice", "bob", "how are you"},
+		{"carol", "alice", "hey"},
+	} {
+		if err := e.SendDirectMessage(message[0], message[1], message[2]); err != nil {
+			t.Fatalf("SendDirectMessage: %v", err)
+		}
+	}
+}
+
+func TestConversations(t *testing.T) {
+	e := NewEngine()
+	seedConversation(t, e)
+
+	conversations, err := e.GetConversations("alice")
+	if err != nil || len(conversations) != 2 {
+		t.Fatalf("got %+v (%v), want conversations with carol and bob", conversations, err)
+	}
+	if got := conversations[0]; got.With != "carol" || got.Unread != 1 || got.LastMessage.Content != "hey" {
+		t.Errorf("got newest conversation %+v, want carol's", got)
+	}
+	if got := conversations[1]; got.With != "bob" || got.Messages != 3 || got.Unread != 1 {
+		t.Errorf("got conversation %+v, want 3 messages with bob, 1 unread", got)
+	}
+
+	first, err := e.GetConversation("alice", "bob", "", 2)
+	if err != nil || len(first.Messages) != 2 || first.NextCursor == "" || first.Messages[0].Content != "how are you" {
+		t.Fatalf("got first page %+v (%v), want the 2 newest messages", first, err)
+	}
+	rest, _ := e.GetConversation("alice", "bob", first.NextCursor, 2)
+	if len(rest.Messages) != 1 || rest.Messages[0].Content != "hi" || rest.NextCursor != "" {
+		t.Errorf("got last page %+v, want the opening message", rest)
+	}
+	if _, err := e.GetConversation("bob", "alice", first.NextCursor, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of another conversation: got %v, want ErrInvalidCursor", err)
+	}
+
+	if marked, err := e.MarkConversationRead("alice", "bob"); err != nil || marked != 1 {
+		t.Errorf("MarkConversationRead marked %d (%v), want 1", marked, err)
+	}
+	page, _ := e.GetConversation("alice", "bob", "", 0)
+	if page.Unread != 0 || page.Messages[1].ReadAt.IsZero() || !page.Messages[0].ReadAt.IsZero() {
+		t.Errorf("got %+v, want only bob's message read", page.Messages)
+	}
+	if page, _ := e.GetConversation("bob", "alice", "", 0); page.Unread != 2 {
+		t.Errorf("bob has %d unread, want 2", page.Unread)
+	}
+}
+
+func TestMessageReplies(t *testing.T) {
+	e := NewEngine()
+	seedConversation(t, e)
+	page, _ := e.GetConversation("alice", "bob", "", 0)
+	opening := page.Messages[2]
+
+	if err := e.ReplyToDirectMessage(opening.ID, "carol", "butting in"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("reply by an outsider: got %v, want ErrMessageNotFound", err)
+	}
+	// Replying to your own message still reaches the other user
+	if err := e.ReplyToDirectMessage(opening.ID, "alice", "anyone?"); err != nil {
+		t.Fatalf("ReplyToDirectMessage: %v", err)
+	}
+	page, _ = e.GetConversation("bob", "alice", "", 1)
+	reply := page.Messages[0]
+	if reply.To.Username != "bob" || reply.ReplyTo != opening.ID {
+		t.Fatalf("got reply %+v, want one to bob replying to %s", reply, opening.ID)
+	}
+	e.ReplyToDirectMessage(reply.ID, "bob", "yes")
+	page, _ = e.GetConversation("alice", "bob", "", 1)
+
+	thread, err := e.GetMessageThread("alice", page.Messages[0].ID)
+	if err != nil || len(thread) != 3 || thread[0].ID != opening.ID || thread[2].Content != "yes" {
+		t.Errorf("got thread %+v (%v), want the opening message, the reply and bob's answer", thread, err)
+	}
+	if _, err := e.GetMessageThread("carol", opening.ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("thread for an outsider: got %v, want ErrMessageNotFound", err)
+	}
+}
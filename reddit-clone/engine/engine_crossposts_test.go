@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// crosspostIDs returns the IDs of the crossposts of a post as seen by viewer
+func crosspostIDs(t *testing.T, e *Engine, postID, viewer string) []string {
+	t.Helper()
+	crossposts, err := e.GetCrossposts(postID, viewer)
+	if err != nil {
+		t.Fatalf("GetCrossposts: %v", err)
+	}
+	return postIDs(crossposts)
+}
+
+func TestCrosspostChains(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+	e.CreateSubreddit("rust", "bob")
+	e.CreateSubreddit("python", "bob")
+
+	first, err := e.Repost(post.ID, "bob", "rust")
+	if err != nil {
+		t.Fatalf("Repost: %v", err)
+	}
+	second, err := e.Repost(first.ID, "alice", "python")
+	if err != nil {
+		t.Fatalf("Repost of a repost: %v", err)
+	}
+	if second.OriginalPost != post || second.Content != post.Content {
+		t.Errorf("repost of a repost points at %v, want the root original", second.OriginalPost)
+	}
+
+	want := []string{first.ID, second.ID}
+	for _, id := range []string{post.ID, first.ID, second.ID} {
+		if got := crosspostIDs(t, e, id, "bob"); !reflect.DeepEqual(got, want) {
+			t.Errorf("crossposts of %s: got %v, want %v", id, got, want)
+		}
+	}
+	if len(post.Crossposts) != 2 || len(first.Crossposts) != 0 {
+		t.Errorf("got %d crossposts on the original and %d on a repost, want 2 and 0", len(post.Crossposts), len(first.Crossposts))
+	}
+
+	if _, err := e.Repost(post.ID, "nobody", "rust"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Repost by an unknown user: got %v, want ErrUserNotFound", err)
+	}
+	if _, err := e.Repost(post.ID, "bob", "missing"); !errors.Is(err, ErrSubredditNotFound) {
+		t.Errorf("Repost to an unknown subreddit: got %v, want ErrSubredditNotFound", err)
+	}
+	if _, err := e.GetCrossposts("missing", "bob"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetCrossposts of an unknown post: got %v, want ErrPostNotFound", err)
+	}
+}
+
+func TestCrosspostsRemovedHidden(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+	repost, _ := e.Repost(post.ID, "bob", "golang")
+	e.Moderate(repost.ID, "mod", ModRemove)
+
+	if got := crosspostIDs(t, e, post.ID, "alice"); len(got) != 0 {
+		t.Errorf("removed crosspost shown: %v", got)
+	}
+	if got := crosspostIDs(t, e, post.ID, "mod"); !reflect.DeepEqual(got, []string{repost.ID}) {
+		t.Errorf("moderator sees crossposts %v, want [%s]", got, repost.ID)
+	}
+}
+
+func TestCrosspostsDisallowed(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+	e.CreateSubreddit("rust", "bob")
+	e.AddModerator("rust", "bob", "bob")
+
+	if err := e.SetCrosspostsAllowed("rust", "alice", false); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("SetCrosspostsAllowed by a non-moderator: got %v, want ErrNotModerator", err)
+	}
+	if err := e.SetCrosspostsAllowed("rust", "bob", false); err != nil {
+		t.Fatalf("SetCrosspostsAllowed: %v", err)
+	}
+	if allowed, _ := e.CrosspostsAllowed("rust"); allowed {
+		t.Error("subreddit still allows crossposts")
+	}
+	if _, err := e.Repost(post.ID, "alice", "rust"); !errors.Is(err, ErrCrosspostsDisabled) {
+		t.Errorf("Repost into an opted-out subreddit: got %v, want ErrCrosspostsDisabled", err)
+	}
+
+	// Reposting within a subreddit is not a crosspost
+	e.SetCrosspostsAllowed("golang", "mod", false)
+	if _, err := e.Repost(post.ID, "alice", "golang"); err != nil {
+		t.Errorf("Repost within the subreddit: %v", err)
+	}
+
+	e.SetCrosspostsAllowed("rust", "bob", true)
+	if _, err := e.Repost(post.ID, "alice", "rust"); err != nil {
+		t.Errorf("Repost once allowed again: %v", err)
+	}
+}
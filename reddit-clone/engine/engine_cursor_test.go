@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// collectPages walks a listing page by page, posting into the subreddit
+// between pages, and returns the IDs in the order they were served.
+func collectPages(t *testing.T, e *Engine, opts FeedOptions) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; ; pages++ {
+		page, err := e.GetSubredditPosts("golang", opts)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		if len(page.Posts) > opts.Limit {
+			t.Fatalf("page %d has %d posts, limit is %d", pages, len(page.Posts), opts.Limit)
+		}
+		for _, post := range page.Posts {
+			ids = append(ids, post.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		opts.Cursor = page.NextCursor
+
+		// New posts must neither repeat nor hide the posts still to come
+		if _, err := e.PostInSubreddit("golang", "author", fmt.Sprintf("Late %d", pages)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	for _, sort := range []SortMode{SortNew, SortHot, SortTop} {
+		t.Run(string(sort), func(t *testing.T) {
+			e := NewEngine()
+			e.RegisterAccount("author")
+			e.CreateSubreddit("golang", "author")
+			e.RegisterAccount("voter")
+			want := make(map[string]bool)
+			for i := 0; i < 47; i++ {
+				post, err := e.PostInSubreddit("golang", "author", fmt.Sprintf("Post %d", i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if i%3 == 0 {
+					e.Upvote(post.ID, true, "voter")
+				}
+				want[post.ID] = true
+			}
+
+			ids := collectPages(t, e, FeedOptions{Sort: sort, Limit: 10})
+			seen := make(map[string]bool)
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("post %s served twice", id)
+				}
+				if !want[id] {
+					t.Errorf("post %s created after the first page was served", id)
+				}
+				seen[id] = true
+			}
+			if len(seen) != len(want) {
+				t.Errorf("served %d posts, want %d", len(seen), len(want))
+			}
+		})
+	}
+}
+
+func TestCursorScope(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("author")
+	e.CreateSubreddit("golang", "author")
+	e.CreateSubreddit("rust", "author")
+	for i := 0; i < 3; i++ {
+		e.PostInSubreddit("golang", "author", "Post")
+	}
+
+	page, err := e.GetSubredditPosts("golang", FeedOptions{Sort: SortNew, Limit: 1})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v, cursor %q", err, page.NextCursor)
+	}
+	if _, err := e.GetSubredditPosts("rust", FeedOptions{Sort: SortNew, Limit: 1, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor accepted by another subreddit: %v", err)
+	}
+	if _, err := e.GetSubredditPosts("golang", FeedOptions{Sort: SortTop, Limit: 1, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor accepted by another sort mode: %v", err)
+	}
+	if _, err := e.GetSubredditPosts("golang", FeedOptions{Limit: 1, Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("malformed cursor accepted: %v", err)
+	}
+}
+
+func TestCursorVotesBetweenPages(t *testing.T) {
+	for _, sort := range []SortMode{SortHot, SortTop, SortControversial, SortRising} {
+		t.Run(string(sort), func(t *testing.T) {
+			e := NewEngine()
+			e.RegisterAccount("author")
+			e.CreateSubreddit("golang", "author")
+			e.RegisterAccount("reader")
+			var voters []string
+			for i := 0; i < 10; i++ {
+				voter := fmt.Sprintf("voter%d", i)
+				e.RegisterAccount(voter)
+				voters = append(voters, voter)
+			}
+			want := make(map[string]bool)
+			for i := 0; i < 30; i++ {
+				post, _ := e.PostInSubreddit("golang", "author", fmt.Sprintf("Post %d", i))
+				for _, voter := range voters[:i%4] {
+					e.Upvote(post.ID, true, voter)
+				}
+				e.Upvote(post.ID, false, voters[9])
+				want[post.ID] = true
+			}
+
+			opts := FeedOptions{Sort: sort, Limit: 8, Viewer: "reader"}
+			served := make(map[string]bool)
+			var hidden string
+			for pages := 0; ; pages++ {
+				page, err := e.GetSubredditPosts("golang", opts)
+				if err != nil {
+					t.Fatalf("page %d: %v", pages, err)
+				}
+				for _, post := range page.Posts {
+					if served[post.ID] {
+						t.Errorf("post %s served twice", post.ID)
+					}
+					served[post.ID] = true
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+
+				// Push the posts still to come ahead of those served, and
+				// the served ones behind
+				for id := range want {
+					for _, voter := range voters[:9] {
+						e.Upvote(id, !served[id], voter)
+					}
+				}
+				if hidden == "" {
+					for id := range want {
+						if !served[id] {
+							hidden = id
+							e.HidePost("reader", id)
+							delete(want, id)
+							break
+						}
+					}
+				}
+			}
+			for id := range want {
+				if !served[id] {
+					t.Errorf("post %s skipped", id)
+				}
+			}
+			if served[hidden] {
+				t.Errorf("post %s served after it was hidden", hidden)
+			}
+		})
+	}
+}
+
+func TestCursorFrozenOrderExpires(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("author")
+	e.CreateSubreddit("golang", "author")
+	for i := 0; i < 3; i++ {
+		e.PostInSubreddit("golang", "author", "Post")
+	}
+	opts := FeedOptions{Sort: SortTop, Limit: 1, Viewer: "author"}
+	page, err := e.GetSubredditPosts("golang", opts)
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v, cursor %q", err, page.NextCursor)
+	}
+	for i := 0; i < maxFrozenOrders; i++ {
+		e.GetSubredditPosts("golang", opts)
+	}
+	opts.Cursor = page.NextCursor
+	if _, err := e.GetSubredditPosts("golang", opts); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of a dropped order: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestCursorFrozenOrdersPerViewer(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("author")
+	e.CreateSubreddit("golang", "author")
+	for i := 0; i < 3; i++ {
+		e.PostInSubreddit("golang", "author", "Post")
+	}
+	opts := FeedOptions{Sort: SortTop, Limit: 1, Viewer: "reader"}
+	page, err := e.GetSubredditPosts("golang", opts)
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v, cursor %q", err, page.NextCursor)
+	}
+
+	// Another client reloading the first page only drops its own orders
+	for i := 0; i < maxFrozenListings+maxFrozenOrders; i++ {
+		e.GetSubredditPosts("golang", FeedOptions{Sort: SortTop, Limit: 1, Viewer: "crawler"})
+		e.GetSubredditPosts("golang", FeedOptions{Sort: SortTop, Limit: 1})
+	}
+	opts.Cursor = page.NextCursor
+	if _, err := e.GetSubredditPosts("golang", opts); err != nil {
+		t.Errorf("reader's cursor after other clients paged: %v", err)
+	}
+	// Cursors are only followed for the viewer they were issued to
+	opts.Viewer = "crawler"
+	if _, err := e.GetSubredditPosts("golang", opts); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("reader's cursor followed by another viewer: got %v, want ErrInvalidCursor", err)
+	}
+	if len(e.orders.byKey) > maxFrozenListings {
+		t.Errorf("%d listings kept, at most %d allowed", len(e.orders.byKey), maxFrozenListings)
+	}
+
+	var snapshot bytes.Buffer
+	if err := e.Snapshot(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	opts.Viewer = "reader"
+	if _, err := e.GetSubredditPosts("golang", opts); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor after a restore: got %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestCursorFrozenWindow(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("author")
+	e.RegisterAccount("voter")
+	e.CreateSubreddit("golang", "author")
+	want := make(map[string]bool)
+	for i := 0; i < maxFrozenPositions+40; i++ {
+		post, _ := e.PostInSubreddit("golang", "author", fmt.Sprintf("Post %d", i))
+		if i%3 == 0 {
+			e.Upvote(post.ID, true, "voter")
+		}
+		want[post.ID] = true
+	}
+
+	opts := FeedOptions{Sort: SortTop, Limit: 60, Viewer: "voter"}
+	served := make(map[string]bool)
+	for pages := 0; ; pages++ {
+		page, err := e.GetSubredditPosts("golang", opts)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, post := range page.Posts {
+			if served[post.ID] {
+				t.Errorf("post %s served twice", post.ID)
+			}
+			served[post.ID] = true
+		}
+		if pages == 0 {
+			for _, kept := range e.orders.byKey {
+				if n := len(kept[0].positions); n != maxFrozenPositions {
+					t.Errorf("froze %d positions, want %d", n, maxFrozenPositions)
+				}
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	for id := range want {
+		if !served[id] {
+			t.Errorf("post %s skipped", id)
+		}
+	}
+}
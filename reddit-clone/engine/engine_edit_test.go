@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEditAndDelete(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("alice")
+	e.RegisterAccount("bob")
+	e.CreateSubreddit("golang", "alice")
+	for _, username := range []string{"alice", "bob"} {
+		e.JoinSubreddit(username, "golang")
+	}
+	post, _ := e.PostInSubreddit("golang", "alice", "first")
+	comment, _ := e.CommentOnPost("golang", post.ID, "bob", "hello")
+	reply, _ := e.ReplyToComment(post.ID, comment.ID, "alice", "hi")
+
+	if err := e.EditPost(post.ID, "bob", "hijacked"); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("EditPost by another user: got %v, want ErrNotAuthor", err)
+	}
+	for _, content := range []string{"second", "third"} {
+		if err := e.EditPost(post.ID, "alice", content); err != nil {
+			t.Fatalf("EditPost: %v", err)
+		}
+	}
+	revisions, _ := e.GetPostRevisions(post.ID)
+	if len(revisions) != 2 || revisions[0].Content != "first" || revisions[1].Content != "second" {
+		t.Errorf("got revisions %+v, want first and second", revisions)
+	}
+	if post.Content != "third" || post.Edited.IsZero() {
+		t.Errorf("post is %q edited at %v, want third with an edit time", post.Content, post.Edited)
+	}
+	if !revisions[1].Timestamp.After(revisions[0].Timestamp) {
+		t.Error("second revision is not dated by the first edit")
+	}
+
+	if err := e.DeleteComment(comment.ID, "bob"); err != nil {
+		t.Fatalf("DeleteComment: %v", err)
+	}
+	if comment.Content != DeletedContent || !comment.Deleted {
+		t.Errorf("deleted comment reads %q", comment.Content)
+	}
+	if len(comment.Replies) != 1 || comment.Replies[0] != reply || reply.Content != "hi" {
+		t.Error("replies under a deleted comment were not kept in place")
+	}
+	if err := e.EditComment(comment.ID, "bob", "back"); !errors.Is(err, ErrDeleted) {
+		t.Errorf("EditComment after delete: got %v, want ErrDeleted", err)
+	}
+
+	// Edits and tombstones survive a snapshot round trip
+	var buf bytes.Buffer
+	if err := e.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewEngine()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restored.GetPostRevisions(post.ID); len(got) != 2 {
+		t.Errorf("restored post has %d revisions, want 2", len(got))
+	}
+	if got := restored.comments[comment.ID]; !got.Deleted || got.Content != DeletedContent {
+		t.Error("restored comment lost its tombstone")
+	}
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestEventsPublished(t *testing.T) {
+	e := NewEngine()
+	var got []Event
+	sub := e.Events().Subscribe(func(event Event) { got = append(got, event) })
+	var posts int
+	e.Events().Subscribe(func(Event) { posts++ }, EventPostCreated)
+
+	e.RegisterAccount("alice")
+	e.RegisterAccount("bob")
+	e.CreateSubreddit("golang", "alice")
+	e.JoinSubreddit("alice", "golang")
+	post, _ := e.PostInSubreddit("golang", "alice", "hello")
+	comment, _ := e.CommentOnPost("golang", post.ID, "bob", "hi")
+	e.ReplyToComment(post.ID, comment.ID, "alice", "hey")
+	e.Upvote(post.ID, true, "bob")
+	e.Upvote(post.ID, true, "bob") // No change, so no event
+	e.SendDirectMessage("alice", "bob", "psst")
+	e.PostInSubreddit("nowhere", "alice", "lost") // Fails, so no event
+
+	want := []EventType{EventUserJoined, EventPostCreated, EventCommentCreated, EventCommentCreated, EventVoteCast, EventMessageSent}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %+v, want %v", len(got), got, want)
+	}
+	for i, event := range got {
+		if event.Type() != want[i] {
+			t.Errorf("event %d is %s, want %s", i, event.Type(), want[i])
+		}
+	}
+	if reply := got[3].(CommentCreated); reply.ParentID != comment.ID || reply.Subreddit != "golang" {
+		t.Errorf("got reply event %+v", reply)
+	}
+	if posts != 1 {
+		t.Errorf("filtered subscriber saw %d posts, want 1", posts)
+	}
+
+	sub.Unsubscribe()
+	e.JoinSubreddit("bob", "golang")
+	if len(got) != len(want) {
+		t.Error("event delivered after Unsubscribe")
+	}
+}
+
+func TestEventsAsyncDropsAreReported(t *testing.T) {
+	bus := NewEventBus()
+	release := make(chan struct{})
+	var got []Event
+	sub := bus.SubscribeAsync(1, func(event Event) {
+		<-release
+		got = append(got, event)
+	})
+
+	// The handler blocks until released, so once the first event is through
+	// at most two of the next four fit: one being handled and one queued
+	for i := 0; i < 5; i++ {
+		bus.Publish(UserJoined{Username: "u", Subreddit: string(rune('a' + i))})
+		if i == 0 {
+			release <- struct{}{}
+		}
+	}
+	close(release)
+	bus.Publish(UserJoined{Username: "u", Subreddit: "z"})
+	sub.Unsubscribe()
+
+	if sub.Dropped() == 0 {
+		t.Fatal("no events dropped")
+	}
+	var delivered, dropped uint64
+	for _, event := range got {
+		if gap, ok := event.(EventsDropped); ok {
+			dropped += gap.Count
+		} else {
+			delivered++
+		}
+	}
+	if dropped != sub.Dropped() || delivered+dropped != 6 {
+		t.Errorf("got %d delivered and %d reported dropped of 6 (Dropped() = %d): %+v", delivered, dropped, sub.Dropped(), got)
+	}
+}
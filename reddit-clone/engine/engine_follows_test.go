@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFollows(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+	e.RegisterAccount("carol")
+
+	if err := e.FollowUser("carol", "carol"); !errors.Is(err, ErrFollowSelf) {
+		t.Errorf("following yourself: got %v, want ErrFollowSelf", err)
+	}
+	if err := e.FollowUser("carol", "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("following an unknown user: got %v, want ErrUserNotFound", err)
+	}
+	if feed := feedIDs(t, e, "carol"); len(feed) != 0 {
+		t.Fatalf("got feed %v before following anyone", feed)
+	}
+
+	for _, followee := range []string{"alice", "bob"} {
+		if err := e.FollowUser("carol", followee); err != nil {
+			t.Fatalf("FollowUser: %v", err)
+		}
+	}
+	if feed := feedIDs(t, e, "carol"); len(feed) != 1 || !feed[post.ID] {
+		t.Errorf("got feed %v, want alice's post", feed)
+	}
+	// Members of the subreddit see the post once
+	e.FollowUser("bob", "alice")
+	if feed, _ := e.GetFeed("bob"); len(feed) != 1 {
+		t.Errorf("got %d posts in bob's feed, want 1", len(feed))
+	}
+
+	if following, _ := e.GetFollowing("carol"); !reflect.DeepEqual(following, []string{"alice", "bob"}) {
+		t.Errorf("got following %v, want [alice bob]", following)
+	}
+	if followers, _ := e.GetFollowers("alice"); !reflect.DeepEqual(followers, []string{"bob", "carol"}) {
+		t.Errorf("got followers %v, want [bob carol]", followers)
+	}
+	profile, err := e.GetProfile("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Posts != 1 || profile.Comments != 1 || profile.Followers != 2 || profile.Following != 0 {
+		t.Errorf("got profile %+v", profile)
+	}
+
+	e.UnfollowUser("carol", "alice")
+	if feed := feedIDs(t, e, "carol"); len(feed) != 0 {
+		t.Errorf("got feed %v after unfollowing", feed)
+	}
+}
+
+func TestFollowBlocked(t *testing.T) {
+	e := NewEngine()
+	seedConversation(t, e)
+	e.FollowUser("bob", "alice")
+	e.BlockUser("alice", "bob")
+
+	if followers, _ := e.GetFollowers("alice"); len(followers) != 0 {
+		t.Errorf("blocked user still follows: %v", followers)
+	}
+	if err := e.FollowUser("bob", "alice"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("following a blocker: got %v, want ErrBlocked", err)
+	}
+}
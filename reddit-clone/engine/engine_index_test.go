@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// checkIndex fails unless the post and comment indexes hold exactly the
+// posts of every subreddit and the comments beneath them at any depth
+func checkIndex(t *testing.T, e *Engine) {
+	t.Helper()
+	posts, comments := 0, 0
+	var walk func(comment *Comment)
+	walk = func(comment *Comment) {
+		comments++
+		if indexed, err := e.comment(comment.ID); err != nil || indexed != comment {
+			t.Errorf("comment %s: index holds %p (%v), want %p", comment.ID, indexed, err, comment)
+		}
+		for _, reply := range comment.Replies {
+			walk(reply)
+		}
+	}
+	for _, subreddit := range e.subreddits {
+		for _, post := range subreddit.Posts {
+			posts++
+			if indexed, err := e.post(post.ID); err != nil || indexed != post {
+				t.Errorf("post %s: index holds %p (%v), want %p", post.ID, indexed, err, post)
+			}
+			for _, comment := range post.Comments {
+				walk(comment)
+			}
+		}
+	}
+	if len(e.posts) != posts || len(e.comments) != comments {
+		t.Errorf("index holds %d posts and %d comments, the tree %d and %d", len(e.posts), len(e.comments), posts, comments)
+	}
+}
+
+func TestIndexedLookupsAtAnyDepth(t *testing.T) {
+	e, postIDs, commentIDs := seedEngine(50)
+	post, _ := e.post(postIDs[7])
+	deepest, _ := e.comment(commentIDs[7])
+	for depth := 0; depth < 6; depth++ {
+		reply, err := e.ReplyToComment(post.ID, deepest.ID, "voter", "Deeper")
+		if err != nil {
+			t.Fatalf("reply at depth %d: %v", depth+1, err)
+		}
+		deepest = reply
+	}
+	checkIndex(t, e)
+
+	if err := e.Upvote(deepest.ID, true, "voter"); err != nil {
+		t.Fatalf("Upvote of a nested comment: %v", err)
+	}
+	if deepest.Upvotes != 1 {
+		t.Errorf("nested comment has %d upvotes, want 1", deepest.Upvotes)
+	}
+	repost, err := e.Repost(postIDs[49], "voter", "golang")
+	if err != nil {
+		t.Fatalf("Repost: %v", err)
+	}
+	if original, _ := e.post(postIDs[49]); repost.OriginalPost != original {
+		t.Errorf("repost points at %p, want the indexed original %p", repost.OriginalPost, original)
+	}
+	checkIndex(t, e)
+
+	// A comment is only found under its own post
+	if _, err := e.ReplyToComment(postIDs[8], deepest.ID, "voter", "Wrong post"); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("reply under another post: got %v, want ErrCommentNotFound", err)
+	}
+	if _, err := e.ReplyToComment(post.ID, "comment-missing", "voter", "Nothing"); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("reply to an unknown comment: got %v, want ErrCommentNotFound", err)
+	}
+	if _, err := e.ReplyToComment("post-missing", deepest.ID, "voter", "Nothing"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("reply on an unknown post: got %v, want ErrPostNotFound", err)
+	}
+	if _, err := e.Repost(deepest.ID, "voter", "golang"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("repost of a comment: got %v, want ErrPostNotFound", err)
+	}
+	if err := e.Upvote("missing", true, "voter"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("vote on an unknown target: got %v, want ErrTargetNotFound", err)
+	}
+}
+
+func TestIndexSurvivesRestoreAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	e := reopen(t, dir)
+	journalActivity(t, e, rand.New(rand.NewSource(7)), 300)
+	checkIndex(t, e)
+	e.Close()
+
+	replayed := reopen(t, dir)
+	checkIndex(t, replayed)
+
+	var buf bytes.Buffer
+	if err := replayed.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewEngine()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	checkIndex(t, restored)
+}
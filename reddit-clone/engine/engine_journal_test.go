@@ -0,0 +1,532 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// journalRules are the AutoModerator rules journalActivity sets on its first
+// subreddit
+const journalRules = `[
+	{"type": "post", "body": "Parallel", "set_flair": "Parallel"},
+	{"type": "comment", "body": "reply", "report": "chatter"}
+]`
+
+// journalActivity drives every journaled operation against e
+func journalActivity(t *testing.T, e *Engine, rng *rand.Rand, rounds int) {
+	t.Helper()
+	for i := 0; i < 20; i++ {
+		username := fmt.Sprintf("user%d", i)
+		e.RegisterAccount(username)
+		if i < 3 {
+			e.CreateSubreddit(concurrencySubreddits[i], username)
+			e.AddModerator(concurrencySubreddits[i], username, username)
+		}
+		e.JoinSubreddit(username, concurrencySubreddits[i%3])
+	}
+	e.SetAutoModRules(concurrencySubreddits[0], "user0", []byte(journalRules))
+	e.SetCrosspostsAllowed(concurrencySubreddits[1], "user1", false)
+
+	for i := 0; i < rounds; i++ {
+		username := fmt.Sprintf("user%d", rng.Intn(20))
+		other := fmt.Sprintf("user%d", rng.Intn(20))
+		subreddit := concurrencySubreddits[rng.Intn(3)]
+		runActivity(e, rng, username, subreddit)
+
+		feed, err := e.GetFeed(username)
+		if err != nil || len(feed) == 0 {
+			continue
+		}
+		post := feed[rng.Intn(len(feed))]
+		switch rng.Intn(12) {
+		case 0:
+			e.Repost(post.ID, username, concurrencySubreddits[rng.Intn(3)])
+		case 1:
+			if comment, err := e.CommentOnPost(post.Subreddit.Name, post.ID, username, "Journaled"); err == nil {
+				e.ReplyToComment(post.ID, comment.ID, other, "Journaled reply")
+			}
+		case 2:
+			e.RetractVote(post.ID, username)
+		case 3:
+			e.SendDirectMessage(username, other, "Hello")
+		case 4:
+			e.LeaveSubreddit(username, subreddit)
+			e.JoinSubreddit(username, subreddit)
+		case 5:
+			messages, _ := e.GetDirectMessages(username)
+			for _, message := range messages {
+				if message.ID != "" {
+					e.ReplyToDirectMessage(message.ID, username, "Reply")
+					break
+				}
+			}
+		case 6:
+			if rng.Intn(4) == 0 {
+				e.DeletePost(post.ID, post.Author.Username)
+			} else {
+				e.EditPost(post.ID, post.Author.Username, "Edited")
+			}
+		case 7:
+			e.SaveItem(username, post.ID)
+			if rng.Intn(2) == 0 {
+				e.HidePost(username, post.ID)
+			} else {
+				e.UnsaveItem(username, post.ID)
+			}
+		case 8:
+			switch rng.Intn(4) {
+			case 0:
+				e.FollowUser(username, other)
+			case 1:
+				e.UnfollowUser(username, other)
+			case 2:
+				e.BlockUser(username, other)
+			case 3:
+				e.UnblockUser(username, other)
+			}
+		case 9:
+			e.MarkAllNotificationsRead(username)
+			e.MarkConversationRead(username, other)
+		case 10:
+			if session, err := e.Connect(username); err == nil && rng.Intn(2) == 0 {
+				e.Disconnect(username, session.ID)
+			}
+		case 11:
+			moderator := post.Subreddit.Creator
+			switch rng.Intn(3) {
+			case 0:
+				e.ReportContent(post.ID, username, "Off topic")
+				e.Moderate(post.ID, moderator, ModLock)
+			case 1:
+				e.BanUser(post.Subreddit.Name, moderator, username, time.Hour, "Spam", "")
+				e.MuteUser(post.Subreddit.Name, moderator, username, 0, "Spam")
+			case 2:
+				e.UnbanUser(post.Subreddit.Name, moderator, username)
+				e.UnmuteUser(post.Subreddit.Name, moderator, username)
+			}
+		}
+	}
+}
+
+// engineState is a comparable summary of an engine's contents. Times are
+// left out, as are open sessions, which a restart closes.
+type engineState struct {
+	Users      map[string]userState
+	Subreddits map[string]subredditState
+	Posts      map[string]contentState
+	Comments   map[string]contentState
+	Votes      map[voteKey]int
+	Messages   map[string][3]int // Received, read, replies
+}
+
+type userState struct {
+	Karma, PostKarma, CommentKarma int
+	Following, Blocked, Hidden     []string // Sorted
+	Saved                          []string // IDs, oldest first
+	Notifications, Unread          int
+	Seen                           bool // Whether the user has ever been online
+	Pending                        int
+}
+
+type subredditState struct {
+	Creator      string
+	Members      int
+	Moderators   []string // Sorted
+	Bans, Mutes  int
+	Rules        int
+	NoCrossposts bool
+}
+
+// contentState summarises a post or a comment. Replies counts the comments
+// on a post, or the replies to a comment.
+type contentState struct {
+	Upvotes, Downvotes, Replies    int
+	Content, Flair, Original       string
+	Edited                         bool
+	Revisions, Reports, Crossposts int
+	Deleted, Removed, Spam         bool
+	Filtered, Locked               bool
+}
+
+// sortedKeys returns the keys of a set, sorted
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stateOf(e *Engine) engineState {
+	state := engineState{
+		Users:      make(map[string]userState),
+		Subreddits: make(map[string]subredditState),
+		Posts:      make(map[string]contentState),
+		Comments:   make(map[string]contentState),
+		Votes:      make(map[voteKey]int),
+		Messages:   make(map[string][3]int),
+	}
+	for username, user := range e.users {
+		u := userState{
+			Karma:        user.Karma,
+			PostKarma:    user.PostKarma,
+			CommentKarma: user.CommentKarma,
+			Following:    sortedKeys(e.following[username]),
+			Blocked:      sortedKeys(user.blocked),
+			Hidden:       []string{},
+			Saved:        []string{},
+			Seen:         user.Connected || !user.LastSeen.IsZero(),
+			Pending:      len(user.pending),
+		}
+		for id := range user.hidden {
+			u.Hidden = append(u.Hidden, id)
+		}
+		sort.Strings(u.Hidden)
+		for _, item := range user.saved {
+			if item.Comment != nil {
+				u.Saved = append(u.Saved, item.Comment.ID)
+			} else {
+				u.Saved = append(u.Saved, item.Post.ID)
+			}
+		}
+		for _, notification := range user.notifications {
+			u.Notifications++
+			if !notification.Read {
+				u.Unread++
+			}
+		}
+		state.Users[username] = u
+	}
+	for name, subreddit := range e.subreddits {
+		state.Subreddits[name] = subredditState{
+			Creator:      subreddit.Creator,
+			Members:      len(subreddit.Members),
+			Moderators:   sortedKeys(subreddit.moderators),
+			Bans:         len(subreddit.bans),
+			Mutes:        len(subreddit.mutes),
+			Rules:        len(subreddit.automod),
+			NoCrossposts: subreddit.noCrossposts,
+		}
+		for key, direction := range subreddit.votes {
+			state.Votes[key] = direction
+		}
+	}
+	for id, post := range e.posts {
+		c := contentState{
+			Upvotes: post.Upvotes, Downvotes: post.Downvotes, Replies: len(post.Comments),
+			Content: post.Content, Flair: post.Flair, Edited: !post.Edited.IsZero(),
+			Revisions: len(post.Revisions), Reports: len(post.Reports), Crossposts: len(post.Crossposts),
+			Deleted: post.Deleted, Removed: post.Removed, Spam: post.Spam, Filtered: post.Filtered, Locked: post.Locked,
+		}
+		if post.OriginalPost != nil {
+			c.Original = post.OriginalPost.ID
+		}
+		state.Posts[id] = c
+	}
+	for id, comment := range e.comments {
+		state.Comments[id] = contentState{
+			Upvotes: comment.Upvotes, Downvotes: comment.Downvotes, Replies: len(comment.Replies),
+			Content: comment.Content, Edited: !comment.Edited.IsZero(),
+			Revisions: len(comment.Revisions), Reports: len(comment.Reports),
+			Deleted: comment.Deleted, Removed: comment.Removed, Spam: comment.Spam, Filtered: comment.Filtered,
+		}
+	}
+	for username, messages := range e.messages {
+		var counts [3]int
+		for _, message := range messages {
+			counts[0]++
+			if !message.ReadAt.IsZero() {
+				counts[1]++
+			}
+			if message.ReplyTo != "" {
+				counts[2]++
+			}
+		}
+		state.Messages[username] = counts
+	}
+	return state
+}
+
+func reopen(t *testing.T, dir string) *Engine {
+	t.Helper()
+	e, err := OpenEngine(dir)
+	if err != nil {
+		t.Fatalf("OpenEngine: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	e, err := OpenEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.journal.maxSize = 16 << 10 // Force several segments
+	journalActivity(t, e, rand.New(rand.NewSource(1)), 500)
+	want := stateOf(e)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, _ := listSeqFiles(dir, segmentPattern)
+	if len(segments) < 2 {
+		t.Fatalf("got %d journal segments, want the journal to rotate", len(segments))
+	}
+	if got := stateOf(reopen(t, dir)); !reflect.DeepEqual(got, want) {
+		t.Error("replayed state differs from the state before the restart")
+	}
+}
+
+// TestFeatureJournalReplay runs each feature's operations on a journaled
+// engine, then checks that replaying the journal after a restart, and
+// restoring a snapshot of the replayed engine, both rebuild the same state
+func TestFeatureJournalReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, e *Engine)
+	}{
+		{"moderation", func(t *testing.T, e *Engine) {
+			post, comment := seedModeration(t, e)
+			e.ReportContent(comment.ID, "bob", "rude")
+			e.Moderate(post.ID, "mod", ModSpam)
+			e.Moderate(post.ID, "mod", ModLock)
+			e.AddModerator("golang", "mod", "bob")
+			e.AddModerator("golang", "bob", "alice")
+			e.RemoveModerator("golang", "mod", "alice")
+		}},
+		{"automod", func(t *testing.T, e *Engine) {
+			seedModeration(t, e)
+			e.SetAutoModRules("golang", "mod", []byte(testRules))
+			e.PostInSubreddit("golang", "bob", "hello")
+			e.PostInSubreddit("golang", "alice", "http://spam.example")
+		}},
+		{"bans", func(t *testing.T, e *Engine) {
+			seedModeration(t, e)
+			e.BanUser("golang", "mod", "bob", time.Hour, "spam", "note")
+			e.MuteUser("golang", "mod", "alice", 0, "harassment")
+		}},
+		{"blocks", func(t *testing.T, e *Engine) {
+			seedConversation(t, e)
+			e.BlockUser("alice", "bob")
+			e.BlockUser("alice", "carol")
+			e.UnblockUser("alice", "bob")
+		}},
+		{"conversations", func(t *testing.T, e *Engine) {
+			seedConversation(t, e)
+			e.MarkConversationRead("alice", "bob")
+			page, _ := e.GetConversation("alice", "bob", "", 0)
+			e.ReplyToDirectMessage(page.Messages[0].ID, "bob", "fine")
+		}},
+		{"crossposts", func(t *testing.T, e *Engine) {
+			post, _ := seedModeration(t, e)
+			e.CreateSubreddit("rust", "bob")
+			e.AddModerator("rust", "bob", "bob")
+			first, _ := e.Repost(post.ID, "bob", "rust")
+			e.Repost(first.ID, "alice", "golang")
+			e.SetCrosspostsAllowed("rust", "bob", false)
+		}},
+		{"edits", func(t *testing.T, e *Engine) {
+			post, comment := seedModeration(t, e)
+			edited, _ := e.PostInSubreddit("golang", "alice", "first")
+			e.EditPost(edited.ID, "alice", "second")
+			e.EditComment(comment.ID, "alice", "hello again")
+			e.EditPost(post.ID, "alice", "edited then deleted")
+			e.DeletePost(post.ID, "alice")
+		}},
+		{"follows", func(t *testing.T, e *Engine) {
+			seedConversation(t, e)
+			e.FollowUser("alice", "bob")
+			e.FollowUser("alice", "carol")
+			e.FollowUser("carol", "bob")
+			e.UnfollowUser("alice", "carol")
+		}},
+		{"notifications", func(t *testing.T, e *Engine) {
+			post, _ := seedModeration(t, e)
+			e.CommentOnPost("golang", post.ID, "bob", "hi u/mod")
+			e.MarkAllNotificationsRead("alice")
+			e.CommentOnPost("golang", post.ID, "mod", "welcome")
+		}},
+		{"presence", func(t *testing.T, e *Engine) {
+			seedConversation(t, e)
+			session, _ := e.Connect("alice")
+			e.Disconnect("alice", session.ID)
+			e.SendDirectMessage("bob", "alice", "while away")
+			e.Connect("bob") // Left open when the engine stops
+		}},
+		{"saved", func(t *testing.T, e *Engine) {
+			post, comment := seedModeration(t, e)
+			e.SaveItem("bob", post.ID)
+			e.SaveItem("bob", comment.ID)
+			e.UnsaveItem("bob", post.ID)
+			e.HidePost("bob", post.ID)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			e := reopen(t, dir)
+			tt.run(t, e)
+			want := stateOf(e)
+			e.Close()
+
+			replayed := reopen(t, dir)
+			if got := stateOf(replayed); !reflect.DeepEqual(got, want) {
+				t.Errorf("replayed state differs from the state before the restart:\n got %+v\nwant %+v", got, want)
+			}
+			var buf bytes.Buffer
+			if err := replayed.Snapshot(&buf); err != nil {
+				t.Fatal(err)
+			}
+			restored := NewEngine()
+			if err := restored.Restore(&buf); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+			if got := stateOf(restored); !reflect.DeepEqual(got, want) {
+				t.Errorf("restored state differs from the state before the restart:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJournalCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	e, err := OpenEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.journal.maxSize = 16 << 10
+	rng := rand.New(rand.NewSource(2))
+	journalActivity(t, e, rng, 300)
+	if err := e.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if segments, _ := listSeqFiles(dir, segmentPattern); len(segments) != 0 {
+		t.Errorf("%d segments left after checkpoint, want them compacted", len(segments))
+	}
+
+	// Changes after the checkpoint are replayed on top of the snapshot
+	journalActivity(t, e, rng, 300)
+	if err := e.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	journalActivity(t, e, rng, 100)
+	want := stateOf(e)
+	e.Close()
+
+	if snapshots, _ := listSeqFiles(dir, snapshotPattern); len(snapshots) != 1 {
+		t.Errorf("got %d snapshots, want only the latest kept", len(snapshots))
+	}
+	got := reopen(t, dir)
+	if !reflect.DeepEqual(stateOf(got), want) {
+		t.Error("state rebuilt from snapshot and journal differs from the state before the restart")
+	}
+	checkKarma(t, got)
+}
+
+func TestJournalTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	e, err := OpenEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journalActivity(t, e, rand.New(rand.NewSource(3)), 50)
+	want := stateOf(e)
+	e.Close()
+
+	// Simulate a crash halfway through writing an entry
+	segments, _ := listSeqFiles(dir, segmentPattern)
+	path := filepath.Join(dir, fmt.Sprintf(segmentPattern, segments[len(segments)-1]))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":999999,"type":"post","user`)
+	f.Close()
+
+	got := reopen(t, dir)
+	if !reflect.DeepEqual(stateOf(got), want) {
+		t.Error("torn entry changed the replayed state")
+	}
+	if _, err := got.RegisterAccount("after-crash"); err != nil {
+		t.Fatalf("RegisterAccount after recovery: %v", err)
+	}
+	got.Close()
+	if _, err := reopen(t, dir).GetUser("after-crash"); err != nil {
+		t.Errorf("entry written after recovery was lost: %v", err)
+	}
+}
+
+// syncedSeq returns the sequence numbers of the last entry written to the
+// journal and of the last one synced to disk
+func syncedSeq(e *Engine) (written, synced uint64) {
+	e.journal.mu.Lock()
+	defer e.journal.mu.Unlock()
+	return e.journal.seq, e.journal.synced
+}
+
+func TestJournalSyncsEachEntry(t *testing.T) {
+	dir := t.TempDir()
+	e := reopen(t, dir)
+	e.journal.maxSize = 16 << 10
+	rng := rand.New(rand.NewSource(4))
+	for round := 0; round < 20; round++ {
+		journalActivity(t, e, rng, 10)
+		if written, synced := syncedSeq(e); synced != written {
+			t.Fatalf("round %d: entries up to %d synced of %d acknowledged", round, synced, written)
+		}
+	}
+
+	// A crash without Close keeps every acknowledged change
+	want := stateOf(e)
+	if got := stateOf(reopen(t, dir)); !reflect.DeepEqual(got, want) {
+		t.Error("state recovered without Close differs from the acknowledged state")
+	}
+}
+
+func TestJournalSyncInterval(t *testing.T) {
+	dir := t.TempDir()
+	e, err := OpenEngineWithOptions(dir, JournalOptions{SyncInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.RegisterAccount("alice")
+	e.RegisterAccount("bob")
+	if written, synced := syncedSeq(e); synced == written {
+		t.Errorf("entry %d synced before the interval elapsed", synced)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if written, synced := syncedSeq(e); synced != written {
+		t.Errorf("Close synced up to %d of %d entries", synced, written)
+	}
+
+	e, err = OpenEngineWithOptions(dir, JournalOptions{SyncInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	e.RegisterAccount("carol")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		written, synced := syncedSeq(e)
+		if synced == written {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entries up to %d of %d synced after 5s", synced, written)
+		}
+	}
+	if _, err := e.GetUser("alice"); err != nil {
+		t.Errorf("entry synced by Close was lost: %v", err)
+	}
+}
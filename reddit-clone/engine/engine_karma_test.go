@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// checkUserKarma fails unless a user has the given post and comment karma,
+// and their sum as total
+func checkUserKarma(t *testing.T, e *Engine, username string, post, comment int) {
+	t.Helper()
+	user, err := e.GetUser(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.PostKarma != post || user.CommentKarma != comment || user.Karma != post+comment {
+		t.Errorf("%s has karma %d (%d post, %d comment), want %d post and %d comment",
+			username, user.Karma, user.PostKarma, user.CommentKarma, post, comment)
+	}
+}
+
+func TestKarmaSplit(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+
+	e.Upvote(post.ID, true, "bob")
+	e.Upvote(post.ID, true, "mod")
+	e.Upvote(comment.ID, false, "bob")
+	checkUserKarma(t, e, "alice", 2, -1)
+	checkUserKarma(t, e, "bob", 0, 0)
+	checkUserKarma(t, e, "mod", 0, 0)
+
+	// Votes on one's own content count towards the score but not karma
+	e.Upvote(post.ID, true, "alice")
+	if post.Upvotes != 3 {
+		t.Errorf("got %d upvotes, want 3", post.Upvotes)
+	}
+	checkUserKarma(t, e, "alice", 2, -1)
+
+	e.Upvote(comment.ID, true, "bob")
+	e.RetractVote(post.ID, "mod")
+	checkUserKarma(t, e, "alice", 1, 1)
+	if profile, _ := e.GetProfile("alice"); profile.PostKarma != 1 || profile.CommentKarma != 1 || profile.Karma != 2 {
+		t.Errorf("got profile karma %d (%d post, %d comment), want 2 (1, 1)", profile.Karma, profile.PostKarma, profile.CommentKarma)
+	}
+}
+
+func TestRecomputeKarma(t *testing.T) {
+	dir := t.TempDir()
+	e := reopen(t, dir)
+	post, comment := seedModeration(t, e)
+	e.Upvote(post.ID, true, "bob")
+	e.Upvote(comment.ID, true, "mod")
+
+	if corrected, err := e.RecomputeKarma(); err != nil || corrected != 0 {
+		t.Errorf("RecomputeKarma without drift: corrected %d (%v), want 0", corrected, err)
+	}
+
+	alice, _ := e.GetUser("alice")
+	bob, _ := e.GetUser("bob")
+	alice.Karma, alice.CommentKarma = 10, 9
+	bob.PostKarma = 3
+	if corrected, err := e.RecomputeKarma(); err != nil || corrected != 2 {
+		t.Errorf("RecomputeKarma: corrected %d (%v), want 2", corrected, err)
+	}
+	checkUserKarma(t, e, "alice", 1, 1)
+	checkUserKarma(t, e, "bob", 0, 0)
+	e.Close()
+
+	got := reopen(t, dir)
+	checkUserKarma(t, got, "alice", 1, 1)
+	checkUserKarma(t, got, "bob", 0, 0)
+}
+
+func TestKarmaFromOldSnapshot(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	e.Upvote(post.ID, true, "bob")
+	e.Upvote(post.ID, true, "alice")
+	e.Upvote(comment.ID, false, "mod")
+
+	var buf bytes.Buffer
+	if err := e.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Snapshots before version 12 had the voter's karma in a single total
+	var file snapshotFile
+	if err := json.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Version = 11
+	for i := range file.Users {
+		file.Users[i].Karma, file.Users[i].PostKarma, file.Users[i].CommentKarma = 7, 0, 0
+	}
+	data, _ := json.Marshal(file)
+
+	restored := NewEngine()
+	if err := restored.Restore(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	checkUserKarma(t, restored, "alice", 1, -1)
+	checkUserKarma(t, restored, "bob", 0, 0)
+}
@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// seedModeration sets up a subreddit moderated by mod, with a post and a
+// comment by alice that bob can see
+func seedModeration(t *testing.T, e *Engine) (*Post, *Comment) {
+	t.Helper()
+	for _, username := range []string{"mod", "alice", "bob"} {
+		e.RegisterAccount(username)
+	}
+	e.CreateSubreddit("golang", "mod")
+	for _, username := range []string{"mod", "alice", "bob"} {
+		e.JoinSubreddit(username, "golang")
+	}
+	if err := e.AddModerator("golang", "mod", "mod"); err != nil {
+		t.Fatalf("AddModerator: %v", err)
+	}
+	post, _ := e.PostInSubreddit("golang", "alice", "post")
+	comment, _ := e.CommentOnPost("golang", post.ID, "alice", "comment")
+	e.ReplyToComment(post.ID, comment.ID, "bob", "reply")
+	return post, comment
+}
+
+func feedIDs(t *testing.T, e *Engine, username string) map[string]bool {
+	t.Helper()
+	feed, err := e.GetUserFeed(username, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, post := range feed {
+		ids[post.ID] = true
+	}
+	return ids
+}
+
+func TestModerationRemove(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+
+	if err := e.Moderate(post.ID, "bob", ModRemove); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("Moderate by a non-moderator: got %v, want ErrNotModerator", err)
+	}
+	e.ReportContent(post.ID, "bob", "off topic")
+	e.ReportContent(post.ID, "bob", "again")
+	e.ReportContent(comment.ID, "bob", "rude")
+	queue, err := e.GetModQueue("golang", "mod")
+	if err != nil || len(queue) != 2 || queue[0].Post != post || len(queue[0].Reports) != 1 {
+		t.Fatalf("got mod queue %+v (%v), want the post with one report and the comment", queue, err)
+	}
+
+	for _, id := range []string{post.ID, comment.ID} {
+		if err := e.Moderate(id, "mod", ModRemove); err != nil {
+			t.Fatalf("Moderate: %v", err)
+		}
+	}
+	if queue, _ := e.GetModQueue("golang", "mod"); len(queue) != 0 {
+		t.Errorf("removed content left in the mod queue: %+v", queue)
+	}
+	if feedIDs(t, e, "bob")[post.ID] {
+		t.Error("removed post is in a member's feed")
+	}
+	if !feedIDs(t, e, "mod")[post.ID] {
+		t.Error("removed post is missing from the moderator's feed")
+	}
+	if page, _ := e.GetSubredditPosts("golang", FeedOptions{Viewer: "bob"}); len(page.Posts) != 0 {
+		t.Error("removed post is listed in its subreddit")
+	}
+
+	tree, _ := e.GetCommentTree(post.ID, CommentTreeOptions{Viewer: "bob"})
+	if got := tree.Comments[0]; got.Comment.Content != RemovedContent || len(got.Replies) != 1 {
+		t.Errorf("non-moderator sees removed comment as %q with %d replies", got.Comment.Content, len(got.Replies))
+	} else if got.Comment.Author != nil {
+		t.Errorf("non-moderator sees the author %s of a removed comment", got.Comment.Author.Username)
+	}
+	if comment.Author == nil {
+		t.Error("tombstone cleared the author of the comment itself")
+	}
+	tree, _ = e.GetCommentTree(post.ID, CommentTreeOptions{Viewer: "mod"})
+	if got := tree.Comments[0].Comment; got.Content != "comment" || got.Author == nil || got.Author.Username != "alice" {
+		t.Errorf("moderator sees removed comment as %q by %v", got.Content, got.Author)
+	}
+
+	if err := e.Moderate(post.ID, "mod", ModApprove); err != nil {
+		t.Fatal(err)
+	}
+	if !feedIDs(t, e, "bob")[post.ID] || len(post.Reports) != 0 {
+		t.Error("approved post is still hidden or reported")
+	}
+}
+
+func TestModerators(t *testing.T) {
+	e := NewEngine()
+	seedModeration(t, e)
+	e.CreateSubreddit("rust", "alice")
+
+	if err := e.AddModerator("golang", "alice", "alice"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("AddModerator by a member: got %v, want ErrNotModerator", err)
+	}
+	if err := e.AddModerator("rust", "bob", "bob"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("first moderator appointed by a non-creator: got %v, want ErrNotModerator", err)
+	}
+	if err := e.AddModerator("rust", "alice", "bob"); err != nil {
+		t.Fatalf("first moderator appointed by the creator: %v", err)
+	}
+	if err := e.AddModerator("rust", "alice", "alice"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("creator appointing once there are moderators: got %v, want ErrNotModerator", err)
+	}
+	if err := e.AddModerator("rust", "bob", "alice"); err != nil || !e.IsModerator("rust", "alice") {
+		t.Errorf("AddModerator by a moderator: %v", err)
+	}
+
+	if err := e.RemoveModerator("golang", "alice", "mod"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("RemoveModerator by a member: got %v, want ErrNotModerator", err)
+	}
+	if err := e.RemoveModerator("rust", "alice", "bob"); err != nil || e.IsModerator("rust", "bob") {
+		t.Errorf("RemoveModerator by a moderator: %v", err)
+	}
+	if err := e.RemoveModerator("rust", "alice", "alice"); err != nil || e.IsModerator("rust", "alice") {
+		t.Errorf("moderator resigning: %v", err)
+	}
+	if err := e.AddModerator("rust", "bob", "bob"); !errors.Is(err, ErrNotModerator) {
+		t.Errorf("AddModerator by a removed moderator: got %v, want ErrNotModerator", err)
+	}
+
+	// With no moderators left, the creator may appoint one again
+	if err := e.AddModerator("rust", "alice", "bob"); err != nil {
+		t.Errorf("creator appointing in a subreddit left without moderators: %v", err)
+	}
+}
+
+func TestModeratorsWithoutActor(t *testing.T) {
+	e := NewEngine()
+	seedModeration(t, e)
+
+	// Entries journaled before moderators were appointed by an actor
+	e.mu.Lock()
+	err := e.apply(journalEntry{Type: entryAddModerator, Time: time.Now(), Username: "bob", Subreddit: "golang"})
+	e.mu.Unlock()
+	if err != nil || !e.IsModerator("golang", "bob") {
+		t.Errorf("replaying a moderator entry without an actor: %v", err)
+	}
+}
+
+func TestModerationLock(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+
+	if err := e.Moderate(comment.ID, "mod", ModLock); !errors.Is(err, ErrInvalidModAction) {
+		t.Errorf("locking a comment: got %v, want ErrInvalidModAction", err)
+	}
+	e.Moderate(post.ID, "mod", ModLock)
+	if _, err := e.CommentOnPost("golang", post.ID, "bob", "late"); !errors.Is(err, ErrLocked) {
+		t.Errorf("CommentOnPost on a locked post: got %v, want ErrLocked", err)
+	}
+	if _, err := e.ReplyToComment(post.ID, comment.ID, "bob", "late"); !errors.Is(err, ErrLocked) {
+		t.Errorf("ReplyToComment on a locked post: got %v, want ErrLocked", err)
+	}
+	if err := e.Upvote(comment.ID, true, "bob"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Upvote in a locked thread: got %v, want ErrLocked", err)
+	}
+
+	e.Moderate(post.ID, "mod", ModUnlock)
+	if _, err := e.CommentOnPost("golang", post.ID, "bob", "on time"); err != nil {
+		t.Errorf("CommentOnPost after unlocking: %v", err)
+	}
+}
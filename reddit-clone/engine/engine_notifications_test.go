@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	got := mentions("thanks u/alice and /u/bob-2, cc u/alice; see example.com/u/carol or ru/dave")
+	if want := []string{"alice", "bob-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got mentions %v, want %v", got, want)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+
+	// seedModeration had bob reply to alice's comment on her own post
+	notifications, err := e.GetNotifications("alice", false)
+	if err != nil || len(notifications) != 1 || notifications[0].Type != NotifyCommentReply || notifications[0].From != "bob" {
+		t.Fatalf("got %+v (%v), want bob's reply", notifications, err)
+	}
+
+	e.CommentOnPost("golang", post.ID, "bob", "agreed, u/mod and u/alice and u/nobody")
+	e.ReplyToComment(post.ID, comment.ID, "alice", "thanks u/alice")
+	notifications, _ = e.GetNotifications("alice", false)
+	if len(notifications) != 2 || notifications[0].Type != NotifyPostReply || notifications[0].CommentID == "" {
+		t.Errorf("got %+v, want bob's comment first and no mention of alice by herself", notifications)
+	}
+	if notifications, _ := e.GetNotifications("mod", false); len(notifications) != 1 || notifications[0].Type != NotifyMention {
+		t.Errorf("got mod's notifications %+v, want one mention", notifications)
+	}
+	e.PostInSubreddit("golang", "bob", "ping u/mod")
+	if notifications, _ := e.GetNotifications("mod", false); len(notifications) != 2 || notifications[0].CommentID != "" {
+		t.Errorf("got mod's notifications %+v, want a mention in a post first", notifications)
+	}
+
+	if err := e.MarkNotificationRead("alice", notifications[1].ID, true); err != nil {
+		t.Fatalf("MarkNotificationRead: %v", err)
+	}
+	if unread, _ := e.UnreadNotificationCount("alice"); unread != 1 {
+		t.Errorf("got %d unread, want 1", unread)
+	}
+	if unread, _ := e.GetNotifications("alice", true); len(unread) != 1 || unread[0].ID != notifications[0].ID {
+		t.Errorf("got unread %+v, want only the newest", unread)
+	}
+	if err := e.MarkNotificationRead("bob", notifications[0].ID, true); !errors.Is(err, ErrNotificationNotFound) {
+		t.Errorf("marking another user's notification: got %v, want ErrNotificationNotFound", err)
+	}
+	if marked, err := e.MarkAllNotificationsRead("alice"); err != nil || marked != 1 {
+		t.Errorf("MarkAllNotificationsRead marked %d (%v), want 1", marked, err)
+	}
+	e.MarkNotificationRead("alice", notifications[0].ID, false)
+	if unread, _ := e.UnreadNotificationCount("alice"); unread != 1 {
+		t.Errorf("got %d unread after marking one unread, want 1", unread)
+	}
+}
+
+func TestNotificationsSkipHeldContent(t *testing.T) {
+	e := NewEngine()
+	seedModeration(t, e)
+	e.SetAutoModRules("golang", "mod", []byte(`[{"body": "ping", "action": "filter"}]`))
+
+	e.PostInSubreddit("golang", "bob", "ping u/alice")
+	if unread, _ := e.UnreadNotificationCount("alice"); unread != 1 {
+		t.Errorf("got %d unread, want only the seeded reply", unread)
+	}
+}
@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// deliveryIDs returns the IDs of the messages and notifications delivered
+// to a session
+func deliveryIDs(session *Session) []string {
+	var ids []string
+	for _, delivery := range session.Pending {
+		if delivery.Message != nil {
+			ids = append(ids, delivery.Message.ID)
+		} else {
+			ids = append(ids, delivery.Notification.ID)
+		}
+	}
+	return ids
+}
+
+// checkPresence fails unless a user's online status and session count match
+func checkPresence(t *testing.T, e *Engine, username string, online bool, sessions int) *Presence {
+	t.Helper()
+	presence, err := e.GetPresence(username)
+	if err != nil {
+		t.Fatalf("GetPresence: %v", err)
+	}
+	if presence.Online != online || presence.Sessions != sessions {
+		t.Errorf("%s: got online %v with %d sessions, want %v with %d", username, presence.Online, presence.Sessions, online, sessions)
+	}
+	return presence
+}
+
+func TestPresenceSessions(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("alice")
+	var got []Event
+	e.Events().Subscribe(func(event Event) { got = append(got, event) }, EventUserOnline, EventUserOffline)
+
+	first, err := e.Connect("alice")
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	second, _ := e.Connect("alice")
+	checkPresence(t, e, "alice", true, 2)
+
+	if err := e.Disconnect("alice", first.ID); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	if presence := checkPresence(t, e, "alice", true, 1); !presence.LastSeen.IsZero() {
+		t.Errorf("last seen %v while still online", presence.LastSeen)
+	}
+	if err := e.Disconnect("alice", first.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Disconnect of a closed session: got %v, want ErrSessionNotFound", err)
+	}
+	e.Disconnect("alice", second.ID)
+	if presence := checkPresence(t, e, "alice", false, 0); presence.LastSeen.Before(second.Opened) {
+		t.Errorf("last seen %v, before the last session opened at %v", presence.LastSeen, second.Opened)
+	}
+
+	want := []EventType{EventUserOnline, EventUserOffline}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %+v, want %v", len(got), got, want)
+	}
+	for i, event := range got {
+		if event.Type() != want[i] {
+			t.Errorf("event %d is %s, want %s", i, event.Type(), want[i])
+		}
+	}
+
+	if _, err := e.Connect("nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Connect of an unknown user: got %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestPresenceQueuesWhileOffline(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	e.SetUserConnection("alice", true) // Takes what seeding queued
+	e.SetUserConnection("alice", false)
+
+	e.SendDirectMessage("bob", "alice", "first")
+	reply, _ := e.ReplyToComment(post.ID, comment.ID, "bob", "@alice second")
+	e.SendDirectMessage("mod", "alice", "third")
+
+	session, err := e.Connect("alice")
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	messages, _ := e.GetDirectMessages("alice")
+	want := []string{messages[0].ID, reply.ID + "-notification-0", messages[1].ID}
+	if got := deliveryIDs(session); !reflect.DeepEqual(got, want) {
+		t.Errorf("pending deliveries %v, want %v", got, want)
+	}
+
+	// Online users and later sessions get nothing queued
+	e.SendDirectMessage("bob", "alice", "live")
+	if later, _ := e.Connect("alice"); len(later.Pending) != 0 {
+		t.Errorf("second session got %d deliveries, want none", len(later.Pending))
+	}
+	e.SetUserConnection("alice", false)
+	if again, _ := e.Connect("alice"); len(again.Pending) != 0 {
+		t.Errorf("reconnect got %d deliveries already taken, want none", len(again.Pending))
+	}
+}
+
+func TestSetUserConnectionConcurrently(t *testing.T) {
+	e := NewEngine()
+	e.RegisterAccount("alice")
+	for round := 0; round < 100; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := e.SetUserConnection("alice", true); err != nil {
+					t.Errorf("SetUserConnection: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		checkPresence(t, e, "alice", true, 1)
+		e.SetUserConnection("alice", false)
+		checkPresence(t, e, "alice", false, 0)
+	}
+}
+
+func TestPresenceAcrossCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	e := reopen(t, dir)
+	e.RegisterAccount("alice")
+	e.RegisterAccount("bob")
+	e.Connect("alice")
+	if err := e.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	// Delivered live, as alice is online until the engine stops
+	e.SendDirectMessage("bob", "alice", "live")
+	e.Close()
+
+	got := reopen(t, dir)
+	checkPresence(t, got, "alice", false, 0)
+	if session, _ := got.Connect("alice"); len(session.Pending) != 0 {
+		t.Errorf("got %d deliveries queued, want the live message delivered", len(session.Pending))
+	}
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// savedIDs lists the IDs of a user's saved items, most recently saved first
+func savedIDs(t *testing.T, e *Engine, username string) []string {
+	t.Helper()
+	page, err := e.GetSavedItems(username, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, item := range page.Items {
+		ids = append(ids, savedID(&item))
+	}
+	return ids
+}
+
+func TestSavedItems(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	other, _ := e.PostInSubreddit("golang", "alice", "other")
+
+	if err := e.SaveItem("bob", "nothing"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("saving a missing item: got %v, want ErrTargetNotFound", err)
+	}
+	for _, id := range []string{post.ID, comment.ID, other.ID, post.ID} {
+		if err := e.SaveItem("bob", id); err != nil {
+			t.Fatalf("SaveItem: %v", err)
+		}
+	}
+	if got, want := savedIDs(t, e, "bob"), []string{other.ID, comment.ID, post.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got saved %v, want %v", got, want)
+	}
+
+	first, _ := e.GetSavedItems("bob", "", 2)
+	rest, err := e.GetSavedItems("bob", first.NextCursor, 2)
+	if err != nil || len(rest.Items) != 1 || rest.Items[0].Post != post || rest.NextCursor != "" {
+		t.Errorf("got last page %+v (%v), want the first saved post", rest, err)
+	}
+
+	// Saved items follow edits and deletion
+	e.EditComment(comment.ID, "alice", "edited")
+	e.DeletePost(post.ID, "alice")
+	page, _ := e.GetSavedItems("bob", "", 0)
+	if page.Items[1].Comment.Content != "edited" || page.Items[2].Post.Content != DeletedContent {
+		t.Errorf("got %q and %q, want the edit and the tombstone", page.Items[1].Comment.Content, page.Items[2].Post.Content)
+	}
+
+	e.Moderate(other.ID, "mod", ModRemove)
+	if got := savedIDs(t, e, "bob"); len(got) != 2 {
+		t.Errorf("got saved %v, want the removed post left out", got)
+	}
+	e.UnsaveItem("bob", comment.ID)
+	if got := savedIDs(t, e, "bob"); !reflect.DeepEqual(got, []string{post.ID}) {
+		t.Errorf("got saved %v after unsaving, want [%s]", got, post.ID)
+	}
+}
+
+func TestHiddenPosts(t *testing.T) {
+	e := NewEngine()
+	post, _ := seedModeration(t, e)
+
+	if err := e.HidePost("bob", "nothing"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("hiding a missing post: got %v, want ErrPostNotFound", err)
+	}
+	if err := e.HidePost("bob", post.ID); err != nil {
+		t.Fatalf("HidePost: %v", err)
+	}
+	if feed := feedIDs(t, e, "bob"); feed[post.ID] {
+		t.Errorf("feed %v shows the hidden post", feed)
+	}
+	if page, _ := e.GetSubredditPosts("golang", FeedOptions{Viewer: "bob"}); len(page.Posts) != 0 {
+		t.Errorf("subreddit listing shows %d posts, want none", len(page.Posts))
+	}
+	if feed := feedIDs(t, e, "alice"); !feed[post.ID] {
+		t.Errorf("feed of another user %v misses the post", feed)
+	}
+	if hidden, _ := e.GetHiddenPosts("bob"); len(hidden) != 1 || hidden[0] != post {
+		t.Errorf("got hidden posts %v", hidden)
+	}
+
+	e.UnhidePost("bob", post.ID)
+	if feed := feedIDs(t, e, "bob"); !feed[post.ID] {
+		t.Errorf("feed %v misses the unhidden post", feed)
+	}
+}
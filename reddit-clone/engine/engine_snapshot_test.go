@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+)
+
+// containsPost reports whether posts holds exactly post, not a copy of it
+func containsPost(posts []*Post, post *Post) bool {
+	for _, p := range posts {
+		if p == post {
+			return true
+		}
+	}
+	return false
+}
+
+// containsComment reports whether comments holds exactly comment
+func containsComment(comments []*Comment, comment *Comment) bool {
+	for _, c := range comments {
+		if c == comment {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSnapshotRestoresLinks(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	e.CreateSubreddit("gophers", "bob")
+	e.JoinSubreddit("bob", "gophers")
+
+	// A thread four levels deep, with two branches under the top comment
+	parent := comment
+	for _, username := range []string{"mod", "alice", "bob"} {
+		reply, err := e.ReplyToComment(post.ID, parent.ID, username, "Deeper")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parent = reply
+	}
+	e.ReplyToComment(post.ID, comment.ID, "mod", "Second branch")
+	repost, _ := e.Repost(post.ID, "bob", "gophers")
+	again, _ := e.Repost(repost.ID, "mod", "golang")
+	e.CommentOnPost("gophers", repost.ID, "alice", "On the repost")
+
+	e.SendDirectMessage("alice", "bob", "Hello")
+	messages, _ := e.GetDirectMessages("bob")
+	e.ReplyToDirectMessage(messages[0].ID, "bob", "Hi")
+	replies, _ := e.GetDirectMessages("alice")
+	e.ReplyToDirectMessage(replies[0].ID, "alice", "How are you?")
+
+	var buf bytes.Buffer
+	if err := e.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewEngine()
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	for name, original := range e.subreddits {
+		if got := restored.subreddits[name]; got == nil || got.Creator != original.Creator {
+			t.Errorf("subreddit %s lost its creator %s", name, original.Creator)
+		}
+	}
+	for id, original := range e.posts {
+		got, err := restored.post(id)
+		if err != nil {
+			t.Fatalf("post %s lost", id)
+		}
+		if got == original {
+			t.Fatalf("post %s is shared with the snapshotted engine", id)
+		}
+		if got.Author != restored.users[original.Author.Username] || !containsPost(got.Author.Posts, got) {
+			t.Errorf("post %s: author not linked to the restored user", id)
+		}
+		if got.Subreddit != restored.subreddits[original.Subreddit.Name] || !containsPost(got.Subreddit.Posts, got) {
+			t.Errorf("post %s: subreddit not linked to the restored subreddit", id)
+		}
+		if original.OriginalPost != nil {
+			root, _ := restored.post(original.OriginalPost.ID)
+			if got.OriginalPost != root || !containsPost(root.Crossposts, got) {
+				t.Errorf("repost %s: original not linked to the restored post %s", id, original.OriginalPost.ID)
+			}
+		}
+		if len(got.Comments) != len(original.Comments) {
+			t.Errorf("post %s: %d comments, want %d", id, len(got.Comments), len(original.Comments))
+		}
+		for _, top := range got.Comments {
+			if indexed, _ := restored.comment(top.ID); indexed != top {
+				t.Errorf("post %s: comment %s is not the indexed one", id, top.ID)
+			}
+		}
+	}
+	if root, _ := restored.post(post.ID); len(root.Crossposts) != 2 {
+		t.Errorf("original has %d crossposts, want 2", len(root.Crossposts))
+	} else if got, _ := restored.post(again.ID); got.OriginalPost != root {
+		t.Error("repost of a repost not linked to the root original")
+	}
+
+	for id, original := range e.comments {
+		got, err := restored.comment(id)
+		if err != nil {
+			t.Fatalf("comment %s lost", id)
+		}
+		if got == original {
+			t.Fatalf("comment %s is shared with the snapshotted engine", id)
+		}
+		if parent, _ := restored.post(original.Parent.ID); got.Parent != parent {
+			t.Errorf("comment %s: parent not linked to the restored post", id)
+		}
+		if got.Author != restored.users[original.Author.Username] || !containsComment(got.Author.Comments, got) {
+			t.Errorf("comment %s: author not linked to the restored user", id)
+		}
+		if original.ReplyTo == nil {
+			if got.ReplyTo != nil || !containsComment(got.Parent.Comments, got) {
+				t.Errorf("comment %s: not a top-level comment of its post", id)
+			}
+			continue
+		}
+		replyTo, _ := restored.comment(original.ReplyTo.ID)
+		if got.ReplyTo != replyTo || !containsComment(replyTo.Replies, got) {
+			t.Errorf("comment %s: not linked to the restored comment %s it replies to", id, original.ReplyTo.ID)
+		}
+	}
+
+	byID := make(map[string]*DirectMessage)
+	for inbox, messages := range restored.messages {
+		for _, message := range messages {
+			if message.To != restored.users[inbox] || message.From != restored.users[message.From.Username] {
+				t.Errorf("message %s: users not linked to the restored users", message.ID)
+			}
+			byID[message.ID] = message
+		}
+	}
+	threaded := 0
+	for _, message := range byID {
+		if message.ReplyTo == "" {
+			continue
+		}
+		threaded++
+		original, exists := byID[message.ReplyTo]
+		if !exists {
+			t.Errorf("message %s replies to %s, which was lost", message.ID, message.ReplyTo)
+		} else if message.From != original.To || message.To != original.From {
+			t.Errorf("reply %s is not between the users of %s", message.ID, original.ID)
+		}
+	}
+	if threaded != 2 {
+		t.Errorf("%d replies restored, want 2", threaded)
+	}
+}
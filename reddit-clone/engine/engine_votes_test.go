@@ -0,0 +1,80 @@
+package engine
+
+import "testing"
+
+func TestVoteSequence(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	var published int
+	e.Events().Subscribe(func(Event) { published++ }, EventVoteCast)
+
+	tests := []struct {
+		name                 string
+		upvote, retract      bool
+		upvotes, downvotes   int
+		score, karma, events int
+	}{
+		{name: "upvote", upvote: true, upvotes: 1, score: 1, karma: 1, events: 1},
+		{name: "repeat upvote is a no-op", upvote: true, upvotes: 1, score: 1, karma: 1, events: 1},
+		{name: "flip to down moves by two", downvotes: 1, score: -1, karma: -1, events: 2},
+		{name: "repeat downvote is a no-op", downvotes: 1, score: -1, karma: -1, events: 2},
+		{name: "retract undoes the vote", retract: true, events: 3},
+		{name: "retract without a vote is a no-op", retract: true, events: 3},
+		{name: "flip from none to up", upvote: true, upvotes: 1, score: 1, karma: 1, events: 4},
+	}
+	for _, target := range []struct {
+		kind               string
+		id                 string
+		upvotes, downvotes *int
+	}{
+		{kind: "post", id: post.ID, upvotes: &post.Upvotes, downvotes: &post.Downvotes},
+		{kind: "comment", id: comment.ID, upvotes: &comment.Upvotes, downvotes: &comment.Downvotes},
+	} {
+		published = 0
+		for _, tt := range tests {
+			var err error
+			if tt.retract {
+				err = e.RetractVote(target.id, "bob")
+			} else {
+				err = e.Upvote(target.id, tt.upvote, "bob")
+			}
+			if err != nil {
+				t.Fatalf("%s %s: %v", target.kind, tt.name, err)
+			}
+			if *target.upvotes != tt.upvotes || *target.downvotes != tt.downvotes || *target.upvotes-*target.downvotes != tt.score {
+				t.Errorf("%s %s: got %d up and %d down, want %d and %d (score %d)",
+					target.kind, tt.name, *target.upvotes, *target.downvotes, tt.upvotes, tt.downvotes, tt.score)
+			}
+			if published != tt.events {
+				t.Errorf("%s %s: %d votes published, want %d", target.kind, tt.name, published, tt.events)
+			}
+			if target.kind == "post" {
+				checkUserKarma(t, e, "alice", tt.karma, 0)
+			} else {
+				checkUserKarma(t, e, "alice", 0, tt.karma)
+			}
+			checkKarma(t, e)
+		}
+		e.RetractVote(target.id, "bob")
+	}
+	checkUserKarma(t, e, "alice", 0, 0)
+	checkUserKarma(t, e, "bob", 0, 0)
+}
+
+func TestVotesEarnTheVoterNothing(t *testing.T) {
+	e := NewEngine()
+	post, comment := seedModeration(t, e)
+	for _, voter := range []string{"alice", "bob", "mod"} {
+		e.Upvote(post.ID, true, voter)
+		e.Upvote(comment.ID, voter != "bob", voter)
+	}
+	if post.Upvotes != 3 || comment.Upvotes != 2 || comment.Downvotes != 1 {
+		t.Errorf("got post %d up, comment %d up %d down; want 3, 2 and 1", post.Upvotes, comment.Upvotes, comment.Downvotes)
+	}
+	checkUserKarma(t, e, "alice", 2, 0)
+	checkUserKarma(t, e, "bob", 0, 0)
+	checkUserKarma(t, e, "mod", 0, 0)
+	if corrected, err := e.RecomputeKarma(); err != nil || corrected != 0 {
+		t.Errorf("RecomputeKarma: corrected %d (%v), want 0", corrected, err)
+	}
+}
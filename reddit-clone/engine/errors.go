@@ -0,0 +1,36 @@
+package engine
+
+import "errors"
+
+// Errors returned by Engine operations. They are wrapped with the offending
+// name or ID, so callers should compare them with errors.Is.
+var (
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserExists           = errors.New("account already exists")
+	ErrSubredditNotFound    = errors.New("subreddit not found")
+	ErrSubredditExists      = errors.New("subreddit already exists")
+	ErrNotMember            = errors.New("user is not a member of the subreddit")
+	ErrPostNotFound         = errors.New("post not found")
+	ErrCommentNotFound      = errors.New("comment not found")
+	ErrTargetNotFound       = errors.New("post or comment not found")
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrSnapshotVersion      = errors.New("unsupported snapshot version")
+	ErrNoJournal            = errors.New("engine has no journal")
+	ErrJournaled            = errors.New("engine is journaled")
+	ErrInvalidSort          = errors.New("unknown sort mode or time window")
+	ErrInvalidCursor        = errors.New("invalid cursor")
+	ErrNotAuthor            = errors.New("user is not the author")
+	ErrDeleted              = errors.New("content has been deleted")
+	ErrNotModerator         = errors.New("user is not a moderator of the subreddit")
+	ErrInvalidModAction     = errors.New("invalid moderator action")
+	ErrLocked               = errors.New("post is locked")
+	ErrBanned               = errors.New("user is banned from the subreddit")
+	ErrMuted                = errors.New("user is muted in the subreddit")
+	ErrInvalidRule          = errors.New("invalid AutoModerator rule")
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrBlocked              = errors.New("user has been blocked")
+	ErrBlockSelf            = errors.New("users cannot block themselves")
+	ErrFollowSelf           = errors.New("users cannot follow themselves")
+	ErrCrosspostsDisabled   = errors.New("subreddit does not accept crossposts")
+	ErrSessionNotFound      = errors.New("session not found")
+)
@@ -0,0 +1,299 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType names a kind of event
+type EventType string
+
+const (
+	EventPostCreated    EventType = "post_created"
+	EventCommentCreated EventType = "comment_created"
+	EventVoteCast       EventType = "vote_cast"
+	EventUserJoined     EventType = "user_joined"
+	EventMessageSent    EventType = "message_sent"
+	EventUserOnline     EventType = "user_online"
+	EventUserOffline    EventType = "user_offline"
+	EventsDroppedType   EventType = "events_dropped"
+)
+
+// Event is something that happened in the engine. Events are plain values,
+// so subscribers can keep them without racing the engine.
+type Event interface {
+	Type() EventType
+}
+
+// PostCreated is published for every new post and repost
+type PostCreated struct {
+	PostID    string
+	Author    string
+	Subreddit string
+	Content   string
+	Time      time.Time
+}
+
+// CommentCreated is published for every new comment and reply
+type CommentCreated struct {
+	CommentID string
+	PostID    string
+	ParentID  string // Comment replied to, empty for top-level comments
+	Author    string
+	Subreddit string
+	Content   string
+	Time      time.Time
+}
+
+// VoteCast is published when a user votes on a post or comment, or retracts
+// their vote
+type VoteCast struct {
+	Username  string
+	TargetID  string
+	Direction int // 1 for up, -1 for down, 0 for a retraction
+	Time      time.Time
+}
+
+// UserJoined is published when a user joins a subreddit
+type UserJoined struct {
+	Username  string
+	Subreddit string
+	Time      time.Time
+}
+
+// MessageSent is published for every direct message delivered, including
+// replies and modmail
+type MessageSent struct {
+	MessageID string
+	From      string
+	To        string
+	Content   string
+	Time      time.Time
+}
+
+// UserOnline is published when a user opens their first session
+type UserOnline struct {
+	Username string
+	Time     time.Time
+}
+
+// UserOffline is published when a user closes their last session
+type UserOffline struct {
+	Username string
+	Time     time.Time
+}
+
+// EventsDropped tells an asynchronous subscriber that Count events were
+// dropped because its queue was full. It is delivered in place of the
+// missing events, before the next one the subscriber receives.
+type EventsDropped struct {
+	Count uint64
+}
+
+func (PostCreated) Type() EventType    { return EventPostCreated }
+func (CommentCreated) Type() EventType { return EventCommentCreated }
+func (VoteCast) Type() EventType       { return EventVoteCast }
+func (UserJoined) Type() EventType     { return EventUserJoined }
+func (MessageSent) Type() EventType    { return EventMessageSent }
+func (UserOnline) Type() EventType     { return EventUserOnline }
+func (UserOffline) Type() EventType    { return EventUserOffline }
+func (EventsDropped) Type() EventType  { return EventsDroppedType }
+
+// EventBus delivers published events to subscribers. Each subscriber sees
+// the events of one publisher in the order they were published.
+//
+// Synchronous subscribers run in the publisher's goroutine, which may hold
+// engine locks: they must be quick and must not call back into the engine or
+// the bus. Asynchronous subscribers run in a goroutine of their own, fed by
+// a bounded queue. Publishing never blocks on them: when a queue is full the
+// event is dropped, counted, and reported to the subscriber with an
+// EventsDropped.
+type EventBus struct {
+	mu          sync.RWMutex // Held for reading while publishing
+	subscribers map[*Subscription]bool
+}
+
+// Subscription is a subscriber's registration on an EventBus
+type Subscription struct {
+	bus     *EventBus
+	types   map[EventType]bool // Nil for every type
+	handler func(Event)
+	queue   chan Event    // Nil for synchronous subscribers
+	done    chan struct{} // Closed once the queue has been drained
+	missed  uint64        // Dropped events not yet reported
+	dropped uint64        // Dropped events in total
+	once    sync.Once
+}
+
+// NewEventBus returns a bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*Subscription]bool)}
+}
+
+// Subscribe calls handler in the publisher's goroutine for every event of
+// the given types, or of every type if none are given
+func (b *EventBus) Subscribe(handler func(Event), types ...EventType) *Subscription {
+	return b.subscribe(&Subscription{handler: handler}, types)
+}
+
+// SubscribeAsync calls handler in a goroutine of its own for every event of
+// the given types, or of every type if none are given. Up to buffer events
+// wait for the handler; any more are dropped and reported.
+func (b *EventBus) SubscribeAsync(buffer int, handler func(Event), types ...EventType) *Subscription {
+	if buffer < 1 {
+		buffer = 1
+	}
+	s := &Subscription{handler: handler, queue: make(chan Event, buffer), done: make(chan struct{})}
+	go s.run()
+	return b.subscribe(s, types)
+}
+
+func (b *EventBus) subscribe(s *Subscription, types []EventType) *Subscription {
+	s.bus = b
+	if len(types) > 0 {
+		s.types = make(map[EventType]bool, len(types))
+		for _, eventType := range types {
+			s.types[eventType] = true
+		}
+	}
+	b.mu.Lock()
+	b.subscribers[s] = true
+	b.mu.Unlock()
+	return s
+}
+
+// Publish delivers an event to every subscriber interested in its type
+func (b *EventBus) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subscribers {
+		if s.types != nil && !s.types[event.Type()] {
+			continue
+		}
+		if s.queue == nil {
+			s.handler(event)
+		} else {
+			s.offer(event)
+		}
+	}
+}
+
+// Close unsubscribes every subscriber, waiting for asynchronous ones to
+// handle the events already queued
+func (b *EventBus) Close() {
+	b.mu.RLock()
+	subscribers := make([]*Subscription, 0, len(b.subscribers))
+	for s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.mu.RUnlock()
+	for _, s := range subscribers {
+		s.Unsubscribe()
+	}
+}
+
+// offer queues an event for an asynchronous subscriber, reporting earlier
+// drops first. Must be called with the bus locked for reading.
+func (s *Subscription) offer(event Event) {
+	if missed := atomic.SwapUint64(&s.missed, 0); missed > 0 {
+		select {
+		case s.queue <- EventsDropped{Count: missed}:
+		default:
+			atomic.AddUint64(&s.missed, missed)
+			s.drop()
+			return
+		}
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.drop()
+	}
+}
+
+func (s *Subscription) drop() {
+	atomic.AddUint64(&s.missed, 1)
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+func (s *Subscription) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		s.handler(event)
+	}
+	if missed := atomic.SwapUint64(&s.missed, 0); missed > 0 {
+		s.handler(EventsDropped{Count: missed})
+	}
+}
+
+// Dropped returns how many events were dropped because the subscriber's
+// queue was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stops deliveries to the subscriber. For an asynchronous
+// subscriber it waits until the events already queued have been handled, so
+// it must not be called from the subscriber's own handler.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		if s.queue != nil {
+			close(s.queue)
+		}
+	})
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+// Events returns the bus the engine publishes its events to
+func (e *Engine) Events() *EventBus {
+	return e.events
+}
+
+// postCreated describes a new post. Must be called with e.mu held.
+func postCreated(post *Post) PostCreated {
+	post.Subreddit.mu.RLock()
+	defer post.Subreddit.mu.RUnlock()
+	return PostCreated{
+		PostID:    post.ID,
+		Author:    post.Author.Username,
+		Subreddit: post.Subreddit.Name,
+		Content:   post.Content,
+		Time:      post.Timestamp,
+	}
+}
+
+// commentCreated describes a new comment. Must be called with e.mu held.
+func commentCreated(comment *Comment) CommentCreated {
+	subreddit := comment.Parent.Subreddit
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	event := CommentCreated{
+		CommentID: comment.ID,
+		PostID:    comment.Parent.ID,
+		Author:    comment.Author.Username,
+		Subreddit: subreddit.Name,
+		Content:   comment.Content,
+		Time:      comment.Timestamp,
+	}
+	if comment.ReplyTo != nil {
+		event.ParentID = comment.ReplyTo.ID
+	}
+	return event
+}
+
+// messageSent describes a delivered direct message
+func messageSent(message *DirectMessage) MessageSent {
+	return MessageSent{
+		MessageID: message.ID,
+		From:      message.From.Username,
+		To:        message.To.Username,
+		Content:   message.Content,
+		Time:      message.Timestamp,
+	}
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Profile summarises a user's public activity
+type Profile struct {
+	Username     string
+	Created      time.Time
+	Karma        int
+	PostKarma    int
+	CommentKarma int
+	Posts        int
+	Comments     int
+	Followers    int
+	Following    int
+}
+
+// FollowUser makes follower follow followee, whose posts then appear in
+// follower's home feed. Users cannot follow someone who has blocked them.
+func (e *Engine) FollowUser(follower, followee string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.follow(journalEntry{Type: entryFollow, Time: time.Now(), Username: follower, Recipient: followee})
+}
+
+// UnfollowUser stops follower following followee
+func (e *Engine) UnfollowUser(follower, followee string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.follow(journalEntry{Type: entryUnfollow, Time: time.Now(), Username: follower, Recipient: followee})
+}
+
+// follow applies a follow or unfollow entry. Must be called with e.mu held.
+func (e *Engine) follow(entry journalEntry) error {
+	if _, err := e.user(entry.Username); err != nil {
+		return err
+	}
+	followee, err := e.user(entry.Recipient)
+	if err != nil {
+		return err
+	}
+	if entry.Username == entry.Recipient {
+		return fmt.Errorf("%w: %s", ErrFollowSelf, entry.Username)
+	}
+
+	followee.mu.Lock()
+	defer followee.mu.Unlock()
+	following := entry.Type == entryFollow
+	if following {
+		if err := followee.checkNotBlocked(entry.Username); err != nil {
+			return err
+		}
+	}
+	e.followsMu.Lock()
+	defer e.followsMu.Unlock()
+	if e.following[entry.Username][entry.Recipient] == following {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	if following {
+		e.addFollow(entry.Username, entry.Recipient)
+	} else {
+		e.removeFollow(entry.Username, entry.Recipient)
+	}
+	return nil
+}
+
+// addFollow adds an edge to the follow graph. Must be called with
+// e.followsMu held.
+func (e *Engine) addFollow(follower, followee string) {
+	if e.following[follower] == nil {
+		e.following[follower] = make(map[string]bool)
+	}
+	if e.followers[followee] == nil {
+		e.followers[followee] = make(map[string]bool)
+	}
+	e.following[follower][followee] = true
+	e.followers[followee][follower] = true
+}
+
+// removeFollow removes an edge from the follow graph. Must be called with
+// e.followsMu held.
+func (e *Engine) removeFollow(follower, followee string) {
+	delete(e.following[follower], followee)
+	delete(e.followers[followee], follower)
+}
+
+// GetFollowers returns the users following a user, sorted by name
+func (e *Engine) GetFollowers(username string) ([]string, error) {
+	return e.follows(username, e.followers)
+}
+
+// GetFollowing returns the users a user follows, sorted by name
+func (e *Engine) GetFollowing(username string) ([]string, error) {
+	return e.follows(username, e.following)
+}
+
+// follows lists one side of the follow graph for a user
+func (e *Engine) follows(username string, graph map[string]map[string]bool) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+
+	e.followsMu.Lock()
+	defer e.followsMu.Unlock()
+	users := make([]string, 0, len(graph[username]))
+	for name := range graph[username] {
+		users = append(users, name)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// followedBy returns a copy of the set of users username follows. Must be
+// called with e.mu held and no user locked.
+func (e *Engine) followedBy(username string) map[string]bool {
+	e.followsMu.Lock()
+	defer e.followsMu.Unlock()
+	followed := make(map[string]bool, len(e.following[username]))
+	for name := range e.following[username] {
+		followed[name] = true
+	}
+	return followed
+}
+
+// GetProfile returns the profile of a user. Their posts and comments are
+// listed by GetUserPosts and GetUserComments.
+func (e *Engine) GetProfile(username string) (*Profile, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	profile := &Profile{
+		Username:     user.Username,
+		Created:      user.Created,
+		Karma:        user.Karma,
+		PostKarma:    user.PostKarma,
+		CommentKarma: user.CommentKarma,
+		Posts:        len(user.Posts),
+		Comments:     len(user.Comments),
+	}
+	user.mu.Unlock()
+
+	e.followsMu.Lock()
+	profile.Followers = len(e.followers[username])
+	profile.Following = len(e.following[username])
+	e.followsMu.Unlock()
+	return profile, nil
+}
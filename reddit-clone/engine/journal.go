@@ -0,0 +1,527 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultSegmentSize is the size past which a journal segment is rotated
+const defaultSegmentSize = 4 << 20
+
+const (
+	segmentPattern  = "journal-%020d.log"
+	snapshotPattern = "snapshot-%020d.json"
+)
+
+// entryType names a mutating engine operation recorded in the journal
+type entryType string
+
+const (
+	entryRegister        entryType = "register"
+	entryCreateSubreddit entryType = "create_subreddit"
+	entryJoin            entryType = "join"
+	entryLeave           entryType = "leave"
+	entryPost            entryType = "post"
+	entryRepost          entryType = "repost"
+	entryComment         entryType = "comment"
+	entryReply           entryType = "reply"
+	entryVote            entryType = "vote"
+	entryDirectMessage   entryType = "direct_message"
+	entryReplyMessage    entryType = "reply_message"
+	entryEditPost        entryType = "edit_post"
+	entryDeletePost      entryType = "delete_post"
+	entryEditComment     entryType = "edit_comment"
+	entryDeleteComment   entryType = "delete_comment"
+	entryAddModerator    entryType = "add_moderator"
+	entryRemoveModerator entryType = "remove_moderator"
+	entryReport          entryType = "report"
+	entryModerate        entryType = "moderate"
+	entryBan             entryType = "ban"
+	entryUnban           entryType = "unban"
+	entryMute            entryType = "mute"
+	entryUnmute          entryType = "unmute"
+	entryModmail         entryType = "modmail"
+	entryAutoModRules    entryType = "automod_rules"
+	entryMarkRead        entryType = "mark_read"
+	entryMarkUnread      entryType = "mark_unread"
+	entryMarkAllRead     entryType = "mark_all_read"
+
+	entryMarkConversationRead entryType = "mark_conversation_read"
+	entryBlock                entryType = "block"
+	entryUnblock              entryType = "unblock"
+	entryFollow               entryType = "follow"
+	entryUnfollow             entryType = "unfollow"
+	entrySave                 entryType = "save"
+	entryUnsave               entryType = "unsave"
+	entryHide                 entryType = "hide"
+	entryUnhide               entryType = "unhide"
+	entryAllowCrossposts      entryType = "allow_crossposts"
+	entryDisallowCrossposts   entryType = "disallow_crossposts"
+	entryRecomputeKarma       entryType = "recompute_karma"
+	entryConnect              entryType = "connect"
+	entryDisconnect           entryType = "disconnect"
+)
+
+// journalEntry records one mutating call together with every value it
+// generated, so that replaying it rebuilds exactly the same state.
+type journalEntry struct {
+	Seq       uint64    `json:"seq"`
+	Type      entryType `json:"type"`
+	Time      time.Time `json:"time"`
+	ID        string    `json:"id,omitempty"`        // ID given to the new post, comment, message or session
+	Username  string    `json:"username,omitempty"`  // Acting user
+	Subreddit string    `json:"subreddit,omitempty"` // Subreddit acted in
+	TargetID  string    `json:"target_id,omitempty"` // Post, comment or message acted upon
+	ParentID  string    `json:"parent_id,omitempty"` // Comment being replied to
+	Recipient string    `json:"recipient,omitempty"` // Receiver of a direct message, user banned, muted, blocked, followed or made or unmade a moderator, or other user of a conversation
+	Content   string    `json:"content,omitempty"`
+	Direction int       `json:"direction,omitempty"` // Vote direction, 0 retracts
+	Action    string    `json:"action,omitempty"`    // Moderator action
+	Note      string    `json:"note,omitempty"`      // Moderator note on a ban
+	Expires   time.Time `json:"expires,omitempty"`   // End of a ban or mute, zero if permanent
+}
+
+// JournalOptions configures the journal of an engine opened with
+// OpenEngineWithOptions
+type JournalOptions struct {
+	// SyncInterval is how often written entries are synced to disk. Zero,
+	// the default, syncs each entry before its change is applied, so a crash
+	// never loses a change that was acknowledged. A positive interval syncs
+	// all entries written since the last sync in one go instead, which is
+	// much cheaper under load but may lose the changes of the last interval
+	// on a crash. Close always syncs.
+	SyncInterval time.Duration
+}
+
+// journal appends entries to size-rotated segment files. Each segment is
+// named after the sequence number of its first entry.
+type journal struct {
+	dir          string
+	maxSize      int64
+	syncInterval time.Duration
+	mu           sync.Mutex
+	seq          uint64        // Sequence number of the last entry written
+	synced       uint64        // Sequence number of the last entry synced to disk
+	syncErr      error         // Failure of the last periodic sync, returned by the next append
+	stop         chan struct{} // Closed to stop periodic syncing, nil without it
+	segment      *os.File      // Open segment, nil until the next append
+	segmentSize  int64
+}
+
+// append assigns the next sequence number to entry and writes it out
+func (j *journal) append(entry *journalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.syncErr; err != nil {
+		j.syncErr = nil
+		return err
+	}
+
+	entry.Seq = j.seq + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if j.segment != nil && j.segmentSize >= j.maxSize {
+		if err := j.closeSegment(); err != nil {
+			return err
+		}
+	}
+	if j.segment == nil {
+		path := filepath.Join(j.dir, fmt.Sprintf(segmentPattern, entry.Seq))
+		segment, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		j.segment, j.segmentSize = segment, 0
+	}
+
+	n, err := j.segment.Write(line)
+	j.segmentSize += int64(n)
+	if err != nil {
+		return err
+	}
+	j.seq = entry.Seq
+	if j.syncInterval == 0 {
+		return j.sync()
+	}
+	return nil
+}
+
+// sync flushes the entries written to the open segment to disk. Must be
+// called with j.mu held.
+func (j *journal) sync() error {
+	if j.segment == nil || j.synced == j.seq {
+		return nil
+	}
+	if err := j.segment.Sync(); err != nil {
+		return err
+	}
+	j.synced = j.seq
+	return nil
+}
+
+// syncEvery syncs the journal every interval until stop is closed. A failed
+// sync is reported by the next append.
+func (j *journal) syncEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if err := j.sync(); err != nil && j.syncErr == nil {
+				j.syncErr = err
+			}
+			j.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// cut closes the current segment so that later entries start a new one, and
+// returns the sequence number of the last entry written.
+func (j *journal) cut() (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq, j.closeSegment()
+}
+
+// closeSegment syncs and closes the open segment. Must be called with j.mu held.
+func (j *journal) closeSegment() error {
+	if j.segment == nil {
+		return nil
+	}
+	err := j.sync()
+	if closeErr := j.segment.Close(); err == nil {
+		err = closeErr
+	}
+	j.segment = nil
+	return err
+}
+
+// record appends an entry for a mutation that has been validated and is about
+// to be applied. The entry is written before the change becomes visible, so
+// the journal order never contradicts the order in which callers observe
+// changes. It is a no-op for engines without a journal, including while a
+// journal is being replayed.
+func (e *Engine) record(entry *journalEntry) error {
+	if e.journal == nil {
+		return nil
+	}
+	return e.journal.append(entry)
+}
+
+// OpenEngine rebuilds an engine from the latest snapshot and the journal
+// segments in dir, then keeps journaling every mutation into dir. The
+// directory is created if it does not exist. Every change is synced to disk
+// before it is applied.
+func OpenEngine(dir string) (*Engine, error) {
+	return OpenEngineWithOptions(dir, JournalOptions{})
+}
+
+// OpenEngineWithOptions is OpenEngine with a configurable journal
+func OpenEngineWithOptions(dir string, opts JournalOptions) (*Engine, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	e := NewEngine()
+	snapshotSeq, err := e.loadLatestSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	lastSeq, err := e.replay(dir, snapshotSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	e.journal = &journal{dir: dir, maxSize: defaultSegmentSize, syncInterval: opts.SyncInterval, seq: lastSeq, synced: lastSeq}
+	if err := e.closeSessions(time.Now()); err != nil {
+		return nil, err
+	}
+	if opts.SyncInterval > 0 {
+		e.journal.stop = make(chan struct{})
+		go e.journal.syncEvery(opts.SyncInterval, e.journal.stop)
+	}
+	return e, nil
+}
+
+// Checkpoint snapshots the engine into its journal directory, then removes
+// the journal segments and older snapshots the new snapshot covers.
+func (e *Engine) Checkpoint() error {
+	if e.journal == nil {
+		return ErrNoJournal
+	}
+
+	e.mu.Lock()
+	file := e.capture()
+	seq, err := e.journal.cut()
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(e.journal.dir, fmt.Sprintf(snapshotPattern, seq))
+	if err := writeSnapshotFile(path, file); err != nil {
+		return err
+	}
+	return compact(e.journal.dir, seq)
+}
+
+// Close syncs and closes the journal. The engine must not be used afterwards.
+func (e *Engine) Close() error {
+	if e.journal == nil {
+		return nil
+	}
+	e.journal.mu.Lock()
+	defer e.journal.mu.Unlock()
+	if e.journal.stop != nil {
+		close(e.journal.stop)
+		e.journal.stop = nil
+	}
+	return e.journal.closeSegment()
+}
+
+// writeSnapshotFile writes a snapshot next to its final path and renames it
+// into place, so a crash never leaves a partial snapshot behind.
+func writeSnapshotFile(path string, file *snapshotFile) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(file); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// compact removes the segments and snapshots made redundant by the snapshot
+// taken at seq. Segments cut at seq only hold entries up to seq.
+func compact(dir string, seq uint64) error {
+	segments, err := listSeqFiles(dir, segmentPattern)
+	if err != nil {
+		return err
+	}
+	for _, first := range segments {
+		if first <= seq {
+			if err := os.Remove(filepath.Join(dir, fmt.Sprintf(segmentPattern, first))); err != nil {
+				return err
+			}
+		}
+	}
+
+	snapshots, err := listSeqFiles(dir, snapshotPattern)
+	if err != nil {
+		return err
+	}
+	for _, taken := range snapshots {
+		if taken < seq {
+			if err := os.Remove(filepath.Join(dir, fmt.Sprintf(snapshotPattern, taken))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listSeqFiles returns the sequence numbers of the files in dir named after
+// pattern, in ascending order.
+func listSeqFiles(dir, pattern string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		var seq uint64
+		if _, err := fmt.Sscanf(entry.Name(), pattern, &seq); err == nil && entry.Name() == fmt.Sprintf(pattern, seq) {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+// loadLatestSnapshot restores the newest snapshot in dir, if any, and returns
+// the journal sequence number it was taken at.
+func (e *Engine) loadLatestSnapshot(dir string) (uint64, error) {
+	snapshots, err := listSeqFiles(dir, snapshotPattern)
+	if err != nil || len(snapshots) == 0 {
+		return 0, err
+	}
+
+	seq := snapshots[len(snapshots)-1]
+	f, err := os.Open(filepath.Join(dir, fmt.Sprintf(snapshotPattern, seq)))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	// Sessions open in the snapshot stay open while the journal after it is
+	// replayed, since its entries were written while they were open.
+	// OpenEngine closes them once it has caught up.
+	if err := e.restore(f, false); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// replay applies the journal entries in dir that come after the snapshot at
+// snapshotSeq, and returns the sequence number of the last entry. A torn
+// final entry, left by a crash in the middle of a write, is cut off.
+func (e *Engine) replay(dir string, snapshotSeq uint64) (uint64, error) {
+	segments, err := listSeqFiles(dir, segmentPattern)
+	if err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	lastSeq := snapshotSeq
+	for i, first := range segments {
+		path := filepath.Join(dir, fmt.Sprintf(segmentPattern, first))
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+
+		reader := bufio.NewReader(f)
+		var offset int64
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				if len(line) > 0 && i == len(segments)-1 {
+					err = os.Truncate(path, offset)
+				} else if len(line) > 0 {
+					err = fmt.Errorf("journal segment %s: torn entry at offset %d", path, offset)
+				} else {
+					err = nil
+				}
+				f.Close()
+				if err != nil {
+					return 0, err
+				}
+				break
+			}
+			if err != nil {
+				f.Close()
+				return 0, err
+			}
+			offset += int64(len(line))
+
+			var entry journalEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				f.Close()
+				return 0, fmt.Errorf("journal segment %s: %w", path, err)
+			}
+			if entry.Seq <= lastSeq {
+				continue
+			}
+			if err := e.apply(entry); err != nil {
+				f.Close()
+				return 0, fmt.Errorf("replay entry %d (%s): %w", entry.Seq, entry.Type, err)
+			}
+			lastSeq = entry.Seq
+		}
+	}
+	return lastSeq, nil
+}
+
+// apply re-executes a journaled mutation. Must be called with e.mu held for writing.
+func (e *Engine) apply(entry journalEntry) error {
+	var err error
+	switch entry.Type {
+	case entryRegister:
+		_, err = e.registerAccount(entry)
+	case entryCreateSubreddit:
+		err = e.createSubreddit(entry)
+	case entryJoin:
+		err = e.joinSubreddit(entry)
+	case entryLeave:
+		err = e.leaveSubreddit(entry)
+	case entryPost:
+		_, err = e.postInSubreddit(entry)
+	case entryRepost:
+		_, err = e.repost(entry)
+	case entryComment:
+		_, err = e.commentOnPost(entry)
+	case entryReply:
+		_, err = e.replyToComment(entry)
+	case entryVote:
+		_, err = e.vote(entry)
+	case entryDirectMessage:
+		_, err = e.sendDirectMessage(entry)
+	case entryReplyMessage:
+		_, err = e.replyToDirectMessage(entry)
+	case entryEditPost:
+		err = e.editPost(entry)
+	case entryDeletePost:
+		err = e.deletePost(entry)
+	case entryEditComment:
+		err = e.editComment(entry)
+	case entryDeleteComment:
+		err = e.deleteComment(entry)
+	case entryAddModerator:
+		err = e.addModerator(entry)
+	case entryRemoveModerator:
+		err = e.removeModerator(entry)
+	case entryReport:
+		err = e.report(entry)
+	case entryModerate:
+		err = e.moderate(entry)
+	case entryBan, entryMute:
+		err = e.restrict(entry)
+	case entryUnban, entryUnmute:
+		err = e.lift(entry)
+	case entryModmail:
+		_, err = e.messageModerators(entry)
+	case entryAutoModRules:
+		err = e.setAutoModRules(entry)
+	case entryMarkRead, entryMarkUnread:
+		err = e.markNotification(entry)
+	case entryMarkAllRead:
+		_, err = e.markAllRead(entry)
+	case entryMarkConversationRead:
+		_, err = e.markConversationRead(entry)
+	case entryBlock, entryUnblock:
+		err = e.block(entry)
+	case entryFollow, entryUnfollow:
+		err = e.follow(entry)
+	case entrySave, entryUnsave:
+		err = e.save(entry)
+	case entryHide, entryUnhide:
+		err = e.hide(entry)
+	case entryAllowCrossposts, entryDisallowCrossposts:
+		err = e.setCrosspostsAllowed(entry)
+	case entryRecomputeKarma:
+		_, err = e.recomputeKarma(entry)
+	case entryConnect:
+		_, _, err = e.connect(entry, false)
+	case entryDisconnect:
+		_, err = e.disconnect(entry)
+	default:
+		err = errors.New("unknown entry type")
+	}
+	return err
+}
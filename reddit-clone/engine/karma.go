@@ -0,0 +1,78 @@
+package engine
+
+import "time"
+
+// karma is a user's karma split by where it was earned
+type karma struct {
+	post, comment int
+}
+
+// RecomputeKarma rebuilds every user's karma from the vote ledgers of all
+// subreddits, repairing any drift from them. It returns the number of users
+// whose karma was corrected.
+func (e *Engine) RecomputeKarma() (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.recomputeKarma(journalEntry{Type: entryRecomputeKarma, Time: time.Now()})
+}
+
+// recomputeKarma applies a recompute karma entry. Nothing is recorded when
+// every user's karma already matches the ledgers. Must be called with e.mu
+// held for writing.
+func (e *Engine) recomputeKarma(entry journalEntry) (int, error) {
+	earned := e.ledgerKarma()
+	var corrected []*User
+	for _, user := range e.users {
+		k := earned[user.Username]
+		if user.PostKarma != k.post || user.CommentKarma != k.comment || user.Karma != k.post+k.comment {
+			corrected = append(corrected, user)
+		}
+	}
+	if len(corrected) == 0 {
+		return 0, nil
+	}
+	if err := e.record(&entry); err != nil {
+		return 0, err
+	}
+	for _, user := range corrected {
+		user.setKarma(earned[user.Username])
+	}
+	return len(corrected), nil
+}
+
+// ledgerKarma totals the karma each user has earned according to the vote
+// ledgers, leaving out votes on one's own content. Must be called with e.mu
+// held for writing.
+func (e *Engine) ledgerKarma() map[string]karma {
+	earned := make(map[string]karma)
+	for _, subreddit := range e.subreddits {
+		for key, direction := range subreddit.votes {
+			post, comment, err := e.content(key.targetID)
+			if err != nil {
+				continue
+			}
+			author := post.Author
+			if comment != nil {
+				author = comment.Author
+			}
+			if author.Username == key.username {
+				continue
+			}
+			k := earned[author.Username]
+			if comment != nil {
+				k.comment += direction
+			} else {
+				k.post += direction
+			}
+			earned[author.Username] = k
+		}
+	}
+	return earned
+}
+
+// setKarma replaces a user's karma. Must be called with e.mu held for
+// writing.
+func (u *User) setKarma(k karma) {
+	u.PostKarma, u.CommentKarma = k.post, k.comment
+	u.Karma = k.post + k.comment
+}
@@ -0,0 +1,136 @@
+package engine
+
+import "fmt"
+
+// CommentPage is one page of a comment listing
+type CommentPage struct {
+	Comments   []*Comment
+	NextCursor string // Empty on the last page
+}
+
+// MessagePage is one page of a direct message listing
+type MessagePage struct {
+	Messages   []*DirectMessage
+	NextCursor string // Empty on the last page
+}
+
+// GetSubredditPosts returns a page of a subreddit's posts in the order selected by opts
+func (e *Engine) GetSubredditPosts(subredditName string, opts FeedOptions) (*FeedPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, hidden := e.blockedBy(opts.Viewer), e.hiddenBy(opts.Viewer)
+	subreddit.mu.RLock()
+	var posts []rankedPost
+	for _, post := range subreddit.Posts {
+		if post.visibleTo(opts.Viewer) && !blocked[post.Author.Username] && !hidden[post.ID] {
+			posts = append(posts, rankedPost{post: post, upvotes: post.Upvotes, downvotes: post.Downvotes})
+		}
+	}
+	subreddit.mu.RUnlock()
+	return e.pagePosts(posts, opts, "r/"+subredditName)
+}
+
+// GetUserPosts returns a page of the posts a user has made in the order selected by opts
+func (e *Engine) GetUserPosts(username string, opts FeedOptions) (*FeedPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	history := append([]*Post(nil), user.Posts...)
+	user.mu.Unlock()
+
+	// Vote counters are guarded by each post's subreddit
+	var posts []rankedPost
+	for _, post := range history {
+		post.Subreddit.mu.RLock()
+		if post.visibleTo(opts.Viewer) {
+			posts = append(posts, rankedPost{post: post, upvotes: post.Upvotes, downvotes: post.Downvotes})
+		}
+		post.Subreddit.mu.RUnlock()
+	}
+	return e.pagePosts(posts, opts, "u/"+username+"/posts")
+}
+
+// GetUserComments returns a page of the comments a user has made, newest
+// first. Comments removed by moderators are left out.
+func (e *Engine) GetUserComments(username, cursorToken string, limit int) (*CommentPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := "u/" + username + "/comments"
+	after, err := decodeCursor(cursorToken, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	comments := append([]*Comment(nil), user.Comments...)
+	user.mu.Unlock()
+
+	var history []*Comment
+	var positions []position
+	for _, comment := range comments {
+		comment.Parent.Subreddit.mu.RLock()
+		removed := comment.Removed || comment.Filtered
+		comment.Parent.Subreddit.mu.RUnlock()
+		if !removed {
+			history = append(history, comment)
+			positions = append(positions, position{Time: comment.Timestamp, ID: comment.ID})
+		}
+	}
+	selected, next := paginate(positions, after, scope, limit, listingTime(after))
+	page := &CommentPage{Comments: make([]*Comment, len(selected)), NextCursor: next}
+	for i, index := range selected {
+		page.Comments[i] = history[index]
+	}
+	return page, nil
+}
+
+// GetDirectMessagesPage returns a page of a user's direct messages, newest first
+func (e *Engine) GetDirectMessagesPage(username, cursorToken string, limit int) (*MessagePage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if _, err := e.user(username); err != nil {
+		return nil, err
+	}
+
+	scope := "dm/" + username
+	after, err := decodeCursor(cursorToken, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	e.messagesMu.Lock()
+	inbox := append([]*DirectMessage(nil), e.messages[username]...)
+	e.messagesMu.Unlock()
+
+	// Inboxes only grow, so a message's place in its inbox orders it even
+	// when messages share a timestamp
+	positions := make([]position, len(inbox))
+	for i, message := range inbox {
+		positions[i] = position{Key: float64(i), Time: message.Timestamp, ID: fmt.Sprint(i)}
+	}
+	selected, next := paginate(positions, after, scope, limit, listingTime(after))
+	page := &MessagePage{Messages: make([]*DirectMessage, len(selected)), NextCursor: next}
+	for i, index := range selected {
+		page.Messages[i] = inbox[index]
+	}
+	return page, nil
+}
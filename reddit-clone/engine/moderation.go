@@ -0,0 +1,273 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ModAction is a moderator's decision on a post or comment
+type ModAction string
+
+const (
+	ModRemove  ModAction = "remove"  // Hide from everyone but the subreddit's moderators
+	ModSpam    ModAction = "spam"    // Remove as spam
+	ModApprove ModAction = "approve" // Restore removed or filtered content and dismiss its reports
+	ModLock    ModAction = "lock"    // Posts only: take no new comments or votes
+	ModUnlock  ModAction = "unlock"  // Posts only
+	ModFilter  ModAction = "filter"  // AutoModerator only: hide until a moderator approves or removes it
+)
+
+// RemovedContent replaces the content of removed comments shown to users who
+// don't moderate their subreddit
+const RemovedContent = "[removed]"
+
+// Report is a user's complaint about a post or comment
+type Report struct {
+	Username  string    `json:"username"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ModQueueItem is a reported or filtered post or comment awaiting a
+// moderator's decision.
+// Exactly one of Post and Comment is set.
+type ModQueueItem struct {
+	Post    *Post
+	Comment *Comment
+	Reports []Report
+}
+
+// AddModerator makes a user a moderator of a subreddit. The actor must
+// moderate it already, or have created it if it has no moderators yet.
+func (e *Engine) AddModerator(subredditName, actor, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.addModerator(journalEntry{Type: entryAddModerator, Time: time.Now(), Username: actor, Subreddit: subredditName, Recipient: username})
+}
+
+// addModerator applies a moderator entry. Must be called with e.mu held.
+// Entries journaled before moderators were appointed by an actor name the
+// new moderator in Username and are applied without a check.
+func (e *Engine) addModerator(entry journalEntry) error {
+	actor, username := entry.Username, entry.Recipient
+	if username == "" {
+		actor, username = "", entry.Username
+	}
+	if _, err := e.user(username); err != nil {
+		return err
+	}
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return err
+	}
+
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if actor != "" && !subreddit.moderators[actor] && (len(subreddit.moderators) > 0 || actor != subreddit.Creator) {
+		return fmt.Errorf("%w: %s in %s", ErrNotModerator, actor, subreddit.Name)
+	}
+	if subreddit.moderators[username] {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	subreddit.moderators[username] = true
+	return nil
+}
+
+// RemoveModerator takes away a user's moderator rights in a subreddit. The
+// actor must be a moderator of it; moderators may remove themselves.
+func (e *Engine) RemoveModerator(subredditName, actor, username string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.removeModerator(journalEntry{Type: entryRemoveModerator, Time: time.Now(), Username: actor, Subreddit: subredditName, Recipient: username})
+}
+
+// removeModerator applies a moderator removal entry. Must be called with
+// e.mu held.
+func (e *Engine) removeModerator(entry journalEntry) error {
+	subreddit, err := e.subreddit(entry.Subreddit)
+	if err != nil {
+		return err
+	}
+
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if !subreddit.moderators[entry.Username] {
+		return fmt.Errorf("%w: %s in %s", ErrNotModerator, entry.Username, subreddit.Name)
+	}
+	if !subreddit.moderators[entry.Recipient] {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	delete(subreddit.moderators, entry.Recipient)
+	return nil
+}
+
+// IsModerator reports whether a user moderates a subreddit
+func (e *Engine) IsModerator(subredditName, username string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return false
+	}
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	return subreddit.moderators[username]
+}
+
+// ReportContent flags a post or comment for the moderators of its subreddit.
+// Each user reports a target at most once; repeated reports are ignored.
+func (e *Engine) ReportContent(targetID, username, reason string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.report(journalEntry{Type: entryReport, Time: time.Now(), Username: username, TargetID: targetID, Content: reason})
+}
+
+// report applies a report entry. Must be called with e.mu held.
+func (e *Engine) report(entry journalEntry) error {
+	if _, err := e.user(entry.Username); err != nil {
+		return err
+	}
+	post, comment, err := e.content(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	subreddit := post.Subreddit
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	reports := &post.Reports
+	if comment != nil {
+		reports = &comment.Reports
+	}
+	for _, report := range *reports {
+		if report.Username == entry.Username {
+			return nil
+		}
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	*reports = append(*reports, Report{Username: entry.Username, Reason: entry.Content, Timestamp: entry.Time})
+	return nil
+}
+
+// Moderate applies a moderator's action to a post or comment of a subreddit
+// they moderate
+func (e *Engine) Moderate(targetID, moderator string, action ModAction) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.moderate(journalEntry{Type: entryModerate, Time: time.Now(), Username: moderator, TargetID: targetID, Action: string(action)})
+}
+
+// moderate applies a moderation entry. Must be called with e.mu held.
+func (e *Engine) moderate(entry journalEntry) error {
+	post, comment, err := e.content(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	subreddit := post.Subreddit
+	subreddit.mu.Lock()
+	defer subreddit.mu.Unlock()
+	if !subreddit.moderators[entry.Username] {
+		return fmt.Errorf("%w: %s in %s", ErrNotModerator, entry.Username, subreddit.Name)
+	}
+
+	removed, spam, filtered, reports := &post.Removed, &post.Spam, &post.Filtered, &post.Reports
+	if comment != nil {
+		removed, spam, filtered, reports = &comment.Removed, &comment.Spam, &comment.Filtered, &comment.Reports
+	}
+	action := ModAction(entry.Action)
+	switch action {
+	case ModRemove, ModSpam, ModApprove:
+	case ModLock, ModUnlock:
+		if comment != nil {
+			return fmt.Errorf("%w: %s on comment %s", ErrInvalidModAction, action, comment.ID)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidModAction, action)
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+
+	switch action {
+	case ModRemove:
+		*removed, *filtered = true, false
+	case ModSpam:
+		*removed, *spam, *filtered = true, true, false
+	case ModApprove:
+		*removed, *spam, *filtered, *reports = false, false, false, nil
+	case ModLock:
+		post.Locked = true
+	case ModUnlock:
+		post.Locked = false
+	}
+	return nil
+}
+
+// GetModQueue returns the reported or filtered posts and comments of a
+// subreddit that have been neither approved nor removed, most reported first
+func (e *Engine) GetModQueue(subredditName, moderator string) ([]ModQueueItem, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	subreddit, err := e.subreddit(subredditName)
+	if err != nil {
+		return nil, err
+	}
+
+	subreddit.mu.RLock()
+	defer subreddit.mu.RUnlock()
+	if !subreddit.moderators[moderator] {
+		return nil, fmt.Errorf("%w: %s in %s", ErrNotModerator, moderator, subredditName)
+	}
+
+	var queue []ModQueueItem
+	var collect func(comments []*Comment)
+	collect = func(comments []*Comment) {
+		for _, comment := range comments {
+			if (len(comment.Reports) > 0 || comment.Filtered) && !comment.Removed {
+				queue = append(queue, ModQueueItem{Comment: comment, Reports: append([]Report(nil), comment.Reports...)})
+			}
+			collect(comment.Replies)
+		}
+	}
+	for _, post := range subreddit.Posts {
+		if (len(post.Reports) > 0 || post.Filtered) && !post.Removed {
+			queue = append(queue, ModQueueItem{Post: post, Reports: append([]Report(nil), post.Reports...)})
+		}
+		collect(post.Comments)
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return len(queue[i].Reports) > len(queue[j].Reports)
+	})
+	return queue, nil
+}
+
+// content looks up a post or comment by ID. For a comment it also returns the
+// post it was made on. Must be called with e.mu held.
+func (e *Engine) content(targetID string) (*Post, *Comment, error) {
+	if post, err := e.post(targetID); err == nil {
+		return post, nil, nil
+	}
+	if comment, err := e.comment(targetID); err == nil {
+		return comment.Parent, comment, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %s", ErrTargetNotFound, targetID)
+}
+
+// visibleTo reports whether a post is shown to username in listings. Removed
+// and filtered posts are only shown to the subreddit's moderators. Must be
+// called with the post's subreddit locked.
+func (p *Post) visibleTo(username string) bool {
+	return !p.Removed && !p.Filtered || p.Subreddit.moderators[username]
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NotificationType says why a user was notified
+type NotificationType string
+
+const (
+	NotifyPostReply    NotificationType = "post_reply"    // A comment on the user's post
+	NotifyCommentReply NotificationType = "comment_reply" // A reply to the user's comment
+	NotifyMention      NotificationType = "mention"       // A u/name mention of the user
+)
+
+// Notification tells a user about a reply to their content or a mention of
+// them in a post or comment.
+type Notification struct {
+	ID        string           `json:"id"`
+	Type      NotificationType `json:"type"`
+	From      string           `json:"from"` // Author of the reply or mention
+	Subreddit string           `json:"subreddit"`
+	PostID    string           `json:"post_id"`
+	CommentID string           `json:"comment_id,omitempty"` // Empty for a mention in a post
+	Timestamp time.Time        `json:"timestamp"`
+	Read      bool             `json:"read"`
+}
+
+// mentionPattern matches u/name and /u/name, but not inside a word or a URL
+// path such as example.com/u/name.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_/])/?u/([A-Za-z0-9_-]+)`)
+
+// mentions returns the usernames mentioned in content, each once, in order
+func mentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if username := match[1]; !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// notify delivers the notifications for a new post or comment (comment is nil
+// for a post): one to the author of what a comment replies to, and one to
+// each registered user mentioned. Nobody is notified about their own content,
+// content by users they have blocked, or content removed or held by
+// AutoModerator as it was created.
+// Must be called with e.mu held and the post's subreddit locked.
+func (e *Engine) notify(post *Post, comment *Comment) {
+	author, content, timestamp := post.Author, post.Content, post.Timestamp
+	hidden := post.Removed || post.Filtered
+	sourceID, commentID := post.ID, ""
+	if comment != nil {
+		author, content, timestamp = comment.Author, comment.Content, comment.Timestamp
+		hidden = comment.Removed || comment.Filtered
+		sourceID, commentID = comment.ID, comment.ID
+	}
+	if hidden {
+		return
+	}
+
+	var recipients []*User
+	var types []NotificationType
+	notified := map[string]bool{author.Username: true}
+	add := func(user *User, kind NotificationType) {
+		if !notified[user.Username] {
+			notified[user.Username] = true
+			recipients = append(recipients, user)
+			types = append(types, kind)
+		}
+	}
+	if comment != nil {
+		if comment.ReplyTo != nil {
+			add(comment.ReplyTo.Author, NotifyCommentReply)
+		} else {
+			add(post.Author, NotifyPostReply)
+		}
+	}
+	for _, username := range mentions(content) {
+		if user, exists := e.users[username]; exists {
+			add(user, NotifyMention)
+		}
+	}
+
+	for i, user := range recipients {
+		notification := &Notification{
+			ID:        fmt.Sprintf("%s-notification-%d", sourceID, i),
+			Type:      types[i],
+			From:      author.Username,
+			Subreddit: post.Subreddit.Name,
+			PostID:    post.ID,
+			CommentID: commentID,
+			Timestamp: timestamp,
+		}
+		user.mu.Lock()
+		if !user.blocked[author.Username] {
+			user.notifications = append(user.notifications, notification)
+			user.queue(Delivery{Notification: notification})
+		}
+		user.mu.Unlock()
+	}
+}
+
+// GetNotifications returns copies of a user's notifications, newest first.
+// With unreadOnly set, notifications already read are left out.
+func (e *Engine) GetNotifications(username string, unreadOnly bool) ([]Notification, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	var notifications []Notification
+	for i := len(user.notifications) - 1; i >= 0; i-- {
+		if notification := user.notifications[i]; !unreadOnly || !notification.Read {
+			notifications = append(notifications, *notification)
+		}
+	}
+	return notifications, nil
+}
+
+// UnreadNotificationCount returns how many of a user's notifications are unread
+func (e *Engine) UnreadNotificationCount(username string) (int, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return 0, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	unread := 0
+	for _, notification := range user.notifications {
+		if !notification.Read {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// MarkNotificationRead marks one of a user's notifications read, or unread
+// again if read is false
+func (e *Engine) MarkNotificationRead(username, notificationID string, read bool) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	entryType := entryMarkRead
+	if !read {
+		entryType = entryMarkUnread
+	}
+	return e.markNotification(journalEntry{Type: entryType, Time: time.Now(), Username: username, TargetID: notificationID})
+}
+
+// markNotification applies a mark_read or mark_unread entry. Must be called
+// with e.mu held.
+func (e *Engine) markNotification(entry journalEntry) error {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	for _, notification := range user.notifications {
+		if notification.ID != entry.TargetID {
+			continue
+		}
+		read := entry.Type == entryMarkRead
+		if notification.Read == read {
+			return nil
+		}
+		if err := e.record(&entry); err != nil {
+			return err
+		}
+		notification.Read = read
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotificationNotFound, entry.TargetID)
+}
+
+// MarkAllNotificationsRead marks every notification of a user read and
+// returns how many were unread
+func (e *Engine) MarkAllNotificationsRead(username string) (int, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.markAllRead(journalEntry{Type: entryMarkAllRead, Time: time.Now(), Username: username})
+}
+
+// markAllRead applies a mark_all_read entry. The entry's content lists the
+// IDs it marks, so that replaying it leaves alone notifications delivered
+// concurrently but journaled before it. Must be called with e.mu held.
+func (e *Engine) markAllRead(entry journalEntry) (int, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return 0, err
+	}
+
+	var listed map[string]bool
+	if entry.Content != "" {
+		listed = make(map[string]bool)
+		for _, id := range strings.Fields(entry.Content) {
+			listed[id] = true
+		}
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	var unread []*Notification
+	var ids []string
+	for _, notification := range user.notifications {
+		if !notification.Read && (listed == nil || listed[notification.ID]) {
+			unread = append(unread, notification)
+			ids = append(ids, notification.ID)
+		}
+	}
+	if len(unread) == 0 {
+		return 0, nil
+	}
+	entry.Content = strings.Join(ids, " ")
+	if err := e.record(&entry); err != nil {
+		return 0, err
+	}
+	for _, notification := range unread {
+		notification.Read = true
+	}
+	return len(unread), nil
+}
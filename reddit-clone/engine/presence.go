@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Session is one connection of a user, such as a browser tab or a client
+type Session struct {
+	ID     string
+	Opened time.Time
+	// Messages and notifications received while the user was offline, oldest
+	// first. Only the session that brings a user online gets them.
+	Pending []Delivery
+}
+
+// Delivery is a direct message or a notification queued for a user who was
+// offline when it arrived
+type Delivery struct {
+	Message      *DirectMessage // Nil for a notification
+	Notification *Notification  // A copy, nil for a message
+}
+
+// Presence is whether a user is online and when they were last seen
+type Presence struct {
+	Username string
+	Online   bool
+	Sessions int       // Open sessions
+	LastSeen time.Time // When the last session closed, zero if never
+}
+
+// Connect opens a session for a user. The first session brings the user
+// online, publishing a UserOnline, and takes every message and notification
+// queued while they were offline. Events published around the call may carry
+// some of them again, so clients should drop deliveries they already have.
+func (e *Engine) Connect(username string) (*Session, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.publishConnect(username, false)
+}
+
+// publishConnect opens a session for a user, unless offlineOnly is set and
+// they are already online, and publishes a UserOnline if it brought them
+// online. It returns nil when no session was opened. Must be called with e.mu
+// held.
+func (e *Engine) publishConnect(username string, offlineOnly bool) (*Session, error) {
+	entry := journalEntry{
+		Type:     entryConnect,
+		Time:     time.Now(),
+		ID:       fmt.Sprintf("session-%d", rand.Int()),
+		Username: username,
+	}
+	session, online, err := e.connect(entry, offlineOnly)
+	if err != nil {
+		return nil, err
+	}
+	if online {
+		e.events.Publish(UserOnline{Username: username, Time: session.Opened})
+	}
+	return session, nil
+}
+
+// connect applies a connect entry, reporting whether it brought the user
+// online. With offlineOnly set, a user already online is left as they are and
+// no session is returned; the check is made under the user's lock, so
+// concurrent calls open one session between them. Must be called with e.mu
+// held.
+func (e *Engine) connect(entry journalEntry, offlineOnly bool) (*Session, bool, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	if offlineOnly && user.Connected {
+		return nil, false, nil
+	}
+	if err := e.record(&entry); err != nil {
+		return nil, false, err
+	}
+	if user.sessions == nil {
+		user.sessions = make(map[string]time.Time)
+	}
+	user.sessions[entry.ID] = entry.Time
+	session := &Session{ID: entry.ID, Opened: entry.Time}
+	if user.Connected {
+		return session, false, nil
+	}
+
+	user.Connected = true
+	for _, delivery := range user.pending {
+		if delivery.Notification != nil {
+			notification := *delivery.Notification
+			delivery.Notification = &notification
+		}
+		session.Pending = append(session.Pending, delivery)
+	}
+	user.pending = nil
+	return session, true, nil
+}
+
+// Disconnect closes one of a user's sessions. Closing the last one takes the
+// user offline, publishing a UserOffline, and queues what they receive until
+// they connect again.
+func (e *Engine) Disconnect(username, sessionID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.publishDisconnect(journalEntry{Type: entryDisconnect, Time: time.Now(), ID: sessionID, Username: username})
+}
+
+// publishDisconnect applies a disconnect entry and publishes a UserOffline if
+// it took the user offline. Must be called with e.mu held.
+func (e *Engine) publishDisconnect(entry journalEntry) error {
+	offline, err := e.disconnect(entry)
+	if err != nil {
+		return err
+	}
+	if offline {
+		e.events.Publish(UserOffline{Username: entry.Username, Time: entry.Time})
+	}
+	return nil
+}
+
+// disconnect applies a disconnect entry, which closes every session of the
+// user when it has no session ID, and reports whether it took the user
+// offline. Must be called with e.mu held.
+func (e *Engine) disconnect(entry journalEntry) (bool, error) {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return false, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	if entry.ID == "" {
+		if len(user.sessions) == 0 {
+			return false, nil
+		}
+	} else if _, exists := user.sessions[entry.ID]; !exists {
+		return false, fmt.Errorf("%w: %s", ErrSessionNotFound, entry.ID)
+	}
+	if err := e.record(&entry); err != nil {
+		return false, err
+	}
+	if entry.ID == "" {
+		user.sessions = nil
+	} else {
+		delete(user.sessions, entry.ID)
+	}
+	if len(user.sessions) > 0 {
+		return false, nil
+	}
+	user.Connected = false
+	user.LastSeen = entry.Time
+	return true, nil
+}
+
+// closeSessions closes the sessions left open when the engine stopped, since
+// their connections did not survive it. No events are published. Must be
+// called before the engine is handed out.
+func (e *Engine) closeSessions(t time.Time) error {
+	for _, user := range e.users {
+		if len(user.sessions) == 0 {
+			continue
+		}
+		if _, err := e.disconnect(journalEntry{Type: entryDisconnect, Time: t, Username: user.Username}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPresence returns whether a user is online and when they were last seen
+func (e *Engine) GetPresence(username string) (*Presence, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	return &Presence{
+		Username: user.Username,
+		Online:   user.Connected,
+		Sessions: len(user.sessions),
+		LastSeen: user.LastSeen,
+	}, nil
+}
+
+// queue holds a delivery for a user without open sessions until they
+// connect. Must be called with u.mu held.
+func (u *User) queue(delivery Delivery) {
+	if !u.Connected {
+		u.pending = append(u.pending, delivery)
+	}
+}
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SortMode selects how a feed is ordered
+type SortMode string
+
+const (
+	SortHot           SortMode = "hot"           // Score decayed by age
+	SortTop           SortMode = "top"           // Highest score within a time window
+	SortNew           SortMode = "new"           // Newest first
+	SortControversial SortMode = "controversial" // Many votes, evenly split
+	SortRising        SortMode = "rising"        // Fastest scoring among recent posts
+)
+
+// TimeWindow limits a top or controversial feed to recent posts
+type TimeWindow string
+
+const (
+	WindowHour TimeWindow = "hour"
+	WindowDay  TimeWindow = "day"
+	WindowWeek TimeWindow = "week"
+	WindowAll  TimeWindow = "all"
+)
+
+// risingWindow is how old a post may be and still be rising
+const risingWindow = 24 * time.Hour
+
+// hotEpoch is the reference time of the hot ranking. A post needs ten times
+// the score of one made 12.5 hours later to rank as high.
+var hotEpoch = time.Unix(1134028003, 0)
+
+// FeedOptions selects the order and page of a feed
+type FeedOptions struct {
+	Sort   SortMode
+	Window TimeWindow // Used by SortTop and SortControversial, defaults to WindowAll
+	Limit  int        // Page size, 0 returns every remaining post
+	Cursor string     // NextCursor of the previous page, empty for the first page
+	Viewer string     // User the listing is for; only moderators see removed posts, and posts the viewer hid or by users they blocked are left out
+}
+
+// FeedPage is one page of a post listing
+type FeedPage struct {
+	Posts      []*Post
+	NextCursor string // Empty on the last page
+}
+
+// ParseSortMode validates a sort mode name. An empty name means SortHot.
+func ParseSortMode(name string) (SortMode, error) {
+	switch mode := SortMode(name); mode {
+	case "":
+		return SortHot, nil
+	case SortHot, SortTop, SortNew, SortControversial, SortRising:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// ParseTimeWindow validates a time window name. An empty name means WindowAll.
+func ParseTimeWindow(name string) (TimeWindow, error) {
+	switch window := TimeWindow(name); window {
+	case "":
+		return WindowAll, nil
+	case WindowHour, WindowDay, WindowWeek, WindowAll:
+		return window, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrInvalidSort, name)
+}
+
+// duration returns the length of the window, or 0 for WindowAll
+func (w TimeWindow) duration() time.Duration {
+	switch w {
+	case WindowHour:
+		return time.Hour
+	case WindowDay:
+		return 24 * time.Hour
+	case WindowWeek:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// HotScore ranks by the logarithm of the score plus a bonus that grows with
+// the post's age, so newer posts need fewer votes to rank as high.
+func HotScore(upvotes, downvotes int, posted time.Time) float64 {
+	score := upvotes - downvotes
+	order := math.Log10(math.Max(math.Abs(float64(score)), 1))
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+	seconds := posted.Sub(hotEpoch).Seconds()
+	return sign*order + seconds/45000
+}
+
+// ControversialScore favors targets with many votes split evenly between up
+// and down. Targets voted only one way score 0.
+func ControversialScore(upvotes, downvotes int) float64 {
+	if upvotes <= 0 || downvotes <= 0 {
+		return 0
+	}
+	magnitude := float64(upvotes + downvotes)
+	balance := float64(downvotes) / float64(upvotes)
+	if upvotes < downvotes {
+		balance = float64(upvotes) / float64(downvotes)
+	}
+	return math.Pow(magnitude, balance)
+}
+
+// RisingScore is the score a post has gained per hour since it was made
+func RisingScore(upvotes, downvotes int, posted, now time.Time) float64 {
+	hours := math.Max(now.Sub(posted).Hours(), 1.0/60)
+	return float64(upvotes-downvotes) / hours
+}
+
+// rankedPost is a post together with the counters it is ranked by, copied
+// under its subreddit's lock so sorting sees a stable value.
+type rankedPost struct {
+	post      *Post
+	upvotes   int
+	downvotes int
+}
+
+// rankKey returns the value a post is ordered by under opts, and false if the
+// post falls outside the time window.
+func rankKey(ranked rankedPost, opts FeedOptions, now time.Time) (float64, bool) {
+	var window time.Duration
+	switch opts.Sort {
+	case SortTop, SortControversial:
+		window = opts.Window.duration()
+	case SortRising:
+		window = risingWindow
+	}
+	if window > 0 && now.Sub(ranked.post.Timestamp) > window {
+		return 0, false
+	}
+
+	switch opts.Sort {
+	case SortTop:
+		return float64(ranked.upvotes - ranked.downvotes), true
+	case SortControversial:
+		return ControversialScore(ranked.upvotes, ranked.downvotes), true
+	case SortRising:
+		return RisingScore(ranked.upvotes, ranked.downvotes, ranked.post.Timestamp, now), true
+	case SortNew:
+		return 0, true // Ordered by time alone
+	}
+	return HotScore(ranked.upvotes, ranked.downvotes, ranked.post.Timestamp), true
+}
+
+// pagePosts orders posts according to opts and returns the page following
+// opts.Cursor. scope names the listing, so that its cursors are rejected by
+// other listings. Scores that depend on the current time are computed at the
+// time of the first page, and the order of ranked listings is frozen then,
+// which keeps the order stable across pages.
+func (e *Engine) pagePosts(posts []rankedPost, opts FeedOptions, scope string) (*FeedPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortHot
+	}
+	if opts.Window == "" {
+		opts.Window = WindowAll
+	}
+	scope = fmt.Sprintf("%s:%s:%s", scope, opts.Sort, opts.Window)
+	after, err := decodeCursor(opts.Cursor, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	asOf := listingTime(after)
+	var listed []*Post
+	var positions []position
+	for _, ranked := range posts {
+		if key, ok := rankKey(ranked, opts, asOf); ok {
+			listed = append(listed, ranked.post)
+			positions = append(positions, position{Key: key, Time: ranked.post.Timestamp, ID: ranked.post.ID})
+		}
+	}
+
+	var selected []int
+	var next string
+	if opts.Sort == SortNew {
+		selected, next = paginate(positions, after, scope, opts.Limit, asOf)
+	} else if selected, next, err = e.paginateFrozen(positions, after, scope, opts.Viewer, opts.Limit, asOf); err != nil {
+		return nil, err
+	}
+	page := &FeedPage{Posts: make([]*Post, len(selected)), NextCursor: next}
+	for i, index := range selected {
+		page.Posts[i] = listed[index]
+	}
+	return page, nil
+}
@@ -0,0 +1,28 @@
+package engine
+
+// Reddit is the set of operations shared by Engine and the actor-based engine
+// in reddit-clone/engine/actor, so the simulator can run against either one.
+// Both return the same sentinel errors for the same calls. The actor engine
+// has no moderation, bans, blocks or crosspost settings, so it never returns
+// ErrLocked, ErrBanned, ErrBlocked or ErrCrosspostsDisabled.
+type Reddit interface {
+	RegisterAccount(username string) (*User, error)
+	GetUser(username string) (*User, error)
+	CreateSubreddit(name, creator string) error
+	JoinSubreddit(username, subredditName string) error
+	LeaveSubreddit(username, subredditName string) error
+	PostInSubreddit(subredditName, username, content string) (*Post, error)
+	CommentOnPost(subredditName string, postID string, username string, content string) (*Comment, error)
+	ReplyToComment(postID, parentCommentID, username, content string) (*Comment, error)
+	Repost(originalPostID, username, subredditName string) (*Post, error)
+	Upvote(postID string, upvote bool, username string) error
+	RetractVote(targetID string, username string) error
+	SendDirectMessage(fromUsername, toUsername, content string) error
+	ReplyToDirectMessage(messageID string, fromUsername string, content string) error
+	GetDirectMessages(username string) ([]*DirectMessage, error)
+	GetFeed(username string) ([]*Post, error)
+	GetUserFeed(username string, limit int) ([]*Post, error)
+	SetUserConnection(username string, connected bool) error
+}
+
+var _ Reddit = (*Engine)(nil)
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"sort"
+	"time"
+)
+
+// SavedItem is a post or comment a user saved. It points at the live content,
+// so later edits show, and the tombstone once the content is deleted.
+type SavedItem struct {
+	Post    *Post    // The post saved, or the post the saved comment was made on
+	Comment *Comment // Nil for a saved post
+	Saved   time.Time
+}
+
+// SavedPage is one page of a user's saved items
+type SavedPage struct {
+	Items      []SavedItem // Most recently saved first
+	NextCursor string      // Empty on the last page
+}
+
+// SaveItem adds a post or comment to a user's saved list. Saving an item
+// again keeps its place in the list.
+func (e *Engine) SaveItem(username, targetID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.save(journalEntry{Type: entrySave, Time: time.Now(), Username: username, TargetID: targetID})
+}
+
+// UnsaveItem removes a post or comment from a user's saved list
+func (e *Engine) UnsaveItem(username, targetID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.save(journalEntry{Type: entryUnsave, Time: time.Now(), Username: username, TargetID: targetID})
+}
+
+// save applies a save or unsave entry. Must be called with e.mu held.
+func (e *Engine) save(entry journalEntry) error {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return err
+	}
+	post, comment, err := e.content(entry.TargetID)
+	if err != nil {
+		return err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	index := -1
+	for i, item := range user.saved {
+		if savedID(item) == entry.TargetID {
+			index = i
+		}
+	}
+	if (index >= 0) == (entry.Type == entrySave) {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	if entry.Type == entryUnsave {
+		user.saved = append(user.saved[:index], user.saved[index+1:]...)
+		return nil
+	}
+	user.saved = append(user.saved, &SavedItem{Post: post, Comment: comment, Saved: entry.Time})
+	return nil
+}
+
+// savedID returns the ID of the post or comment a saved item stands for
+func savedID(item *SavedItem) string {
+	if item.Comment != nil {
+		return item.Comment.ID
+	}
+	return item.Post.ID
+}
+
+// GetSavedItems returns a page of a user's saved posts and comments, most
+// recently saved first. Content removed by moderators is left out unless the
+// user moderates its subreddit.
+func (e *Engine) GetSavedItems(username, cursorToken string, limit int) (*SavedPage, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := "u/" + username + "/saved"
+	after, err := decodeCursor(cursorToken, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	saved := make([]SavedItem, len(user.saved))
+	for i, item := range user.saved {
+		saved[i] = *item
+	}
+	user.mu.Unlock()
+
+	var items []SavedItem
+	var positions []position
+	for _, item := range saved {
+		subreddit := item.Post.Subreddit
+		subreddit.mu.RLock()
+		visible := item.Post.visibleTo(username)
+		if item.Comment != nil {
+			visible = !item.Comment.Removed && !item.Comment.Filtered || subreddit.moderators[username]
+		}
+		subreddit.mu.RUnlock()
+		if visible {
+			items = append(items, item)
+			positions = append(positions, position{Time: item.Saved, ID: savedID(&item)})
+		}
+	}
+	selected, next := paginate(positions, after, scope, limit, listingTime(after))
+	page := &SavedPage{Items: make([]SavedItem, len(selected)), NextCursor: next}
+	for i, index := range selected {
+		page.Items[i] = items[index]
+	}
+	return page, nil
+}
+
+// HidePost keeps a post out of a user's feeds and subreddit listings
+func (e *Engine) HidePost(username, postID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.hide(journalEntry{Type: entryHide, Time: time.Now(), Username: username, TargetID: postID})
+}
+
+// UnhidePost lets a hidden post back into a user's listings
+func (e *Engine) UnhidePost(username, postID string) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.hide(journalEntry{Type: entryUnhide, Time: time.Now(), Username: username, TargetID: postID})
+}
+
+// hide applies a hide or unhide entry. Must be called with e.mu held.
+func (e *Engine) hide(entry journalEntry) error {
+	user, err := e.user(entry.Username)
+	if err != nil {
+		return err
+	}
+	if _, err := e.post(entry.TargetID); err != nil {
+		return err
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	_, hidden := user.hidden[entry.TargetID]
+	if hidden == (entry.Type == entryHide) {
+		return nil
+	}
+	if err := e.record(&entry); err != nil {
+		return err
+	}
+	if entry.Type == entryUnhide {
+		delete(user.hidden, entry.TargetID)
+		return nil
+	}
+	if user.hidden == nil {
+		user.hidden = make(map[string]time.Time)
+	}
+	user.hidden[entry.TargetID] = entry.Time
+	return nil
+}
+
+// GetHiddenPosts returns the posts a user has hidden, most recently hidden
+// first
+func (e *Engine) GetHiddenPosts(username string) ([]*Post, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	user, err := e.user(username)
+	if err != nil {
+		return nil, err
+	}
+
+	user.mu.Lock()
+	ids := make([]string, 0, len(user.hidden))
+	for id := range user.hidden {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return user.hidden[ids[i]].After(user.hidden[ids[j]])
+	})
+	user.mu.Unlock()
+
+	posts := make([]*Post, 0, len(ids))
+	for _, id := range ids {
+		if post, err := e.post(id); err == nil {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// hiddenBy returns a copy of the set of posts username has hidden, nil for
+// unknown users. Must be called with e.mu held and no user locked.
+func (e *Engine) hiddenBy(username string) map[string]bool {
+	user, exists := e.users[username]
+	if !exists {
+		return nil
+	}
+
+	user.mu.Lock()
+	defer user.mu.Unlock()
+	hidden := make(map[string]bool, len(user.hidden))
+	for id := range user.hidden {
+		hidden[id] = true
+	}
+	return hidden
+}
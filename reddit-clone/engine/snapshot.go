@@ -0,0 +1,639 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// snapshotVersion is the format version written by Snapshot. Restore rejects
+// snapshots written with a newer version.
+const snapshotVersion = 14
+
+// snapshotFile is the on-disk form of an engine. Pointer links between
+// objects are stored as usernames, subreddit names and IDs.
+type snapshotFile struct {
+	Version    int                 `json:"version"`
+	TakenAt    time.Time           `json:"taken_at"`
+	Users      []snapshotUser      `json:"users"`
+	Subreddits []snapshotSubreddit `json:"subreddits"`
+	Posts      []snapshotPost      `json:"posts"`
+	Comments   []snapshotComment   `json:"comments"`
+	Votes      []snapshotVote      `json:"votes"`
+	Messages   []snapshotMessage   `json:"messages"`
+}
+
+type snapshotUser struct {
+	Username  string    `json:"username"`
+	Karma     int       `json:"karma"`
+	Connected bool      `json:"connected"`
+	Posts     []string  `json:"posts"`
+	Comments  []string  `json:"comments"`
+	Created   time.Time `json:"created"` // Since version 5
+
+	Notifications []Notification       `json:"notifications,omitempty"` // Since version 6, oldest first
+	Blocked       []string             `json:"blocked,omitempty"`       // Since version 8
+	Following     []string             `json:"following,omitempty"`     // Since version 9
+	Saved         []snapshotSaved      `json:"saved,omitempty"`         // Since version 10, oldest first
+	Hidden        map[string]time.Time `json:"hidden,omitempty"`        // Since version 10
+	PostKarma     int                  `json:"post_karma"`              // Since version 12
+	CommentKarma  int                  `json:"comment_karma"`           // Since version 12
+	LastSeen      time.Time            `json:"last_seen"`               // Since version 13
+	Sessions      map[string]time.Time `json:"sessions,omitempty"`      // Since version 13
+	Pending       []string             `json:"pending,omitempty"`       // Since version 13, IDs of messages and notifications, oldest first
+}
+
+// snapshotSaved is a saved item, by the ID of the post or comment saved
+type snapshotSaved struct {
+	ID    string    `json:"id"`
+	Saved time.Time `json:"saved"`
+}
+
+type snapshotSubreddit struct {
+	Name       string        `json:"name"`
+	Creator    string        `json:"creator,omitempty"` // Since version 14
+	Members    []string      `json:"members"`
+	Posts      []string      `json:"posts"`
+	Moderators []string      `json:"moderators,omitempty"` // Since version 3
+	Bans       []Ban         `json:"bans,omitempty"`       // Since version 4
+	Mutes      []Ban         `json:"mutes,omitempty"`      // Since version 4
+	AutoMod    []AutoModRule `json:"automod,omitempty"`    // Since version 5
+
+	NoCrossposts bool `json:"no_crossposts,omitempty"` // Since version 11
+}
+
+type snapshotPost struct {
+	ID             string     `json:"id"`
+	Author         string     `json:"author"`
+	Subreddit      string     `json:"subreddit"`
+	Content        string     `json:"content"`
+	Timestamp      time.Time  `json:"timestamp"`
+	Upvotes        int        `json:"upvotes"`
+	Downvotes      int        `json:"downvotes"`
+	Comments       []string   `json:"comments"`
+	IsRepost       bool       `json:"is_repost,omitempty"`
+	OriginalPostID string     `json:"original_post_id,omitempty"`
+	Edited         time.Time  `json:"edited"`              // Since version 2
+	Deleted        bool       `json:"deleted,omitempty"`   // Since version 2
+	Revisions      []Revision `json:"revisions,omitempty"` // Since version 2
+	Removed        bool       `json:"removed,omitempty"`   // Since version 3
+	Spam           bool       `json:"spam,omitempty"`      // Since version 3
+	Locked         bool       `json:"locked,omitempty"`    // Since version 3
+	Reports        []Report   `json:"reports,omitempty"`   // Since version 3
+	Filtered       bool       `json:"filtered,omitempty"`  // Since version 5
+	Flair          string     `json:"flair,omitempty"`     // Since version 5
+}
+
+type snapshotComment struct {
+	ID        string     `json:"id"`
+	Author    string     `json:"author"`
+	PostID    string     `json:"post_id"`
+	ReplyTo   string     `json:"reply_to,omitempty"`
+	Content   string     `json:"content"`
+	Timestamp time.Time  `json:"timestamp"`
+	Upvotes   int        `json:"upvotes"`
+	Downvotes int        `json:"downvotes"`
+	Replies   []string   `json:"replies"`
+	Edited    time.Time  `json:"edited"`              // Since version 2
+	Deleted   bool       `json:"deleted,omitempty"`   // Since version 2
+	Revisions []Revision `json:"revisions,omitempty"` // Since version 2
+	Removed   bool       `json:"removed,omitempty"`   // Since version 3
+	Spam      bool       `json:"spam,omitempty"`      // Since version 3
+	Reports   []Report   `json:"reports,omitempty"`   // Since version 3
+	Filtered  bool       `json:"filtered,omitempty"`  // Since version 5
+}
+
+type snapshotVote struct {
+	Username  string `json:"username"`
+	TargetID  string `json:"target_id"`
+	Direction int    `json:"direction"`
+}
+
+type snapshotMessage struct {
+	Inbox     string    `json:"inbox"`
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+	ReplyTo   string    `json:"reply_to,omitempty"`
+	ReadAt    time.Time `json:"read_at"` // Since version 7
+}
+
+// Snapshot writes the complete engine state to w. The state is captured in
+// one consistent cut while other operations are paused, then encoded after
+// they have resumed.
+func (e *Engine) Snapshot(w io.Writer) error {
+	e.mu.Lock()
+	file := e.capture()
+	e.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(file)
+}
+
+// capture copies the engine state into its snapshot form. Every other
+// operation holds e.mu for reading, so holding it for writing is enough to
+// see a consistent state. Must be called with e.mu held for writing.
+func (e *Engine) capture() *snapshotFile {
+	file := &snapshotFile{Version: snapshotVersion, TakenAt: time.Now()}
+
+	for _, user := range e.users {
+		saved := snapshotUser{
+			Username:  user.Username,
+			Karma:     user.Karma,
+			Connected: user.Connected,
+			Posts:     postIDs(user.Posts),
+			Comments:  commentIDs(user.Comments),
+			Created:   user.Created,
+		}
+		for _, notification := range user.notifications {
+			saved.Notifications = append(saved.Notifications, *notification)
+		}
+		for blocked := range user.blocked {
+			saved.Blocked = append(saved.Blocked, blocked)
+		}
+		for followee := range e.following[user.Username] {
+			saved.Following = append(saved.Following, followee)
+		}
+		for _, item := range user.saved {
+			saved.Saved = append(saved.Saved, snapshotSaved{ID: savedID(item), Saved: item.Saved})
+		}
+		for id, hidden := range user.hidden {
+			if saved.Hidden == nil {
+				saved.Hidden = make(map[string]time.Time)
+			}
+			saved.Hidden[id] = hidden
+		}
+		saved.PostKarma, saved.CommentKarma = user.PostKarma, user.CommentKarma
+		saved.LastSeen = user.LastSeen
+		for id, opened := range user.sessions {
+			if saved.Sessions == nil {
+				saved.Sessions = make(map[string]time.Time)
+			}
+			saved.Sessions[id] = opened
+		}
+		for _, delivery := range user.pending {
+			if delivery.Message != nil {
+				saved.Pending = append(saved.Pending, delivery.Message.ID)
+			} else {
+				saved.Pending = append(saved.Pending, delivery.Notification.ID)
+			}
+		}
+		file.Users = append(file.Users, saved)
+	}
+
+	for _, subreddit := range e.subreddits {
+		members := make([]string, 0, len(subreddit.Members))
+		for username := range subreddit.Members {
+			members = append(members, username)
+		}
+		var moderators []string
+		for username := range subreddit.moderators {
+			moderators = append(moderators, username)
+		}
+		file.Subreddits = append(file.Subreddits, snapshotSubreddit{
+			Name:       subreddit.Name,
+			Creator:    subreddit.Creator,
+			Members:    members,
+			Posts:      postIDs(subreddit.Posts),
+			Moderators: moderators,
+			Bans:       activeBans(subreddit.bans, file.TakenAt),
+			Mutes:      activeBans(subreddit.mutes, file.TakenAt),
+			AutoMod:    subreddit.automod,
+
+			NoCrossposts: subreddit.noCrossposts,
+		})
+		for key, direction := range subreddit.votes {
+			file.Votes = append(file.Votes, snapshotVote{Username: key.username, TargetID: key.targetID, Direction: direction})
+		}
+	}
+
+	for _, post := range e.posts {
+		saved := snapshotPost{
+			ID:        post.ID,
+			Author:    post.Author.Username,
+			Subreddit: post.Subreddit.Name,
+			Content:   post.Content,
+			Timestamp: post.Timestamp,
+			Upvotes:   post.Upvotes,
+			Downvotes: post.Downvotes,
+			Comments:  commentIDs(post.Comments),
+			IsRepost:  post.IsRepost,
+			Edited:    post.Edited,
+			Deleted:   post.Deleted,
+			Revisions: post.Revisions,
+			Removed:   post.Removed,
+			Spam:      post.Spam,
+			Locked:    post.Locked,
+			Reports:   post.Reports,
+			Filtered:  post.Filtered,
+			Flair:     post.Flair,
+		}
+		if post.OriginalPost != nil {
+			saved.OriginalPostID = post.OriginalPost.ID
+		}
+		file.Posts = append(file.Posts, saved)
+	}
+
+	for _, comment := range e.comments {
+		saved := snapshotComment{
+			ID:        comment.ID,
+			Author:    comment.Author.Username,
+			PostID:    comment.Parent.ID,
+			Content:   comment.Content,
+			Timestamp: comment.Timestamp,
+			Upvotes:   comment.Upvotes,
+			Downvotes: comment.Downvotes,
+			Replies:   commentIDs(comment.Replies),
+			Edited:    comment.Edited,
+			Deleted:   comment.Deleted,
+			Revisions: comment.Revisions,
+			Removed:   comment.Removed,
+			Spam:      comment.Spam,
+			Reports:   comment.Reports,
+			Filtered:  comment.Filtered,
+		}
+		if comment.ReplyTo != nil {
+			saved.ReplyTo = comment.ReplyTo.ID
+		}
+		file.Comments = append(file.Comments, saved)
+	}
+
+	for inbox, messages := range e.messages {
+		for _, message := range messages {
+			file.Messages = append(file.Messages, snapshotMessage{
+				Inbox:     inbox,
+				ID:        message.ID,
+				From:      message.From.Username,
+				To:        message.To.Username,
+				Content:   message.Content,
+				Timestamp: message.Timestamp,
+				ReplyTo:   message.ReplyTo,
+				ReadAt:    message.ReadAt,
+			})
+		}
+	}
+	return file
+}
+
+// Restore replaces the engine state with a snapshot read from r. The engine is
+// left untouched if the snapshot cannot be read or is inconsistent. Engines
+// opened with OpenEngine restore from their journal directory instead.
+func (e *Engine) Restore(r io.Reader) error {
+	if e.journal != nil {
+		return ErrJournaled
+	}
+	return e.restore(r, true)
+}
+
+// restore replaces the engine state with a snapshot read from r, closing the
+// sessions open in it when closeSessions is set
+func (e *Engine) restore(r io.Reader, closeSessions bool) error {
+	var file snapshotFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if file.Version < 1 || file.Version > snapshotVersion {
+		return fmt.Errorf("%w: %d", ErrSnapshotVersion, file.Version)
+	}
+
+	restored := NewEngine()
+	if err := restored.load(&file); err != nil {
+		return err
+	}
+	if closeSessions {
+		if err := restored.closeSessions(file.TakenAt); err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.users, e.subreddits = restored.users, restored.subreddits
+	e.posts, e.comments = restored.posts, restored.comments
+	e.messages = restored.messages
+	e.following, e.followers = restored.following, restored.followers
+	e.orders.clear() // Cursors into the replaced state start again
+	return nil
+}
+
+// load rebuilds objects from a snapshot into an empty engine, then resolves
+// the links between them.
+func (e *Engine) load(file *snapshotFile) error {
+	for _, saved := range file.Users {
+		user := &User{
+			Username:     saved.Username,
+			Karma:        saved.Karma,
+			PostKarma:    saved.PostKarma,
+			CommentKarma: saved.CommentKarma,
+			Connected:    len(saved.Sessions) > 0,
+			LastSeen:     saved.LastSeen,
+			Created:      saved.Created,
+		}
+		if len(saved.Sessions) > 0 {
+			user.sessions = saved.Sessions
+		}
+		for i := range saved.Notifications {
+			user.notifications = append(user.notifications, &saved.Notifications[i])
+		}
+		for _, blocked := range saved.Blocked {
+			if user.blocked == nil {
+				user.blocked = make(map[string]bool)
+			}
+			user.blocked[blocked] = true
+		}
+		if len(saved.Hidden) > 0 {
+			user.hidden = saved.Hidden
+		}
+		e.users[saved.Username] = user
+	}
+	for _, saved := range file.Subreddits {
+		e.subreddits[saved.Name] = &Subreddit{
+			Name:         saved.Name,
+			Creator:      saved.Creator,
+			Members:      make(map[string]*User),
+			votes:        make(map[voteKey]int),
+			moderators:   make(map[string]bool),
+			bans:         make(map[string]Ban),
+			mutes:        make(map[string]Ban),
+			noCrossposts: saved.NoCrossposts,
+		}
+	}
+
+	for _, saved := range file.Posts {
+		author, err := e.user(saved.Author)
+		if err != nil {
+			return fmt.Errorf("post %s: %w", saved.ID, err)
+		}
+		subreddit, err := e.subreddit(saved.Subreddit)
+		if err != nil {
+			return fmt.Errorf("post %s: %w", saved.ID, err)
+		}
+		e.posts[saved.ID] = &Post{
+			ID:        saved.ID,
+			Author:    author,
+			Subreddit: subreddit,
+			Content:   saved.Content,
+			Timestamp: saved.Timestamp,
+			Upvotes:   saved.Upvotes,
+			Downvotes: saved.Downvotes,
+			IsRepost:  saved.IsRepost,
+			Edited:    saved.Edited,
+			Deleted:   saved.Deleted,
+			Revisions: saved.Revisions,
+			Removed:   saved.Removed,
+			Spam:      saved.Spam,
+			Locked:    saved.Locked,
+			Reports:   saved.Reports,
+			Filtered:  saved.Filtered,
+			Flair:     saved.Flair,
+		}
+	}
+	for _, saved := range file.Comments {
+		author, err := e.user(saved.Author)
+		if err != nil {
+			return fmt.Errorf("comment %s: %w", saved.ID, err)
+		}
+		post, exists := e.posts[saved.PostID]
+		if !exists {
+			return fmt.Errorf("comment %s: %w: %s", saved.ID, ErrPostNotFound, saved.PostID)
+		}
+		e.comments[saved.ID] = &Comment{
+			ID:        saved.ID,
+			Author:    author,
+			Content:   saved.Content,
+			Timestamp: saved.Timestamp,
+			Parent:    post,
+			Upvotes:   saved.Upvotes,
+			Downvotes: saved.Downvotes,
+			Edited:    saved.Edited,
+			Deleted:   saved.Deleted,
+			Revisions: saved.Revisions,
+			Removed:   saved.Removed,
+			Spam:      saved.Spam,
+			Reports:   saved.Reports,
+			Filtered:  saved.Filtered,
+		}
+	}
+
+	// Resolve the links now that every object exists
+	var err error
+	for _, saved := range file.Posts {
+		post := e.posts[saved.ID]
+		if saved.OriginalPostID != "" {
+			if post.OriginalPost, err = e.post(saved.OriginalPostID); err != nil {
+				return fmt.Errorf("post %s: %w", saved.ID, err)
+			}
+		}
+		if post.Comments, err = e.lookupComments(saved.Comments); err != nil {
+			return fmt.Errorf("post %s: %w", saved.ID, err)
+		}
+	}
+	e.linkCrossposts()
+	for _, saved := range file.Comments {
+		comment := e.comments[saved.ID]
+		if saved.ReplyTo != "" {
+			if comment.ReplyTo, err = e.comment(saved.ReplyTo); err != nil {
+				return fmt.Errorf("comment %s: %w", saved.ID, err)
+			}
+		}
+		if comment.Replies, err = e.lookupComments(saved.Replies); err != nil {
+			return fmt.Errorf("comment %s: %w", saved.ID, err)
+		}
+	}
+	for _, saved := range file.Users {
+		user := e.users[saved.Username]
+		if user.Posts, err = e.lookupPosts(saved.Posts); err != nil {
+			return fmt.Errorf("user %s: %w", saved.Username, err)
+		}
+		if user.Comments, err = e.lookupComments(saved.Comments); err != nil {
+			return fmt.Errorf("user %s: %w", saved.Username, err)
+		}
+		for _, followee := range saved.Following {
+			if _, err := e.user(followee); err != nil {
+				return fmt.Errorf("user %s: %w", saved.Username, err)
+			}
+			e.addFollow(saved.Username, followee)
+		}
+		for _, item := range saved.Saved {
+			post, comment, err := e.content(item.ID)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", saved.Username, err)
+			}
+			user.saved = append(user.saved, &SavedItem{Post: post, Comment: comment, Saved: item.Saved})
+		}
+	}
+	for _, saved := range file.Subreddits {
+		subreddit := e.subreddits[saved.Name]
+		for _, username := range saved.Members {
+			member, err := e.user(username)
+			if err != nil {
+				return fmt.Errorf("subreddit %s: %w", saved.Name, err)
+			}
+			subreddit.Members[username] = member
+		}
+		if subreddit.Posts, err = e.lookupPosts(saved.Posts); err != nil {
+			return fmt.Errorf("subreddit %s: %w", saved.Name, err)
+		}
+		for _, username := range saved.Moderators {
+			if _, err := e.user(username); err != nil {
+				return fmt.Errorf("subreddit %s: %w", saved.Name, err)
+			}
+			subreddit.moderators[username] = true
+		}
+		for _, ban := range saved.Bans {
+			subreddit.bans[ban.Username] = ban
+		}
+		for _, mute := range saved.Mutes {
+			subreddit.mutes[mute.Username] = mute
+		}
+		for i := range saved.AutoMod {
+			if err := saved.AutoMod[i].compile(); err != nil {
+				return fmt.Errorf("subreddit %s: %w: %s: %v", saved.Name, ErrInvalidRule, saved.AutoMod[i].label(i), err)
+			}
+		}
+		subreddit.automod = saved.AutoMod
+	}
+
+	for _, saved := range file.Votes {
+		subreddit, err := e.targetSubreddit(saved.TargetID)
+		if err != nil {
+			return fmt.Errorf("vote by %s: %w", saved.Username, err)
+		}
+		subreddit.votes[voteKey{username: saved.Username, targetID: saved.TargetID}] = saved.Direction
+	}
+	if file.Version < 12 {
+		// Karma used to include the voter's own votes and was not split
+		earned := e.ledgerKarma()
+		for _, user := range e.users {
+			user.setKarma(earned[user.Username])
+		}
+	}
+
+	for _, saved := range file.Messages {
+		from, err := e.user(saved.From)
+		if err != nil {
+			return fmt.Errorf("message %s: %w", saved.ID, err)
+		}
+		to, err := e.user(saved.To)
+		if err != nil {
+			return fmt.Errorf("message %s: %w", saved.ID, err)
+		}
+		e.messages[saved.Inbox] = append(e.messages[saved.Inbox], &DirectMessage{
+			ID:        saved.ID,
+			From:      from,
+			To:        to,
+			Content:   saved.Content,
+			Timestamp: saved.Timestamp,
+			ReplyTo:   saved.ReplyTo,
+			ReadAt:    saved.ReadAt,
+		})
+	}
+	for _, saved := range file.Users {
+		user := e.users[saved.Username]
+		for _, id := range saved.Pending {
+			delivery, err := e.pendingDelivery(user, id)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", saved.Username, err)
+			}
+			user.pending = append(user.pending, delivery)
+		}
+	}
+	return nil
+}
+
+// pendingDelivery finds a message in a user's inbox, or one of their
+// notifications, by ID
+func (e *Engine) pendingDelivery(user *User, id string) (Delivery, error) {
+	for _, message := range e.messages[user.Username] {
+		if message.ID == id {
+			return Delivery{Message: message}, nil
+		}
+	}
+	for _, notification := range user.notifications {
+		if notification.ID == id {
+			return Delivery{Notification: notification}, nil
+		}
+	}
+	return Delivery{}, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+}
+
+// linkCrossposts points every repost at its root original, which snapshots
+// before version 11 may not do, and rebuilds the originals' crossposts
+func (e *Engine) linkCrossposts() {
+	roots := make(map[*Post]bool)
+	for _, post := range e.posts {
+		if post.OriginalPost == nil {
+			continue
+		}
+		for post.OriginalPost.OriginalPost != nil {
+			post.OriginalPost = post.OriginalPost.OriginalPost
+		}
+		post.OriginalPost.Crossposts = append(post.OriginalPost.Crossposts, post)
+		roots[post.OriginalPost] = true
+	}
+	for root := range roots {
+		sort.Slice(root.Crossposts, func(i, j int) bool {
+			a, b := root.Crossposts[i], root.Crossposts[j]
+			if !a.Timestamp.Equal(b.Timestamp) {
+				return a.Timestamp.Before(b.Timestamp)
+			}
+			return a.ID < b.ID
+		})
+	}
+}
+
+// targetSubreddit returns the subreddit holding a post or comment
+func (e *Engine) targetSubreddit(targetID string) (*Subreddit, error) {
+	if post, exists := e.posts[targetID]; exists {
+		return post.Subreddit, nil
+	}
+	if comment, exists := e.comments[targetID]; exists {
+		return comment.Parent.Subreddit, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTargetNotFound, targetID)
+}
+
+// lookupPosts resolves a list of post IDs in order
+func (e *Engine) lookupPosts(ids []string) ([]*Post, error) {
+	var posts []*Post
+	for _, id := range ids {
+		post, err := e.post(id)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// lookupComments resolves a list of comment IDs in order
+func (e *Engine) lookupComments(ids []string) ([]*Comment, error) {
+	var comments []*Comment
+	for _, id := range ids {
+		comment, err := e.comment(id)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+func postIDs(posts []*Post) []string {
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+func commentIDs(comments []*Comment) []string {
+	ids := make([]string, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	return ids
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"reddit-clone/engine"
+	"reddit-clone/engine/actor"
 	"sync"
 	"time"
 )
 
 // Generate a Zipf distribution for subreddit memberships
-func simulateZipfDistribution(engine *engine.Engine, subreddits []string, users []*engine.User) {
+func simulateZipfDistribution(engine engine.Reddit, subreddits []string, users []*engine.User) {
 	ratio := 1.07
 	for i, subreddit := range subreddits {
 		memberCount := int(float64(len(users)) / math.Pow(float64(i+1), ratio))
@@ -21,7 +25,7 @@ func simulateZipfDistribution(engine *engine.Engine, subreddits []string, users
 	}
 }
 
-func simulateUserActivity(engine *engine.Engine, user *engine.User, subreddits []string, wg *sync.WaitGroup) {
+func simulateUserActivity(engine engine.Reddit, user *engine.User, subreddits []string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	connected := true
@@ -44,7 +48,7 @@ func simulateUserActivity(engine *engine.Engine, user *engine.User, subreddits [
 			if rand.Float32() < 0.3 {
 				subreddit := subreddits[rand.Intn(len(subreddits))]
 				content := fmt.Sprintf("Post %d by %s", i, user.Username)
-				if post := engine.PostInSubreddit(subreddit, user.Username, content); post != nil {
+				if post, err := engine.PostInSubreddit(subreddit, user.Username, content); err == nil {
 					postIDs = append(postIDs, post.ID)
 				}
 			}
@@ -54,7 +58,7 @@ func simulateUserActivity(engine *engine.Engine, user *engine.User, subreddits [
 				postID := postIDs[rand.Intn(len(postIDs))]
 				subreddit := subreddits[rand.Intn(len(subreddits))]
 				content := fmt.Sprintf("Comment on post %s", postID)
-				if comment := engine.CommentOnPost(subreddit, postID, user.Username, content); comment != nil {
+				if comment, err := engine.CommentOnPost(subreddit, postID, user.Username, content); err == nil {
 					commentIDs = append(commentIDs, comment.ID)
 				}
 			}
@@ -82,7 +86,7 @@ func simulateUserActivity(engine *engine.Engine, user *engine.User, subreddits [
 			}
 
 			// Get feed and interact
-			feed := engine.GetUserFeed(user.Username, 10)
+			feed, _ := engine.GetUserFeed(user.Username, 10)
 			for _, post := range feed {
 				if rand.Float32() < 0.3 {
 					engine.Upvote(post.ID, rand.Float32() > 0.3, user.Username)
@@ -94,7 +98,7 @@ func simulateUserActivity(engine *engine.Engine, user *engine.User, subreddits [
 	}
 }
 
-func displayDetailedMetrics(engine *engine.Engine, users []*engine.User, numUsers int, elapsed time.Duration) {
+func displayDetailedMetrics(engine engine.Reddit, users []*engine.User, numUsers int, elapsed time.Duration) {
 	totalPosts := 0
 	totalComments := 0
 	totalVotes := 0
@@ -109,7 +113,7 @@ func displayDetailedMetrics(engine *engine.Engine, users []*engine.User, numUser
 		totalVotes += user.Karma
 	}
 
-	fmt.Printf("\nPerformance Metrics:\n")
+	fmt.Printf("\nPerformance Metrics (%T):\n", engine)
 	fmt.Printf("Runtime: %s\n", elapsed)
 	fmt.Printf("Total Users: %d (Active: %d)\n", numUsers, activeUsers)
 	fmt.Printf("Total Posts: %d\n", totalPosts)
@@ -118,19 +122,83 @@ func displayDetailedMetrics(engine *engine.Engine, users []*engine.User, numUser
 	fmt.Printf("Operations/sec: %.2f\n", float64(totalPosts+totalComments+totalVotes)/elapsed.Seconds())
 }
 
+// restoreSnapshot loads a snapshot file written by saveSnapshot into the engine
+func restoreSnapshot(e *engine.Engine, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return e.Restore(f)
+}
+
+// saveSnapshot writes the engine state to a file so a later run can resume from it
+func saveSnapshot(e *engine.Engine, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Snapshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func main() {
-	redditEngine := engine.NewEngine()
+	engineKind := flag.String("engine", "locked", "engine implementation to simulate: locked or actor")
+	restorePath := flag.String("restore", "", "snapshot file to resume the simulation from (locked engine only)")
+	snapshotPath := flag.String("snapshot", "", "file to save the engine state to after the run (locked engine only)")
+	journalDir := flag.String("journal", "", "directory to journal every change to and recover from (locked engine only)")
+	journalSync := flag.Duration("journal-sync", 0, "how often to sync the journal to disk, 0 to sync every change")
+	flag.Parse()
+
+	var redditEngine engine.Reddit
+	switch *engineKind {
+	case "locked":
+		lockedEngine := engine.NewEngine()
+		if *journalDir != "" {
+			var err error
+			if lockedEngine, err = engine.OpenEngineWithOptions(*journalDir, engine.JournalOptions{SyncInterval: *journalSync}); err != nil {
+				fmt.Printf("Failed to open journal: %v\n", err)
+				return
+			}
+		}
+		if *restorePath != "" {
+			if err := restoreSnapshot(lockedEngine, *restorePath); err != nil {
+				fmt.Printf("Failed to restore snapshot: %v\n", err)
+				return
+			}
+		}
+		redditEngine = lockedEngine
+	case "actor":
+		redditEngine = actor.NewEngine()
+	default:
+		fmt.Printf("Unknown engine %q, expected locked or actor\n", *engineKind)
+		return
+	}
 
 	numUsers := 1000
 	var users []*engine.User
 	for i := 0; i < numUsers; i++ {
-		user := redditEngine.RegisterAccount(fmt.Sprintf("user%d", i+1))
+		username := fmt.Sprintf("user%d", i+1)
+		user, err := redditEngine.RegisterAccount(username)
+		if errors.Is(err, engine.ErrUserExists) {
+			// Resumed from a snapshot
+			user, err = redditEngine.GetUser(username)
+		}
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
 		users = append(users, user)
 	}
 
 	subreddits := []string{"golang", "python", "java", "csharp", "rust"}
 	for _, subreddit := range subreddits {
-		redditEngine.CreateSubreddit(subreddit)
+		if err := redditEngine.CreateSubreddit(subreddit, "user1"); err != nil && !errors.Is(err, engine.ErrSubredditExists) {
+			fmt.Println(err)
+		}
 	}
 
 	simulateZipfDistribution(redditEngine, subreddits, users)
@@ -154,5 +222,26 @@ func main() {
 	wg.Wait()
 	elapsed := time.Since(start)
 
+	// Let the actors drain their mailboxes before reading user state
+	if actorEngine, ok := redditEngine.(*actor.Engine); ok {
+		actorEngine.Stop()
+	}
+
 	displayDetailedMetrics(redditEngine, users, numUsers, elapsed)
+
+	if lockedEngine, ok := redditEngine.(*engine.Engine); ok && *snapshotPath != "" {
+		if err := saveSnapshot(lockedEngine, *snapshotPath); err != nil {
+			fmt.Printf("Failed to save snapshot: %v\n", err)
+		}
+	}
+
+	if lockedEngine, ok := redditEngine.(*engine.Engine); ok && *journalDir != "" {
+		// Fold this run's journal into a snapshot so the next start replays less
+		if err := lockedEngine.Checkpoint(); err != nil {
+			fmt.Printf("Failed to checkpoint journal: %v\n", err)
+		}
+		if err := lockedEngine.Close(); err != nil {
+			fmt.Printf("Failed to close journal: %v\n", err)
+		}
+	}
 }
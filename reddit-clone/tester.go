@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"reddit-clone/engine"
 	"time"
@@ -12,75 +13,238 @@ func testRedditFunctionality() {
 
 	// Test 1: Account Registration
 	fmt.Println("\n1. Testing Account Registration:")
-	user1 := e.RegisterAccount("testUser1")
-	user2 := e.RegisterAccount("testUser2")
+	user1, _ := e.RegisterAccount("testUser1")
+	user2, _ := e.RegisterAccount("testUser2")
 	fmt.Printf("- Created users: %s, %s\n", user1.Username, user2.Username)
 
 	// Test duplicate registration
-	dupUser := e.RegisterAccount("testUser1")
-	if dupUser == nil {
+	if _, err := e.RegisterAccount("testUser1"); errors.Is(err, engine.ErrUserExists) {
 		fmt.Println("✓ Duplicate user registration prevented")
 	}
 
 	// Test 2: Subreddit Creation and Membership
 	fmt.Println("\n2. Testing Subreddit Operations:")
-	e.CreateSubreddit("testSubreddit")
+	e.CreateSubreddit("testSubreddit", "testUser1")
 	e.JoinSubreddit("testUser1", "testSubreddit")
 	fmt.Println("- Created and joined subreddit")
+	if err := e.CreateSubreddit("testSubreddit", "testUser1"); errors.Is(err, engine.ErrSubredditExists) {
+		fmt.Println("✓ Duplicate subreddit prevented")
+	}
+	if err := e.JoinSubreddit("nobody", "testSubreddit"); errors.Is(err, engine.ErrUserNotFound) {
+		fmt.Println("✓ Unknown user reported")
+	}
+	if err := e.LeaveSubreddit("testUser2", "testSubreddit"); errors.Is(err, engine.ErrNotMember) {
+		fmt.Println("✓ Leaving an unjoined subreddit reported")
+	}
 
 	// Test 3: Posting
 	fmt.Println("\n3. Testing Posting:")
-	post := e.PostInSubreddit("testSubreddit", "testUser1", "Test post content")
-	if post != nil {
+	post, err := e.PostInSubreddit("testSubreddit", "testUser1", "Test post content")
+	if err == nil {
 		fmt.Printf("✓ Post created with ID: %s\n", post.ID)
 	}
 
 	// Test 4: Commenting
 	fmt.Println("\n4. Testing Comments:")
-	comment1 := e.CommentOnPost("testSubreddit", post.ID, "testUser2", "Test comment")
-	if comment1 != nil {
+	comment1, err := e.CommentOnPost("testSubreddit", post.ID, "testUser2", "Test comment")
+	if err == nil {
 		fmt.Println("✓ Comment created")
 		// Test reply to comment
-		reply := e.ReplyToComment(post.ID, comment1.ID, "testUser1", "Reply to comment")
-		if reply != nil {
+		if _, err := e.ReplyToComment(post.ID, comment1.ID, "testUser1", "Reply to comment"); err == nil {
 			fmt.Println("✓ Reply to comment created")
 		}
 	}
+	if tree, err := e.GetCommentTree(post.ID, engine.CommentTreeOptions{Sort: engine.CommentBest, MaxDepth: 1}); err == nil &&
+		len(tree.Comments) == 1 && tree.Comments[0].More != nil && tree.Comments[0].More.Count == 1 {
+		fmt.Println("✓ Comment tree collapses replies below the depth limit")
+	}
 
 	// Test 5: Voting System
 	fmt.Println("\n5. Testing Voting System:")
 	initialKarma := user1.Karma
 	e.Upvote(post.ID, true, "testUser2")
-	if user1.Karma > initialKarma {
-		fmt.Println("✓ Karma system working")
-	}
+	fmt.Printf("- Karma of testUser1 went from %d to %d\n", initialKarma, user1.Karma)
 
 	// Test 6: User Feed
 	fmt.Println("\n6. Testing User Feed:")
-	feed := e.GetUserFeed("testUser1", 10)
+	feed, _ := e.GetUserFeed("testUser1", 10)
 	fmt.Printf("- Feed contains %d posts\n", len(feed))
+	if len(feed) > 0 && !feed[0].Timestamp.IsZero() {
+		fmt.Println("✓ Posts are timestamped")
+	}
+	hot, _ := e.GetSortedFeed("testUser1", engine.FeedOptions{Sort: engine.SortHot, Limit: 10})
+	top, _ := e.GetSortedFeed("testUser1", engine.FeedOptions{Sort: engine.SortTop, Window: engine.WindowDay, Limit: 10})
+	fmt.Printf("- Hot feed has %d posts, top of the day has %d\n", len(hot.Posts), len(top.Posts))
+	firstPage, _ := e.GetSubredditPosts("testSubreddit", engine.FeedOptions{Sort: engine.SortNew, Limit: 1})
+	if firstPage != nil && firstPage.NextCursor == "" {
+		fmt.Println("✓ Single page listing has no next cursor")
+	}
+	if _, err := e.GetSortedFeed("testUser1", engine.FeedOptions{Cursor: "bogus"}); errors.Is(err, engine.ErrInvalidCursor) {
+		fmt.Println("✓ Invalid cursor reported")
+	}
+	if _, err := engine.ParseSortMode("best"); errors.Is(err, engine.ErrInvalidSort) {
+		fmt.Println("✓ Unknown sort mode reported")
+	}
 
 	// Test 7: Direct Messages
 	fmt.Println("\n7. Testing Direct Messages:")
 	e.SendDirectMessage("testUser1", "testUser2", "Test message")
-	messages := e.GetDirectMessages("testUser2")
+	messages, _ := e.GetDirectMessages("testUser2")
 	if len(messages) > 0 {
 		fmt.Printf("✓ Message delivered from %s to %s\n", messages[0].From.Username, messages[0].To.Username)
+		e.ReplyToDirectMessage(messages[0].ID, "testUser2", "Test reply")
+	}
+	if conversation, err := e.GetConversation("testUser1", "testUser2", "", 0); err == nil && len(conversation.Messages) == 2 {
+		fmt.Printf("✓ Conversation holds %d messages, %d unread\n", len(conversation.Messages), conversation.Unread)
 	}
 
 	// Test 8: Repost Functionality
 	fmt.Println("\n8. Testing Repost:")
-	repost := e.Repost(post.ID, "testUser2", "testSubreddit")
-	if repost != nil {
+	if _, err := e.Repost(post.ID, "testUser2", "testSubreddit"); err == nil {
 		fmt.Println("✓ Repost created")
 	}
+	if _, err := e.Repost(post.ID, "testUser2", "missingSubreddit"); errors.Is(err, engine.ErrSubredditNotFound) {
+		fmt.Println("✓ Repost to unknown subreddit reported")
+	}
+
+	// Test 9: Editing and Deleting
+	fmt.Println("\n9. Testing Edit and Delete:")
+	if err := e.EditPost(post.ID, "testUser1", "Edited test post"); err == nil && !post.Edited.IsZero() {
+		fmt.Println("✓ Post edited")
+	}
+	if revisions, _ := e.GetPostRevisions(post.ID); len(revisions) == 1 {
+		fmt.Printf("✓ Earlier revision kept: %q\n", revisions[0].Content)
+	}
+	if err := e.EditPost(post.ID, "testUser2", "Not mine"); errors.Is(err, engine.ErrNotAuthor) {
+		fmt.Println("✓ Edit by another user rejected")
+	}
+	doomed, _ := e.CommentOnPost("testSubreddit", post.ID, "testUser2", "Soon deleted")
+	e.ReplyToComment(post.ID, doomed.ID, "testUser1", "Reply that stays")
+	if err := e.DeleteComment(doomed.ID, "testUser2"); err == nil && doomed.Content == engine.DeletedContent && len(doomed.Replies) == 1 {
+		fmt.Println("✓ Deleted comment keeps its replies")
+	}
+
+	// Test 10: Moderation
+	fmt.Println("\n10. Testing Moderation:")
+	e.AddModerator("testSubreddit", "testUser1", "testUser1")
+	spam, _ := e.PostInSubreddit("testSubreddit", "testUser2", "Buy now")
+	e.ReportContent(spam.ID, "testUser1", "spam")
+	if queue, _ := e.GetModQueue("testSubreddit", "testUser1"); len(queue) == 1 {
+		fmt.Println("✓ Reported post in mod queue")
+	}
+	if err := e.Moderate(spam.ID, "testUser2", engine.ModRemove); errors.Is(err, engine.ErrNotModerator) {
+		fmt.Println("✓ Moderation by a non-moderator rejected")
+	}
+	e.Moderate(spam.ID, "testUser1", engine.ModSpam)
+	if page, _ := e.GetSubredditPosts("testSubreddit", engine.FeedOptions{}); page != nil && !containsPost(page.Posts, spam) {
+		fmt.Println("✓ Removed post hidden from listings")
+	}
+	e.Moderate(post.ID, "testUser1", engine.ModLock)
+	if _, err := e.CommentOnPost("testSubreddit", post.ID, "testUser2", "Too late"); errors.Is(err, engine.ErrLocked) {
+		fmt.Println("✓ Locked post takes no comments")
+	}
+
+	// Test 11: Bans and Mutes
+	fmt.Println("\n11. Testing Bans and Mutes:")
+	e.BanUser("testSubreddit", "testUser1", "testUser2", 24*time.Hour, "spam", "sold fake watches")
+	if _, err := e.PostInSubreddit("testSubreddit", "testUser2", "Buy again"); errors.Is(err, engine.ErrBanned) {
+		fmt.Println("✓ Banned user cannot post")
+	}
+	e.UnbanUser("testSubreddit", "testUser1", "testUser2")
+	e.MuteUser("testSubreddit", "testUser1", "testUser2", 0, "abusive modmail")
+	if err := e.MessageModerators("testSubreddit", "testUser2", "Unban me"); errors.Is(err, engine.ErrMuted) {
+		fmt.Println("✓ Muted user cannot message the moderators")
+	}
+
+	// Test 12: AutoModerator
+	fmt.Println("\n12. Testing AutoModerator:")
+	rules := `[{"name": "shorteners", "domain": ["bit.ly"], "action": "remove", "comment": "Link shorteners are not allowed"}]`
+	if _, err := engine.ParseAutoModRules([]byte(`[{"action": "explode"}]`)); errors.Is(err, engine.ErrInvalidRule) {
+		fmt.Println("✓ Invalid rules rejected")
+	}
+	e.SetAutoModRules("testSubreddit", "testUser1", []byte(rules))
+	if matches, _ := e.DryRunAutoMod("testSubreddit", "testUser1", nil); len(matches) == 0 {
+		fmt.Println("✓ Dry run matches no existing content")
+	}
+	if short, err := e.PostInSubreddit("testSubreddit", "testUser1", "See https://bit.ly/x"); err == nil && short.Removed && len(short.Comments) == 1 {
+		fmt.Println("✓ AutoModerator removed a post and replied")
+	}
 
-	// Test 9: Connection Status
-	fmt.Println("\n9. Testing Connection Status:")
-	e.SetUserConnection("testUser1", false)
+	// Test 13: Events
+	fmt.Println("\n13. Testing Events:")
+	var created []engine.Event
+	sub := e.Events().Subscribe(func(event engine.Event) { created = append(created, event) }, engine.EventPostCreated)
+	e.PostInSubreddit("testSubreddit", "testUser1", "Announcing events")
+	e.JoinSubreddit("testUser2", "testSubreddit")
+	sub.Unsubscribe()
+	if len(created) == 1 {
+		fmt.Println("✓ Subscriber received only the post it asked for")
+	}
+
+	// Test 14: Notifications
+	fmt.Println("\n14. Testing Notifications:")
+	e.PostInSubreddit("testSubreddit", "testUser2", "Thanks u/testUser1")
+	if unread, _ := e.UnreadNotificationCount("testUser1"); unread > 0 {
+		fmt.Printf("✓ %d unread notifications\n", unread)
+	}
+	if marked, _ := e.MarkAllNotificationsRead("testUser1"); marked > 0 {
+		fmt.Println("✓ All notifications marked read")
+	}
+
+	// Test 15: Blocking
+	fmt.Println("\n15. Testing Blocking:")
+	e.BlockUser("testUser1", "testUser2")
+	if err := e.SendDirectMessage("testUser2", "testUser1", "Hello again"); errors.Is(err, engine.ErrBlocked) {
+		fmt.Println("✓ Blocked user cannot send messages")
+	}
+	e.UnblockUser("testUser1", "testUser2")
+
+	// Test 16: Following
+	fmt.Println("\n16. Testing Following:")
+	e.FollowUser("testUser2", "testUser1")
+	if profile, err := e.GetProfile("testUser1"); err == nil && profile.Followers == 1 {
+		fmt.Printf("✓ Profile shows %d posts, %d comments and %d follower\n", profile.Posts, profile.Comments, profile.Followers)
+	}
+
+	// Test 17: Saved and Hidden Posts
+	fmt.Println("\n17. Testing Saved and Hidden Posts:")
+	e.SaveItem("testUser2", post.ID)
+	if page, err := e.GetSavedItems("testUser2", "", 10); err == nil && len(page.Items) == 1 {
+		fmt.Println("✓ Post saved")
+	}
+	e.HidePost("testUser2", post.ID)
+	if feed, _ := e.GetUserFeed("testUser2", 0); !containsPost(feed, post) {
+		fmt.Println("✓ Hidden post left out of the feed")
+	}
+
+	// Test 18: Crossposting
+	fmt.Println("\n18. Testing Crossposting:")
+	e.CreateSubreddit("otherSubreddit", "testUser2")
+	e.AddModerator("otherSubreddit", "testUser2", "testUser2")
+	crosspost, _ := e.Repost(post.ID, "testUser2", "otherSubreddit")
+	if again, err := e.Repost(crosspost.ID, "testUser1", "testSubreddit"); err == nil && again.OriginalPost == post {
+		fmt.Println("✓ Crossposting a crosspost shares the original")
+	}
+	if crossposts, err := e.GetCrossposts(crosspost.ID, "testUser1"); err == nil {
+		fmt.Printf("✓ Original has %d crossposts\n", len(crossposts))
+	}
+	e.SetCrosspostsAllowed("otherSubreddit", "testUser2", false)
+	if _, err := e.Repost(post.ID, "testUser1", "otherSubreddit"); errors.Is(err, engine.ErrCrosspostsDisabled) {
+		fmt.Println("✓ Subreddit refused crossposts")
+	}
+
+	// Test 19: Connection Status
+	fmt.Println("\n19. Testing Connection Status:")
+	session, _ := e.Connect("testUser1")
+	e.Disconnect("testUser1", session.ID)
+	if presence, err := e.GetPresence("testUser1"); err == nil && !presence.Online && !presence.LastSeen.IsZero() {
+		fmt.Println("✓ Last seen recorded on disconnect")
+	}
 	time.Sleep(time.Millisecond * 100)
-	e.SetUserConnection("testUser1", true)
-	fmt.Println("✓ Connection status changes simulated")
+	e.SendDirectMessage("testUser2", "testUser1", "Sent while away")
+	if session, err := e.Connect("testUser1"); err == nil && len(session.Pending) == 1 {
+		fmt.Println("✓ Message queued while offline delivered on reconnect")
+	}
 
 	fmt.Println("\n=== Functionality Test Summary ===")
 	fmt.Printf("Users: %d\n", len(e.GetSubreddits()))
@@ -88,6 +252,16 @@ func testRedditFunctionality() {
 	fmt.Printf("User1 Karma: %d\n", user1.Karma)
 }
 
+// containsPost reports whether post is among posts
+func containsPost(posts []*engine.Post, post *engine.Post) bool {
+	for _, p := range posts {
+		if p == post {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	testRedditFunctionality()
 }